@@ -0,0 +1,265 @@
+// telemetry-dlq 查看与处理死信队列中重试耗尽的写库批次
+//
+//	telemetry-dlq [-config config.yaml] [-dir data/dlq] <命令> [参数]
+//
+// 命令：
+//   - stats                        按表汇总批次数、行数与大小
+//   - list [-table t]              按失败时间列出批次
+//   - inspect [-limit n] <id>      输出批次描述与数据（JSON）
+//   - redrive [-table t] [id...]   重新写库，成功后删除；不指定id时按失败时间处理所有批次，遇到失败停止
+//   - purge [-table t] [-before RFC3339] [-all] [id...]  删除批次
+//
+// 死信目录可以在采集器运行时处理，重新写库使用配置文件中的数据库。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/database"
+	"github.com/wwswwsuns/ztelem/internal/dlq"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+var (
+	configFile = flag.String("config", "config.yaml", "配置文件路径（死信目录与重新写库的数据库）")
+	dir        = flag.String("dir", "", "死信目录（覆盖配置文件的dead_letter.dir）")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `用法: telemetry-dlq [-config config.yaml] [-dir data/dlq] <命令> [参数]
+
+命令:
+  stats                                  按表汇总批次数、行数与大小
+  list [-table t]                        按失败时间列出批次
+  inspect [-limit n] <id>                输出批次描述与数据（JSON）
+  redrive [-table t] [id...]             重新写库，成功后删除；不指定id时处理所有批次
+  purge [-table t] [-before RFC3339] [-all] [id...]
+                                         删除批次
+
+全局参数:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	log := logrus.New()
+	log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		log.WithError(err).Fatal("加载配置失败")
+	}
+	if *dir == "" {
+		*dir = cfg.DeadLetter.Dir
+	}
+	store, err := dlq.Open(*dir, 0)
+	if err != nil {
+		log.WithError(err).Fatal("打开死信目录失败")
+	}
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch cmd {
+	case "stats":
+		err = stats(store)
+	case "list":
+		err = list(store, args)
+	case "inspect":
+		err = inspect(store, args)
+	case "redrive":
+		err = redrive(log, cfg, store, args)
+	case "purge":
+		err = purge(store, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.WithError(err).Fatalf("%s 失败", cmd)
+	}
+}
+
+func stats(store *dlq.Store) error {
+	tables, err := store.Stats()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tBATCHES\tROWS\tBYTES")
+	var total dlq.TableStats
+	for _, name := range names {
+		st := tables[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", name, st.Batches, st.Rows, st.Bytes)
+		total.Batches += st.Batches
+		total.Rows += st.Rows
+		total.Bytes += st.Bytes
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t%d\n", total.Batches, total.Rows, total.Bytes)
+	return w.Flush()
+}
+
+func list(store *dlq.Store, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	table := fs.String("table", "", "只列出该表")
+	fs.Parse(args)
+
+	batches, err := store.List(*table)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTABLE\tROWS\tBYTES\tFAILED_AT\tREASON")
+	for _, b := range batches {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", b.ID, b.Table, b.Rows, b.Bytes,
+			b.FailedAt.Local().Format(time.RFC3339), truncate(b.Reason, 120))
+	}
+	return w.Flush()
+}
+
+func inspect(store *dlq.Store, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	limit := fs.Int("limit", 0, "最多输出的行数，0为全部")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("需要一个批次ID")
+	}
+
+	b, e, err := store.Get(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *limit > 0 {
+		limitEntry(e, *limit)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		dlq.Batch
+		Data *wal.Entry `json:"data"`
+	}{b, e})
+}
+
+func redrive(log *logrus.Logger, cfg *config.Config, store *dlq.Store, args []string) error {
+	fs := flag.NewFlagSet("redrive", flag.ExitOnError)
+	table := fs.String("table", "", "不指定id时只处理该表")
+	fs.Parse(args)
+
+	ids := fs.Args()
+	if len(ids) == 0 {
+		batches, err := store.List(*table)
+		if err != nil {
+			return err
+		}
+		for _, b := range batches {
+			ids = append(ids, b.ID)
+		}
+	}
+	if len(ids) == 0 {
+		log.Info("没有待处理的批次")
+		return nil
+	}
+
+	db, err := database.NewDatabaseWithConfig(cfg.Database, log)
+	if err != nil {
+		return fmt.Errorf("数据库连接失败: %v", err)
+	}
+	defer db.Close()
+	db.SetCounterRateColumns(cfg.Rates.Enabled)
+
+	var batches, rows int
+	for _, id := range ids {
+		b, err := store.Redrive(id, func(e *wal.Entry) error { return dlq.Insert(db, e) })
+		if errors.Is(err, dlq.ErrNotFound) {
+			log.Warnf("批次不存在（可能已被处理）: %s", id)
+			continue
+		}
+		if err != nil {
+			log.Infof("已重新写库 %d 个批次（%d 行）", batches, rows)
+			return fmt.Errorf("批次 %s: %v", id, err)
+		}
+		batches++
+		rows += b.Rows
+		log.Infof("已重新写库: %s (%s, %d 行)", b.ID, b.Table, b.Rows)
+	}
+	log.Infof("已重新写库 %d 个批次（%d 行）", batches, rows)
+	return nil
+}
+
+func purge(store *dlq.Store, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	table := fs.String("table", "", "只删除该表的批次")
+	beforeFlag := fs.String("before", "", "只删除失败时间早于该时间（RFC3339）的批次")
+	all := fs.Bool("all", false, "不指定id、-table与-before时必须指定，删除所有批次")
+	fs.Parse(args)
+
+	if ids := fs.Args(); len(ids) > 0 {
+		for _, id := range ids {
+			if err := store.Remove(id); err != nil {
+				return fmt.Errorf("批次 %s: %w", id, err)
+			}
+			fmt.Printf("已删除: %s\n", id)
+		}
+		return nil
+	}
+
+	var before time.Time
+	if *beforeFlag != "" {
+		t, err := time.Parse(time.RFC3339, *beforeFlag)
+		if err != nil {
+			return fmt.Errorf("-before 格式错误: %v", err)
+		}
+		before = t
+	}
+	if *table == "" && before.IsZero() && !*all {
+		return errors.New("需要指定批次ID、-table、-before 或 -all")
+	}
+	purged, err := store.Purge(*table, before)
+	var rows int
+	for _, b := range purged {
+		rows += b.Rows
+	}
+	fmt.Printf("已删除 %d 个批次（%d 行）\n", len(purged), rows)
+	return err
+}
+
+// limitEntry 截断批次数据，只保留前n行
+func limitEntry(e *wal.Entry, n int) {
+	e.Platform = e.Platform[:min(n, len(e.Platform))]
+	e.Interface = e.Interface[:min(n, len(e.Interface))]
+	e.Subinterface = e.Subinterface[:min(n, len(e.Subinterface))]
+	e.AlarmReport = e.AlarmReport[:min(n, len(e.AlarmReport))]
+	e.NotificationReport = e.NotificationReport[:min(n, len(e.NotificationReport))]
+	e.SelfDefinedEvent = e.SelfDefinedEvent[:min(n, len(e.SelfDefinedEvent))]
+	e.Generic = e.Generic[:min(n, len(e.Generic))]
+	e.MappedRow = e.MappedRow[:min(n, len(e.MappedRow))]
+	e.OpticalChannel = e.OpticalChannel[:min(n, len(e.OpticalChannel))]
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
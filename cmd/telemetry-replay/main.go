@@ -0,0 +1,372 @@
+// telemetry-replay 回放采集器抓取的原始Publish报文
+//
+// 三种模式：
+//   - dry-run 只经过解析器，按sensor_path输出报文数、行数与解析错误
+//   - local   经过与采集器相同的解析、缓冲与写库流程写入配置文件中的数据库
+//   - grpc    作为设备向远端采集器发起Publish流，每个原始对端地址一条流
+//
+// -speed 为1时按抓包时的间隔回放，为N时加速N倍，为0时不等待。
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/wwswwsuns/ztelem/internal/buffer"
+	"github.com/wwswwsuns/ztelem/internal/capture"
+	"github.com/wwswwsuns/ztelem/internal/collector"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/database"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	dialout "github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+var (
+	files      = flag.String("file", "", "抓包文件，支持通配符，多个用逗号分隔")
+	configFile = flag.String("config", "config.yaml", "配置文件路径（parser与local模式的数据库、缓冲区配置）")
+	mode       = flag.String("mode", "dry-run", "回放模式: dry-run|local|grpc")
+	target     = flag.String("target", "127.0.0.1:50051", "grpc模式的采集器地址")
+	speed      = flag.Float64("speed", 1, "回放速度倍数，0为不等待")
+	systemID   = flag.String("system-id", "", "只回放该system_id的报文")
+	sensorPath = flag.String("sensor-path", "", "只回放以该前缀开头的sensor_path")
+	tlsCA      = flag.String("tls-ca", "", "grpc模式校验采集器证书的CA文件，为空时使用明文")
+	tlsCert    = flag.String("tls-cert", "", "grpc模式的客户端证书")
+	tlsKey     = flag.String("tls-key", "", "grpc模式的客户端私钥")
+	serverName = flag.String("server-name", "", "grpc模式校验证书时使用的服务器名")
+	debugMode  = flag.Bool("debug", false, "输出debug日志")
+)
+
+// summary 按sensor_path汇总的回放结果
+type summary struct {
+	messages int64
+	rows     int64
+	errors   int64
+}
+
+func main() {
+	flag.Parse()
+
+	log := logrus.New()
+	log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	if *debugMode {
+		log.SetLevel(logrus.DebugLevel)
+	}
+	if *speed < 0 {
+		log.Fatal("-speed 不能为负数")
+	}
+
+	records, err := loadRecords(log, *files)
+	if err != nil {
+		log.WithError(err).Fatal("读取抓包文件失败")
+	}
+	if len(records) == 0 {
+		log.Fatal("没有可回放的记录")
+	}
+
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		log.WithError(err).Fatal("加载配置失败")
+	}
+	p := parser.NewTelemetryParserWithConfig(log, cfg.Parser)
+	records = filterRecords(p, records)
+	log.Infof("待回放记录 %d 条，模式=%s，速度=%g", len(records), *mode, *speed)
+
+	switch *mode {
+	case "dry-run":
+		dryRun(log, p, records)
+	case "local":
+		replayLocal(log, cfg, records)
+	case "grpc":
+		replayGRPC(log, records)
+	default:
+		log.Fatalf("不支持的回放模式: %s", *mode)
+	}
+}
+
+// loadRecords 读取所有匹配的抓包文件，按接收时间排序
+func loadRecords(log *logrus.Logger, patterns string) ([]*capture.Record, error) {
+	if patterns == "" {
+		return nil, errors.New("缺少 -file 参数")
+	}
+	var records []*capture.Record
+	for _, pattern := range strings.Split(patterns, ",") {
+		matches, err := filepath.Glob(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("没有匹配的文件: %s", pattern)
+		}
+		for _, file := range matches {
+			recs, err := capture.ReadFile(file)
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				// 采集器异常退出时最后一条记录不完整，之前的记录仍可回放
+				log.Warnf("%v，忽略不完整的记录", err)
+			} else if err != nil {
+				return nil, err
+			}
+			records = append(records, recs...)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].ReceivedAt.Before(records[j].ReceivedAt) })
+	return records, nil
+}
+
+// filterRecords 按 -system-id 与 -sensor-path 过滤，无法解析的报文只在未设置过滤条件时保留
+func filterRecords(p *parser.TelemetryParser, records []*capture.Record) []*capture.Record {
+	if *systemID == "" && *sensorPath == "" {
+		return records
+	}
+	kept := records[:0]
+	for _, rec := range records {
+		result, err := parse(p, rec)
+		if err != nil {
+			continue
+		}
+		if *systemID != "" && result.SystemID != *systemID {
+			continue
+		}
+		if !strings.HasPrefix(result.SensorPath, *sensorPath) {
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	return kept
+}
+
+func parse(p *parser.TelemetryParser, rec *capture.Record) (*parser.ParseResult, error) {
+	if data := rec.Args.GetData(); len(data) > 0 {
+		return p.ParseTelemetryData(data)
+	}
+	return p.ParseJSONData(rec.Args.GetJsonData())
+}
+
+// pacer 按抓包时的接收间隔与回放速度等待
+type pacer struct {
+	first time.Time
+	start time.Time
+}
+
+func (pc *pacer) wait(rec *capture.Record) {
+	if pc.start.IsZero() {
+		pc.first, pc.start = rec.ReceivedAt, time.Now()
+		return
+	}
+	if *speed == 0 {
+		return
+	}
+	offset := time.Duration(float64(rec.ReceivedAt.Sub(pc.first)) / *speed)
+	if d := time.Until(pc.start.Add(offset)); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// dryRun 只解析，不写库
+func dryRun(log *logrus.Logger, p *parser.TelemetryParser, records []*capture.Record) {
+	sums := make(map[string]*summary)
+	get := func(key string) *summary {
+		s, ok := sums[key]
+		if !ok {
+			s = &summary{}
+			sums[key] = s
+		}
+		return s
+	}
+	for _, rec := range records {
+		result, err := parse(p, rec)
+		if err != nil {
+			get("(parse error)").errors++
+			log.Debugf("ReqId=%d 对端=%s 解析失败: %v", rec.Args.ReqId, rec.Peer, err)
+			continue
+		}
+		s := get(result.SensorPath)
+		s.messages++
+		s.rows += int64(result.RowCount())
+		if result.UnknownSensorPath {
+			s.errors++
+		}
+	}
+	printSummary(sums)
+}
+
+// replayLocal 经过采集器的解析、缓冲与写库流程写入数据库
+func replayLocal(log *logrus.Logger, cfg *config.Config, records []*capture.Record) {
+	db, err := database.NewDatabaseWithConfig(cfg.Database, log)
+	if err != nil {
+		log.WithError(err).Fatal("数据库连接失败")
+	}
+	defer db.Close()
+	db.SetCounterRateColumns(cfg.Rates.Enabled)
+
+	bufferManager := buffer.NewFixedBufferManager(db, cfg.Buffer, cfg.DatabaseWriter, log)
+	if err := bufferManager.EnableAggregation(cfg.Buffer.Aggregation, cfg.Collection.Enabled); err != nil {
+		log.WithError(err).Fatal("聚合窗口配置错误")
+	}
+
+	// 访问控制、限速与时钟偏差依赖现场的对端与接收时间，设备登记与周期监测会把历史报文当作当前状态，回放时不启用
+	c := collector.NewSimpleCollector(log, bufferManager, cfg.Server, cfg.Parser, cfg.Collection, cfg.Rates,
+		config.AccessConfig{}, config.RateLimitConfig{}, config.DevicesConfig{}, config.CadenceConfig{},
+		cfg.Sequence, config.ClockSkewConfig{}, config.CaptureConfig{})
+
+	var pc pacer
+	var failed int64
+	errorsByReason := make(map[string]int64)
+	for _, rec := range records {
+		pc.wait(rec)
+		if err := c.ProcessCaptured(rec); err != nil {
+			failed++
+			errorsByReason[collector.ErrorReason(err)]++
+			log.Debugf("ReqId=%d 对端=%s 处理失败: %v", rec.Args.ReqId, rec.Peer, err)
+		}
+	}
+
+	if err := bufferManager.FlushAll(); err != nil {
+		log.WithError(err).Error("刷新缓冲区失败")
+	}
+	for bufferManager.PendingWrites() > 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := bufferManager.Stop(); err != nil {
+		log.WithError(err).Error("停止缓冲区管理器失败")
+	}
+	// 等待写入协程完成正在写的批次
+	time.Sleep(time.Second)
+
+	stats := bufferManager.GetStats()
+	log.Infof("本地回放完成: 记录 %d 条, 失败 %d 条 %v, 写入 %d 行, 写入错误 %d",
+		len(records), failed, errorsByReason, stats.TotalRecordsWritten, stats.TotalErrors)
+}
+
+// replayGRPC 作为设备向远端采集器回放，每个原始对端地址使用一条Publish流
+func replayGRPC(log *logrus.Logger, records []*capture.Record) {
+	creds, err := transportCredentials()
+	if err != nil {
+		log.WithError(err).Fatal("加载TLS配置失败")
+	}
+	conn, err := grpc.NewClient(*target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		log.WithError(err).Fatal("连接采集器失败")
+	}
+	defer conn.Close()
+	client := dialout.NewZtedialoutServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		sent     int64
+		acked    int64
+		rejected int64
+	)
+	streams := make(map[string]grpc.BidiStreamingClient[dialout.PublishArgs, dialout.PublishArgs])
+	openStream := func(peer string) (grpc.BidiStreamingClient[dialout.PublishArgs, dialout.PublishArgs], error) {
+		if s, ok := streams[peer]; ok {
+			return s, nil
+		}
+		s, err := client.Publish(ctx)
+		if err != nil {
+			return nil, err
+		}
+		streams[peer] = s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				resp, err := s.Recv()
+				if err != nil {
+					if err != io.EOF {
+						log.Warnf("对端 %s 的回放流结束: %v", peer, err)
+					}
+					return
+				}
+				atomic.AddInt64(&acked, 1)
+				if resp.Errors != "" {
+					atomic.AddInt64(&rejected, 1)
+					log.Debugf("对端 %s ReqId=%d 被拒绝: %s", peer, resp.ReqId, resp.Errors)
+				}
+			}
+		}()
+		return s, nil
+	}
+
+	var pc pacer
+	broken := make(map[string]bool)
+	for _, rec := range records {
+		if broken[rec.Peer] {
+			continue
+		}
+		pc.wait(rec)
+		s, err := openStream(rec.Peer)
+		if err != nil {
+			log.WithError(err).Fatal("建立Publish流失败")
+		}
+		if err := s.Send(rec.Args); err != nil {
+			log.WithError(err).Errorf("对端 %s 发送失败，停止该流", rec.Peer)
+			broken[rec.Peer] = true
+			continue
+		}
+		sent++
+	}
+	for _, s := range streams {
+		s.CloseSend()
+	}
+	wg.Wait()
+
+	log.Infof("gRPC回放完成: 流 %d 条, 发送 %d 条, 应答 %d 条, 其中错误 %d 条",
+		len(streams), sent, acked, rejected)
+}
+
+// transportCredentials 未指定CA时使用明文连接
+func transportCredentials() (credentials.TransportCredentials, error) {
+	if *tlsCA == "" {
+		return insecure.NewCredentials(), nil
+	}
+	pem, err := os.ReadFile(*tlsCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("CA文件中没有有效证书: %s", *tlsCA)
+	}
+	tlsConfig := &tls.Config{RootCAs: pool, ServerName: *serverName, MinVersion: tls.VersionTLS12}
+	if *tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func printSummary(sums map[string]*summary) {
+	keys := make([]string, 0, len(sums))
+	for k := range sums {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Printf("%-70s %10s %10s %8s\n", "SENSOR_PATH", "MESSAGES", "ROWS", "ERRORS")
+	for _, k := range keys {
+		s := sums[k]
+		fmt.Printf("%-70s %10d %10d %8d\n", k, s.messages, s.rows, s.errors)
+	}
+}
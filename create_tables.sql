@@ -14,24 +14,34 @@ CREATE TABLE IF NOT EXISTS platform_metrics (
     uptime TEXT,
     used_power INTEGER,
     allocated_power INTEGER,
-    current_voltage TEXT,
-    current_current TEXT,
-    total_capacity TEXT,
-    used_capacity TEXT,
+    current_voltage_v DOUBLE PRECISION,
+    current_current_a DOUBLE PRECISION,
+    total_capacity_w DOUBLE PRECISION,
+    used_capacity_w DOUBLE PRECISION,
     type TEXT,
     redundancy_type TEXT,
     modules TEXT,
-    total_input_power TEXT,
+    total_input_power_w DOUBLE PRECISION,
     
     -- 风扇数据字段
     fan_speed INTEGER,
     fan_state TEXT,
     fan_phy_status TEXT,
     fan_work_mode TEXT,
+    fan_current_power_w DOUBLE PRECISION,
+    fan_current_voltage_v DOUBLE PRECISION,
+    fan_current_current_a DOUBLE PRECISION,
+    fan_speed_percent TEXT,
+    
+    -- 兼容列：设备上报的原始电压/电流/功率字符串，仅在 legacy_string_columns 开启时写入
+    current_voltage TEXT,
+    current_current TEXT,
+    total_capacity TEXT,
+    used_capacity TEXT,
+    total_input_power TEXT,
     fan_current_power TEXT,
     fan_current_voltage TEXT,
     fan_current_current TEXT,
-    fan_speed_percent TEXT,
     
     -- 内存数据字段
     mem_available BIGINT,
@@ -161,10 +171,10 @@ CREATE TABLE IF NOT EXISTS interface_metrics (
     out_pkts BIGINT,
     input_utilization NUMERIC(5,2),
     output_utilization NUMERIC(5,2),
-    in_traffic_rate TEXT,
-    in_packet_rate TEXT,
-    out_traffic_rate TEXT,
-    out_packet_rate TEXT,
+    in_traffic_rate_mbps DOUBLE PRECISION,
+    in_packet_rate_kfps DOUBLE PRECISION,
+    out_traffic_rate_mbps DOUBLE PRECISION,
+    out_packet_rate_kfps DOUBLE PRECISION,
     in_v4_octets BIGINT,
     out_v4_octets BIGINT,
     in_v4_pkts BIGINT,
@@ -173,6 +183,23 @@ CREATE TABLE IF NOT EXISTS interface_metrics (
     out_v6_octets BIGINT,
     in_v6_pkts BIGINT,
     out_v6_pkts BIGINT,
+    in_v4_traffic_rate_mbps DOUBLE PRECISION,
+    in_v4_packet_rate_kfps DOUBLE PRECISION,
+    out_v4_traffic_rate_mbps DOUBLE PRECISION,
+    out_v4_packet_rate_kfps DOUBLE PRECISION,
+    in_v6_traffic_rate_mbps DOUBLE PRECISION,
+    in_v6_packet_rate_kfps DOUBLE PRECISION,
+    out_v6_traffic_rate_mbps DOUBLE PRECISION,
+    out_v6_packet_rate_kfps DOUBLE PRECISION,
+    input_v4_utilization NUMERIC(5,2),
+    output_v4_utilization NUMERIC(5,2),
+    input_v6_utilization NUMERIC(5,2),
+    output_v6_utilization NUMERIC(5,2),
+    -- 兼容列：旧版格式化字符串（如 "12.50 Mbps"），仅在 legacy_string_columns 开启时写入
+    in_traffic_rate TEXT,
+    in_packet_rate TEXT,
+    out_traffic_rate TEXT,
+    out_packet_rate TEXT,
     in_v4_traffic_rate TEXT,
     in_v4_packet_rate TEXT,
     out_v4_traffic_rate TEXT,
@@ -181,14 +208,39 @@ CREATE TABLE IF NOT EXISTS interface_metrics (
     in_v6_packet_rate TEXT,
     out_v6_traffic_rate TEXT,
     out_v6_packet_rate TEXT,
-    input_v4_utilization NUMERIC(5,2),
-    output_v4_utilization NUMERIC(5,2),
-    input_v6_utilization NUMERIC(5,2),
-    output_v6_utilization NUMERIC(5,2),
     in_bier_octets BIGINT,
     in_bier_pkts BIGINT,
     out_bier_octets BIGINT,
-    out_bier_pkts BIGINT
+    out_bier_pkts BIGINT,
+    -- 服务端计算的计数器速率（每秒，字节/包）
+    in_octets_rate DOUBLE PRECISION,
+    in_unicast_pkts_rate DOUBLE PRECISION,
+    in_broadcast_pkts_rate DOUBLE PRECISION,
+    in_multicast_pkts_rate DOUBLE PRECISION,
+    in_discards_rate DOUBLE PRECISION,
+    in_errors_rate DOUBLE PRECISION,
+    in_unknown_protos_rate DOUBLE PRECISION,
+    in_fcs_errors_rate DOUBLE PRECISION,
+    out_octets_rate DOUBLE PRECISION,
+    out_unicast_pkts_rate DOUBLE PRECISION,
+    out_broadcast_pkts_rate DOUBLE PRECISION,
+    out_multicast_pkts_rate DOUBLE PRECISION,
+    out_discards_rate DOUBLE PRECISION,
+    out_errors_rate DOUBLE PRECISION,
+    in_pkts_rate DOUBLE PRECISION,
+    out_pkts_rate DOUBLE PRECISION,
+    in_v4_octets_rate DOUBLE PRECISION,
+    out_v4_octets_rate DOUBLE PRECISION,
+    in_v4_pkts_rate DOUBLE PRECISION,
+    out_v4_pkts_rate DOUBLE PRECISION,
+    in_v6_octets_rate DOUBLE PRECISION,
+    out_v6_octets_rate DOUBLE PRECISION,
+    in_v6_pkts_rate DOUBLE PRECISION,
+    out_v6_pkts_rate DOUBLE PRECISION,
+    in_bier_octets_rate DOUBLE PRECISION,
+    in_bier_pkts_rate DOUBLE PRECISION,
+    out_bier_octets_rate DOUBLE PRECISION,
+    out_bier_pkts_rate DOUBLE PRECISION
 );
 
 -- 创建子接口指标表
@@ -235,10 +287,10 @@ CREATE TABLE IF NOT EXISTS subinterface_metrics (
     out_pkts BIGINT,
     input_utilization NUMERIC(5,2),
     output_utilization NUMERIC(5,2),
-    in_traffic_rate TEXT,
-    in_packet_rate TEXT,
-    out_traffic_rate TEXT,
-    out_packet_rate TEXT,
+    in_traffic_rate_mbps DOUBLE PRECISION,
+    in_packet_rate_kfps DOUBLE PRECISION,
+    out_traffic_rate_mbps DOUBLE PRECISION,
+    out_packet_rate_kfps DOUBLE PRECISION,
     in_v4_octets BIGINT,
     out_v4_octets BIGINT,
     in_v4_pkts BIGINT,
@@ -247,6 +299,23 @@ CREATE TABLE IF NOT EXISTS subinterface_metrics (
     out_v6_octets BIGINT,
     in_v6_pkts BIGINT,
     out_v6_pkts BIGINT,
+    in_v4_traffic_rate_mbps DOUBLE PRECISION,
+    in_v4_packet_rate_kfps DOUBLE PRECISION,
+    out_v4_traffic_rate_mbps DOUBLE PRECISION,
+    out_v4_packet_rate_kfps DOUBLE PRECISION,
+    in_v6_traffic_rate_mbps DOUBLE PRECISION,
+    in_v6_packet_rate_kfps DOUBLE PRECISION,
+    out_v6_traffic_rate_mbps DOUBLE PRECISION,
+    out_v6_packet_rate_kfps DOUBLE PRECISION,
+    input_v4_utilization NUMERIC(5,2),
+    output_v4_utilization NUMERIC(5,2),
+    input_v6_utilization NUMERIC(5,2),
+    output_v6_utilization NUMERIC(5,2),
+    -- 兼容列：旧版格式化字符串（如 "12.50 Mbps"），仅在 legacy_string_columns 开启时写入
+    in_traffic_rate TEXT,
+    in_packet_rate TEXT,
+    out_traffic_rate TEXT,
+    out_packet_rate TEXT,
     in_v4_traffic_rate TEXT,
     in_v4_packet_rate TEXT,
     out_v4_traffic_rate TEXT,
@@ -255,25 +324,132 @@ CREATE TABLE IF NOT EXISTS subinterface_metrics (
     in_v6_packet_rate TEXT,
     out_v6_traffic_rate TEXT,
     out_v6_packet_rate TEXT,
-    input_v4_utilization NUMERIC(5,2),
-    output_v4_utilization NUMERIC(5,2),
-    input_v6_utilization NUMERIC(5,2),
-    output_v6_utilization NUMERIC(5,2),
     in_bier_octets BIGINT,
     in_bier_pkts BIGINT,
     out_bier_octets BIGINT,
-    out_bier_pkts BIGINT
+    out_bier_pkts BIGINT,
+    -- 服务端计算的计数器速率（每秒，字节/包）
+    in_octets_rate DOUBLE PRECISION,
+    in_unicast_pkts_rate DOUBLE PRECISION,
+    in_broadcast_pkts_rate DOUBLE PRECISION,
+    in_multicast_pkts_rate DOUBLE PRECISION,
+    in_discards_rate DOUBLE PRECISION,
+    in_errors_rate DOUBLE PRECISION,
+    in_unknown_protos_rate DOUBLE PRECISION,
+    in_fcs_errors_rate DOUBLE PRECISION,
+    out_octets_rate DOUBLE PRECISION,
+    out_unicast_pkts_rate DOUBLE PRECISION,
+    out_broadcast_pkts_rate DOUBLE PRECISION,
+    out_multicast_pkts_rate DOUBLE PRECISION,
+    out_discards_rate DOUBLE PRECISION,
+    out_errors_rate DOUBLE PRECISION,
+    in_pkts_rate DOUBLE PRECISION,
+    out_pkts_rate DOUBLE PRECISION,
+    in_v4_octets_rate DOUBLE PRECISION,
+    out_v4_octets_rate DOUBLE PRECISION,
+    in_v4_pkts_rate DOUBLE PRECISION,
+    out_v4_pkts_rate DOUBLE PRECISION,
+    in_v6_octets_rate DOUBLE PRECISION,
+    out_v6_octets_rate DOUBLE PRECISION,
+    in_v6_pkts_rate DOUBLE PRECISION,
+    out_v6_pkts_rate DOUBLE PRECISION,
+    in_bier_octets_rate DOUBLE PRECISION,
+    in_bier_pkts_rate DOUBLE PRECISION,
+    out_bier_octets_rate DOUBLE PRECISION,
+    out_bier_pkts_rate DOUBLE PRECISION
+);
+
+-- 创建自定义事件表（设备侧阈值触发的SelfDefinedEvent）
+CREATE TABLE IF NOT EXISTS self_defined_event (
+    timestamp TIMESTAMPTZ NOT NULL,
+    system_id TEXT NOT NULL,
+    level INTEGER NOT NULL,
+    description TEXT,
+    filter_relation TEXT,
+    filters JSONB,
+    sensor_path TEXT NOT NULL,
+    proto_path TEXT,
+    instance TEXT,
+    content_encoding TEXT
+);
+
+-- 创建通用指标表（无类型解析器的sensor_path按叶子展开，parser.generic_dispatch开启时写入）
+CREATE TABLE IF NOT EXISTS generic_metrics (
+    timestamp TIMESTAMPTZ NOT NULL,
+    system_id TEXT NOT NULL,
+    sensor_path TEXT NOT NULL,
+    proto_path TEXT,
+    instance_keys JSONB,
+    field TEXT NOT NULL,
+    value_num DOUBLE PRECISION,
+    value_str TEXT
+);
+
+-- 创建光通道指标表（组件内每个光通道一行，字段与平台指标的光模块字段相同）
+CREATE TABLE IF NOT EXISTS optical_channel_metrics (
+    timestamp TIMESTAMPTZ NOT NULL,
+    system_id TEXT NOT NULL,
+    component_name TEXT NOT NULL,
+    channel_index BIGINT NOT NULL,
+    optical_in_power DOUBLE PRECISION,
+    optical_out_power DOUBLE PRECISION,
+    optical_bias_current DOUBLE PRECISION,
+    optical_temperature DOUBLE PRECISION,
+    optical_voltage_vol33 DOUBLE PRECISION,
+    optical_voltage_vol5 DOUBLE PRECISION,
+    optical_alarm_los_status TEXT,
+    optical_alarm_los_info_event_id BIGINT,
+    optical_alarm_los_info_event_interval BIGINT,
+    optical_alarm_los_info_in_power DOUBLE PRECISION,
+    optical_alarm_los_info_out_power DOUBLE PRECISION,
+    optical_online_status TEXT,
+    optical_rx_threshold_high_alarm DOUBLE PRECISION,
+    optical_rx_threshold_pre_high_alarm DOUBLE PRECISION,
+    optical_rx_threshold_low_alarm DOUBLE PRECISION,
+    optical_rx_threshold_pre_low_alarm DOUBLE PRECISION
+);
+
+-- 设备登记（采集器从报文中学习，按变化更新）
+CREATE TABLE IF NOT EXISTS devices (
+    system_id TEXT PRIMARY KEY,
+    mng_ipv4 TEXT,
+    mng_ipv6 TEXT,
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    streams JSONB NOT NULL DEFAULT '[]',
+    sensor_paths JSONB NOT NULL DEFAULT '{}',
+    duplicate_streams INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 数据缺口（超过上报周期未收到数据，gap_end为空表示仍未恢复）
+CREATE TABLE IF NOT EXISTS telemetry_gaps (
+    id BIGSERIAL PRIMARY KEY,
+    system_id TEXT NOT NULL,
+    sensor_path TEXT NOT NULL,
+    expected_period_ms BIGINT NOT NULL,
+    gap_start TIMESTAMPTZ NOT NULL,
+    gap_end TIMESTAMPTZ,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (system_id, sensor_path, gap_start)
 );
 
 -- 创建时序表（TimescaleDB hypertables）
 SELECT create_hypertable('platform_metrics', 'time', if_not_exists => TRUE);
 SELECT create_hypertable('interface_metrics', 'time', if_not_exists => TRUE);
 SELECT create_hypertable('subinterface_metrics', 'time', if_not_exists => TRUE);
+SELECT create_hypertable('self_defined_event', 'timestamp', if_not_exists => TRUE);
+SELECT create_hypertable('generic_metrics', 'timestamp', if_not_exists => TRUE);
+SELECT create_hypertable('optical_channel_metrics', 'timestamp', if_not_exists => TRUE);
 
 -- 创建索引以提高查询性能
 CREATE INDEX IF NOT EXISTS idx_platform_metrics_system_component ON platform_metrics (system_id, component_name, time DESC);
 CREATE INDEX IF NOT EXISTS idx_interface_metrics_system_interface ON interface_metrics (system_id, interface_name, time DESC);
 CREATE INDEX IF NOT EXISTS idx_subinterface_metrics_system_interface ON subinterface_metrics (system_id, interface_name, subinterface_index, time DESC);
+CREATE INDEX IF NOT EXISTS idx_self_defined_event_system_level ON self_defined_event (system_id, level, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_generic_metrics_system_sensor ON generic_metrics (system_id, sensor_path, field, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_optical_channel_metrics_system_component ON optical_channel_metrics (system_id, component_name, channel_index, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_telemetry_gaps_open ON telemetry_gaps (system_id, sensor_path) WHERE gap_end IS NULL;
 
 -- 设置数据保留策略（可选，保留30天数据）
 -- SELECT add_retention_policy('platform_metrics', INTERVAL '30 days', if_not_exists => TRUE);
@@ -0,0 +1,257 @@
+package access
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+// Publish流访问控制
+//
+// 建立连接时按对端地址检查 deny/allow 列表，不通过则直接拒绝整个流。
+// 每条报文再按 system_id 查设备登记：对端地址须在配置的来源中，
+// 或（learn_source）等于该设备首次通过检查时的对端地址；可选要求与TLS证书身份一致。
+// 报文自带的 mng_ipv4/mng_ipv6 由发送方填写，不作为来源依据。
+// 报文检查不通过时按 mismatch_policy 处理：断开流、丢弃报文或给行打标记后照常写入。
+
+// Action 报文来源不匹配时的处理策略
+type Action string
+
+const (
+	RejectStream Action = "reject_stream"
+	DropMessage  Action = "drop_message"
+	TagRow       Action = "tag_row"
+)
+
+// 拒绝原因，用作Prometheus标签
+const (
+	ReasonPeerDenied     = "peer_denied"
+	ReasonUnknownDevice  = "unknown_device"
+	ReasonSourceMismatch = "source_mismatch"
+	ReasonCertMismatch   = "cert_mismatch"
+)
+
+// Violation 访问控制检查未通过
+type Violation struct {
+	Reason   string
+	Action   Action
+	SystemID string
+	Peer     string
+}
+
+// Error 返回给设备的错误描述（PublishArgs.errors）
+func (v *Violation) Error() string {
+	if v.SystemID == "" {
+		return fmt.Sprintf("access denied: %s, peer=%s", v.Reason, v.Peer)
+	}
+	return fmt.Sprintf("access denied: %s, system_id=%s, peer=%s", v.Reason, v.SystemID, v.Peer)
+}
+
+// Message 单条报文的来源信息
+type Message struct {
+	SystemID     string
+	Peer         netip.Addr
+	CertMismatch bool // 已提供客户端证书且与system_id不一致
+}
+
+type binding struct {
+	sources []netip.Prefix
+	learn   bool
+
+	mu      sync.Mutex
+	learned netip.Addr // learn_source 时首次通过检查的对端地址
+}
+
+// Checker 访问控制检查器
+type Checker struct {
+	allow             []netip.Prefix
+	deny              []netip.Prefix
+	devices           map[string]*binding
+	requireRegistered bool
+	requireCertMatch  bool
+	action            Action
+	tagSuffix         string
+
+	mu         sync.Mutex
+	rejections map[string]int64
+}
+
+// NewChecker 根据配置创建检查器，地址或策略配置错误时返回错误
+func NewChecker(cfg config.AccessConfig) (*Checker, error) {
+	c := &Checker{
+		devices:           make(map[string]*binding, len(cfg.Devices)),
+		requireRegistered: cfg.RequireRegistered,
+		requireCertMatch:  cfg.RequireCertMatch,
+		action:            Action(cfg.MismatchPolicy),
+		tagSuffix:         cfg.TagSuffix,
+		rejections:        make(map[string]int64),
+	}
+
+	switch c.action {
+	case RejectStream, DropMessage, TagRow:
+	case "":
+		c.action = DropMessage
+	default:
+		return nil, fmt.Errorf("不支持的mismatch_policy: %s（可选 reject_stream/drop_message/tag_row）", cfg.MismatchPolicy)
+	}
+	if c.action == TagRow && c.tagSuffix == "" {
+		return nil, fmt.Errorf("mismatch_policy=tag_row 需要配置tag_suffix")
+	}
+
+	var err error
+	if c.allow, err = parsePrefixes(cfg.Allow); err != nil {
+		return nil, fmt.Errorf("解析allow失败: %v", err)
+	}
+	if c.deny, err = parsePrefixes(cfg.Deny); err != nil {
+		return nil, fmt.Errorf("解析deny失败: %v", err)
+	}
+	for _, d := range cfg.Devices {
+		if d.SystemID == "" {
+			return nil, fmt.Errorf("设备登记缺少system_id")
+		}
+		if d.MatchMngIP {
+			return nil, fmt.Errorf("设备 %s: match_mng_ip 已移除（报文中的管理口地址可被伪造），请改用 sources 或 learn_source", d.SystemID)
+		}
+		sources, err := parsePrefixes(d.Sources)
+		if err != nil {
+			return nil, fmt.Errorf("解析设备 %s 的sources失败: %v", d.SystemID, err)
+		}
+		c.devices[d.SystemID] = &binding{sources: sources, learn: d.LearnSource}
+	}
+	return c, nil
+}
+
+// CheckPeer 建立连接时检查对端地址，不通过时总是拒绝整个流
+func (c *Checker) CheckPeer(peer netip.Addr) *Violation {
+	denied := contains(c.deny, peer) || (len(c.allow) > 0 && !contains(c.allow, peer))
+	if !denied {
+		return nil
+	}
+	return c.violation(&Violation{Reason: ReasonPeerDenied, Action: RejectStream, Peer: addrString(peer)})
+}
+
+// CheckMessage 按system_id登记检查报文来源，通过时返回nil
+func (c *Checker) CheckMessage(m Message) *Violation {
+	reason := ""
+	if b, ok := c.devices[m.SystemID]; ok {
+		if !b.allows(m) {
+			reason = ReasonSourceMismatch
+		}
+	} else if c.requireRegistered {
+		reason = ReasonUnknownDevice
+	}
+	if reason == "" && c.requireCertMatch && m.CertMismatch {
+		reason = ReasonCertMismatch
+	}
+	if reason == "" {
+		return nil
+	}
+	return c.violation(&Violation{Reason: reason, Action: c.action, SystemID: m.SystemID, Peer: addrString(m.Peer)})
+}
+
+// TagSystemID 返回tag_row策略下写入的system_id
+func (c *Checker) TagSystemID(systemID string) string {
+	return systemID + c.tagSuffix
+}
+
+// Rejections 按原因统计的检查未通过次数（累计值）
+func (c *Checker) Rejections() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[string]int64, len(c.rejections))
+	for reason, n := range c.rejections {
+		counts[reason] = n
+	}
+	return counts
+}
+
+func (c *Checker) violation(v *Violation) *Violation {
+	c.mu.Lock()
+	c.rejections[v.Reason]++
+	c.mu.Unlock()
+	return v
+}
+
+// allows 对端地址是否为该设备的合法来源。learn_source 时首条报文的对端地址
+// （已通过allow/deny，且未与证书身份冲突）记为该设备的来源，此后只接受该地址
+func (b *binding) allows(m Message) bool {
+	if contains(b.sources, m.Peer) {
+		return true
+	}
+	if !b.learn || !m.Peer.IsValid() {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.learned.IsValid() {
+		if m.CertMismatch {
+			return false
+		}
+		b.learned = m.Peer
+		return true
+	}
+	return b.learned == m.Peer
+}
+
+// PeerAddr 从gRPC对端地址中取出IP，无法解析时返回无效地址
+func PeerAddr(addr net.Addr) netip.Addr {
+	if addr == nil {
+		return netip.Addr{}
+	}
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		ip, _ := netip.AddrFromSlice(tcp.IP)
+		return ip.Unmap()
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ap.Addr().Unmap()
+}
+
+// parsePrefixes 解析IP或CIDR列表，单个IP视为主机路由
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			prefix, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func addrString(addr netip.Addr) string {
+	if !addr.IsValid() {
+		return "unknown"
+	}
+	return addr.String()
+}
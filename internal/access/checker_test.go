@@ -0,0 +1,162 @@
+package access
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+func TestChecker_CheckPeer(t *testing.T) {
+	c, err := NewChecker(config.AccessConfig{
+		Allow: []string{"10.0.0.0/8", "2001:db8::/32"},
+		Deny:  []string{"10.9.0.0/16", "10.1.1.1"},
+	})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+
+	tests := []struct {
+		peer    string
+		allowed bool
+	}{
+		{"10.1.2.3", true},
+		{"2001:db8::1", true},
+		{"::ffff:10.1.2.3", true},
+		{"10.9.1.1", false}, // deny优先
+		{"10.1.1.1", false},
+		{"192.0.2.1", false}, // 不在allow中
+		{"", false},
+	}
+	for _, tt := range tests {
+		var addr netip.Addr
+		if tt.peer != "" {
+			addr = netip.MustParseAddr(tt.peer).Unmap()
+		}
+		v := c.CheckPeer(addr)
+		if (v == nil) != tt.allowed {
+			t.Errorf("CheckPeer(%q) = %v, allowed want %v", tt.peer, v, tt.allowed)
+		}
+		if v != nil && (v.Reason != ReasonPeerDenied || v.Action != RejectStream) {
+			t.Errorf("CheckPeer(%q) = %+v", tt.peer, v)
+		}
+	}
+	if got := c.Rejections()[ReasonPeerDenied]; got != 4 {
+		t.Errorf("peer_denied = %d, want 4", got)
+	}
+}
+
+func TestChecker_CheckMessage(t *testing.T) {
+	c, err := NewChecker(config.AccessConfig{
+		Devices: []config.DeviceBinding{
+			{SystemID: "PE-01", Sources: []string{"10.1.1.0/24"}},
+			{SystemID: "PE-02", LearnSource: true},
+		},
+		RequireRegistered: true,
+		RequireCertMatch:  true,
+		MismatchPolicy:    "tag_row",
+		TagSuffix:         "#untrusted",
+	})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+
+	peer := netip.MustParseAddr("10.1.1.5")
+	tests := []struct {
+		name   string
+		msg    Message
+		reason string
+	}{
+		{"bound source", Message{SystemID: "PE-01", Peer: peer}, ""},
+		{"spoofed system_id", Message{SystemID: "PE-01", Peer: netip.MustParseAddr("10.2.0.1")}, ReasonSourceMismatch},
+		{"learned on first contact", Message{SystemID: "PE-02", Peer: peer}, ""},
+		{"learned source", Message{SystemID: "PE-02", Peer: peer}, ""},
+		{"other peer after learning", Message{SystemID: "PE-02", Peer: netip.MustParseAddr("2001:db8::5")}, ReasonSourceMismatch},
+		{"unregistered", Message{SystemID: "PE-03", Peer: peer}, ReasonUnknownDevice},
+		{"cert mismatch", Message{SystemID: "PE-01", Peer: peer, CertMismatch: true}, ReasonCertMismatch},
+	}
+	for _, tt := range tests {
+		v := c.CheckMessage(tt.msg)
+		got := ""
+		if v != nil {
+			got = v.Reason
+			if v.Action != TagRow {
+				t.Errorf("%s: Action = %s, want tag_row", tt.name, v.Action)
+			}
+		}
+		if got != tt.reason {
+			t.Errorf("%s: reason = %q, want %q", tt.name, got, tt.reason)
+		}
+	}
+
+	if got := c.TagSystemID("PE-01"); got != "PE-01#untrusted" {
+		t.Errorf("TagSystemID = %s", got)
+	}
+	counts := c.Rejections()
+	if counts[ReasonSourceMismatch] != 2 || counts[ReasonUnknownDevice] != 1 || counts[ReasonCertMismatch] != 1 {
+		t.Errorf("Rejections = %v", counts)
+	}
+}
+
+func TestChecker_LearnSourceCertMismatch(t *testing.T) {
+	c, err := NewChecker(config.AccessConfig{
+		Devices: []config.DeviceBinding{{SystemID: "PE-01", LearnSource: true}},
+	})
+	if err != nil {
+		t.Fatalf("NewChecker: %v", err)
+	}
+	attacker, device := netip.MustParseAddr("10.2.0.9"), netip.MustParseAddr("10.1.1.5")
+
+	// 与证书身份冲突的首条报文不作为学习来源
+	if v := c.CheckMessage(Message{SystemID: "PE-01", Peer: attacker, CertMismatch: true}); v == nil {
+		t.Fatal("cert mismatch accepted on first contact")
+	}
+	if v := c.CheckMessage(Message{SystemID: "PE-01", Peer: device}); v != nil {
+		t.Fatalf("first trusted contact rejected: %v", v)
+	}
+	if v := c.CheckMessage(Message{SystemID: "PE-01", Peer: attacker}); v == nil || v.Reason != ReasonSourceMismatch {
+		t.Errorf("attacker after learning = %v, want source_mismatch", v)
+	}
+}
+
+func TestNewChecker_InvalidConfig(t *testing.T) {
+	tests := []config.AccessConfig{
+		{Allow: []string{"10.0.0.0/33"}},
+		{Deny: []string{"not-an-ip"}},
+		{Devices: []config.DeviceBinding{{Sources: []string{"10.0.0.1"}}}},
+		{Devices: []config.DeviceBinding{{SystemID: "PE-01", MatchMngIP: true}}},
+		{MismatchPolicy: "ignore"},
+		{MismatchPolicy: "tag_row"},
+	}
+	for _, cfg := range tests {
+		if _, err := NewChecker(cfg); err == nil {
+			t.Errorf("NewChecker(%+v) should fail", cfg)
+		}
+	}
+
+	c, err := NewChecker(config.AccessConfig{})
+	if err != nil {
+		t.Fatalf("NewChecker(empty): %v", err)
+	}
+	if c.action != DropMessage {
+		t.Errorf("default action = %s, want drop_message", c.action)
+	}
+}
+
+func TestPeerAddr(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		want string
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.1.1.5"), Port: 50000}, "10.1.1.5"},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::5"), Port: 50000}, "2001:db8::5"},
+		{&net.UnixAddr{Name: "/tmp/sock", Net: "unix"}, "invalid IP"},
+		{nil, "invalid IP"},
+	}
+	for _, tt := range tests {
+		if got := PeerAddr(tt.addr).String(); got != tt.want {
+			t.Errorf("PeerAddr(%v) = %s, want %s", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package buffer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+// 聚合窗口
+//
+// 采样指标按 时间桶_system_id_名称 聚合为一行，同键的记录逐字段合并。默认时间桶为1秒，
+// 定时刷新写出全部记录，一轮采样跨越秒边界时会拆成两行。
+// 按缓冲区配置窗口（buffer.aggregation）后：
+//   - 时间桶为按窗口对齐的时间段；period按设备上报的采样周期（current_period）对齐；
+//     或采样轮次（collection，行时间戳已统一为轮次开始时间）
+//   - 定时刷新与WAL检查点只写出已关闭的窗口：窗口结束（collection为轮次开始）后再过grace
+//   - 不再按flush_threshold刷新，未关闭的窗口留在缓冲区继续合并
+//
+// 缓冲中的行时间戳为最近一次合并的报文时间（用于后写者胜的比较），写库时改为时间桶的开始时间。
+// 超出缓冲区上限时的刷新、采样轮次结束（collection.flush_on_complete）与停止时
+// 仍会写出未关闭的窗口，此时同一窗口可能写成多行。
+
+// WindowCollection 按采样轮次聚合
+const WindowCollection = "collection"
+
+// WindowPeriod 按设备上报的采样周期聚合
+const WindowPeriod = "period"
+
+// aggregationRows 支持配置聚合窗口的缓冲区及其记录类型
+var aggregationRows = map[string]reflect.Type{
+	"platform":        reflect.TypeOf(models.PlatformMetric{}),
+	"interface":       reflect.TypeOf(models.InterfaceMetric{}),
+	"subinterface":    reflect.TypeOf(models.SubinterfaceMetric{}),
+	"optical_channel": reflect.TypeOf(models.OpticalChannelMetric{}),
+	"generic":         reflect.TypeOf(models.GenericMetric{}),
+}
+
+// aggregation 单个缓冲区的聚合窗口，nil表示默认（秒级时间桶，不保留窗口）
+type aggregation struct {
+	window     time.Duration
+	collection bool
+	period     bool
+	grace      time.Duration
+	// keys 额外聚合键字段的下标路径
+	keys [][]int
+	// periods period窗口下各设备的采样周期（system_id -> time.Duration），未上报的按1秒
+	periods sync.Map
+}
+
+// EnableAggregation 按缓冲区配置聚合窗口，需在写入缓冲区（含WAL载入）之前调用。
+// rounds 为是否启用了采样轮次重组，collection窗口依赖它统一的时间戳。
+func (bm *FixedBufferManager) EnableAggregation(cfg map[string]config.AggregationConfig, rounds bool) error {
+	aggs := make(map[string]*aggregation, len(cfg))
+	for table, c := range cfg {
+		rowType, ok := aggregationRows[table]
+		if !ok {
+			return fmt.Errorf("不支持配置聚合窗口的缓冲区: %s", table)
+		}
+		agg := &aggregation{grace: c.Grace}
+		switch c.Window {
+		case WindowCollection:
+			if !rounds {
+				return fmt.Errorf("%s: window为collection时需启用collection", table)
+			}
+			if c.Grace <= 0 {
+				return fmt.Errorf("%s: window为collection时grace必须大于0", table)
+			}
+			agg.collection = true
+		case WindowPeriod:
+			agg.period = true
+		case "":
+			agg.window = time.Second
+		default:
+			d, err := time.ParseDuration(c.Window)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("%s: window格式错误: %q", table, c.Window)
+			}
+			agg.window = d
+		}
+		if c.Grace < 0 {
+			return fmt.Errorf("%s: grace不能为负: %v", table, c.Grace)
+		}
+		for _, name := range c.Keys {
+			path := jsonFieldPath(rowType, name)
+			if path == nil {
+				return fmt.Errorf("%s: 未知的聚合键字段: %s", table, name)
+			}
+			agg.keys = append(agg.keys, path)
+		}
+		aggs[table] = agg
+	}
+	bm.aggregation = aggs
+	return nil
+}
+
+// ObserveSamplePeriod 记录设备在某缓冲区的采样周期（current_period），供period窗口分桶
+func (bm *FixedBufferManager) ObserveSamplePeriod(table, systemID string, period time.Duration) {
+	agg := bm.aggregation[table]
+	if agg == nil || !agg.period || period <= 0 {
+		return
+	}
+	if prev, ok := agg.periods.Load(systemID); !ok || prev.(time.Duration) != period {
+		agg.periods.Store(systemID, period)
+	}
+}
+
+// windowOf 设备记录的窗口长度
+func (a *aggregation) windowOf(systemID string) time.Duration {
+	if !a.period {
+		return a.window
+	}
+	if p, ok := a.periods.Load(systemID); ok {
+		return p.(time.Duration)
+	}
+	return time.Second
+}
+
+// bucket 记录所属的时间桶
+func (a *aggregation) bucket(ts time.Time, systemID string) int64 {
+	switch {
+	case a == nil:
+		return ts.Truncate(time.Second).Unix()
+	case a.collection:
+		return ts.UnixNano()
+	}
+	return ts.Truncate(a.windowOf(systemID)).UnixNano()
+}
+
+// start 时间桶的开始时间，即写库的行时间；未配置聚合时保持原时间戳
+func (a *aggregation) start(ts time.Time, systemID string) time.Time {
+	if a == nil || a.collection {
+		return ts
+	}
+	return ts.Truncate(a.windowOf(systemID))
+}
+
+// closed 记录所在窗口在now时是否已关闭
+func (a *aggregation) closed(ts time.Time, systemID string, now time.Time) bool {
+	end := ts
+	if !a.collection {
+		w := a.windowOf(systemID)
+		end = ts.Truncate(w).Add(w)
+	}
+	return !now.Before(end.Add(a.grace))
+}
+
+// restampRows 写库前将行时间戳改为所在时间桶的开始时间，row返回行的时间戳字段与system_id；
+// 未配置聚合窗口时行时间为最后一个报文的时间，不做改写
+func restampRows[T any](a *aggregation, rows []T, row func(*T) (*time.Time, string)) {
+	if a == nil {
+		return
+	}
+	for i := range rows {
+		ts, systemID := row(&rows[i])
+		*ts = a.start(*ts, systemID)
+	}
+}
+
+// writeKeys 追加额外聚合键，row为记录指针
+func (a *aggregation) writeKeys(kb *keyBuffer, row any) {
+	if a == nil || len(a.keys) == 0 {
+		return
+	}
+	v := reflect.ValueOf(row).Elem()
+	for _, path := range a.keys {
+		kb.writeByte('_')
+		if f, ok := fieldByPath(v, path); ok {
+			kb.writeString(fmt.Sprint(f.Interface()))
+		}
+	}
+}
+
+// fieldByPath 按下标路径取字段值（解引用指针），途经或最终为空指针时返回false
+func fieldByPath(v reflect.Value, path []int) (reflect.Value, bool) {
+	for _, i := range path {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// jsonFieldPath 按JSON字段名查找字段（含内嵌子结构体），找不到时返回nil
+func jsonFieldPath(t reflect.Type, name string) []int {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if path := jsonFieldPath(embedded, name); path != nil {
+					return append([]int{i}, path...)
+				}
+			}
+			continue
+		}
+		if tag, _, _ := strings.Cut(sf.Tag.Get("json"), ","); tag == name {
+			return []int{i}
+		}
+	}
+	return nil
+}
+
+// overThreshold 缓冲区记录数达到flush_threshold，配置了聚合窗口的缓冲区不按阈值刷新
+func (bm *FixedBufferManager) overThreshold(table string, n int) bool {
+	return n >= bm.config.FlushThreshold && bm.aggregation[table] == nil
+}
+
+// flushDue 定时刷新：配置了聚合窗口的缓冲区只写出已关闭的窗口，其余全部写出
+func (bm *FixedBufferManager) flushDue() error {
+	if len(bm.aggregation) == 0 {
+		return bm.FlushAll()
+	}
+	start := time.Now()
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, table := range bufferTables {
+		flush := bm.flushFunc(table)
+		if agg := bm.aggregation[table]; agg != nil {
+			flush = func() error { return bm.flushClosedWindows(table, agg, start) }
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := flush(); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s 刷新失败: %v", table, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	bm.statsMutex.Lock()
+	bm.stats.LastFlushTime = time.Now()
+	bm.stats.FlushDuration = time.Since(start)
+	bm.statsMutex.Unlock()
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// flushClosedWindows 写出缓冲区中在now时已关闭的窗口
+func (bm *FixedBufferManager) flushClosedWindows(table string, agg *aggregation, now time.Time) error {
+	defer bm.trackFlush()()
+	switch table {
+	case "platform":
+		return bm.writePlatformMetrics(bm.platformBuffer.SwapMatching(func(m *models.PlatformMetric) bool {
+			return agg.closed(m.Timestamp, m.SystemID, now)
+		}))
+	case "interface":
+		return bm.writeInterfaceMetrics(bm.interfaceBuffer.SwapMatching(func(m *models.InterfaceMetric) bool {
+			return agg.closed(m.Timestamp, m.SystemID, now)
+		}))
+	case "subinterface":
+		return bm.writeSubinterfaceMetrics(bm.subinterfaceBuffer.SwapMatching(func(m *models.SubinterfaceMetric) bool {
+			return agg.closed(m.Timestamp, m.SystemID, now)
+		}))
+	case "optical_channel":
+		return bm.writeOpticalChannelMetrics(bm.opticalChannelBuffer.SwapMatching(func(m *models.OpticalChannelMetric) bool {
+			return agg.closed(m.Timestamp, m.SystemID, now)
+		}))
+	case "generic":
+		return bm.writeGenericMetrics(bm.genericBuffer.SwapMatching(func(m *models.GenericMetric) bool {
+			return agg.closed(m.Timestamp, m.SystemID, now)
+		}))
+	}
+	return nil
+}
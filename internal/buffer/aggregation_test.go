@@ -0,0 +1,162 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+func TestAggregationWindowKeys(t *testing.T) {
+	bm := newTestBufferManager()
+	base := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	first := &models.PlatformMetric{Timestamp: base.Add(900 * time.Millisecond), SystemID: "dev-1", ComponentName: "PSU1"}
+	second := &models.PlatformMetric{Timestamp: base.Add(1100 * time.Millisecond), SystemID: "dev-1", ComponentName: "PSU1"}
+
+	// 默认秒级时间桶，跨秒边界的同一轮采样拆成两行
+	if bm.generatePlatformKey(first) == bm.generatePlatformKey(second) {
+		t.Fatal("default keys should differ across a second boundary")
+	}
+
+	if err := bm.EnableAggregation(map[string]config.AggregationConfig{
+		"platform": {Window: "30s", Keys: []string{"power_name"}},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	if bm.generatePlatformKey(first) != bm.generatePlatformKey(second) {
+		t.Error("keys differ within a 30s window")
+	}
+	if next := (&models.PlatformMetric{Timestamp: base.Add(30 * time.Second), SystemID: "dev-1", ComponentName: "PSU1"}); bm.generatePlatformKey(next) == bm.generatePlatformKey(first) {
+		t.Error("next window shares the key")
+	}
+
+	name := "PSU-A"
+	second.PowerData = &models.PowerData{PowerName: &name}
+	if key := bm.generatePlatformKey(second); key == bm.generatePlatformKey(first) || !strings.HasSuffix(key, "_PSU-A") {
+		t.Errorf("key with power_name = %q", key)
+	}
+}
+
+func TestFlushClosedWindows(t *testing.T) {
+	db := &fakeDB{}
+	bm := newBudgetTestManager(t, db, config.BufferConfig{})
+	defer bm.Stop()
+	bm.config.FlushThreshold = 1
+	if err := bm.EnableAggregation(map[string]config.AggregationConfig{
+		"platform": {Window: "10s", Grace: 2 * time.Second},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	closed := models.PlatformMetric{Timestamp: now.Truncate(10 * time.Second).Add(-20 * time.Second), SystemID: "dev-1", ComponentName: "CPU0"}
+	open := models.PlatformMetric{Timestamp: now, SystemID: "dev-1", ComponentName: "CPU0"}
+	if err := bm.AddPlatformMetrics([]models.PlatformMetric{closed, open}); err != nil {
+		t.Fatal(err)
+	}
+	// 配置了窗口的缓冲区不按阈值刷新
+	time.Sleep(20 * time.Millisecond)
+	if n := bm.platformBuffer.Len(); n != 2 {
+		t.Fatalf("platform buffer holds %d records after threshold, want 2", n)
+	}
+
+	if err := bm.flushDue(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "closed window written", func() bool { return db.written() == 1 })
+	if _, ok := bm.platformBuffer.Get(bm.generatePlatformKey(&open)); !ok {
+		t.Error("open window flushed")
+	}
+
+	agg := bm.aggregation["platform"]
+	end := open.Timestamp.Truncate(10 * time.Second).Add(10 * time.Second)
+	if agg.closed(open.Timestamp, open.SystemID, end.Add(time.Second)) || !agg.closed(open.Timestamp, open.SystemID, end.Add(2*time.Second)) {
+		t.Error("window should close after window end plus grace")
+	}
+}
+
+func TestEnableAggregationErrors(t *testing.T) {
+	bm := newTestBufferManager()
+	for _, tc := range []struct {
+		cfg    map[string]config.AggregationConfig
+		rounds bool
+	}{
+		{map[string]config.AggregationConfig{"alarm_report": {}}, false},
+		{map[string]config.AggregationConfig{"platform": {Window: "soon"}}, false},
+		{map[string]config.AggregationConfig{"platform": {Window: WindowCollection, Grace: time.Second}}, false},
+		{map[string]config.AggregationConfig{"platform": {Window: WindowCollection}}, true},
+		{map[string]config.AggregationConfig{"interface": {Keys: []string{"no_such_field"}}}, false},
+	} {
+		if err := bm.EnableAggregation(tc.cfg, tc.rounds); err == nil {
+			t.Errorf("EnableAggregation(%+v, %v) succeeded", tc.cfg, tc.rounds)
+		}
+	}
+	if err := bm.EnableAggregation(map[string]config.AggregationConfig{
+		"subinterface": {Window: WindowCollection, Grace: 5 * time.Second, Keys: []string{"ifindex"}},
+	}, true); err != nil {
+		t.Errorf("collection window: %v", err)
+	}
+}
+
+func TestAggregationPeriodWindowRowTime(t *testing.T) {
+	db := &fakeDB{}
+	bm := newBudgetTestManager(t, db, config.BufferConfig{})
+	defer bm.Stop()
+	if err := bm.EnableAggregation(map[string]config.AggregationConfig{
+		"platform": {Window: WindowPeriod},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	first := models.PlatformMetric{Timestamp: base.Add(2 * time.Second), SystemID: "dev-1", ComponentName: "CPU0"}
+	second := models.PlatformMetric{Timestamp: base.Add(20 * time.Second), SystemID: "dev-1", ComponentName: "CPU0"}
+
+	// 未上报采样周期时按1秒分桶
+	if bm.generatePlatformKey(&first) == bm.generatePlatformKey(&second) {
+		t.Fatal("keys should differ before current_period is known")
+	}
+	bm.ObserveSamplePeriod("platform", "dev-1", 30*time.Second)
+	if bm.generatePlatformKey(&first) != bm.generatePlatformKey(&second) {
+		t.Fatal("keys differ within the device's 30s sample period")
+	}
+
+	if err := bm.AddPlatformMetrics([]models.PlatformMetric{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.FlushPlatformMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "row written", func() bool { return db.written() == 1 })
+	// 行时间为窗口开始时间，不是最后一个报文的时间
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if ts := db.platform[0].Timestamp; !ts.Equal(base) {
+		t.Errorf("row time = %v, want window start %v", ts, base)
+	}
+}
+
+func TestDefaultAggregationKeepsTimestamp(t *testing.T) {
+	db := &fakeDB{}
+	bm := newBudgetTestManager(t, db, config.BufferConfig{})
+	defer bm.Stop()
+
+	base := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	first := models.PlatformMetric{Timestamp: base.Add(100 * time.Millisecond), SystemID: "dev-1", ComponentName: "CPU0"}
+	second := models.PlatformMetric{Timestamp: base.Add(750 * time.Millisecond), SystemID: "dev-1", ComponentName: "CPU0"}
+	if err := bm.AddPlatformMetrics([]models.PlatformMetric{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.FlushPlatformMetrics(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "row written", func() bool { return db.written() == 1 })
+	// 未配置聚合窗口时行时间为最后一个报文的时间，保留毫秒精度
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if ts := db.platform[0].Timestamp; !ts.Equal(second.Timestamp) {
+		t.Errorf("row time = %v, want %v", ts, second.Timestamp)
+	}
+}
@@ -2,6 +2,7 @@ package buffer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"sync"
@@ -9,10 +10,15 @@ import (
 	"time"
 
 	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/dlq"
 	"github.com/wwswwsuns/ztelem/internal/models"
+	"github.com/wwswwsuns/ztelem/internal/wal"
 	"github.com/sirupsen/logrus"
 )
 
+// ErrBufferFull 缓冲区超出上限（见OverflowError）或WAL已满
+var ErrBufferFull = errors.New("buffer full")
+
 type DatabaseWriter interface {
 	BatchInsertPlatformMetrics([]models.PlatformMetric) error
 	BatchInsertInterfaceMetrics([]models.InterfaceMetric) error
@@ -25,12 +31,19 @@ type FixedBufferStats struct {
 	SubinterfaceBufferSize       int
 	AlarmReportBufferSize        int
 	NotificationReportBufferSize int
+	SelfDefinedEventBufferSize   int
+	GenericBufferSize            int
+	MappedRowBufferSize          int
+	OpticalChannelBufferSize     int
 	TotalRecordsProcessed        int64
 	TotalRecordsWritten          int64
 	TotalErrors                  int64
 	LastFlushTime                time.Time
 	FlushDuration                time.Duration
 	KeyCollisions                int64
+	WAL                          WALStats
+	DeadLetter                   DeadLetterStats
+	Memory                       MemoryStats
 }
 
 type DatabaseInterface interface {
@@ -39,6 +52,10 @@ type DatabaseInterface interface {
 	BatchInsertSubinterfaceMetrics(data []models.SubinterfaceMetric) error
 	BatchInsertAlarmReportMetrics(data []models.AlarmReportMetric) error
 	BatchInsertNotificationReportMetrics(data []models.NotificationReportMetric) error
+	BatchInsertSelfDefinedEventMetrics(data []models.SelfDefinedEventMetric) error
+	BatchInsertGenericMetrics(data []models.GenericMetric) error
+	BatchInsertMappedRows(data []models.MappedRow) error
+	BatchInsertOpticalChannelMetrics(data []models.OpticalChannelMetric) error
 }
 
 // FixedBufferManager 缓冲区管理器（分片锁 + 零分配聚合键）
@@ -54,6 +71,10 @@ type FixedBufferManager struct {
 	subinterfaceBuffer *ShardedSubinterfaceMap
 	alarmReportBuffer *ShardedAlarmMap
 	notificationReportBuffer *ShardedNotificationMap
+	selfDefinedEventBuffer   *ShardedSelfDefinedEventMap
+	genericBuffer            *ShardedGenericMap
+	mappedRowBuffer          *ShardedMappedRowMap
+	opticalChannelBuffer     *ShardedOpticalChannelMap
 
 	// 统计信息
 	stats      FixedBufferStats
@@ -72,6 +93,34 @@ type FixedBufferManager struct {
 	subinterfaceWriteChan       chan []models.SubinterfaceMetric
 	alarmReportWriteChan        chan []models.AlarmReportMetric
 	notificationReportWriteChan chan []models.NotificationReportMetric
+	selfDefinedEventWriteChan   chan []models.SelfDefinedEventMetric
+	genericWriteChan            chan []models.GenericMetric
+	mappedRowWriteChan          chan []models.MappedRow
+	opticalChannelWriteChan     chan []models.OpticalChannelMetric
+
+	// 写库跟踪：已从缓冲区取出但尚未写完的批次数、重试后仍失败的批次数
+	writesInFlight int64
+	failedWrites   int64
+	// 刷新（从缓冲区取出并提交写入）期间持有读锁，WAL检查点借此等待进行中的刷新
+	flushMu sync.RWMutex
+
+	// 预写日志（未启用时为nil）
+	wal *walState
+
+	// 死信队列（未启用时为nil）与重试耗尽批次的行数统计
+	dlq         *dlq.Store
+	dlqStored   int64
+	dlqDropped  int64
+	dlqRedriven int64
+
+	// 合计字节数上限（0为不限制）与超出上限的统计
+	memoryLimit      int64
+	overflowFlushing atomic.Bool
+	overflowMu       sync.Mutex
+	overflow         map[OverflowKey]int64
+
+	// 按缓冲区配置的聚合窗口，未配置的缓冲区不在其中
+	aggregation map[string]*aggregation
 }
 
 // keyBuffer 聚合键字节构建器，复用避免分配
@@ -110,6 +159,10 @@ func NewFixedBufferManager(db DatabaseInterface, cfg config.BufferConfig, writer
 		subinterfaceBuffer:       newShardedSubinterfaceMap(),
 		alarmReportBuffer:        newShardedAlarmMap(),
 		notificationReportBuffer: newShardedNotificationMap(),
+		selfDefinedEventBuffer:   newShardedSelfDefinedEventMap(),
+		genericBuffer:            newShardedGenericMap(),
+		mappedRowBuffer:          newShardedMappedRowMap(),
+		opticalChannelBuffer:     newShardedOpticalChannelMap(),
 		stopChan:                 make(chan struct{}),
 		keyBuf: sync.Pool{
 			New: func() interface{} { return &keyBuffer{buf: make([]byte, 0, 128)} },
@@ -119,6 +172,10 @@ func NewFixedBufferManager(db DatabaseInterface, cfg config.BufferConfig, writer
 		subinterfaceWriteChan:    make(chan []models.SubinterfaceMetric, 100),
 		alarmReportWriteChan:     make(chan []models.AlarmReportMetric, 100),
 		notificationReportWriteChan: make(chan []models.NotificationReportMetric, 100),
+		selfDefinedEventWriteChan:   make(chan []models.SelfDefinedEventMetric, 100),
+		genericWriteChan:            make(chan []models.GenericMetric, 100),
+		mappedRowWriteChan:          make(chan []models.MappedRow, 100),
+		opticalChannelWriteChan:     make(chan []models.OpticalChannelMetric, 100),
 	}
 
 	bm.startFlushTimer()
@@ -137,39 +194,45 @@ func (bm *FixedBufferManager) releaseKeyBuf(kb *keyBuffer) {
 	bm.keyBuf.Put(kb)
 }
 
-// generatePlatformKey 零分配聚合键：秒级时间戳_系统ID_组件名
+// generatePlatformKey 零分配聚合键：时间桶_系统ID_组件名（时间桶与额外键见 aggregation）
 func (bm *FixedBufferManager) generatePlatformKey(metric *models.PlatformMetric) string {
 	kb := bm.acquireKeyBuf()
 	defer bm.releaseKeyBuf(kb)
-	kb.writeInt(metric.Timestamp.Truncate(time.Second).Unix())
+	agg := bm.aggregation["platform"]
+	kb.writeInt(agg.bucket(metric.Timestamp, metric.SystemID))
 	kb.writeByte('_')
 	kb.writeString(metric.SystemID)
 	kb.writeByte('_')
 	kb.writeString(metric.ComponentName)
+	agg.writeKeys(kb, metric)
 	return kb.string()
 }
 
 func (bm *FixedBufferManager) generateInterfaceKey(metric *models.InterfaceMetric) string {
 	kb := bm.acquireKeyBuf()
 	defer bm.releaseKeyBuf(kb)
-	kb.writeInt(metric.Timestamp.Truncate(time.Second).Unix())
+	agg := bm.aggregation["interface"]
+	kb.writeInt(agg.bucket(metric.Timestamp, metric.SystemID))
 	kb.writeByte('_')
 	kb.writeString(metric.SystemID)
 	kb.writeByte('_')
 	kb.writeString(metric.InterfaceName)
+	agg.writeKeys(kb, metric)
 	return kb.string()
 }
 
 func (bm *FixedBufferManager) generateSubinterfaceKey(metric *models.SubinterfaceMetric) string {
 	kb := bm.acquireKeyBuf()
 	defer bm.releaseKeyBuf(kb)
-	kb.writeInt(metric.Timestamp.Truncate(time.Second).Unix())
+	agg := bm.aggregation["subinterface"]
+	kb.writeInt(agg.bucket(metric.Timestamp, metric.SystemID))
 	kb.writeByte('_')
 	kb.writeString(metric.SystemID)
 	kb.writeByte('_')
 	kb.writeString(metric.InterfaceName)
 	kb.writeByte('_')
 	kb.writeString(metric.SubinterfaceName)
+	agg.writeKeys(kb, metric)
 	return kb.string()
 }
 
@@ -195,89 +258,173 @@ func (bm *FixedBufferManager) generateNotificationKey(metric *models.Notificatio
 	return kb.string()
 }
 
-// AddPlatformMetrics 添加平台指标数据（分片锁，无全局互斥）
-func (bm *FixedBufferManager) AddPlatformMetrics(metrics []models.PlatformMetric) error {
+// generateSelfDefinedEventKey 事件键：系统ID:毫秒时间戳:采样路径:实例:级别
+// 同一实例在同一时刻重复上报的事件只保留一条
+func (bm *FixedBufferManager) generateSelfDefinedEventKey(metric *models.SelfDefinedEventMetric) string {
+	kb := bm.acquireKeyBuf()
+	defer bm.releaseKeyBuf(kb)
+	kb.writeString(metric.SystemID)
+	kb.writeByte(':')
+	kb.writeInt(metric.Timestamp.UnixMilli())
+	kb.writeByte(':')
+	kb.writeString(metric.SensorPath)
+	kb.writeByte(':')
+	if metric.Instance != nil {
+		kb.writeString(*metric.Instance)
+	}
+	kb.writeByte(':')
+	kb.writeInt(int64(metric.Level))
+	return kb.string()
+}
+
+// generateGenericKey 时间桶_系统ID_采样路径_实例键_字段
+func (bm *FixedBufferManager) generateGenericKey(metric *models.GenericMetric) string {
+	kb := bm.acquireKeyBuf()
+	defer bm.releaseKeyBuf(kb)
+	agg := bm.aggregation["generic"]
+	kb.writeInt(agg.bucket(metric.Timestamp, metric.SystemID))
+	kb.writeByte('_')
+	kb.writeString(metric.SystemID)
+	kb.writeByte('_')
+	kb.writeString(metric.SensorPath)
+	kb.writeByte('_')
+	if metric.InstanceKeys != nil {
+		kb.writeString(*metric.InstanceKeys)
+	}
+	kb.writeByte('_')
+	kb.writeString(metric.Field)
+	agg.writeKeys(kb, metric)
+	return kb.string()
+}
+
+// generateMappedRowKey 时间戳(毫秒)_系统ID_目标表_行键
+func (bm *FixedBufferManager) generateMappedRowKey(row *models.MappedRow) string {
+	kb := bm.acquireKeyBuf()
+	defer bm.releaseKeyBuf(kb)
+	kb.writeInt(row.Timestamp.UnixMilli())
+	kb.writeByte('_')
+	kb.writeString(row.SystemID)
+	kb.writeByte('_')
+	kb.writeString(row.Table)
+	kb.writeByte('_')
+	kb.writeString(row.Key)
+	return kb.string()
+}
+
+// generateOpticalChannelKey 聚合键：时间桶_系统ID_组件名_通道号
+func (bm *FixedBufferManager) generateOpticalChannelKey(metric *models.OpticalChannelMetric) string {
+	kb := bm.acquireKeyBuf()
+	defer bm.releaseKeyBuf(kb)
+	agg := bm.aggregation["optical_channel"]
+	kb.writeInt(agg.bucket(metric.Timestamp, metric.SystemID))
+	kb.writeByte('_')
+	kb.writeString(metric.SystemID)
+	kb.writeByte('_')
+	kb.writeString(metric.ComponentName)
+	kb.writeByte('_')
+	kb.writeInt(int64(metric.ChannelIndex))
+	agg.writeKeys(kb, metric)
+	return kb.string()
+}
+
+// addPlatformMetrics 添加平台指标数据（分片锁，无全局互斥）
+func (bm *FixedBufferManager) addPlatformMetrics(metrics []models.PlatformMetric) error {
+	var bytes int64
 	for i := range metrics {
 		key := bm.generatePlatformKey(&metrics[i])
 		shard := bm.platformBuffer.getShard(key)
 		shard.mu.Lock()
 
 		if existing, exists := shard.items[key]; exists {
+			before := sizeOf(existing)
 			bm.mergePlatformMetric(existing, &metrics[i])
+			bytes += sizeOf(existing) - before
 			bm.statsMutex.Lock()
 			bm.stats.KeyCollisions++
 			bm.statsMutex.Unlock()
 		} else {
 			metricCopy := metrics[i]
 			shard.items[key] = &metricCopy
+			bytes += sizeOf(&metricCopy)
 		}
 		shard.mu.Unlock()
 	}
 
+	bm.platformBuffer.addBytes(bytes)
 	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
 
-	if bm.platformBuffer.Len() >= bm.config.FlushThreshold {
-		go bm.FlushPlatformMetrics()
+	if bm.overThreshold("platform", bm.platformBuffer.Len()) {
+		bm.triggerFlush(bm.FlushPlatformMetrics)
 	}
 
 	return nil
 }
 
-func (bm *FixedBufferManager) AddInterfaceMetrics(metrics []models.InterfaceMetric) error {
+func (bm *FixedBufferManager) addInterfaceMetrics(metrics []models.InterfaceMetric) error {
+	var bytes int64
 	for i := range metrics {
 		key := bm.generateInterfaceKey(&metrics[i])
 		shard := bm.interfaceBuffer.getShard(key)
 		shard.mu.Lock()
 
 		if existing, exists := shard.items[key]; exists {
+			before := sizeOf(existing)
 			bm.mergeInterfaceMetric(existing, &metrics[i])
+			bytes += sizeOf(existing) - before
 			bm.statsMutex.Lock()
 			bm.stats.KeyCollisions++
 			bm.statsMutex.Unlock()
 		} else {
 			metricCopy := metrics[i]
 			shard.items[key] = &metricCopy
+			bytes += sizeOf(&metricCopy)
 		}
 		shard.mu.Unlock()
 	}
 
+	bm.interfaceBuffer.addBytes(bytes)
 	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
 
-	if bm.interfaceBuffer.Len() >= bm.config.FlushThreshold {
-		go bm.FlushInterfaceMetrics()
+	if bm.overThreshold("interface", bm.interfaceBuffer.Len()) {
+		bm.triggerFlush(bm.FlushInterfaceMetrics)
 	}
 
 	return nil
 }
 
-func (bm *FixedBufferManager) AddSubinterfaceMetrics(metrics []models.SubinterfaceMetric) error {
+func (bm *FixedBufferManager) addSubinterfaceMetrics(metrics []models.SubinterfaceMetric) error {
+	var bytes int64
 	for i := range metrics {
 		key := bm.generateSubinterfaceKey(&metrics[i])
 		shard := bm.subinterfaceBuffer.getShard(key)
 		shard.mu.Lock()
 
 		if existing, exists := shard.items[key]; exists {
+			before := sizeOf(existing)
 			bm.mergeSubinterfaceMetric(existing, &metrics[i])
+			bytes += sizeOf(existing) - before
 			bm.statsMutex.Lock()
 			bm.stats.KeyCollisions++
 			bm.statsMutex.Unlock()
 		} else {
 			metricCopy := metrics[i]
 			shard.items[key] = &metricCopy
+			bytes += sizeOf(&metricCopy)
 		}
 		shard.mu.Unlock()
 	}
 
+	bm.subinterfaceBuffer.addBytes(bytes)
 	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
 
-	if bm.subinterfaceBuffer.Len() >= bm.config.FlushThreshold {
-		go bm.FlushSubinterfaceMetrics()
+	if bm.overThreshold("subinterface", bm.subinterfaceBuffer.Len()) {
+		bm.triggerFlush(bm.FlushSubinterfaceMetrics)
 	}
 
 	return nil
 }
 
-func (bm *FixedBufferManager) AddAlarmReportMetrics(metrics []models.AlarmReportMetric) error {
+func (bm *FixedBufferManager) addAlarmReportMetrics(metrics []models.AlarmReportMetric) error {
 	for i := range metrics {
 		key := bm.generateAlarmKey(&metrics[i])
 		metricCopy := metrics[i]
@@ -287,13 +434,13 @@ func (bm *FixedBufferManager) AddAlarmReportMetrics(metrics []models.AlarmReport
 	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
 
 	if bm.alarmReportBuffer.Len() >= bm.config.FlushThreshold {
-		go bm.FlushAlarmReportMetrics()
+		bm.triggerFlush(bm.FlushAlarmReportMetrics)
 	}
 
 	return nil
 }
 
-func (bm *FixedBufferManager) AddNotificationReportMetrics(metrics []models.NotificationReportMetric) error {
+func (bm *FixedBufferManager) addNotificationReportMetrics(metrics []models.NotificationReportMetric) error {
 	for i := range metrics {
 		key := bm.generateNotificationKey(&metrics[i])
 		metricCopy := metrics[i]
@@ -303,84 +450,93 @@ func (bm *FixedBufferManager) AddNotificationReportMetrics(metrics []models.Noti
 	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
 
 	if bm.notificationReportBuffer.Len() >= bm.config.FlushThreshold {
-		go bm.FlushNotificationReportMetrics()
+		bm.triggerFlush(bm.FlushNotificationReportMetrics)
 	}
 
 	return nil
 }
 
-func (bm *FixedBufferManager) mergePlatformMetric(existing, new *models.PlatformMetric) {
-	if new.CommonState != nil {
-		if existing.CommonState == nil {
-			existing.CommonState = &models.CommonState{}
-		}
-		if new.OperStatus != nil {
-			existing.OperStatus = new.OperStatus
-		}
-		if new.Uptime != nil {
-			existing.Uptime = new.Uptime
-		}
-		if new.UsedPower != nil {
-			existing.UsedPower = new.UsedPower
-		}
-		if new.AllocatedPower != nil {
-			existing.AllocatedPower = new.AllocatedPower
-		}
-	}
-	if new.MemData != nil && new.MemAlarmStatus != nil {
-		if existing.MemData == nil {
-			existing.MemData = &models.MemData{}
-		}
-		existing.MemAlarmStatus = new.MemAlarmStatus
-	}
-	if new.CPUData != nil && new.CPUAlarmStatus != nil {
-		if existing.CPUData == nil {
-			existing.CPUData = &models.CPUData{}
-		}
-		existing.CPUAlarmStatus = new.CPUAlarmStatus
+// addSelfDefinedEventMetrics 添加自定义事件数据（事件不合并，按键去重）
+func (bm *FixedBufferManager) addSelfDefinedEventMetrics(metrics []models.SelfDefinedEventMetric) error {
+	for i := range metrics {
+		key := bm.generateSelfDefinedEventKey(&metrics[i])
+		metricCopy := metrics[i]
+		bm.selfDefinedEventBuffer.Set(key, &metricCopy)
 	}
-	if new.Timestamp.After(existing.Timestamp) {
-		existing.Timestamp = new.Timestamp
+
+	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
+
+	if bm.selfDefinedEventBuffer.Len() >= bm.config.FlushThreshold {
+		bm.triggerFlush(bm.FlushSelfDefinedEventMetrics)
 	}
+
+	return nil
 }
 
-func (bm *FixedBufferManager) mergeInterfaceMetric(existing, new *models.InterfaceMetric) {
-	if new.AdminStatusStr != nil {
-		existing.AdminStatusStr = new.AdminStatusStr
+// addGenericMetrics 添加通用指标数据（同一秒内同一字段保留最新值）
+func (bm *FixedBufferManager) addGenericMetrics(metrics []models.GenericMetric) error {
+	for i := range metrics {
+		key := bm.generateGenericKey(&metrics[i])
+		metricCopy := metrics[i]
+		bm.genericBuffer.Set(key, &metricCopy)
 	}
-	if new.OperStatusStr != nil {
-		existing.OperStatusStr = new.OperStatusStr
+
+	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
+
+	if bm.overThreshold("generic", bm.genericBuffer.Len()) {
+		bm.triggerFlush(bm.FlushGenericMetrics)
 	}
-	if new.PhyStatusStr != nil {
-		existing.PhyStatusStr = new.PhyStatusStr
+
+	return nil
+}
+
+// addMappedRows 添加映射文件生成的行
+func (bm *FixedBufferManager) addMappedRows(rows []models.MappedRow) error {
+	for i := range rows {
+		key := bm.generateMappedRowKey(&rows[i])
+		rowCopy := rows[i]
+		bm.mappedRowBuffer.Set(key, &rowCopy)
 	}
-	if new.InOctets != nil {
-		existing.InOctets = new.InOctets
+
+	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(rows)))
+
+	if bm.mappedRowBuffer.Len() >= bm.config.FlushThreshold {
+		bm.triggerFlush(bm.FlushMappedRows)
 	}
-	if new.OutOctets != nil {
-		existing.OutOctets = new.OutOctets
+
+	return nil
+}
+
+// addOpticalChannelMetrics 添加光通道指标
+func (bm *FixedBufferManager) addOpticalChannelMetrics(metrics []models.OpticalChannelMetric) error {
+	for i := range metrics {
+		key := bm.generateOpticalChannelKey(&metrics[i])
+		metricCopy := metrics[i]
+		bm.opticalChannelBuffer.Set(key, &metricCopy)
 	}
-	if new.Timestamp.After(existing.Timestamp) {
-		existing.Timestamp = new.Timestamp
+
+	atomic.AddInt64(&bm.stats.TotalRecordsProcessed, int64(len(metrics)))
+
+	if bm.overThreshold("optical_channel", bm.opticalChannelBuffer.Len()) {
+		bm.triggerFlush(bm.FlushOpticalChannelMetrics)
 	}
+
+	return nil
 }
 
+// mergePlatformMetric 合并同一聚合键的平台指标，见 mergeLatest
+func (bm *FixedBufferManager) mergePlatformMetric(existing, new *models.PlatformMetric) {
+	mergeLatest(existing, new, existing.Timestamp, new.Timestamp)
+}
+
+// mergeInterfaceMetric 合并同一聚合键的接口指标，见 mergeLatest
+func (bm *FixedBufferManager) mergeInterfaceMetric(existing, new *models.InterfaceMetric) {
+	mergeLatest(existing, new, existing.Timestamp, new.Timestamp)
+}
+
+// mergeSubinterfaceMetric 合并同一聚合键的子接口指标，见 mergeLatest
 func (bm *FixedBufferManager) mergeSubinterfaceMetric(existing, new *models.SubinterfaceMetric) {
-	if new.AdminStatusStr != nil {
-		existing.AdminStatusStr = new.AdminStatusStr
-	}
-	if new.OperStatusStr != nil {
-		existing.OperStatusStr = new.OperStatusStr
-	}
-	if new.InOctets != nil {
-		existing.InOctets = new.InOctets
-	}
-	if new.OutOctets != nil {
-		existing.OutOctets = new.OutOctets
-	}
-	if new.Timestamp.After(existing.Timestamp) {
-		existing.Timestamp = new.Timestamp
-	}
+	mergeLatest(existing, new, existing.Timestamp, new.Timestamp)
 }
 
 func (bm *FixedBufferManager) startParallelWriters() {
@@ -399,17 +555,28 @@ func (bm *FixedBufferManager) startParallelWriters() {
 	for i := 0; i < bm.writerConfig.ParallelWriters; i++ {
 		go bm.notificationReportWriter()
 	}
+	for i := 0; i < bm.writerConfig.ParallelWriters; i++ {
+		go bm.selfDefinedEventWriter()
+	}
+	for i := 0; i < bm.writerConfig.ParallelWriters; i++ {
+		go bm.genericWriter()
+	}
+	for i := 0; i < bm.writerConfig.ParallelWriters; i++ {
+		go bm.mappedRowWriter()
+	}
+	for i := 0; i < bm.writerConfig.ParallelWriters; i++ {
+		go bm.opticalChannelWriter()
+	}
 }
 
 func (bm *FixedBufferManager) platformWriter() {
 	for {
 		select {
 		case batch := <-bm.platformWriteChan:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{Platform: batch}, func() error {
 				return bm.db.BatchInsertPlatformMetrics(batch)
 			}); err != nil {
 				bm.logger.Errorf("平台指标写入失败: %v", err)
-				atomic.AddInt64(&bm.stats.TotalErrors, 1)
 			} else {
 				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
 			}
@@ -423,11 +590,10 @@ func (bm *FixedBufferManager) interfaceWriter() {
 	for {
 		select {
 		case batch := <-bm.interfaceWriteChan:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{Interface: batch}, func() error {
 				return bm.db.BatchInsertInterfaceMetrics(batch)
 			}); err != nil {
 				bm.logger.Errorf("接口指标写入失败: %v", err)
-				atomic.AddInt64(&bm.stats.TotalErrors, 1)
 			} else {
 				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
 			}
@@ -441,11 +607,10 @@ func (bm *FixedBufferManager) subinterfaceWriter() {
 	for {
 		select {
 		case batch := <-bm.subinterfaceWriteChan:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{Subinterface: batch}, func() error {
 				return bm.db.BatchInsertSubinterfaceMetrics(batch)
 			}); err != nil {
 				bm.logger.Errorf("子接口指标写入失败: %v", err)
-				atomic.AddInt64(&bm.stats.TotalErrors, 1)
 			} else {
 				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
 			}
@@ -459,11 +624,10 @@ func (bm *FixedBufferManager) alarmReportWriter() {
 	for {
 		select {
 		case batch := <-bm.alarmReportWriteChan:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{AlarmReport: batch}, func() error {
 				return bm.db.BatchInsertAlarmReportMetrics(batch)
 			}); err != nil {
 				bm.logger.Errorf("告警上报写入失败: %v", err)
-				atomic.AddInt64(&bm.stats.TotalErrors, 1)
 			} else {
 				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
 			}
@@ -477,11 +641,10 @@ func (bm *FixedBufferManager) notificationReportWriter() {
 	for {
 		select {
 		case batch := <-bm.notificationReportWriteChan:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{NotificationReport: batch}, func() error {
 				return bm.db.BatchInsertNotificationReportMetrics(batch)
 			}); err != nil {
 				bm.logger.Errorf("通知上报写入失败: %v", err)
-				atomic.AddInt64(&bm.stats.TotalErrors, 1)
 			} else {
 				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
 			}
@@ -491,6 +654,100 @@ func (bm *FixedBufferManager) notificationReportWriter() {
 	}
 }
 
+func (bm *FixedBufferManager) selfDefinedEventWriter() {
+	for {
+		select {
+		case batch := <-bm.selfDefinedEventWriteChan:
+			if err := bm.writeBatch(&wal.Entry{SelfDefinedEvent: batch}, func() error {
+				return bm.db.BatchInsertSelfDefinedEventMetrics(batch)
+			}); err != nil {
+				bm.logger.Errorf("自定义事件写入失败: %v", err)
+			} else {
+				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
+			}
+		case <-bm.stopChan:
+			return
+		}
+	}
+}
+
+func (bm *FixedBufferManager) genericWriter() {
+	for {
+		select {
+		case batch := <-bm.genericWriteChan:
+			if err := bm.writeBatch(&wal.Entry{Generic: batch}, func() error {
+				return bm.db.BatchInsertGenericMetrics(batch)
+			}); err != nil {
+				bm.logger.Errorf("通用指标写入失败: %v", err)
+			} else {
+				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
+			}
+		case <-bm.stopChan:
+			return
+		}
+	}
+}
+
+func (bm *FixedBufferManager) mappedRowWriter() {
+	for {
+		select {
+		case batch := <-bm.mappedRowWriteChan:
+			if err := bm.writeBatch(&wal.Entry{MappedRow: batch}, func() error {
+				return bm.db.BatchInsertMappedRows(batch)
+			}); err != nil {
+				bm.logger.Errorf("映射数据写入失败: %v", err)
+			} else {
+				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
+			}
+		case <-bm.stopChan:
+			return
+		}
+	}
+}
+
+func (bm *FixedBufferManager) opticalChannelWriter() {
+	for {
+		select {
+		case batch := <-bm.opticalChannelWriteChan:
+			if err := bm.writeBatch(&wal.Entry{OpticalChannel: batch}, func() error {
+				return bm.db.BatchInsertOpticalChannelMetrics(batch)
+			}); err != nil {
+				bm.logger.Errorf("光通道指标写入失败: %v", err)
+			} else {
+				atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(len(batch)))
+			}
+		case <-bm.stopChan:
+			return
+		}
+	}
+}
+
+// beginWrite 记录一个即将提交（写入通道或直接写库）的批次，由writeBatch结束
+func (bm *FixedBufferManager) beginWrite() {
+	atomic.AddInt64(&bm.writesInFlight, 1)
+}
+
+// writeBatch 带重试写入一个批次并结束beginWrite的跟踪。
+// 重试耗尽时按行数计入错误并转入死信队列，未能保存的批次计为写库失败（启用WAL时检查点保留对应的段）。
+func (bm *FixedBufferManager) writeBatch(e *wal.Entry, writeFunc func() error) error {
+	err := bm.writeWithRetry(writeFunc)
+	if err != nil {
+		atomic.AddInt64(&bm.stats.TotalErrors, int64(e.Rows()))
+		if !bm.deadLetter(e, err) {
+			atomic.AddInt64(&bm.failedWrites, 1)
+		}
+	}
+	bm.releaseBytes(e)
+	atomic.AddInt64(&bm.writesInFlight, -1)
+	return err
+}
+
+// trackFlush 刷新期间持有flushMu读锁，返回释放函数
+func (bm *FixedBufferManager) trackFlush() func() {
+	bm.flushMu.RLock()
+	return bm.flushMu.RUnlock
+}
+
 func (bm *FixedBufferManager) writeWithRetry(writeFunc func() error) error {
 	var lastErr error
 
@@ -530,9 +787,9 @@ func (bm *FixedBufferManager) FlushAll() error {
 	var errs []error
 
 	var wg sync.WaitGroup
-	errChan := make(chan error, 5)
+	errChan := make(chan error, 9)
 
-	wg.Add(5)
+	wg.Add(9)
 
 	go func() {
 		defer wg.Done()
@@ -569,6 +826,34 @@ func (bm *FixedBufferManager) FlushAll() error {
 		}
 	}()
 
+	go func() {
+		defer wg.Done()
+		if err := bm.FlushSelfDefinedEventMetrics(); err != nil {
+			errChan <- fmt.Errorf("自定义事件刷新失败: %v", err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		if err := bm.FlushGenericMetrics(); err != nil {
+			errChan <- fmt.Errorf("通用指标刷新失败: %v", err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		if err := bm.FlushMappedRows(); err != nil {
+			errChan <- fmt.Errorf("映射数据刷新失败: %v", err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		if err := bm.FlushOpticalChannelMetrics(); err != nil {
+			errChan <- fmt.Errorf("光通道指标刷新失败: %v", err)
+		}
+	}()
+
 	wg.Wait()
 	close(errChan)
 
@@ -589,132 +874,328 @@ func (bm *FixedBufferManager) FlushAll() error {
 }
 
 func (bm *FixedBufferManager) FlushPlatformMetrics() error {
-	metrics := bm.platformBuffer.SwapAll()
+	defer bm.trackFlush()()
+	return bm.writePlatformMetrics(bm.platformBuffer.SwapAll())
+}
+
+func (bm *FixedBufferManager) writePlatformMetrics(metrics []models.PlatformMetric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
+	restampRows(bm.aggregation["platform"], metrics, func(m *models.PlatformMetric) (*time.Time, string) { return &m.Timestamp, m.SystemID })
 
 	batchSize := bm.writerConfig.MaxBatchSize
+	// 某个批次失败时继续写入其余批次，失败的批次已进入死信队列或计入失败
+	var firstErr error
 	for i := 0; i < len(metrics); i += batchSize {
 		end := i + batchSize
 		if end > len(metrics) {
 			end = len(metrics)
 		}
 		batch := metrics[i:end]
+		bm.beginWrite()
 		select {
 		case bm.platformWriteChan <- batch:
 		default:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{Platform: batch}, func() error {
 				return bm.db.BatchInsertPlatformMetrics(batch)
-			}); err != nil {
-				return err
+			}); err != nil && firstErr == nil {
+				firstErr = err
 			}
 		}
 	}
-	return nil
+	return firstErr
 }
 
 func (bm *FixedBufferManager) FlushInterfaceMetrics() error {
-	metrics := bm.interfaceBuffer.SwapAll()
+	defer bm.trackFlush()()
+	return bm.writeInterfaceMetrics(bm.interfaceBuffer.SwapAll())
+}
+
+func (bm *FixedBufferManager) writeInterfaceMetrics(metrics []models.InterfaceMetric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
+	restampRows(bm.aggregation["interface"], metrics, func(m *models.InterfaceMetric) (*time.Time, string) { return &m.Timestamp, m.SystemID })
 
 	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
 	for i := 0; i < len(metrics); i += batchSize {
 		end := i + batchSize
 		if end > len(metrics) {
 			end = len(metrics)
 		}
 		batch := metrics[i:end]
+		bm.beginWrite()
 		select {
 		case bm.interfaceWriteChan <- batch:
 		default:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{Interface: batch}, func() error {
 				return bm.db.BatchInsertInterfaceMetrics(batch)
-			}); err != nil {
-				return err
+			}); err != nil && firstErr == nil {
+				firstErr = err
 			}
 		}
 	}
-	return nil
+	return firstErr
 }
 
 func (bm *FixedBufferManager) FlushSubinterfaceMetrics() error {
-	metrics := bm.subinterfaceBuffer.SwapAll()
+	defer bm.trackFlush()()
+	return bm.writeSubinterfaceMetrics(bm.subinterfaceBuffer.SwapAll())
+}
+
+func (bm *FixedBufferManager) writeSubinterfaceMetrics(metrics []models.SubinterfaceMetric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
+	restampRows(bm.aggregation["subinterface"], metrics, func(m *models.SubinterfaceMetric) (*time.Time, string) { return &m.Timestamp, m.SystemID })
 
 	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
 	for i := 0; i < len(metrics); i += batchSize {
 		end := i + batchSize
 		if end > len(metrics) {
 			end = len(metrics)
 		}
 		batch := metrics[i:end]
+		bm.beginWrite()
 		select {
 		case bm.subinterfaceWriteChan <- batch:
 		default:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{Subinterface: batch}, func() error {
 				return bm.db.BatchInsertSubinterfaceMetrics(batch)
-			}); err != nil {
-				return err
+			}); err != nil && firstErr == nil {
+				firstErr = err
 			}
 		}
 	}
-	return nil
+	return firstErr
 }
 
 func (bm *FixedBufferManager) FlushAlarmReportMetrics() error {
+	defer bm.trackFlush()()
 	metrics := bm.alarmReportBuffer.SwapAll()
 	if len(metrics) == 0 {
 		return nil
 	}
 
 	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
 	for i := 0; i < len(metrics); i += batchSize {
 		end := i + batchSize
 		if end > len(metrics) {
 			end = len(metrics)
 		}
 		batch := metrics[i:end]
+		bm.beginWrite()
 		select {
 		case bm.alarmReportWriteChan <- batch:
 		default:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{AlarmReport: batch}, func() error {
 				return bm.db.BatchInsertAlarmReportMetrics(batch)
-			}); err != nil {
-				return err
+			}); err != nil && firstErr == nil {
+				firstErr = err
 			}
 		}
 	}
-	return nil
+	return firstErr
 }
 
 func (bm *FixedBufferManager) FlushNotificationReportMetrics() error {
+	defer bm.trackFlush()()
 	metrics := bm.notificationReportBuffer.SwapAll()
 	if len(metrics) == 0 {
 		return nil
 	}
 
 	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
 	for i := 0; i < len(metrics); i += batchSize {
 		end := i + batchSize
 		if end > len(metrics) {
 			end = len(metrics)
 		}
 		batch := metrics[i:end]
+		bm.beginWrite()
 		select {
 		case bm.notificationReportWriteChan <- batch:
 		default:
-			if err := bm.writeWithRetry(func() error {
+			if err := bm.writeBatch(&wal.Entry{NotificationReport: batch}, func() error {
 				return bm.db.BatchInsertNotificationReportMetrics(batch)
-			}); err != nil {
-				return err
+			}); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (bm *FixedBufferManager) FlushSelfDefinedEventMetrics() error {
+	defer bm.trackFlush()()
+	metrics := bm.selfDefinedEventBuffer.SwapAll()
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
+	for i := 0; i < len(metrics); i += batchSize {
+		end := i + batchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		batch := metrics[i:end]
+		bm.beginWrite()
+		select {
+		case bm.selfDefinedEventWriteChan <- batch:
+		default:
+			if err := bm.writeBatch(&wal.Entry{SelfDefinedEvent: batch}, func() error {
+				return bm.db.BatchInsertSelfDefinedEventMetrics(batch)
+			}); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (bm *FixedBufferManager) FlushGenericMetrics() error {
+	defer bm.trackFlush()()
+	return bm.writeGenericMetrics(bm.genericBuffer.SwapAll())
+}
+
+func (bm *FixedBufferManager) writeGenericMetrics(metrics []models.GenericMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	restampRows(bm.aggregation["generic"], metrics, func(m *models.GenericMetric) (*time.Time, string) { return &m.Timestamp, m.SystemID })
+
+	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
+	for i := 0; i < len(metrics); i += batchSize {
+		end := i + batchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		batch := metrics[i:end]
+		bm.beginWrite()
+		select {
+		case bm.genericWriteChan <- batch:
+		default:
+			if err := bm.writeBatch(&wal.Entry{Generic: batch}, func() error {
+				return bm.db.BatchInsertGenericMetrics(batch)
+			}); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (bm *FixedBufferManager) FlushMappedRows() error {
+	defer bm.trackFlush()()
+	return bm.writeMappedRows(bm.mappedRowBuffer.SwapAll())
+}
+
+func (bm *FixedBufferManager) writeMappedRows(metrics []models.MappedRow) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
+	for i := 0; i < len(metrics); i += batchSize {
+		end := i + batchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		batch := metrics[i:end]
+		bm.beginWrite()
+		select {
+		case bm.mappedRowWriteChan <- batch:
+		default:
+			if err := bm.writeBatch(&wal.Entry{MappedRow: batch}, func() error {
+				return bm.db.BatchInsertMappedRows(batch)
+			}); err != nil && firstErr == nil {
+				firstErr = err
 			}
 		}
 	}
+	return firstErr
+}
+
+func (bm *FixedBufferManager) FlushOpticalChannelMetrics() error {
+	defer bm.trackFlush()()
+	return bm.writeOpticalChannelMetrics(bm.opticalChannelBuffer.SwapAll())
+}
+
+func (bm *FixedBufferManager) writeOpticalChannelMetrics(metrics []models.OpticalChannelMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+	restampRows(bm.aggregation["optical_channel"], metrics, func(m *models.OpticalChannelMetric) (*time.Time, string) { return &m.Timestamp, m.SystemID })
+
+	batchSize := bm.writerConfig.MaxBatchSize
+	var firstErr error
+	for i := 0; i < len(metrics); i += batchSize {
+		end := i + batchSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		batch := metrics[i:end]
+		bm.beginWrite()
+		select {
+		case bm.opticalChannelWriteChan <- batch:
+		default:
+			if err := bm.writeBatch(&wal.Entry{OpticalChannel: batch}, func() error {
+				return bm.db.BatchInsertOpticalChannelMetrics(batch)
+			}); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// FlushRound 立即写入某设备一个采样轮次（时间戳为轮次开始时间）的采样指标
+func (bm *FixedBufferManager) FlushRound(systemID string, start time.Time) error {
+	defer bm.trackFlush()()
+	var errs []error
+	if err := bm.writePlatformMetrics(bm.platformBuffer.SwapMatching(func(m *models.PlatformMetric) bool {
+		return m.SystemID == systemID && m.Timestamp.Equal(start)
+	})); err != nil {
+		errs = append(errs, fmt.Errorf("平台指标刷新失败: %v", err))
+	}
+	if err := bm.writeOpticalChannelMetrics(bm.opticalChannelBuffer.SwapMatching(func(m *models.OpticalChannelMetric) bool {
+		return m.SystemID == systemID && m.Timestamp.Equal(start)
+	})); err != nil {
+		errs = append(errs, fmt.Errorf("光通道指标刷新失败: %v", err))
+	}
+	if err := bm.writeInterfaceMetrics(bm.interfaceBuffer.SwapMatching(func(m *models.InterfaceMetric) bool {
+		return m.SystemID == systemID && m.Timestamp.Equal(start)
+	})); err != nil {
+		errs = append(errs, fmt.Errorf("接口指标刷新失败: %v", err))
+	}
+	if err := bm.writeSubinterfaceMetrics(bm.subinterfaceBuffer.SwapMatching(func(m *models.SubinterfaceMetric) bool {
+		return m.SystemID == systemID && m.Timestamp.Equal(start)
+	})); err != nil {
+		errs = append(errs, fmt.Errorf("子接口指标刷新失败: %v", err))
+	}
+	if err := bm.writeGenericMetrics(bm.genericBuffer.SwapMatching(func(m *models.GenericMetric) bool {
+		return m.SystemID == systemID && m.Timestamp.Equal(start)
+	})); err != nil {
+		errs = append(errs, fmt.Errorf("通用指标刷新失败: %v", err))
+	}
+	if err := bm.writeMappedRows(bm.mappedRowBuffer.SwapMatching(func(m *models.MappedRow) bool {
+		return m.SystemID == systemID && m.Timestamp.Equal(start)
+	})); err != nil {
+		errs = append(errs, fmt.Errorf("映射数据刷新失败: %v", err))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("采样轮次刷新失败: %v", errs)
+	}
 	return nil
 }
 
@@ -726,7 +1207,7 @@ func (bm *FixedBufferManager) startFlushTimer() {
 			select {
 			case <-bm.flushTimer.C:
 				bm.logger.Debug("定时刷新缓冲区")
-				bm.FlushAll()
+				bm.flushDue()
 				bm.flushTimer.Reset(bm.config.FlushInterval)
 			case <-bm.stopChan:
 				bm.flushTimer.Stop()
@@ -741,12 +1222,40 @@ func (bm *FixedBufferManager) Stop() error {
 	case <-bm.stopChan:
 		return nil
 	default:
-		close(bm.stopChan)
 	}
+	// 写入协程退出前做最后一次检查点
+	bm.stopWAL()
+	close(bm.stopChan)
 
 	return bm.FlushAll()
 }
 
+// BufferedRecords 各缓冲区当前记录总数
+func (bm *FixedBufferManager) BufferedRecords() int {
+	return bm.platformBuffer.Len() +
+		bm.interfaceBuffer.Len() +
+		bm.subinterfaceBuffer.Len() +
+		bm.alarmReportBuffer.Len() +
+		bm.notificationReportBuffer.Len() +
+		bm.selfDefinedEventBuffer.Len() +
+		bm.genericBuffer.Len() +
+		bm.mappedRowBuffer.Len() +
+		bm.opticalChannelBuffer.Len()
+}
+
+// PendingWrites 已刷新但仍在写入队列中等待写库的批次数
+func (bm *FixedBufferManager) PendingWrites() int {
+	return len(bm.platformWriteChan) +
+		len(bm.interfaceWriteChan) +
+		len(bm.subinterfaceWriteChan) +
+		len(bm.alarmReportWriteChan) +
+		len(bm.notificationReportWriteChan) +
+		len(bm.selfDefinedEventWriteChan) +
+		len(bm.genericWriteChan) +
+		len(bm.mappedRowWriteChan) +
+		len(bm.opticalChannelWriteChan)
+}
+
 func (bm *FixedBufferManager) GetStats() FixedBufferStats {
 	bm.statsMutex.RLock()
 	stats := bm.stats
@@ -757,6 +1266,13 @@ func (bm *FixedBufferManager) GetStats() FixedBufferStats {
 	stats.SubinterfaceBufferSize = bm.subinterfaceBuffer.Len()
 	stats.AlarmReportBufferSize = bm.alarmReportBuffer.Len()
 	stats.NotificationReportBufferSize = bm.notificationReportBuffer.Len()
+	stats.SelfDefinedEventBufferSize = bm.selfDefinedEventBuffer.Len()
+	stats.GenericBufferSize = bm.genericBuffer.Len()
+	stats.MappedRowBufferSize = bm.mappedRowBuffer.Len()
+	stats.OpticalChannelBufferSize = bm.opticalChannelBuffer.Len()
+	stats.WAL = bm.GetWALStats()
+	stats.DeadLetter = bm.GetDeadLetterStats()
+	stats.Memory = bm.GetMemoryStats()
 
 	return stats
 }
@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"errors"
 	"strings"
 	"sync"
 	"testing"
@@ -20,6 +21,10 @@ func newTestBufferManager() *FixedBufferManager {
 		subinterfaceBuffer:       newShardedSubinterfaceMap(),
 		alarmReportBuffer:        newShardedAlarmMap(),
 		notificationReportBuffer: newShardedNotificationMap(),
+		selfDefinedEventBuffer:   newShardedSelfDefinedEventMap(),
+		genericBuffer:            newShardedGenericMap(),
+		mappedRowBuffer:          newShardedMappedRowMap(),
+		opticalChannelBuffer:     newShardedOpticalChannelMap(),
 		keyBuf: sync.Pool{
 			New: func() interface{} { return &keyBuffer{buf: make([]byte, 0, 128)} },
 		},
@@ -109,6 +114,79 @@ func TestGenerateNotificationKey(t *testing.T) {
 	}
 }
 
+func TestGenerateSelfDefinedEventKey(t *testing.T) {
+	bm := newTestBufferManager()
+	instance := "interface[name=gei-1/2/1]"
+	metric := &models.SelfDefinedEventMetric{
+		Timestamp:  time.UnixMilli(1700000000123),
+		SystemID:   "dev-6",
+		SensorPath: "oc-if:interfaces/interface/state/counters",
+		Instance:   &instance,
+		Level:      3,
+	}
+
+	key := bm.generateSelfDefinedEventKey(metric)
+	expected := "dev-6:1700000000123:oc-if:interfaces/interface/state/counters:interface[name=gei-1/2/1]:3"
+	if key != expected {
+		t.Fatalf("expected %s, got %s", expected, key)
+	}
+
+	metric.Instance = nil
+	if key := bm.generateSelfDefinedEventKey(metric); !strings.HasSuffix(key, "::3") {
+		t.Fatalf("unexpected key without instance: %s", key)
+	}
+}
+
+func TestGenerateGenericKey(t *testing.T) {
+	bm := newTestBufferManager()
+	keys := `{"name":"gei-1/2/1"}`
+	metric := &models.GenericMetric{
+		Timestamp:    time.Unix(1700000000, 500000000),
+		SystemID:     "dev-7",
+		SensorPath:   "zte-if:custom",
+		InstanceKeys: &keys,
+		Field:        "counters/in_octets",
+	}
+
+	key := bm.generateGenericKey(metric)
+	expected := `1700000000_dev-7_zte-if:custom_{"name":"gei-1/2/1"}_counters/in_octets`
+	if key != expected {
+		t.Fatalf("expected %s, got %s", expected, key)
+	}
+}
+
+func TestGenerateMappedRowKey(t *testing.T) {
+	bm := newTestBufferManager()
+	row := &models.MappedRow{
+		Timestamp: time.Unix(1700000000, 500000000),
+		SystemID:  "dev-7",
+		Table:     "qos_queues",
+		Key:       "gei-1/2/1|3",
+	}
+
+	key := bm.generateMappedRowKey(row)
+	expected := "1700000000500_dev-7_qos_queues_gei-1/2/1|3"
+	if key != expected {
+		t.Fatalf("expected %s, got %s", expected, key)
+	}
+}
+
+func TestGenerateOpticalChannelKey(t *testing.T) {
+	bm := newTestBufferManager()
+	metric := &models.OpticalChannelMetric{
+		Timestamp:     time.Unix(1700000000, 500000000),
+		SystemID:      "dev-7",
+		ComponentName: "optical-1/2/1",
+		ChannelIndex:  4,
+	}
+
+	key := bm.generateOpticalChannelKey(metric)
+	expected := "1700000000_dev-7_optical-1/2/1_4"
+	if key != expected {
+		t.Fatalf("expected %s, got %s", expected, key)
+	}
+}
+
 func TestMergePlatformMetric(t *testing.T) {
 	bm := newTestBufferManager()
 
@@ -345,3 +423,60 @@ func TestGetStats(t *testing.T) {
 		t.Fatalf("expected interface=1, got %d", stats.InterfaceBufferSize)
 	}
 }
+
+func TestMergeInterfaceMetric_CounterRates(t *testing.T) {
+	bm := newTestBufferManager()
+	inRate, outRate, newInRate := 1.0, 2.0, 3.0
+
+	existing := &models.InterfaceMetric{
+		CounterRates: &models.CounterRates{InOctetsRate: &inRate, OutOctetsRate: &outRate},
+	}
+	bm.mergeInterfaceMetric(existing, &models.InterfaceMetric{
+		CounterRates: &models.CounterRates{InOctetsRate: &newInRate},
+	})
+
+	if *existing.InOctetsRate != 3 || *existing.OutOctetsRate != 2 {
+		t.Fatalf("rates = in %v out %v, want 3/2", *existing.InOctetsRate, *existing.OutOctetsRate)
+	}
+
+	empty := &models.InterfaceMetric{}
+	bm.mergeInterfaceMetric(empty, existing)
+	if empty.CounterRates == nil || *empty.InOctetsRate != 3 {
+		t.Fatal("expected rates copied into empty metric")
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	bm := newTestBufferManager()
+	bm.config.OverflowPolicy = OverflowDropNewest
+	bm.platformBuffer.Set("p1", &models.PlatformMetric{})
+	bm.interfaceBuffer.Set("i1", &models.InterfaceMetric{})
+	rows := map[string]int{"platform": 1, "interface": 2}
+
+	// 未配置上限时不限制
+	if err := bm.CheckBudget(rows); err != nil {
+		t.Fatalf("CheckBudget without limit = %v", err)
+	}
+
+	bm.config.MaxSize = 3
+	if err := bm.CheckBudget(rows); err != nil || bm.OverBudget() != "" {
+		t.Fatalf("CheckBudget under limit = %v, OverBudget = %q", err, bm.OverBudget())
+	}
+
+	// 告警计入合计记录数，但不受上限约束
+	bm.alarmReportBuffer.Set("a1", &models.AlarmReportMetric{})
+	err := bm.CheckBudget(rows)
+	var oe *OverflowError
+	if !errors.Is(err, ErrBufferFull) || !errors.As(err, &oe) || oe.Buffer != "platform" || oe.Rows != 1 {
+		t.Fatalf("CheckBudget at limit = %v, want platform OverflowError", err)
+	}
+	if bm.OverBudget() == "" {
+		t.Error("OverBudget() empty at limit")
+	}
+	if err := bm.CheckBudget(map[string]int{"alarm_report": 1}); err != nil {
+		t.Errorf("CheckBudget for alarms = %v", err)
+	}
+	if bm.BufferedRecords() != 3 {
+		t.Errorf("BufferedRecords = %d, want 3", bm.BufferedRecords())
+	}
+}
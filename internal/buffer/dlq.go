@@ -0,0 +1,133 @@
+package buffer
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/dlq"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+// ErrDeadLetterDisabled 未启用死信队列
+var ErrDeadLetterDisabled = errors.New("dead-letter queue not enabled")
+
+// DeadLetterStats 死信队列统计
+type DeadLetterStats struct {
+	Enabled bool
+	// Tables 目录中各表的批次数、行数与大小
+	Tables map[string]dlq.TableStats
+	// StoredRows 重试耗尽后写入死信队列的行数
+	StoredRows int64
+	// DroppedRows 重试耗尽且未能保存（未启用或写入失败）的行数
+	DroppedRows int64
+	// RedrivenRows 从死信队列重新写库成功的行数
+	RedrivenRows int64
+}
+
+// EnableDeadLetter 打开死信目录，之后重试耗尽的批次写入死信队列
+func (bm *FixedBufferManager) EnableDeadLetter(cfg config.DeadLetterConfig) error {
+	store, err := dlq.Open(cfg.Dir, cfg.MaxSize)
+	if err != nil {
+		return err
+	}
+	bm.dlq = store
+	if stats, err := store.Stats(); err == nil {
+		var batches, rows int64
+		for _, st := range stats {
+			batches += st.Batches
+			rows += st.Rows
+		}
+		if batches > 0 {
+			bm.logger.Warnf("死信队列中有 %d 个批次（%d 行）待处理: %s", batches, rows, cfg.Dir)
+		}
+	}
+	return nil
+}
+
+// deadLetter 保存重试耗尽的批次，返回是否已保存
+func (bm *FixedBufferManager) deadLetter(e *wal.Entry, cause error) bool {
+	rows := int64(e.Rows())
+	if bm.dlq == nil {
+		atomic.AddInt64(&bm.dlqDropped, rows)
+		bm.logger.Errorf("%s 批次写库失败，丢弃 %d 行: %v", e.Table(), rows, cause)
+		return false
+	}
+	b, err := bm.dlq.Put(e, cause.Error(), time.Now())
+	if err != nil {
+		atomic.AddInt64(&bm.dlqDropped, rows)
+		bm.logger.WithError(err).Errorf("%s 批次写入死信队列失败，丢弃 %d 行（写库错误: %v）", e.Table(), rows, cause)
+		return false
+	}
+	atomic.AddInt64(&bm.dlqStored, rows)
+	bm.logger.Warnf("%s 批次写库失败，%d 行已写入死信队列: id=%s", e.Table(), rows, b.ID)
+	return true
+}
+
+// GetDeadLetterStats 获取死信队列统计，未启用时Tables为空
+func (bm *FixedBufferManager) GetDeadLetterStats() DeadLetterStats {
+	stats := DeadLetterStats{
+		Enabled:      bm.dlq != nil,
+		StoredRows:   atomic.LoadInt64(&bm.dlqStored),
+		DroppedRows:  atomic.LoadInt64(&bm.dlqDropped),
+		RedrivenRows: atomic.LoadInt64(&bm.dlqRedriven),
+	}
+	if bm.dlq != nil {
+		tables, err := bm.dlq.Stats()
+		if err != nil {
+			bm.logger.WithError(err).Warn("读取死信队列统计失败")
+		}
+		stats.Tables = tables
+	}
+	return stats
+}
+
+// ListDeadLetters 按失败时间列出死信批次，table为空时列出所有表
+func (bm *FixedBufferManager) ListDeadLetters(table string) ([]dlq.Batch, error) {
+	if bm.dlq == nil {
+		return nil, ErrDeadLetterDisabled
+	}
+	return bm.dlq.List(table)
+}
+
+// GetDeadLetter 读取死信批次及其数据
+func (bm *FixedBufferManager) GetDeadLetter(id string) (dlq.Batch, *wal.Entry, error) {
+	if bm.dlq == nil {
+		return dlq.Batch{}, nil, ErrDeadLetterDisabled
+	}
+	return bm.dlq.Get(id)
+}
+
+// RedriveDeadLetter 重新写库（带重试），成功后从死信队列删除
+func (bm *FixedBufferManager) RedriveDeadLetter(id string) (dlq.Batch, error) {
+	if bm.dlq == nil {
+		return dlq.Batch{}, ErrDeadLetterDisabled
+	}
+	b, err := bm.dlq.Redrive(id, func(e *wal.Entry) error {
+		return bm.writeWithRetry(func() error { return dlq.Insert(bm.db, e) })
+	})
+	if err != nil {
+		return b, err
+	}
+	atomic.AddInt64(&bm.dlqRedriven, int64(b.Rows))
+	atomic.AddInt64(&bm.stats.TotalRecordsWritten, int64(b.Rows))
+	bm.logger.Infof("死信批次重新写库成功: id=%s, %d 行", b.ID, b.Rows)
+	return b, nil
+}
+
+// PurgeDeadLetters 删除失败时间早于before的死信批次，table为空时所有表，before为零值时不限时间
+func (bm *FixedBufferManager) PurgeDeadLetters(table string, before time.Time) ([]dlq.Batch, error) {
+	if bm.dlq == nil {
+		return nil, ErrDeadLetterDisabled
+	}
+	purged, err := bm.dlq.Purge(table, before)
+	if len(purged) > 0 {
+		var rows int
+		for _, b := range purged {
+			rows += b.Rows
+		}
+		bm.logger.Warnf("清除死信批次 %d 个（%d 行）", len(purged), rows)
+	}
+	return purged, err
+}
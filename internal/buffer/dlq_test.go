@@ -0,0 +1,72 @@
+package buffer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/dlq"
+)
+
+func TestDeadLetterFailedBatch(t *testing.T) {
+	db := &fakeDB{}
+	db.fail.Store(true)
+	bm := newWALTestManager(t, db, t.TempDir(), time.Hour)
+	defer bm.Stop()
+	if err := bm.EnableDeadLetter(config.DeadLetterConfig{Dir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 4)); err != nil {
+		t.Fatal(err)
+	}
+	bm.FlushAll()
+	waitFor(t, "dead-lettered batch", func() bool { return bm.GetDeadLetterStats().StoredRows == 4 })
+
+	stats := bm.GetStats()
+	if stats.TotalErrors != 4 {
+		t.Errorf("TotalErrors = %d, want 4 (rows, not batches)", stats.TotalErrors)
+	}
+	if st := stats.DeadLetter.Tables["platform"]; st.Batches != 1 || st.Rows != 4 {
+		t.Errorf("dead letter stats = %+v", stats.DeadLetter)
+	}
+
+	list, err := bm.ListDeadLetters("")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListDeadLetters() = %v, %v", list, err)
+	}
+	if _, err := bm.RedriveDeadLetter(list[0].ID); err == nil {
+		t.Fatal("redrive succeeded while database is down")
+	}
+
+	db.fail.Store(false)
+	if _, err := bm.RedriveDeadLetter(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if db.written() != 4 {
+		t.Errorf("redriven rows = %d, want 4", db.written())
+	}
+	if got := bm.GetDeadLetterStats(); got.RedrivenRows != 4 || len(got.Tables) != 0 {
+		t.Errorf("stats after redrive = %+v", got)
+	}
+	if _, err := bm.RedriveDeadLetter(list[0].ID); !errors.Is(err, dlq.ErrNotFound) {
+		t.Errorf("second redrive err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeadLetterDisabled(t *testing.T) {
+	db := &fakeDB{}
+	db.fail.Store(true)
+	bm := newWALTestManager(t, db, t.TempDir(), time.Hour)
+	defer bm.Stop()
+
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 2)); err != nil {
+		t.Fatal(err)
+	}
+	bm.FlushAll()
+	waitFor(t, "dropped rows", func() bool { return bm.GetDeadLetterStats().DroppedRows == 2 })
+	if _, err := bm.ListDeadLetters(""); !errors.Is(err, ErrDeadLetterDisabled) {
+		t.Errorf("ListDeadLetters err = %v, want ErrDeadLetterDisabled", err)
+	}
+}
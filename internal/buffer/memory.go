@@ -0,0 +1,606 @@
+package buffer
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+// 缓冲区内存预算
+//
+// 每个缓冲区按记录估算占用的字节数（结构体、指针指向的值、字符串与切片内容），
+// 刷新取出的批次在写库完成（成功或转入死信队列）前仍计入，因此数据库变慢时积压的批次同样受限。
+// 写入前检查三类上限：
+//   - 平台/接口/子接口缓冲区的记录数（buffer.*_buffer_size）
+//   - 所有缓冲区合计记录数（buffer.max_size）
+//   - 所有缓冲区合计字节数（memory.max_memory_usage）
+//
+// 超出时按 buffer.overflow_policy 处理本次写入。告警、通知与自定义事件不受上限约束，也不会被淘汰，
+// 但其记录数与字节数计入合计。
+
+// 缓冲区超出上限时的处理策略（buffer.overflow_policy）
+const (
+	// OverflowBlock 触发刷新并阻塞写入方，直到回落或超过block_timeout后拒绝
+	OverflowBlock = "block"
+	// OverflowDropOldest 淘汰缓冲区中最旧的记录（腾出10%余量），仍超出时拒绝
+	OverflowDropOldest = "drop_oldest"
+	// OverflowDropNewest 拒绝本次写入
+	OverflowDropNewest = "drop_newest"
+	// OverflowSpill 本次写入只写WAL，数据库写入跟上后载入，需启用WAL
+	OverflowSpill = "spill"
+)
+
+// 超出上限时实际采取的处理，用于OverflowError与统计
+const (
+	ActionBlocked = "blocked" // 等待后写入
+	ActionTimeout = "timeout" // 等待超时，拒绝
+	ActionDropped = "dropped" // 拒绝（丢弃最新数据）
+	ActionEvicted = "evicted" // 淘汰的旧记录
+	ActionSpilled = "spilled" // 只写入WAL
+)
+
+// overflowPollInterval block策略下检查是否回落的间隔
+const overflowPollInterval = 10 * time.Millisecond
+
+// bufferTables 所有缓冲区的类型名，与wal.Entry.Table一致
+var bufferTables = []string{
+	"platform", "interface", "subinterface", "alarm_report", "notification_report",
+	"self_defined_event", "generic", "mapped_row", "optical_channel",
+}
+
+// OverflowError 写入因超出上限被拒绝，错误文本经Publish响应返回给设备
+type OverflowError struct {
+	Buffer string
+	Action string
+	Rows   int
+	// Limit 超出的上限说明
+	Limit string
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("%v: %s; %d %s rows rejected (%s)", ErrBufferFull, e.Limit, e.Rows, e.Buffer, e.Action)
+}
+
+func (e *OverflowError) Unwrap() error { return ErrBufferFull }
+
+// OverflowKey 超出上限统计维度
+type OverflowKey struct {
+	Buffer string
+	Action string
+}
+
+// MemoryStats 缓冲区内存预算统计
+type MemoryStats struct {
+	// Limit 合计字节数上限，0为不限制
+	Limit int64
+	// Bytes 各缓冲区估算字节数（含等待写库的批次）
+	Bytes map[string]int64
+	// Overflow 按缓冲区与处理统计的行数（累计值）
+	Overflow map[OverflowKey]int64
+}
+
+// meteredBuffer 统计估算字节数的缓冲区
+type meteredBuffer interface {
+	Len() int
+	Bytes() int64
+	addBytes(n int64)
+}
+
+// evictableBuffer 可按时间淘汰旧记录的缓冲区，告警、通知与自定义事件缓冲区不实现
+type evictableBuffer interface {
+	meteredBuffer
+	EvictOldest(minRows int, minBytes int64) (int, int64)
+}
+
+// EnableMemoryBudget 校验溢出策略并设置合计字节数上限，spill策略需在EnableWAL之后调用
+func (bm *FixedBufferManager) EnableMemoryBudget(cfg config.MemoryConfig) error {
+	switch bm.config.OverflowPolicy {
+	case "", OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	case OverflowSpill:
+		if bm.wal == nil {
+			return fmt.Errorf("overflow_policy为spill时需要启用recovery.enable_data_persistence")
+		}
+	default:
+		return fmt.Errorf("不支持的overflow_policy: %s（可选 block/drop_oldest/drop_newest/spill）", bm.config.OverflowPolicy)
+	}
+	limit, err := parseByteSize(cfg.MaxMemoryUsage)
+	if err != nil {
+		return fmt.Errorf("max_memory_usage格式错误: %v", err)
+	}
+	bm.memoryLimit = limit
+	return nil
+}
+
+// buffer 按类型名返回缓冲区
+func (bm *FixedBufferManager) buffer(table string) meteredBuffer {
+	switch table {
+	case "platform":
+		return bm.platformBuffer
+	case "interface":
+		return bm.interfaceBuffer
+	case "subinterface":
+		return bm.subinterfaceBuffer
+	case "alarm_report":
+		return bm.alarmReportBuffer
+	case "notification_report":
+		return bm.notificationReportBuffer
+	case "self_defined_event":
+		return bm.selfDefinedEventBuffer
+	case "generic":
+		return bm.genericBuffer
+	case "mapped_row":
+		return bm.mappedRowBuffer
+	case "optical_channel":
+		return bm.opticalChannelBuffer
+	}
+	return nil
+}
+
+// recordLimit 缓冲区记录数上限，0为不限制
+func (bm *FixedBufferManager) recordLimit(table string) int {
+	switch table {
+	case "platform":
+		return bm.config.PlatformBufferSize
+	case "interface":
+		return bm.config.InterfaceBufferSize
+	case "subinterface":
+		return bm.config.SubinterfaceBufferSize
+	}
+	return 0
+}
+
+// flushFunc 缓冲区的刷新函数
+func (bm *FixedBufferManager) flushFunc(table string) func() error {
+	switch table {
+	case "platform":
+		return bm.FlushPlatformMetrics
+	case "interface":
+		return bm.FlushInterfaceMetrics
+	case "subinterface":
+		return bm.FlushSubinterfaceMetrics
+	case "alarm_report":
+		return bm.FlushAlarmReportMetrics
+	case "notification_report":
+		return bm.FlushNotificationReportMetrics
+	case "self_defined_event":
+		return bm.FlushSelfDefinedEventMetrics
+	case "generic":
+		return bm.FlushGenericMetrics
+	case "mapped_row":
+		return bm.FlushMappedRows
+	case "optical_channel":
+		return bm.FlushOpticalChannelMetrics
+	}
+	return bm.FlushAll
+}
+
+// memoryUsed 所有缓冲区合计估算字节数
+func (bm *FixedBufferManager) memoryUsed() int64 {
+	var used int64
+	for _, table := range bufferTables {
+		used += bm.buffer(table).Bytes()
+	}
+	return used
+}
+
+// overLimit 写入table前检查上限，超出时返回说明，否则返回空串
+func (bm *FixedBufferManager) overLimit(table string) string {
+	if limit := bm.recordLimit(table); limit > 0 {
+		if n := bm.buffer(table).Len(); n >= limit {
+			return fmt.Sprintf("%s buffer holds %d records, limit %d", table, n, limit)
+		}
+	}
+	if limit := bm.config.MaxSize; limit > 0 {
+		if n := bm.BufferedRecords(); n >= limit {
+			return fmt.Sprintf("buffers hold %d records, max_size %d", n, limit)
+		}
+	}
+	if bm.memoryLimit > 0 {
+		if used := bm.memoryUsed(); used >= bm.memoryLimit {
+			return fmt.Sprintf("buffered data uses %s, memory limit %s", formatBytes(used), formatBytes(bm.memoryLimit))
+		}
+	}
+	return ""
+}
+
+// admit 按上限与溢出策略决定如何接纳一条写入，spill为true时只写WAL
+func (bm *FixedBufferManager) admit(e *wal.Entry) (spill bool, err error) {
+	return bm.admitRows(e.Table(), e.Rows())
+}
+
+// CheckBudget 按与写入相同的上限与溢出策略，检查rows（缓冲区类型名→行数）能否全部写入，
+// 任一缓冲区被拒绝时返回包装了ErrBufferFull的OverflowError。
+// 一条报文的各缓冲区先整体检查再写入，避免部分写入后报文被拒绝、设备重传时重复写入；
+// block与drop_oldest策略在检查时即等待或淘汰，spill策略总是接纳
+func (bm *FixedBufferManager) CheckBudget(rows map[string]int) error {
+	if bm.config.OverflowPolicy == OverflowSpill && bm.wal != nil {
+		return nil
+	}
+	for _, table := range bufferTables {
+		if n := rows[table]; n > 0 {
+			if _, err := bm.admitRows(table, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// OverBudget 任一受上限约束的缓冲区超出上限时返回说明，否则返回空串，WAL溢出模式下总是返回空串
+func (bm *FixedBufferManager) OverBudget() string {
+	if bm.spilling() {
+		return ""
+	}
+	for _, table := range bufferTables {
+		if _, ok := bm.buffer(table).(evictableBuffer); ok {
+			if limit := bm.overLimit(table); limit != "" {
+				return limit
+			}
+		}
+	}
+	return ""
+}
+
+// admitRows 按上限与溢出策略决定如何接纳table的rows行写入
+func (bm *FixedBufferManager) admitRows(table string, rows int) (spill bool, err error) {
+	if _, ok := bm.buffer(table).(evictableBuffer); !ok || bm.spilling() {
+		// 告警、通知与自定义事件总是写入；WAL溢出模式下数据本就不进缓冲区
+		return false, nil
+	}
+	limit := bm.overLimit(table)
+	if limit == "" {
+		return false, nil
+	}
+
+	switch bm.config.OverflowPolicy {
+	case OverflowDropOldest:
+		bm.evict(table)
+		if limit = bm.overLimit(table); limit == "" {
+			return false, nil
+		}
+		// 等待写库的批次无法淘汰，仍超出时拒绝
+	case OverflowSpill:
+		if bm.wal != nil {
+			bm.countOverflow(table, ActionSpilled, rows)
+			return true, nil
+		}
+	case OverflowDropNewest:
+	default:
+		if limit = bm.waitForRoom(table); limit == "" {
+			bm.countOverflow(table, ActionBlocked, rows)
+			return false, nil
+		}
+		bm.countOverflow(table, ActionTimeout, rows)
+		return false, &OverflowError{Buffer: table, Action: ActionTimeout, Rows: rows, Limit: limit}
+	}
+	bm.countOverflow(table, ActionDropped, rows)
+	return false, &OverflowError{Buffer: table, Action: ActionDropped, Rows: rows, Limit: limit}
+}
+
+// waitForRoom block策略：触发刷新并等待回落，超过block_timeout或停止时返回仍超出的上限说明
+func (bm *FixedBufferManager) waitForRoom(table string) string {
+	flush := bm.FlushAll
+	if limit := bm.recordLimit(table); limit > 0 && bm.buffer(table).Len() >= limit {
+		flush = bm.flushFunc(table)
+	}
+	// 多个写入方同时等待时只保留一个进行中的刷新
+	bm.triggerFlush(func() error {
+		if !bm.overflowFlushing.CompareAndSwap(false, true) {
+			return nil
+		}
+		defer bm.overflowFlushing.Store(false)
+		return flush()
+	})
+
+	deadline := time.NewTimer(bm.config.BlockTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(overflowPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline.C:
+			return bm.overLimit(table)
+		case <-bm.stopChan:
+			return bm.overLimit(table)
+		case <-ticker.C:
+			if limit := bm.overLimit(table); limit == "" {
+				return ""
+			}
+		}
+	}
+}
+
+// evict drop_oldest策略：记录数超限时淘汰该缓冲区最旧的记录，
+// 合计记录数或字节数超限时从占用最多的缓冲区开始淘汰，均腾出上限的10%余量
+func (bm *FixedBufferManager) evict(table string) {
+	if limit := bm.recordLimit(table); limit > 0 {
+		if n := bm.buffer(table).Len(); n >= limit {
+			rows, _ := bm.buffer(table).(evictableBuffer).EvictOldest(n-limit*9/10, 0)
+			bm.countEvicted(table, rows)
+		}
+	}
+	if limit := bm.config.MaxSize; limit > 0 {
+		if need := bm.BufferedRecords() - limit*9/10; need > 0 {
+			bm.evictLargest(int64(need), true)
+		}
+	}
+	if bm.memoryLimit > 0 {
+		if need := bm.memoryUsed() - bm.memoryLimit*9/10; need > 0 {
+			bm.evictLargest(need, false)
+		}
+	}
+}
+
+// evictLargest 从占用最多的缓冲区开始淘汰最旧的记录，直到腾出need条记录（byRows）或need字节
+func (bm *FixedBufferManager) evictLargest(need int64, byRows bool) {
+	type victim struct {
+		table string
+		buf   evictableBuffer
+		size  int64
+	}
+	var victims []victim
+	for _, name := range bufferTables {
+		if b, ok := bm.buffer(name).(evictableBuffer); ok && b.Len() > 0 {
+			size := b.Bytes()
+			if byRows {
+				size = int64(b.Len())
+			}
+			victims = append(victims, victim{table: name, buf: b, size: size})
+		}
+	}
+	sort.Slice(victims, func(i, j int) bool { return victims[i].size > victims[j].size })
+	for _, v := range victims {
+		if need <= 0 {
+			break
+		}
+		if byRows {
+			rows, _ := v.buf.EvictOldest(int(need), 0)
+			need -= int64(rows)
+			bm.countEvicted(v.table, rows)
+			continue
+		}
+		rows, freed := v.buf.EvictOldest(0, need)
+		need -= freed
+		bm.countEvicted(v.table, rows)
+	}
+}
+
+func (bm *FixedBufferManager) countEvicted(table string, rows int) {
+	if rows == 0 {
+		return
+	}
+	bm.countOverflow(table, ActionEvicted, rows)
+	bm.logger.Warnf("缓冲区超出上限，淘汰 %s 最旧的 %d 条记录", table, rows)
+}
+
+func (bm *FixedBufferManager) countOverflow(table, action string, rows int) {
+	bm.overflowMu.Lock()
+	if bm.overflow == nil {
+		bm.overflow = make(map[OverflowKey]int64)
+	}
+	bm.overflow[OverflowKey{Buffer: table, Action: action}] += int64(rows)
+	bm.overflowMu.Unlock()
+}
+
+// releaseBytes 批次写库结束（成功、转入死信队列或丢弃）后扣除其字节数
+func (bm *FixedBufferManager) releaseBytes(e *wal.Entry) {
+	if b := bm.buffer(e.Table()); b != nil {
+		b.addBytes(-entryBytes(e))
+	}
+}
+
+// GetMemoryStats 获取缓冲区内存预算统计
+func (bm *FixedBufferManager) GetMemoryStats() MemoryStats {
+	stats := MemoryStats{
+		Limit:    bm.memoryLimit,
+		Bytes:    make(map[string]int64, len(bufferTables)),
+		Overflow: make(map[OverflowKey]int64),
+	}
+	for _, table := range bufferTables {
+		stats.Bytes[table] = bm.buffer(table).Bytes()
+	}
+	bm.overflowMu.Lock()
+	for k, n := range bm.overflow {
+		stats.Overflow[k] = n
+	}
+	bm.overflowMu.Unlock()
+	return stats
+}
+
+// evictOldest 在各分片中按时间戳从旧到新删除记录，返回删除数与释放的字节数。
+// items需在持有分片锁时调用：快照之后分片的map可能已被SwapAll替换。
+func evictOldest[T any](shards int, lock func(int) *sync.RWMutex, items func(int) map[string]*T,
+	timestamp func(*T) time.Time, bytes *atomic.Int64, minRows int, minBytes int64) (int, int64) {
+	type candidate struct {
+		shard int
+		key   string
+		ts    time.Time
+	}
+	var candidates []candidate
+	for i := 0; i < shards; i++ {
+		lock(i).RLock()
+		for k, v := range items(i) {
+			candidates = append(candidates, candidate{shard: i, key: k, ts: timestamp(v)})
+		}
+		lock(i).RUnlock()
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ts.Before(candidates[j].ts) })
+
+	var rows int
+	var freed int64
+	for _, c := range candidates {
+		if rows >= minRows && freed >= minBytes {
+			break
+		}
+		mu := lock(c.shard)
+		mu.Lock()
+		m := items(c.shard)
+		if v, ok := m[c.key]; ok {
+			size := sizeOf(v)
+			delete(m, c.key)
+			bytes.Add(-size)
+			freed += size
+			rows++
+		}
+		mu.Unlock()
+	}
+	return rows, freed
+}
+
+// entryBytes 估算WAL记录中各行的字节数
+func entryBytes(e *wal.Entry) int64 {
+	return rowsBytes(e.Platform) + rowsBytes(e.Interface) + rowsBytes(e.Subinterface) +
+		rowsBytes(e.AlarmReport) + rowsBytes(e.NotificationReport) + rowsBytes(e.SelfDefinedEvent) +
+		rowsBytes(e.Generic) + rowsBytes(e.MappedRow) + rowsBytes(e.OpticalChannel)
+}
+
+func rowsBytes[T any](rows []T) int64 {
+	var n int64
+	for i := range rows {
+		n += sizeOf(&rows[i])
+	}
+	return n
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// indirectFields 各结构体类型中可能引用额外内存的字段下标
+var indirectFields sync.Map
+
+// mapEntryOverhead map每个条目的估算额外开销
+const mapEntryOverhead = 16
+
+// sizeOf 估算记录占用的字节数：结构体本身加上指针、字符串、切片、map与接口引用的内容。
+// time.Time的时区指针指向共享的Location，不计入。
+func sizeOf(v any) int64 {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+	}
+	return int64(rv.Type().Size()) + indirectSize(rv)
+}
+
+// indirectSize 值本身之外引用的字节数
+func indirectSize(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		return int64(e.Type().Size()) + indirectSize(e)
+	case reflect.Slice:
+		n := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		if hasIndirect(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				n += indirectSize(v.Index(i))
+			}
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		if hasIndirect(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				n += indirectSize(v.Index(i))
+			}
+		}
+		return n
+	case reflect.Map:
+		t := v.Type()
+		n := int64(v.Len()) * int64(t.Key().Size()+t.Elem().Size()+mapEntryOverhead)
+		if hasIndirect(t.Key()) || hasIndirect(t.Elem()) {
+			iter := v.MapRange()
+			for iter.Next() {
+				n += indirectSize(iter.Key()) + indirectSize(iter.Value())
+			}
+		}
+		return n
+	case reflect.Struct:
+		var n int64
+		for _, i := range structIndirectFields(v.Type()) {
+			n += indirectSize(v.Field(i))
+		}
+		return n
+	}
+	return 0
+}
+
+// hasIndirect 该类型的值是否可能引用额外内存
+func hasIndirect(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	case reflect.Array:
+		return hasIndirect(t.Elem())
+	case reflect.Struct:
+		return len(structIndirectFields(t)) > 0
+	}
+	return false
+}
+
+func structIndirectFields(t reflect.Type) []int {
+	if t == timeType {
+		return nil
+	}
+	if cached, ok := indirectFields.Load(t); ok {
+		return cached.([]int)
+	}
+	fields := []int{}
+	for i := 0; i < t.NumField(); i++ {
+		if hasIndirect(t.Field(i).Type) {
+			fields = append(fields, i)
+		}
+	}
+	indirectFields.Store(t, fields)
+	return fields
+}
+
+// parseByteSize 解析 "2GB"、"512MB"、"1.5GiB"、"1048576" 形式的大小（1024进制），空串为0
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		size   float64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	multiplier := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			multiplier = u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的大小: %q", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// formatBytes 以MB/GB显示字节数
+func formatBytes(n int64) string {
+	if n >= 1<<30 {
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	}
+	return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+}
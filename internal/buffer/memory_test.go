@@ -0,0 +1,285 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+func newBudgetTestManager(t *testing.T, db *fakeDB, cfg config.BufferConfig) *FixedBufferManager {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg.FlushInterval = time.Hour
+	cfg.FlushThreshold = 100000
+	bm := NewFixedBufferManager(db, cfg,
+		config.DatabaseWriterConfig{BatchTimeout: time.Second, RetryAttempts: 1, MaxBatchSize: 100, ParallelWriters: 1},
+		logger)
+	if err := bm.EnableMemoryBudget(config.MemoryConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	return bm
+}
+
+// setMemoryLimit 以当前用量为合计字节数上限
+func setMemoryLimit(t *testing.T, bm *FixedBufferManager) {
+	t.Helper()
+	limit := strconv.FormatInt(bm.memoryUsed(), 10)
+	if err := bm.EnableMemoryBudget(config.MemoryConfig{MaxMemoryUsage: limit}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func alarmReports(n int) []models.AlarmReportMetric {
+	alarms := make([]models.AlarmReportMetric, n)
+	for i := range alarms {
+		alarms[i] = models.AlarmReportMetric{SystemID: "dev-1", FlowID: uint32(i + 1)}
+	}
+	return alarms
+}
+
+func TestBufferByteAccounting(t *testing.T) {
+	if d := sizeOf(&models.GenericMetric{Field: "abcd"}) - sizeOf(&models.GenericMetric{}); d != 4 {
+		t.Errorf("string content counted as %d bytes, want 4", d)
+	}
+
+	bm := newTestBufferManager()
+	metrics := platformMetrics("dev-1", 3)
+	if err := bm.addPlatformMetrics(metrics); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bm.platformBuffer.Bytes(), rowsBytes(metrics); got != want {
+		t.Fatalf("platform bytes = %d, want %d", got, want)
+	}
+
+	// 合并后按合并结果计数
+	status := "ACTIVE"
+	update := metrics[0]
+	update.CommonState = &models.CommonState{OperStatus: &status}
+	if err := bm.addPlatformMetrics([]models.PlatformMetric{update}); err != nil {
+		t.Fatal(err)
+	}
+	swapped := bm.platformBuffer.SwapAll()
+	if got, want := bm.platformBuffer.Bytes(), rowsBytes(swapped); got != want || got <= rowsBytes(metrics) {
+		t.Fatalf("bytes after merge and swap = %d, want %d", got, want)
+	}
+	// 取出的批次在写库结束后扣除
+	bm.releaseBytes(&wal.Entry{Platform: swapped})
+	if got := bm.platformBuffer.Bytes(); got != 0 {
+		t.Errorf("bytes after release = %d, want 0", got)
+	}
+
+	// 同键覆盖时扣除旧记录
+	short, long := "a", "a longer value"
+	bm.addGenericMetrics([]models.GenericMetric{{SystemID: "dev-1", Field: "f", ValueStr: &long}})
+	latest := models.GenericMetric{SystemID: "dev-1", Field: "f", ValueStr: &short}
+	bm.addGenericMetrics([]models.GenericMetric{latest})
+	if got, want := bm.genericBuffer.Bytes(), sizeOf(&latest); got != want {
+		t.Errorf("generic bytes = %d, want %d", got, want)
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	bm := newBudgetTestManager(t, &fakeDB{}, config.BufferConfig{PlatformBufferSize: 5, OverflowPolicy: OverflowDropNewest})
+	defer bm.Stop()
+
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 5)); err != nil {
+		t.Fatal(err)
+	}
+	err := bm.AddPlatformMetrics(platformMetrics("dev-2", 2))
+	var oe *OverflowError
+	if !errors.Is(err, ErrBufferFull) || !errors.As(err, &oe) || oe.Action != ActionDropped || oe.Rows != 2 {
+		t.Fatalf("err = %v, want dropped OverflowError", err)
+	}
+	if n := bm.platformBuffer.Len(); n != 5 {
+		t.Errorf("platform buffer holds %d records, want 5", n)
+	}
+	if err := bm.AddAlarmReportMetrics(alarmReports(1)); err != nil {
+		t.Errorf("alarm rejected: %v", err)
+	}
+	if got := bm.GetMemoryStats().Overflow[OverflowKey{"platform", ActionDropped}]; got != 2 {
+		t.Errorf("dropped rows = %d, want 2", got)
+	}
+}
+
+func TestOverflowMaxSize(t *testing.T) {
+	bm := newBudgetTestManager(t, &fakeDB{}, config.BufferConfig{MaxSize: 5, OverflowPolicy: OverflowDropNewest})
+	defer bm.Stop()
+
+	// 告警计入合计记录数，但不受上限约束
+	if err := bm.AddAlarmReportMetrics(alarmReports(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 2)); err != nil {
+		t.Fatal(err)
+	}
+	err := bm.AddGenericMetrics([]models.GenericMetric{{SystemID: "dev-1", Field: "f"}})
+	var oe *OverflowError
+	if !errors.As(err, &oe) || oe.Buffer != "generic" || oe.Action != ActionDropped {
+		t.Fatalf("generic err = %v, want dropped OverflowError", err)
+	}
+	if err := bm.AddOpticalChannelMetrics([]models.OpticalChannelMetric{{SystemID: "dev-1"}}); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("optical channel err = %v, want ErrBufferFull", err)
+	}
+	if err := bm.AddAlarmReportMetrics(alarmReports(1)); err != nil {
+		t.Errorf("alarm rejected over max_size: %v", err)
+	}
+
+	// drop_oldest从记录最多的缓冲区淘汰
+	bm.config.OverflowPolicy = OverflowDropOldest
+	bm.config.MaxSize = 10
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-2", 6)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.AddGenericMetrics([]models.GenericMetric{{SystemID: "dev-1", Field: "f"}}); err != nil {
+		t.Fatalf("generic rejected under drop_oldest: %v", err)
+	}
+	if evicted := bm.GetMemoryStats().Overflow[OverflowKey{"platform", ActionEvicted}]; evicted == 0 {
+		t.Error("platform records not evicted")
+	}
+	if n := bm.alarmReportBuffer.Len(); n != 3 {
+		t.Errorf("alarm buffer holds %d records, want 3", n)
+	}
+	if n := bm.BufferedRecords(); n > 10 {
+		t.Errorf("buffers hold %d records, want at most 10", n)
+	}
+}
+
+func TestOverflowDropOldestProtectsAlarms(t *testing.T) {
+	bm := newBudgetTestManager(t, &fakeDB{}, config.BufferConfig{OverflowPolicy: OverflowDropOldest})
+	defer bm.Stop()
+
+	if err := bm.AddAlarmReportMetrics(alarmReports(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 10)); err != nil {
+		t.Fatal(err)
+	}
+	setMemoryLimit(t, bm)
+
+	newest := platformMetrics("dev-1", 1)
+	newest[0].Timestamp = newest[0].Timestamp.Add(time.Hour)
+	if err := bm.AddPlatformMetrics(newest); err != nil {
+		t.Fatal(err)
+	}
+	if n := bm.alarmReportBuffer.Len(); n != 3 {
+		t.Errorf("alarm buffer holds %d records, want 3", n)
+	}
+	evicted := bm.GetMemoryStats().Overflow[OverflowKey{"platform", ActionEvicted}]
+	if evicted == 0 || bm.platformBuffer.Len() != 11-int(evicted) {
+		t.Fatalf("evicted %d, platform buffer holds %d", evicted, bm.platformBuffer.Len())
+	}
+	oldest := platformMetrics("dev-1", 1)[0]
+	if _, ok := bm.platformBuffer.Get(bm.generatePlatformKey(&oldest)); ok {
+		t.Error("oldest platform record not evicted")
+	}
+	if _, ok := bm.platformBuffer.Get(bm.generatePlatformKey(&newest[0])); !ok {
+		t.Error("newest platform record missing")
+	}
+
+	// 告警超出上限照常写入，也不触发淘汰
+	remaining := bm.platformBuffer.Len()
+	for i := 0; i < 2; i++ {
+		if err := bm.AddAlarmReportMetrics(alarmReports(50)); err != nil {
+			t.Fatalf("alarm rejected over limit: %v", err)
+		}
+	}
+	if bm.memoryUsed() < bm.memoryLimit || bm.platformBuffer.Len() != remaining {
+		t.Errorf("used %d of %d, platform buffer holds %d, want %d", bm.memoryUsed(), bm.memoryLimit, bm.platformBuffer.Len(), remaining)
+	}
+}
+
+func TestOverflowDropOldestProtectsSelfDefinedEvents(t *testing.T) {
+	bm := newBudgetTestManager(t, &fakeDB{}, config.BufferConfig{OverflowPolicy: OverflowDropOldest})
+	defer bm.Stop()
+
+	ts := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	events := make([]models.SelfDefinedEventMetric, 3)
+	for i := range events {
+		events[i] = models.SelfDefinedEventMetric{Timestamp: ts.Add(time.Duration(i) * time.Second), SystemID: "dev-1", SensorPath: "cpu"}
+	}
+	if err := bm.AddSelfDefinedEventMetrics(events); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 10)); err != nil {
+		t.Fatal(err)
+	}
+	setMemoryLimit(t, bm)
+
+	// 超出上限时只淘汰平台记录，自定义事件照常写入
+	newest := platformMetrics("dev-1", 1)
+	newest[0].Timestamp = newest[0].Timestamp.Add(time.Hour)
+	if err := bm.AddPlatformMetrics(newest); err != nil {
+		t.Fatal(err)
+	}
+	more := []models.SelfDefinedEventMetric{{Timestamp: ts.Add(time.Hour), SystemID: "dev-1", SensorPath: "cpu"}}
+	if err := bm.AddSelfDefinedEventMetrics(more); err != nil {
+		t.Fatalf("self-defined event rejected over limit: %v", err)
+	}
+	if n := bm.selfDefinedEventBuffer.Len(); n != 4 {
+		t.Errorf("self-defined event buffer holds %d records, want 4", n)
+	}
+	stats := bm.GetMemoryStats()
+	if n := stats.Overflow[OverflowKey{"self_defined_event", ActionEvicted}]; n != 0 {
+		t.Errorf("evicted %d self-defined events, want 0", n)
+	}
+	if n := stats.Overflow[OverflowKey{"platform", ActionEvicted}]; n == 0 {
+		t.Error("platform records not evicted")
+	}
+}
+
+func TestFlushFuncCoversBufferTables(t *testing.T) {
+	bm := newBudgetTestManager(t, &fakeDB{}, config.BufferConfig{})
+	defer bm.Stop()
+
+	// 每个缓冲区都有单独的刷新函数，flushDue不会退回整体刷新
+	flushAll := reflect.ValueOf(bm.FlushAll).Pointer()
+	for _, table := range bufferTables {
+		if reflect.ValueOf(bm.flushFunc(table)).Pointer() == flushAll {
+			t.Errorf("flushFunc(%q) falls back to FlushAll", table)
+		}
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	db := &fakeDB{gate: make(chan struct{})}
+	bm := newBudgetTestManager(t, db, config.BufferConfig{OverflowPolicy: OverflowBlock, BlockTimeout: 50 * time.Millisecond})
+	defer bm.Stop()
+
+	// 写库阻塞时，已取出的批次仍占用预算
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 4)); err != nil {
+		t.Fatal(err)
+	}
+	bm.FlushAll()
+	setMemoryLimit(t, bm)
+
+	err := bm.AddPlatformMetrics(platformMetrics("dev-2", 1))
+	var oe *OverflowError
+	if !errors.As(err, &oe) || oe.Action != ActionTimeout {
+		t.Fatalf("err = %v, want timeout OverflowError", err)
+	}
+
+	bm.config.BlockTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(db.gate)
+	}()
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-3", 1)); err != nil {
+		t.Fatalf("add after write completed: %v", err)
+	}
+	stats := bm.GetMemoryStats()
+	if stats.Overflow[OverflowKey{"platform", ActionTimeout}] != 1 || stats.Overflow[OverflowKey{"platform", ActionBlocked}] != 1 {
+		t.Errorf("overflow = %+v", stats.Overflow)
+	}
+	if db.written() != 4 {
+		t.Errorf("written = %d, want 4", db.written())
+	}
+}
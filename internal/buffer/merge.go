@@ -0,0 +1,64 @@
+package buffer
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// mergeField 合并计划中的一个字段
+type mergeField struct {
+	index  int
+	nested bool // 指向子结构体的指针（如内嵌的 *CPUData），逐字段递归合并
+}
+
+// mergePlans 各结构体类型的合并计划
+var mergePlans sync.Map
+
+// mergeLatest 按字段合并同一聚合键的两条记录，冲突时以时间戳较新的一方为准（后写者胜）：
+// new 不早于 existing 时，new 中已设置的字段覆盖 existing；
+// new 较旧时只补充 existing 中未设置的字段。时间戳本身随之取较新值，供后续比较；
+// 写库时改为聚合时间桶的开始时间（见 aggregation）。
+// 子结构体在 existing 中缺失时新建后复制，不与 new 共用。
+func mergeLatest[T any](existing, new *T, existingTS, newTS time.Time) {
+	mergeStruct(reflect.ValueOf(existing).Elem(), reflect.ValueOf(new).Elem(), !newTS.Before(existingTS))
+}
+
+// mergeStruct 将 src 中非零值的字段合并到 dst
+func mergeStruct(dst, src reflect.Value, overwrite bool) {
+	for _, f := range mergePlan(dst.Type()) {
+		s := src.Field(f.index)
+		if s.IsZero() {
+			continue
+		}
+		d := dst.Field(f.index)
+		if f.nested {
+			if d.IsNil() {
+				d.Set(reflect.New(s.Type().Elem()))
+			}
+			mergeStruct(d.Elem(), s.Elem(), overwrite)
+			continue
+		}
+		if overwrite || d.IsZero() {
+			d.Set(s)
+		}
+	}
+}
+
+func mergePlan(t reflect.Type) []mergeField {
+	if cached, ok := mergePlans.Load(t); ok {
+		return cached.([]mergeField)
+	}
+	var plan []mergeField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		nested := sf.Type.Kind() == reflect.Pointer &&
+			sf.Type.Elem().Kind() == reflect.Struct && sf.Type.Elem() != timeType
+		plan = append(plan, mergeField{index: i, nested: nested})
+	}
+	mergePlans.Store(t, plan)
+	return plan
+}
@@ -0,0 +1,191 @@
+package buffer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+// leafPaths 列出结构体中所有需要合并的字段路径，子结构体指针展开到其字段
+func leafPaths(t reflect.Type, prefix []int) [][]int {
+	var paths [][]int
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		path := append(append([]int(nil), prefix...), i)
+		if sf.Type.Kind() == reflect.Pointer && sf.Type.Elem().Kind() == reflect.Struct && sf.Type.Elem() != timeType {
+			paths = append(paths, leafPaths(sf.Type.Elem(), path)...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// fieldAt 按路径取字段，alloc 为 true 时为途经的空子结构体分配内存
+func fieldAt(v reflect.Value, path []int, alloc bool) (reflect.Value, bool) {
+	for i, idx := range path {
+		v = v.Field(idx)
+		if i == len(path)-1 {
+			break
+		}
+		if v.IsNil() {
+			if !alloc {
+				return reflect.Value{}, false
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+func pathName(t reflect.Type, path []int) string {
+	var names []string
+	for _, idx := range path {
+		sf := t.Field(idx)
+		names = append(names, sf.Name)
+		if sf.Type.Kind() == reflect.Pointer {
+			t = sf.Type.Elem()
+		} else {
+			t = sf.Type
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// sampleValue 生成该类型的非零样例值，seed 不同时值不同
+func sampleValue(t reflect.Type, seed int) (reflect.Value, bool) {
+	v := reflect.New(t).Elem()
+	switch {
+	case t == timeType:
+		v.Set(reflect.ValueOf(time.Date(2026, 7, 1, 0, 0, seed, 0, time.UTC)))
+	case t.Kind() == reflect.Pointer:
+		elem, ok := sampleValue(t.Elem(), seed)
+		if !ok {
+			return v, false
+		}
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(elem)
+	case t.Kind() == reflect.String:
+		v.SetString(strings.Repeat("x", seed))
+	case t.Kind() == reflect.Bool:
+		if seed%2 == 0 {
+			return v, false // 布尔只有一个非零值
+		}
+		v.SetBool(true)
+	case v.CanInt():
+		v.SetInt(int64(seed))
+	case v.CanUint():
+		v.SetUint(uint64(seed))
+	case v.CanFloat():
+		v.SetFloat(float64(seed) + 0.5)
+	default:
+		return v, false
+	}
+	return v, true
+}
+
+// TestMergeCoversAllFields 新增字段后合并未覆盖（或类型无法自动合并）时失败
+func TestMergeCoversAllFields(t *testing.T) {
+	bm := newTestBufferManager()
+	cases := []struct {
+		typ   reflect.Type
+		merge func(existing, new any)
+	}{
+		{reflect.TypeOf(models.PlatformMetric{}), func(e, n any) {
+			bm.mergePlatformMetric(e.(*models.PlatformMetric), n.(*models.PlatformMetric))
+		}},
+		{reflect.TypeOf(models.InterfaceMetric{}), func(e, n any) {
+			bm.mergeInterfaceMetric(e.(*models.InterfaceMetric), n.(*models.InterfaceMetric))
+		}},
+		{reflect.TypeOf(models.SubinterfaceMetric{}), func(e, n any) {
+			bm.mergeSubinterfaceMetric(e.(*models.SubinterfaceMetric), n.(*models.SubinterfaceMetric))
+		}},
+	}
+
+	for _, c := range cases {
+		paths := leafPaths(c.typ, nil)
+		if len(paths) < 10 {
+			t.Fatalf("%s: only %d fields found", c.typ.Name(), len(paths))
+		}
+		for _, path := range paths {
+			name := c.typ.Name() + "." + pathName(c.typ, path)
+			existing, new := reflect.New(c.typ), reflect.New(c.typ)
+			field, _ := fieldAt(new.Elem(), path, true)
+			sample, ok := sampleValue(field.Type(), 3)
+			if !ok {
+				t.Errorf("%s: type %s not covered by merge test", name, field.Type())
+				continue
+			}
+			field.Set(sample)
+
+			c.merge(existing.Interface(), new.Interface())
+			got, ok := fieldAt(existing.Elem(), path, false)
+			if !ok || !reflect.DeepEqual(got.Interface(), sample.Interface()) {
+				t.Errorf("%s not merged into empty record", name)
+				continue
+			}
+
+			// 同一时间戳的后到记录覆盖已有值
+			other, _ := sampleValue(field.Type(), 5)
+			field.Set(other)
+			c.merge(existing.Interface(), new.Interface())
+			if got, _ := fieldAt(existing.Elem(), path, false); !reflect.DeepEqual(got.Interface(), other.Interface()) {
+				t.Errorf("%s not overwritten by newer record", name)
+			}
+		}
+	}
+}
+
+func TestMergeLastWriterWins(t *testing.T) {
+	bm := newTestBufferManager()
+	ts1 := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+	ts2 := ts1.Add(30 * time.Second)
+	cpu1, cpu2, mem := 10.0, 20.0, 55.5
+	up, down := "UP", "DOWN"
+
+	existing := &models.PlatformMetric{
+		Timestamp:   ts2,
+		CommonState: &models.CommonState{OperStatus: &up},
+		CPUData:     &models.CPUData{CPUInstant: &cpu2},
+	}
+	// 较旧的记录只补充缺失字段
+	older := &models.PlatformMetric{
+		Timestamp:   ts1,
+		CommonState: &models.CommonState{OperStatus: &down},
+		CPUData:     &models.CPUData{CPUInstant: &cpu1},
+		MemData:     &models.MemData{MemUsage: &mem},
+	}
+	bm.mergePlatformMetric(existing, older)
+
+	if *existing.OperStatus != "UP" || *existing.CPUInstant != 20 {
+		t.Errorf("older record overwrote fields: oper=%s cpu=%v", *existing.OperStatus, *existing.CPUInstant)
+	}
+	if existing.MemData == nil || *existing.MemUsage != 55.5 {
+		t.Error("missing MemData not filled from older record")
+	}
+	if !existing.Timestamp.Equal(ts2) {
+		t.Errorf("timestamp = %v, want %v", existing.Timestamp, ts2)
+	}
+
+	// 子结构体不与合并来源共用
+	older.MemData.MemUsage = &cpu1
+	if *existing.MemUsage != 55.5 {
+		t.Error("merged MemData aliases the source record")
+	}
+
+	newer := &models.PlatformMetric{
+		Timestamp:   ts2.Add(time.Second),
+		CommonState: &models.CommonState{OperStatus: &down},
+	}
+	bm.mergePlatformMetric(existing, newer)
+	if *existing.OperStatus != "DOWN" || *existing.CPUInstant != 20 || !existing.Timestamp.Equal(newer.Timestamp) {
+		t.Errorf("newer record: oper=%s cpu=%v ts=%v", *existing.OperStatus, *existing.CPUInstant, existing.Timestamp)
+	}
+}
@@ -3,6 +3,8 @@ package buffer
 import (
 	"hash/fnv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/wwswwsuns/ztelem/internal/models"
 )
@@ -13,6 +15,8 @@ const defaultShardCount = 16
 type ShardedPlatformMap struct {
 	shards    []*platformShard
 	shardMask uint32
+	// 记录的估算字节数，取出（SwapAll/SwapMatching）的记录在写库完成后扣除
+	bytes atomic.Int64
 }
 
 type platformShard struct {
@@ -24,6 +28,7 @@ type platformShard struct {
 type ShardedInterfaceMap struct {
 	shards    []*interfaceShard
 	shardMask uint32
+	bytes     atomic.Int64
 }
 
 type interfaceShard struct {
@@ -35,6 +40,7 @@ type interfaceShard struct {
 type ShardedSubinterfaceMap struct {
 	shards    []*subinterfaceShard
 	shardMask uint32
+	bytes     atomic.Int64
 }
 
 type subinterfaceShard struct {
@@ -46,6 +52,7 @@ type subinterfaceShard struct {
 type ShardedAlarmMap struct {
 	shards    []*alarmShard
 	shardMask uint32
+	bytes     atomic.Int64
 }
 
 type alarmShard struct {
@@ -57,6 +64,7 @@ type alarmShard struct {
 type ShardedNotificationMap struct {
 	shards    []*notificationShard
 	shardMask uint32
+	bytes     atomic.Int64
 }
 
 type notificationShard struct {
@@ -64,6 +72,54 @@ type notificationShard struct {
 	items map[string]*models.NotificationReportMetric
 }
 
+// ShardedSelfDefinedEventMap 分片的自定义事件缓冲区
+type ShardedSelfDefinedEventMap struct {
+	shards    []*selfDefinedEventShard
+	shardMask uint32
+	bytes     atomic.Int64
+}
+
+type selfDefinedEventShard struct {
+	mu    sync.RWMutex
+	items map[string]*models.SelfDefinedEventMetric
+}
+
+// ShardedGenericMap 分片的通用指标缓冲区
+type ShardedGenericMap struct {
+	shards    []*genericShard
+	shardMask uint32
+	bytes     atomic.Int64
+}
+
+type genericShard struct {
+	mu    sync.RWMutex
+	items map[string]*models.GenericMetric
+}
+
+// ShardedMappedRowMap 分片的映射数据缓冲区
+type ShardedMappedRowMap struct {
+	shards    []*mappedRowShard
+	shardMask uint32
+	bytes     atomic.Int64
+}
+
+type mappedRowShard struct {
+	mu    sync.RWMutex
+	items map[string]*models.MappedRow
+}
+
+// ShardedOpticalChannelMap 分片的光通道指标缓冲区
+type ShardedOpticalChannelMap struct {
+	shards    []*opticalChannelShard
+	shardMask uint32
+	bytes     atomic.Int64
+}
+
+type opticalChannelShard struct {
+	mu    sync.RWMutex
+	items map[string]*models.OpticalChannelMetric
+}
+
 func fnv32(s string) uint32 {
 	h := fnv.New32a()
 	h.Write([]byte(s))
@@ -92,18 +148,26 @@ func (m *ShardedPlatformMap) Get(key string) (*models.PlatformMetric, bool) {
 }
 
 func (m *ShardedPlatformMap) Set(key string, val *models.PlatformMetric) {
+	size := sizeOf(val)
 	shard := m.getShard(key)
 	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
 	shard.items[key] = val
 	shard.mu.Unlock()
+	m.bytes.Add(size)
 }
 
 func (m *ShardedPlatformMap) Swap(key string) *models.PlatformMetric {
 	shard := m.getShard(key)
 	shard.mu.Lock()
-	old := shard.items[key]
+	old, ok := shard.items[key]
 	delete(shard.items, key)
 	shard.mu.Unlock()
+	if ok {
+		m.bytes.Add(-sizeOf(old))
+	}
 	return old
 }
 
@@ -117,6 +181,36 @@ func (m *ShardedPlatformMap) Len() int {
 	return total
 }
 
+// Bytes 缓冲记录与等待写库记录的估算字节数
+func (m *ShardedPlatformMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedPlatformMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
+// EvictOldest 按时间戳从旧到新删除记录，直到删除数不少于minRows且释放字节数不少于minBytes
+func (m *ShardedPlatformMap) EvictOldest(minRows int, minBytes int64) (int, int64) {
+	return evictOldest(len(m.shards), func(i int) *sync.RWMutex { return &m.shards[i].mu },
+		func(i int) map[string]*models.PlatformMetric { return m.shards[i].items },
+		func(v *models.PlatformMetric) time.Time { return v.Timestamp },
+		&m.bytes, minRows, minBytes)
+}
+
+// Snapshot 返回所有条目的副本，不清空
+func (m *ShardedPlatformMap) Snapshot() []models.PlatformMetric {
+	var result []models.PlatformMetric
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		for _, v := range shard.items {
+			result = append(result, *v)
+		}
+		shard.mu.RUnlock()
+	}
+	return result
+}
+
 // SwapAll 清空所有分片并返回旧数据（持有各分片锁的时间最短）
 func (m *ShardedPlatformMap) SwapAll() []models.PlatformMetric {
 	var result []models.PlatformMetric
@@ -133,6 +227,22 @@ func (m *ShardedPlatformMap) SwapAll() []models.PlatformMetric {
 	return result
 }
 
+// SwapMatching 取出满足条件的条目，其余保留
+func (m *ShardedPlatformMap) SwapMatching(match func(*models.PlatformMetric) bool) []models.PlatformMetric {
+	var result []models.PlatformMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for k, v := range shard.items {
+			if match(v) {
+				result = append(result, *v)
+				delete(shard.items, k)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
 // --- Interface ---
 
 func newShardedInterfaceMap() *ShardedInterfaceMap {
@@ -157,10 +267,15 @@ func (m *ShardedInterfaceMap) Get(key string) (*models.InterfaceMetric, bool) {
 }
 
 func (m *ShardedInterfaceMap) Set(key string, val *models.InterfaceMetric) {
+	size := sizeOf(val)
 	shard := m.getShard(key)
 	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
 	shard.items[key] = val
 	shard.mu.Unlock()
+	m.bytes.Add(size)
 }
 
 func (m *ShardedInterfaceMap) Len() int {
@@ -173,6 +288,34 @@ func (m *ShardedInterfaceMap) Len() int {
 	return total
 }
 
+func (m *ShardedInterfaceMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedInterfaceMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
+func (m *ShardedInterfaceMap) EvictOldest(minRows int, minBytes int64) (int, int64) {
+	return evictOldest(len(m.shards), func(i int) *sync.RWMutex { return &m.shards[i].mu },
+		func(i int) map[string]*models.InterfaceMetric { return m.shards[i].items },
+		func(v *models.InterfaceMetric) time.Time { return v.Timestamp },
+		&m.bytes, minRows, minBytes)
+}
+
+// Snapshot 返回所有条目的副本，不清空
+func (m *ShardedInterfaceMap) Snapshot() []models.InterfaceMetric {
+	var result []models.InterfaceMetric
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		for _, v := range shard.items {
+			result = append(result, *v)
+		}
+		shard.mu.RUnlock()
+	}
+	return result
+}
+
 func (m *ShardedInterfaceMap) SwapAll() []models.InterfaceMetric {
 	var result []models.InterfaceMetric
 	for _, shard := range m.shards {
@@ -188,6 +331,22 @@ func (m *ShardedInterfaceMap) SwapAll() []models.InterfaceMetric {
 	return result
 }
 
+// SwapMatching 取出满足条件的条目，其余保留
+func (m *ShardedInterfaceMap) SwapMatching(match func(*models.InterfaceMetric) bool) []models.InterfaceMetric {
+	var result []models.InterfaceMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for k, v := range shard.items {
+			if match(v) {
+				result = append(result, *v)
+				delete(shard.items, k)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
 // --- Subinterface ---
 
 func newShardedSubinterfaceMap() *ShardedSubinterfaceMap {
@@ -212,10 +371,15 @@ func (m *ShardedSubinterfaceMap) Get(key string) (*models.SubinterfaceMetric, bo
 }
 
 func (m *ShardedSubinterfaceMap) Set(key string, val *models.SubinterfaceMetric) {
+	size := sizeOf(val)
 	shard := m.getShard(key)
 	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
 	shard.items[key] = val
 	shard.mu.Unlock()
+	m.bytes.Add(size)
 }
 
 func (m *ShardedSubinterfaceMap) Len() int {
@@ -228,6 +392,34 @@ func (m *ShardedSubinterfaceMap) Len() int {
 	return total
 }
 
+func (m *ShardedSubinterfaceMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedSubinterfaceMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
+func (m *ShardedSubinterfaceMap) EvictOldest(minRows int, minBytes int64) (int, int64) {
+	return evictOldest(len(m.shards), func(i int) *sync.RWMutex { return &m.shards[i].mu },
+		func(i int) map[string]*models.SubinterfaceMetric { return m.shards[i].items },
+		func(v *models.SubinterfaceMetric) time.Time { return v.Timestamp },
+		&m.bytes, minRows, minBytes)
+}
+
+// Snapshot 返回所有条目的副本，不清空
+func (m *ShardedSubinterfaceMap) Snapshot() []models.SubinterfaceMetric {
+	var result []models.SubinterfaceMetric
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		for _, v := range shard.items {
+			result = append(result, *v)
+		}
+		shard.mu.RUnlock()
+	}
+	return result
+}
+
 func (m *ShardedSubinterfaceMap) SwapAll() []models.SubinterfaceMetric {
 	var result []models.SubinterfaceMetric
 	for _, shard := range m.shards {
@@ -243,6 +435,22 @@ func (m *ShardedSubinterfaceMap) SwapAll() []models.SubinterfaceMetric {
 	return result
 }
 
+// SwapMatching 取出满足条件的条目，其余保留
+func (m *ShardedSubinterfaceMap) SwapMatching(match func(*models.SubinterfaceMetric) bool) []models.SubinterfaceMetric {
+	var result []models.SubinterfaceMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for k, v := range shard.items {
+			if match(v) {
+				result = append(result, *v)
+				delete(shard.items, k)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
 // --- Alarm ---
 
 func newShardedAlarmMap() *ShardedAlarmMap {
@@ -259,10 +467,15 @@ func (m *ShardedAlarmMap) getShard(key string) *alarmShard {
 }
 
 func (m *ShardedAlarmMap) Set(key string, val *models.AlarmReportMetric) {
+	size := sizeOf(val)
 	shard := m.getShard(key)
 	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
 	shard.items[key] = val
 	shard.mu.Unlock()
+	m.bytes.Add(size)
 }
 
 func (m *ShardedAlarmMap) Len() int {
@@ -275,6 +488,14 @@ func (m *ShardedAlarmMap) Len() int {
 	return total
 }
 
+func (m *ShardedAlarmMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedAlarmMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
 func (m *ShardedAlarmMap) SwapAll() []models.AlarmReportMetric {
 	var result []models.AlarmReportMetric
 	for _, shard := range m.shards {
@@ -306,10 +527,15 @@ func (m *ShardedNotificationMap) getShard(key string) *notificationShard {
 }
 
 func (m *ShardedNotificationMap) Set(key string, val *models.NotificationReportMetric) {
+	size := sizeOf(val)
 	shard := m.getShard(key)
 	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
 	shard.items[key] = val
 	shard.mu.Unlock()
+	m.bytes.Add(size)
 }
 
 func (m *ShardedNotificationMap) Len() int {
@@ -322,6 +548,14 @@ func (m *ShardedNotificationMap) Len() int {
 	return total
 }
 
+func (m *ShardedNotificationMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedNotificationMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
 func (m *ShardedNotificationMap) SwapAll() []models.NotificationReportMetric {
 	var result []models.NotificationReportMetric
 	for _, shard := range m.shards {
@@ -336,3 +570,338 @@ func (m *ShardedNotificationMap) SwapAll() []models.NotificationReportMetric {
 	}
 	return result
 }
+
+// --- SelfDefinedEvent ---
+
+func newShardedSelfDefinedEventMap() *ShardedSelfDefinedEventMap {
+	m := &ShardedSelfDefinedEventMap{shardMask: defaultShardCount - 1}
+	m.shards = make([]*selfDefinedEventShard, defaultShardCount)
+	for i := range m.shards {
+		m.shards[i] = &selfDefinedEventShard{items: make(map[string]*models.SelfDefinedEventMetric)}
+	}
+	return m
+}
+
+func (m *ShardedSelfDefinedEventMap) getShard(key string) *selfDefinedEventShard {
+	return m.shards[fnv32(key)&m.shardMask]
+}
+
+func (m *ShardedSelfDefinedEventMap) Set(key string, val *models.SelfDefinedEventMetric) {
+	size := sizeOf(val)
+	shard := m.getShard(key)
+	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
+	shard.items[key] = val
+	shard.mu.Unlock()
+	m.bytes.Add(size)
+}
+
+func (m *ShardedSelfDefinedEventMap) Len() int {
+	total := 0
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		total += len(shard.items)
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+func (m *ShardedSelfDefinedEventMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedSelfDefinedEventMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
+func (m *ShardedSelfDefinedEventMap) SwapAll() []models.SelfDefinedEventMetric {
+	var result []models.SelfDefinedEventMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		if len(shard.items) > 0 {
+			for _, v := range shard.items {
+				result = append(result, *v)
+			}
+			shard.items = make(map[string]*models.SelfDefinedEventMetric)
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
+// --- Generic ---
+
+func newShardedGenericMap() *ShardedGenericMap {
+	m := &ShardedGenericMap{shardMask: defaultShardCount - 1}
+	m.shards = make([]*genericShard, defaultShardCount)
+	for i := range m.shards {
+		m.shards[i] = &genericShard{items: make(map[string]*models.GenericMetric)}
+	}
+	return m
+}
+
+func (m *ShardedGenericMap) getShard(key string) *genericShard {
+	return m.shards[fnv32(key)&m.shardMask]
+}
+
+func (m *ShardedGenericMap) Set(key string, val *models.GenericMetric) {
+	size := sizeOf(val)
+	shard := m.getShard(key)
+	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
+	shard.items[key] = val
+	shard.mu.Unlock()
+	m.bytes.Add(size)
+}
+
+func (m *ShardedGenericMap) Len() int {
+	total := 0
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		total += len(shard.items)
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+func (m *ShardedGenericMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedGenericMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
+func (m *ShardedGenericMap) EvictOldest(minRows int, minBytes int64) (int, int64) {
+	return evictOldest(len(m.shards), func(i int) *sync.RWMutex { return &m.shards[i].mu },
+		func(i int) map[string]*models.GenericMetric { return m.shards[i].items },
+		func(v *models.GenericMetric) time.Time { return v.Timestamp },
+		&m.bytes, minRows, minBytes)
+}
+
+// Snapshot 返回所有条目的副本，不清空
+func (m *ShardedGenericMap) Snapshot() []models.GenericMetric {
+	var result []models.GenericMetric
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		for _, v := range shard.items {
+			result = append(result, *v)
+		}
+		shard.mu.RUnlock()
+	}
+	return result
+}
+
+func (m *ShardedGenericMap) SwapAll() []models.GenericMetric {
+	var result []models.GenericMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		if len(shard.items) > 0 {
+			for _, v := range shard.items {
+				result = append(result, *v)
+			}
+			shard.items = make(map[string]*models.GenericMetric)
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
+// SwapMatching 取出满足条件的条目，其余保留
+func (m *ShardedGenericMap) SwapMatching(match func(*models.GenericMetric) bool) []models.GenericMetric {
+	var result []models.GenericMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for k, v := range shard.items {
+			if match(v) {
+				result = append(result, *v)
+				delete(shard.items, k)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
+// --- MappedRow ---
+
+func newShardedMappedRowMap() *ShardedMappedRowMap {
+	m := &ShardedMappedRowMap{shardMask: defaultShardCount - 1}
+	m.shards = make([]*mappedRowShard, defaultShardCount)
+	for i := range m.shards {
+		m.shards[i] = &mappedRowShard{items: make(map[string]*models.MappedRow)}
+	}
+	return m
+}
+
+func (m *ShardedMappedRowMap) getShard(key string) *mappedRowShard {
+	return m.shards[fnv32(key)&m.shardMask]
+}
+
+func (m *ShardedMappedRowMap) Set(key string, val *models.MappedRow) {
+	size := sizeOf(val)
+	shard := m.getShard(key)
+	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
+	shard.items[key] = val
+	shard.mu.Unlock()
+	m.bytes.Add(size)
+}
+
+func (m *ShardedMappedRowMap) Len() int {
+	total := 0
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		total += len(shard.items)
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+func (m *ShardedMappedRowMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedMappedRowMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
+func (m *ShardedMappedRowMap) EvictOldest(minRows int, minBytes int64) (int, int64) {
+	return evictOldest(len(m.shards), func(i int) *sync.RWMutex { return &m.shards[i].mu },
+		func(i int) map[string]*models.MappedRow { return m.shards[i].items },
+		func(v *models.MappedRow) time.Time { return v.Timestamp },
+		&m.bytes, minRows, minBytes)
+}
+
+func (m *ShardedMappedRowMap) SwapAll() []models.MappedRow {
+	var result []models.MappedRow
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		if len(shard.items) > 0 {
+			for _, v := range shard.items {
+				result = append(result, *v)
+			}
+			shard.items = make(map[string]*models.MappedRow)
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
+// SwapMatching 取出满足条件的条目，其余保留
+func (m *ShardedMappedRowMap) SwapMatching(match func(*models.MappedRow) bool) []models.MappedRow {
+	var result []models.MappedRow
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for k, v := range shard.items {
+			if match(v) {
+				result = append(result, *v)
+				delete(shard.items, k)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
+// --- OpticalChannel ---
+
+func newShardedOpticalChannelMap() *ShardedOpticalChannelMap {
+	m := &ShardedOpticalChannelMap{shardMask: defaultShardCount - 1}
+	m.shards = make([]*opticalChannelShard, defaultShardCount)
+	for i := range m.shards {
+		m.shards[i] = &opticalChannelShard{items: make(map[string]*models.OpticalChannelMetric)}
+	}
+	return m
+}
+
+func (m *ShardedOpticalChannelMap) getShard(key string) *opticalChannelShard {
+	return m.shards[fnv32(key)&m.shardMask]
+}
+
+func (m *ShardedOpticalChannelMap) Set(key string, val *models.OpticalChannelMetric) {
+	size := sizeOf(val)
+	shard := m.getShard(key)
+	shard.mu.Lock()
+	if old, ok := shard.items[key]; ok {
+		size -= sizeOf(old)
+	}
+	shard.items[key] = val
+	shard.mu.Unlock()
+	m.bytes.Add(size)
+}
+
+func (m *ShardedOpticalChannelMap) Len() int {
+	total := 0
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		total += len(shard.items)
+		shard.mu.RUnlock()
+	}
+	return total
+}
+
+func (m *ShardedOpticalChannelMap) Bytes() int64 {
+	return m.bytes.Load()
+}
+
+func (m *ShardedOpticalChannelMap) addBytes(n int64) {
+	m.bytes.Add(n)
+}
+
+func (m *ShardedOpticalChannelMap) EvictOldest(minRows int, minBytes int64) (int, int64) {
+	return evictOldest(len(m.shards), func(i int) *sync.RWMutex { return &m.shards[i].mu },
+		func(i int) map[string]*models.OpticalChannelMetric { return m.shards[i].items },
+		func(v *models.OpticalChannelMetric) time.Time { return v.Timestamp },
+		&m.bytes, minRows, minBytes)
+}
+
+// Snapshot 返回所有条目的副本，不清空
+func (m *ShardedOpticalChannelMap) Snapshot() []models.OpticalChannelMetric {
+	var result []models.OpticalChannelMetric
+	for _, shard := range m.shards {
+		shard.mu.RLock()
+		for _, v := range shard.items {
+			result = append(result, *v)
+		}
+		shard.mu.RUnlock()
+	}
+	return result
+}
+
+func (m *ShardedOpticalChannelMap) SwapAll() []models.OpticalChannelMetric {
+	var result []models.OpticalChannelMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		if len(shard.items) > 0 {
+			for _, v := range shard.items {
+				result = append(result, *v)
+			}
+			shard.items = make(map[string]*models.OpticalChannelMetric)
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
+
+// SwapMatching 取出满足条件的条目，其余保留
+func (m *ShardedOpticalChannelMap) SwapMatching(match func(*models.OpticalChannelMetric) bool) []models.OpticalChannelMetric {
+	var result []models.OpticalChannelMetric
+	for _, shard := range m.shards {
+		shard.mu.Lock()
+		for k, v := range shard.items {
+			if match(v) {
+				result = append(result, *v)
+				delete(shard.items, k)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return result
+}
@@ -153,6 +153,39 @@ func TestShardedNotificationMap_SetGetSwap(t *testing.T) {
 	}
 }
 
+func TestShardedSelfDefinedEventMap_SetGetSwap(t *testing.T) {
+	m := newShardedSelfDefinedEventMap()
+	m.Set("k1", &models.SelfDefinedEventMetric{Level: 1})
+	m.Set("k1", &models.SelfDefinedEventMetric{Level: 2})
+	m.Set("k2", &models.SelfDefinedEventMetric{Level: 3})
+
+	if m.Len() != 2 {
+		t.Fatalf("expected 2 items, got %d", m.Len())
+	}
+	result := m.SwapAll()
+	if len(result) != 2 || m.Len() != 0 {
+		t.Fatalf("expected 2 swapped items and empty map, got %d/%d", len(result), m.Len())
+	}
+}
+
+func TestShardedInterfaceMap_SwapMatching(t *testing.T) {
+	m := newShardedInterfaceMap()
+	m.Set("a", &models.InterfaceMetric{SystemID: "dev-1", InterfaceName: "gei-1/1"})
+	m.Set("b", &models.InterfaceMetric{SystemID: "dev-2", InterfaceName: "gei-1/1"})
+	m.Set("c", &models.InterfaceMetric{SystemID: "dev-1", InterfaceName: "gei-1/2"})
+
+	result := m.SwapMatching(func(v *models.InterfaceMetric) bool { return v.SystemID == "dev-1" })
+	if len(result) != 2 {
+		t.Fatalf("expected 2 matched items, got %d", len(result))
+	}
+	if m.Len() != 1 {
+		t.Fatalf("expected 1 remaining item, got %d", m.Len())
+	}
+	if v, ok := m.Get("b"); !ok || v.SystemID != "dev-2" {
+		t.Errorf("unmatched item should remain, got %+v", v)
+	}
+}
+
 func TestFnv32Distribution(t *testing.T) {
 	// Verify fnv32 produces different values for different keys
 	seen := make(map[uint32]bool)
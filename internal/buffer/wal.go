@@ -0,0 +1,456 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+// 缓冲区预写日志
+//
+// 启用后每次写入缓冲区前先追加到WAL，Add返回（设备收到应答）时数据已在磁盘上。
+// 检查点协程每 checkpoint_interval 执行一次：
+//  1. 切换WAL活动段，此前写入的数据都已在缓冲区中
+//  2. 刷新缓冲区并等待进行中的写库完成；配置了聚合窗口的缓冲区只写出已关闭的窗口，
+//     未关闭的窗口重新追加到新的活动段，留在缓冲区继续合并
+//  3. 期间没有写库失败则删除切换前的段，否则保留，待数据库恢复后重新载入缓冲区
+//
+// 写库失败后进入溢出模式：新数据只写WAL不进缓冲区，内存不随积压增长；
+// 之后的检查点成功即退出溢出模式，并逐段载入积压的数据，每载入一段做一次检查点。
+// 启动时目录中已有的段（上次运行未确认写库）同样逐段载入。
+//
+// 失败窗口内部分已写库的数据会在重新载入时再次写入，即至少一次语义。
+
+// WALStats WAL统计
+type WALStats struct {
+	Enabled           bool
+	Bytes             int64
+	PendingSegments   int64
+	Spilling          bool
+	Checkpoints       int64
+	FailedCheckpoints int64
+	LoadedSegments    int64
+}
+
+type walState struct {
+	log *wal.Log
+	cfg config.RecoveryConfig
+
+	// 追加WAL并写入缓冲区期间持有读锁，切换活动段时持有写锁
+	mu sync.RWMutex
+	// 溢出模式：数据库不可用，新数据只写WAL
+	spill atomic.Bool
+	// 当前活动段中有只写入WAL的数据
+	spilled atomic.Bool
+	// 检查点正在等待写库完成，期间不触发阈值刷新
+	checkpointing atomic.Bool
+
+	// 需要重新载入缓冲区的段，只由检查点协程访问
+	pending      []uint64
+	lastFailures int64
+
+	pendingCount      int64
+	checkpoints       int64
+	failedCheckpoints int64
+	loadedSegments    int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// EnableWAL 打开预写日志并启动检查点协程，需在写入缓冲区之前调用
+func (bm *FixedBufferManager) EnableWAL(cfg config.RecoveryConfig) error {
+	if cfg.CheckpointInterval <= 0 {
+		return fmt.Errorf("checkpoint_interval必须大于0: %v", cfg.CheckpointInterval)
+	}
+	log, err := wal.Open(cfg.PersistencePath, wal.Options{
+		SegmentSize:    cfg.SegmentSize,
+		MaxSize:        cfg.MaxSize,
+		SyncEveryWrite: cfg.SyncInterval <= 0,
+	})
+	if err != nil {
+		return err
+	}
+	w := &walState{
+		log:          log,
+		cfg:          cfg,
+		pending:      log.Recovered(),
+		lastFailures: atomic.LoadInt64(&bm.failedWrites),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	w.pendingCount = int64(len(w.pending))
+	bm.wal = w
+
+	if len(w.pending) > 0 {
+		bm.logger.Infof("WAL中有 %d 个段（%dMB）未确认写库，将逐段载入", len(w.pending), log.Size()/(1024*1024))
+	}
+	go bm.walLoop()
+	return nil
+}
+
+// walLoop 定期检查点与fsync
+func (bm *FixedBufferManager) walLoop() {
+	w := bm.wal
+	defer close(w.done)
+
+	checkpointTicker := time.NewTicker(w.cfg.CheckpointInterval)
+	defer checkpointTicker.Stop()
+	var syncTick <-chan time.Time
+	if w.cfg.SyncInterval > 0 {
+		syncTicker := time.NewTicker(w.cfg.SyncInterval)
+		defer syncTicker.Stop()
+		syncTick = syncTicker.C
+	}
+
+	// 启动时先载入上次遗留的段
+	bm.walCheckpointAndLoad()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-checkpointTicker.C:
+			bm.walCheckpointAndLoad()
+		case <-syncTick:
+			if err := w.log.Sync(); err != nil {
+				bm.logger.WithError(err).Error("WAL同步到磁盘失败")
+			}
+		}
+	}
+}
+
+// stopWAL 停止检查点协程，做最后一次检查点并关闭WAL
+func (bm *FixedBufferManager) stopWAL() {
+	w := bm.wal
+	if w == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+
+	bm.walCheckpoint(nil, true)
+	if err := w.log.Close(); err != nil {
+		bm.logger.WithError(err).Error("关闭WAL失败")
+	}
+	if n := len(w.pending); n > 0 {
+		bm.logger.Warnf("WAL中仍有 %d 个段未写库，下次启动时载入", n)
+	}
+}
+
+// walCheckpointAndLoad 检查点成功后逐段载入积压的数据
+func (bm *FixedBufferManager) walCheckpointAndLoad() {
+	w := bm.wal
+	if !bm.walCheckpoint(nil, false) {
+		return
+	}
+	for len(w.pending) > 0 {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		seq := w.pending[0]
+		w.pending = w.pending[1:]
+		atomic.StoreInt64(&w.pendingCount, int64(len(w.pending)))
+		if !bm.loadSegment(seq) {
+			continue
+		}
+		if !bm.walCheckpoint([]uint64{seq}, false) {
+			return
+		}
+	}
+}
+
+// loadSegment 将段中的数据载入缓冲区（不再写WAL），损坏的段隔离后跳过
+func (bm *FixedBufferManager) loadSegment(seq uint64) bool {
+	w := bm.wal
+	entries := 0
+	truncated, err := w.log.ReadSegment(seq, func(e *wal.Entry) error {
+		bm.addEntry(e)
+		entries++
+		return nil
+	})
+	if err != nil {
+		bm.logger.WithError(err).Errorf("载入WAL段 %d 失败，已载入 %d 条，隔离该段", seq, entries)
+		if qerr := w.log.Quarantine(seq); qerr != nil {
+			bm.logger.WithError(qerr).Error("隔离WAL段失败")
+		}
+		return entries > 0
+	}
+	if truncated {
+		bm.logger.Warnf("WAL段 %d 末尾不完整，已载入其前的 %d 条", seq, entries)
+	}
+	atomic.AddInt64(&w.loadedSegments, 1)
+	bm.logger.Infof("载入WAL段 %d: %d 条，剩余 %d 段", seq, entries, len(w.pending))
+	return true
+}
+
+// walCheckpoint 切换活动段、刷新缓冲区并等待写库完成，成功时删除切换前的段与loaded中的段。
+// final为停止前的最后一次检查点，写出全部缓冲数据（含未关闭的窗口）。
+func (bm *FixedBufferManager) walCheckpoint(loaded []uint64, final bool) bool {
+	w := bm.wal
+
+	w.mu.Lock()
+	sealed, err := w.log.Rotate()
+	spilled := w.spilled.Swap(false)
+	w.mu.Unlock()
+	if err != nil {
+		bm.logger.WithError(err).Error("切换WAL段失败")
+		return false
+	}
+
+	w.checkpointing.Store(true)
+	var flushErr error
+	if final {
+		flushErr = bm.FlushAll()
+	} else {
+		flushErr = bm.flushDue()
+	}
+	finished := bm.waitWrites(w.cfg.MaxRecoveryTime)
+	w.checkpointing.Store(false)
+
+	failures := atomic.LoadInt64(&bm.failedWrites)
+	ok := flushErr == nil && finished && failures == w.lastFailures
+	w.lastFailures = failures
+	atomic.AddInt64(&w.checkpoints, 1)
+
+	if !ok {
+		atomic.AddInt64(&w.failedCheckpoints, 1)
+		w.addPending(sealed...)
+		w.addPending(loaded...)
+		if !w.spill.Swap(true) {
+			bm.logger.Warnf("写库失败，新数据只写入WAL，待数据库恢复后载入（积压 %d 段）", len(w.pending))
+		}
+		return false
+	}
+
+	if w.spill.Swap(false) {
+		bm.logger.Infof("数据库写入已恢复，开始载入WAL中积压的数据（%d 段）", len(w.pending)+len(sealed))
+	}
+	if spilled {
+		// 溢出期间的数据只在WAL中，需要载入后再写库
+		w.addPending(sealed...)
+		sealed = nil
+	}
+	if !final {
+		if err := bm.walRetainOpenWindows(); err != nil {
+			// 切换前的段保留在磁盘上，下次启动时载入
+			bm.logger.WithError(err).Error("未关闭的聚合窗口写入WAL失败，保留切换前的段")
+			sealed = nil
+		}
+	}
+	for _, seq := range append(sealed, loaded...) {
+		if err := w.log.Remove(seq); err != nil {
+			bm.logger.WithError(err).Warn("删除已写库的WAL段失败")
+		}
+	}
+	return true
+}
+
+// walRetainOpenWindows 将仍在缓冲区中的聚合窗口追加到活动段，切换前的段随后可以删除
+func (bm *FixedBufferManager) walRetainOpenWindows() error {
+	if len(bm.aggregation) == 0 {
+		return nil
+	}
+	e := &wal.Entry{}
+	if bm.aggregation["platform"] != nil {
+		e.Platform = bm.platformBuffer.Snapshot()
+	}
+	if bm.aggregation["interface"] != nil {
+		e.Interface = bm.interfaceBuffer.Snapshot()
+	}
+	if bm.aggregation["subinterface"] != nil {
+		e.Subinterface = bm.subinterfaceBuffer.Snapshot()
+	}
+	if bm.aggregation["generic"] != nil {
+		e.Generic = bm.genericBuffer.Snapshot()
+	}
+	if bm.aggregation["optical_channel"] != nil {
+		e.OpticalChannel = bm.opticalChannelBuffer.Snapshot()
+	}
+	if e.Rows() == 0 {
+		return nil
+	}
+
+	w := bm.wal
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.log.Append(e)
+}
+
+// waitWrites 等待已取出的批次全部写完，超时或停止时返回false
+func (bm *FixedBufferManager) waitWrites(timeout time.Duration) bool {
+	// 持有写锁时没有正在取数的刷新，此前取出的批次都已计入writesInFlight
+	bm.flushMu.Lock()
+	defer bm.flushMu.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&bm.writesInFlight) > 0 {
+		if timeout > 0 && time.Now().After(deadline) {
+			bm.logger.Warnf("等待写库完成超时 (%v)，仍有 %d 个批次", timeout, atomic.LoadInt64(&bm.writesInFlight))
+			return false
+		}
+		select {
+		case <-bm.stopChan:
+			return false
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	return true
+}
+
+func (w *walState) addPending(seqs ...uint64) {
+	if len(seqs) == 0 {
+		return
+	}
+	w.pending = append(w.pending, seqs...)
+	sort.Slice(w.pending, func(i, j int) bool { return w.pending[i] < w.pending[j] })
+	atomic.StoreInt64(&w.pendingCount, int64(len(w.pending)))
+}
+
+// spilling 是否处于溢出模式（数据只写WAL）
+func (bm *FixedBufferManager) spilling() bool {
+	return bm.wal != nil && bm.wal.spill.Load()
+}
+
+// triggerFlush 缓冲区达到阈值时异步刷新，检查点等待写库期间跳过，由检查点的刷新处理
+func (bm *FixedBufferManager) triggerFlush(flush func() error) {
+	if bm.wal != nil && bm.wal.checkpointing.Load() {
+		return
+	}
+	go flush()
+}
+
+// addWithBudget 按缓冲区上限接纳数据后写入WAL与缓冲区。
+// 接纳（block策略下可能等待）在持有WAL读锁之前完成，不会阻塞检查点切换活动段。
+func (bm *FixedBufferManager) addWithBudget(e *wal.Entry, add func() error) error {
+	spill, err := bm.admit(e)
+	if err != nil {
+		return err
+	}
+	return bm.appendWAL(e, spill, add)
+}
+
+// appendWAL 先追加WAL再执行add写入缓冲区；溢出模式或spill为true时只写WAL
+func (bm *FixedBufferManager) appendWAL(e *wal.Entry, spill bool, add func() error) error {
+	w := bm.wal
+	if w == nil {
+		return add()
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if err := w.log.Append(e); err != nil {
+		if errors.Is(err, wal.ErrFull) {
+			return fmt.Errorf("%w: %v", ErrBufferFull, err)
+		}
+		return err
+	}
+	if spill || w.spill.Load() {
+		w.spilled.Store(true)
+		return nil
+	}
+	return add()
+}
+
+// addEntry 将WAL记录写入缓冲区
+func (bm *FixedBufferManager) addEntry(e *wal.Entry) {
+	if len(e.Platform) > 0 {
+		bm.addPlatformMetrics(e.Platform)
+	}
+	if len(e.Interface) > 0 {
+		bm.addInterfaceMetrics(e.Interface)
+	}
+	if len(e.Subinterface) > 0 {
+		bm.addSubinterfaceMetrics(e.Subinterface)
+	}
+	if len(e.AlarmReport) > 0 {
+		bm.addAlarmReportMetrics(e.AlarmReport)
+	}
+	if len(e.NotificationReport) > 0 {
+		bm.addNotificationReportMetrics(e.NotificationReport)
+	}
+	if len(e.SelfDefinedEvent) > 0 {
+		bm.addSelfDefinedEventMetrics(e.SelfDefinedEvent)
+	}
+	if len(e.Generic) > 0 {
+		bm.addGenericMetrics(e.Generic)
+	}
+	if len(e.MappedRow) > 0 {
+		bm.addMappedRows(e.MappedRow)
+	}
+	if len(e.OpticalChannel) > 0 {
+		bm.addOpticalChannelMetrics(e.OpticalChannel)
+	}
+}
+
+// GetWALStats 获取WAL统计，未启用时Enabled为false
+func (bm *FixedBufferManager) GetWALStats() WALStats {
+	w := bm.wal
+	if w == nil {
+		return WALStats{}
+	}
+	return WALStats{
+		Enabled:           true,
+		Bytes:             w.log.Size(),
+		PendingSegments:   atomic.LoadInt64(&w.pendingCount),
+		Spilling:          w.spill.Load(),
+		Checkpoints:       atomic.LoadInt64(&w.checkpoints),
+		FailedCheckpoints: atomic.LoadInt64(&w.failedCheckpoints),
+		LoadedSegments:    atomic.LoadInt64(&w.loadedSegments),
+	}
+}
+
+// AddPlatformMetrics 添加平台指标数据，启用WAL时先写入WAL
+func (bm *FixedBufferManager) AddPlatformMetrics(metrics []models.PlatformMetric) error {
+	return bm.addWithBudget(&wal.Entry{Platform: metrics}, func() error { return bm.addPlatformMetrics(metrics) })
+}
+
+// AddInterfaceMetrics 添加接口指标数据
+func (bm *FixedBufferManager) AddInterfaceMetrics(metrics []models.InterfaceMetric) error {
+	return bm.addWithBudget(&wal.Entry{Interface: metrics}, func() error { return bm.addInterfaceMetrics(metrics) })
+}
+
+// AddSubinterfaceMetrics 添加子接口指标数据
+func (bm *FixedBufferManager) AddSubinterfaceMetrics(metrics []models.SubinterfaceMetric) error {
+	return bm.addWithBudget(&wal.Entry{Subinterface: metrics}, func() error { return bm.addSubinterfaceMetrics(metrics) })
+}
+
+// AddAlarmReportMetrics 添加告警上报数据
+func (bm *FixedBufferManager) AddAlarmReportMetrics(metrics []models.AlarmReportMetric) error {
+	return bm.addWithBudget(&wal.Entry{AlarmReport: metrics}, func() error { return bm.addAlarmReportMetrics(metrics) })
+}
+
+// AddNotificationReportMetrics 添加通知上报数据
+func (bm *FixedBufferManager) AddNotificationReportMetrics(metrics []models.NotificationReportMetric) error {
+	return bm.addWithBudget(&wal.Entry{NotificationReport: metrics}, func() error { return bm.addNotificationReportMetrics(metrics) })
+}
+
+// AddSelfDefinedEventMetrics 添加自定义事件数据
+func (bm *FixedBufferManager) AddSelfDefinedEventMetrics(metrics []models.SelfDefinedEventMetric) error {
+	return bm.addWithBudget(&wal.Entry{SelfDefinedEvent: metrics}, func() error { return bm.addSelfDefinedEventMetrics(metrics) })
+}
+
+// AddGenericMetrics 添加通用指标数据
+func (bm *FixedBufferManager) AddGenericMetrics(metrics []models.GenericMetric) error {
+	return bm.addWithBudget(&wal.Entry{Generic: metrics}, func() error { return bm.addGenericMetrics(metrics) })
+}
+
+// AddMappedRows 添加映射文件生成的行
+func (bm *FixedBufferManager) AddMappedRows(rows []models.MappedRow) error {
+	return bm.addWithBudget(&wal.Entry{MappedRow: rows}, func() error { return bm.addMappedRows(rows) })
+}
+
+// AddOpticalChannelMetrics 添加光通道指标
+func (bm *FixedBufferManager) AddOpticalChannelMetrics(metrics []models.OpticalChannelMetric) error {
+	return bm.addWithBudget(&wal.Entry{OpticalChannel: metrics}, func() error { return bm.addOpticalChannelMetrics(metrics) })
+}
@@ -0,0 +1,205 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+// fakeDB 记录写入的平台指标，fail为true时所有写入失败，gate非nil时写入平台指标前等待其关闭
+type fakeDB struct {
+	fail     atomic.Bool
+	gate     chan struct{}
+	mu       sync.Mutex
+	platform []models.PlatformMetric
+}
+
+func (db *fakeDB) BatchInsertPlatformMetrics(data []models.PlatformMetric) error {
+	if db.gate != nil {
+		<-db.gate
+	}
+	if db.fail.Load() {
+		return errors.New("database unavailable")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.platform = append(db.platform, data...)
+	return nil
+}
+
+func (db *fakeDB) written() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.platform)
+}
+
+func (db *fakeDB) BatchInsertInterfaceMetrics([]models.InterfaceMetric) error       { return nil }
+func (db *fakeDB) BatchInsertSubinterfaceMetrics([]models.SubinterfaceMetric) error { return nil }
+func (db *fakeDB) BatchInsertAlarmReportMetrics([]models.AlarmReportMetric) error   { return nil }
+func (db *fakeDB) BatchInsertNotificationReportMetrics([]models.NotificationReportMetric) error {
+	return nil
+}
+func (db *fakeDB) BatchInsertSelfDefinedEventMetrics([]models.SelfDefinedEventMetric) error {
+	return nil
+}
+func (db *fakeDB) BatchInsertGenericMetrics([]models.GenericMetric) error               { return nil }
+func (db *fakeDB) BatchInsertMappedRows([]models.MappedRow) error                       { return nil }
+func (db *fakeDB) BatchInsertOpticalChannelMetrics([]models.OpticalChannelMetric) error { return nil }
+
+func newWALTestManager(t *testing.T, db *fakeDB, dir string, checkpoint time.Duration) *FixedBufferManager {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	bm := NewFixedBufferManager(db,
+		config.BufferConfig{FlushInterval: time.Hour, FlushThreshold: 100000},
+		config.DatabaseWriterConfig{BatchTimeout: time.Second, RetryAttempts: 1, MaxBatchSize: 100, ParallelWriters: 1},
+		logger)
+	err := bm.EnableWAL(config.RecoveryConfig{
+		PersistencePath:    dir,
+		MaxRecoveryTime:    5 * time.Second,
+		CheckpointInterval: checkpoint,
+		SegmentSize:        1 << 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bm
+}
+
+func platformMetrics(systemID string, n int) []models.PlatformMetric {
+	ts := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	metrics := make([]models.PlatformMetric, n)
+	for i := range metrics {
+		metrics[i] = models.PlatformMetric{Timestamp: ts.Add(time.Duration(i) * time.Second), SystemID: systemID, ComponentName: "CPU0"}
+	}
+	return metrics
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func walSegments(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestWALReplayAfterFailedShutdown(t *testing.T) {
+	dir := t.TempDir()
+
+	down := &fakeDB{}
+	down.fail.Store(true)
+	bm := newWALTestManager(t, down, dir, time.Hour)
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 5)); err != nil {
+		t.Fatal(err)
+	}
+	bm.Stop()
+	if n := walSegments(t, dir); n != 1 {
+		t.Fatalf("%d WAL segments after failed shutdown, want 1", n)
+	}
+
+	up := &fakeDB{}
+	bm = newWALTestManager(t, up, dir, time.Hour)
+	defer bm.Stop()
+	waitFor(t, "replayed rows", func() bool { return up.written() == 5 })
+	// 载入的段写库后删除，只剩新的活动段
+	waitFor(t, "segment removal", func() bool { return walSegments(t, dir) == 1 })
+	if stats := bm.GetWALStats(); stats.LoadedSegments != 1 || stats.PendingSegments != 0 {
+		t.Errorf("stats = %+v, want 1 loaded and 0 pending", stats)
+	}
+}
+
+func TestWALSpillAndDrain(t *testing.T) {
+	dir := t.TempDir()
+	db := &fakeDB{}
+	db.fail.Store(true)
+	bm := newWALTestManager(t, db, dir, 20*time.Millisecond)
+	defer bm.Stop()
+
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-1", 3)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "spill mode", func() bool { return bm.GetWALStats().Spilling })
+
+	// 溢出期间数据只写WAL，不占用缓冲区
+	if err := bm.AddPlatformMetrics(platformMetrics("dev-2", 4)); err != nil {
+		t.Fatal(err)
+	}
+	if n := bm.BufferedRecords(); n != 0 {
+		t.Errorf("BufferedRecords() = %d while spilling, want 0", n)
+	}
+	if limit := bm.OverBudget(); limit != "" {
+		t.Errorf("OverBudget() = %q while spilling, want empty", limit)
+	}
+
+	db.fail.Store(false)
+	waitFor(t, "drained rows", func() bool { return db.written() == 7 })
+	waitFor(t, "drained WAL", func() bool {
+		stats := bm.GetWALStats()
+		return !stats.Spilling && stats.PendingSegments == 0 && walSegments(t, dir) <= 1
+	})
+	if stats := bm.GetWALStats(); stats.FailedCheckpoints == 0 {
+		t.Errorf("stats = %+v, want failed checkpoints recorded", stats)
+	}
+}
+
+func TestWALCheckpointKeepsOpenWindows(t *testing.T) {
+	dir := t.TempDir()
+	aggregation := map[string]config.AggregationConfig{"platform": {Window: "1h"}}
+
+	db := &fakeDB{}
+	bm := newWALTestManager(t, db, dir, 20*time.Millisecond)
+	if err := bm.EnableAggregation(aggregation, false); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	closed := models.PlatformMetric{Timestamp: now.Add(-2 * time.Hour), SystemID: "dev-1", ComponentName: "CPU0"}
+	open := models.PlatformMetric{Timestamp: now, SystemID: "dev-1", ComponentName: "CPU0"}
+	if err := bm.AddPlatformMetrics([]models.PlatformMetric{closed, open}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 检查点只写出已关闭的窗口，未关闭的窗口留在缓冲区
+	waitFor(t, "checkpoints", func() bool { return bm.GetWALStats().Checkpoints >= 3 })
+	if n := db.written(); n != 1 {
+		t.Fatalf("checkpoints wrote %d rows, want only the closed window", n)
+	}
+	if n := bm.platformBuffer.Len(); n != 1 {
+		t.Fatalf("platform buffer holds %d records, want the open window", n)
+	}
+
+	// 模拟崩溃：不做最后一次检查点，未关闭的窗口从WAL恢复
+	close(bm.wal.stop)
+	<-bm.wal.done
+	bm.wal.log.Close()
+
+	recovered := &fakeDB{}
+	bm = newWALTestManager(t, recovered, dir, time.Hour)
+	if err := bm.EnableAggregation(aggregation, false); err != nil {
+		t.Fatal(err)
+	}
+	defer bm.Stop()
+	waitFor(t, "open window recovered", func() bool { return bm.platformBuffer.Len() == 1 })
+	if n := recovered.written(); n != 0 {
+		t.Errorf("recovery wrote %d rows, want 0", n)
+	}
+}
@@ -0,0 +1,191 @@
+package cadence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 上报周期监测
+//
+// 设备在 Telemetry.current_period 中携带订阅的采样周期。Monitor 按 (system_id, sensor_path)
+// 记住最近一次的周期与到达时间，超过 max(周期 × lateMultiple, minLate) 仍无数据时判定该序列迟到，
+// 打开一个数据缺口；数据恢复时关闭缺口。接口订阅静默停止而平台订阅仍在上报的设备由此可见。
+// 未携带 current_period 的sensor_path（告警、事件等）没有固定周期，不做监测。
+
+// Gap 数据缺口，End为零值表示缺口仍未结束
+type Gap struct {
+	SystemID       string
+	SensorPath     string
+	ExpectedPeriod time.Duration
+	Start          time.Time // 缺口前最后一次收到数据的时间
+	End            time.Time // 数据恢复的时间
+}
+
+// Stats 上报周期监测统计
+type Stats struct {
+	Series     int              // 监测中的序列数
+	Late       int              // 当前迟到的序列数
+	GapsOpened map[string]int64 // 按sensor_path累计打开的缺口数
+	GapsClosed int64            // 累计关闭的缺口数
+}
+
+// Store 数据缺口持久化，同一缺口先以未结束状态写入，结束后再次写入
+type Store interface {
+	UpsertGaps(gaps []Gap) error
+}
+
+type seriesKey struct {
+	systemID   string
+	sensorPath string
+}
+
+type series struct {
+	period   time.Duration
+	lastSeen time.Time
+	gap      *Gap // 当前未结束的缺口
+}
+
+// Monitor 上报周期监测器
+type Monitor struct {
+	lateMultiple float64
+	minLate      time.Duration
+	stateTTL     time.Duration
+	now          func() time.Time
+
+	mu         sync.Mutex
+	series     map[seriesKey]*series
+	pending    []Gap // 待持久化的缺口变化
+	gapsOpened map[string]int64
+	gapsClosed int64
+}
+
+// NewMonitor 创建上报周期监测器
+// lateMultiple为判定迟到的周期倍数，minLate为迟到阈值下限，stateTTL为迟到序列保留的时长
+func NewMonitor(lateMultiple float64, minLate, stateTTL time.Duration) *Monitor {
+	return &Monitor{
+		lateMultiple: lateMultiple,
+		minLate:      minLate,
+		stateTTL:     stateTTL,
+		now:          time.Now,
+		series:       make(map[seriesKey]*series),
+		gapsOpened:   make(map[string]int64),
+	}
+}
+
+// Observe 记录一条报文，序列处于缺口中时关闭缺口并返回
+func (m *Monitor) Observe(systemID, sensorPath string, period time.Duration) *Gap {
+	if systemID == "" || sensorPath == "" || period <= 0 {
+		return nil
+	}
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := seriesKey{systemID: systemID, sensorPath: sensorPath}
+	s, ok := m.series[key]
+	if !ok {
+		m.series[key] = &series{period: period, lastSeen: now}
+		return nil
+	}
+	s.period = period
+	s.lastSeen = now
+	if s.gap == nil {
+		return nil
+	}
+
+	gap := *s.gap
+	gap.End = now
+	s.gap = nil
+	m.gapsClosed++
+	m.pending = append(m.pending, gap)
+	return &gap
+}
+
+// Check 检查所有序列，返回新打开的缺口；迟到超过stateTTL的序列被清理，其缺口保持未结束
+func (m *Monitor) Check() []Gap {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var opened []Gap
+	for key, s := range m.series {
+		silent := now.Sub(s.lastSeen)
+		if s.gap != nil {
+			if m.stateTTL > 0 && silent > m.stateTTL {
+				delete(m.series, key)
+			}
+			continue
+		}
+		if silent <= m.lateAfter(s.period) {
+			continue
+		}
+		s.gap = &Gap{
+			SystemID:       key.systemID,
+			SensorPath:     key.sensorPath,
+			ExpectedPeriod: s.period,
+			Start:          s.lastSeen,
+		}
+		m.gapsOpened[key.sensorPath]++
+		m.pending = append(m.pending, *s.gap)
+		opened = append(opened, *s.gap)
+	}
+	sort.Slice(opened, func(i, j int) bool {
+		if opened[i].SystemID != opened[j].SystemID {
+			return opened[i].SystemID < opened[j].SystemID
+		}
+		return opened[i].SensorPath < opened[j].SensorPath
+	})
+	return opened
+}
+
+// Flush 将待持久化的缺口变化写入store，写入失败时保留等待下次重试
+func (m *Monitor) Flush(store Store) (int, error) {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	if err := store.UpsertGaps(pending); err != nil {
+		m.mu.Lock()
+		m.pending = append(pending, m.pending...)
+		m.mu.Unlock()
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// Stats 返回上报周期监测统计
+func (m *Monitor) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := Stats{
+		Series:     len(m.series),
+		GapsOpened: make(map[string]int64, len(m.gapsOpened)),
+		GapsClosed: m.gapsClosed,
+	}
+	for path, n := range m.gapsOpened {
+		stats.GapsOpened[path] = n
+	}
+	for _, s := range m.series {
+		if s.gap != nil {
+			stats.Late++
+		}
+	}
+	return stats
+}
+
+// lateAfter 无数据超过该时长判定为迟到
+func (m *Monitor) lateAfter(period time.Duration) time.Duration {
+	late := time.Duration(float64(period) * m.lateMultiple)
+	if late < m.minLate {
+		return m.minLate
+	}
+	return late
+}
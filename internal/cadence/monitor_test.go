@@ -0,0 +1,122 @@
+package cadence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	gaps []Gap
+	err  error
+}
+
+func (f *fakeStore) UpsertGaps(gaps []Gap) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.gaps = append(f.gaps, gaps...)
+	return nil
+}
+
+func newTestMonitor(now *time.Time) *Monitor {
+	m := NewMonitor(3, 30*time.Second, time.Hour)
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func TestMonitor_Gap(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := newTestMonitor(&now)
+	const ifPath, pfPath = "oc-if:interfaces/interface/state", "oc-platform:components/component/state"
+
+	start := now
+	m.Observe("PE-01", ifPath, 10*time.Second)
+	m.Observe("PE-01", pfPath, 10*time.Second)
+
+	// 平台订阅继续上报，接口订阅静默
+	for i := 0; i < 3; i++ {
+		now = now.Add(10 * time.Second)
+		m.Observe("PE-01", pfPath, 10*time.Second)
+		if opened := m.Check(); len(opened) != 0 {
+			t.Fatalf("t+%v: opened %+v before threshold", now.Sub(start), opened)
+		}
+	}
+	now = now.Add(time.Second)
+	m.Observe("PE-01", pfPath, 10*time.Second)
+	opened := m.Check()
+	if len(opened) != 1 || opened[0].SensorPath != ifPath || !opened[0].Start.Equal(start) || opened[0].ExpectedPeriod != 10*time.Second {
+		t.Fatalf("opened = %+v", opened)
+	}
+	// 已打开的缺口不重复上报
+	if again := m.Check(); len(again) != 0 {
+		t.Fatalf("opened again = %+v", again)
+	}
+	if stats := m.Stats(); stats.Late != 1 || stats.GapsOpened[ifPath] != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	now = now.Add(time.Minute)
+	gap := m.Observe("PE-01", ifPath, 10*time.Second)
+	if gap == nil || !gap.Start.Equal(start) || !gap.End.Equal(now) {
+		t.Fatalf("closed gap = %+v", gap)
+	}
+	if stats := m.Stats(); stats.Late != 0 || stats.GapsClosed != 1 {
+		t.Errorf("stats after close = %+v", stats)
+	}
+}
+
+func TestMonitor_Thresholds(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := newTestMonitor(&now)
+
+	// 周期为0（告警、事件）不监测
+	m.Observe("PE-01", "zxr10-alarm:alarms", 0)
+	// 1秒周期的迟到阈值取下限30秒
+	m.Observe("PE-01", "oc-if:interfaces/interface/state", time.Second)
+
+	now = now.Add(20 * time.Second)
+	if opened := m.Check(); len(opened) != 0 {
+		t.Fatalf("opened before min_late: %+v", opened)
+	}
+	now = now.Add(11 * time.Second)
+	if opened := m.Check(); len(opened) != 1 {
+		t.Fatalf("opened = %+v, want 1", opened)
+	}
+	if stats := m.Stats(); stats.Series != 1 {
+		t.Errorf("Series = %d, want 1", stats.Series)
+	}
+
+	// 迟到超过stateTTL的序列被清理
+	now = now.Add(2 * time.Hour)
+	m.Check()
+	if stats := m.Stats(); stats.Series != 0 {
+		t.Errorf("Series after ttl = %d, want 0", stats.Series)
+	}
+}
+
+func TestMonitor_Flush(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := newTestMonitor(&now)
+	store := &fakeStore{}
+
+	m.Observe("PE-01", "oc-if:interfaces/interface/state", 10*time.Second)
+	now = now.Add(time.Minute)
+	m.Check()
+
+	store.err = errors.New("db down")
+	if _, err := m.Flush(store); err == nil {
+		t.Fatal("Flush should fail")
+	}
+	store.err = nil
+	m.Observe("PE-01", "oc-if:interfaces/interface/state", 10*time.Second)
+	if n, err := m.Flush(store); n != 2 || err != nil {
+		t.Fatalf("Flush = %d, %v", n, err)
+	}
+	if !store.gaps[0].End.IsZero() || store.gaps[1].End.IsZero() {
+		t.Errorf("gaps = %+v", store.gaps)
+	}
+	if n, _ := m.Flush(store); n != 0 {
+		t.Errorf("Flush without change = %d", n)
+	}
+}
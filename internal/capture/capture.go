@@ -0,0 +1,134 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	dialout "github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+// 原始Publish报文抓包文件
+//
+// 文件以 Magic 开头，随后是若干条记录，每条记录为：
+//
+//	uint32  记录长度（不含本字段，大端）
+//	int64   接收时间（Unix纳秒，大端）
+//	uint16  对端地址长度（大端）
+//	[]byte  对端地址
+//	[]byte  PublishArgs 的protobuf编码（含ReqId与原始data/json_data）
+//
+// 进程异常退出时最后一条记录可能不完整，读取时返回 io.ErrUnexpectedEOF。
+
+// Magic 抓包文件头
+const Magic = "ZTCAP01\n"
+
+// FileExt 抓包文件扩展名
+const FileExt = ".zcap"
+
+// maxRecordSize 单条记录长度上限，防止读取损坏文件时分配过大内存
+const maxRecordSize = 256 << 20
+
+// Record 一条抓取的Publish报文
+type Record struct {
+	ReceivedAt time.Time
+	Peer       string
+	Args       *dialout.PublishArgs
+}
+
+// Encode 将记录编码为带长度前缀的帧
+func (r *Record) Encode() ([]byte, error) {
+	payload, err := proto.Marshal(r.Args)
+	if err != nil {
+		return nil, fmt.Errorf("编码PublishArgs失败: %v", err)
+	}
+	if len(r.Peer) > 0xFFFF {
+		return nil, fmt.Errorf("对端地址过长: %d", len(r.Peer))
+	}
+
+	size := 8 + 2 + len(r.Peer) + len(payload)
+	frame := make([]byte, 4, 4+size)
+	binary.BigEndian.PutUint32(frame, uint32(size))
+	frame = binary.BigEndian.AppendUint64(frame, uint64(r.ReceivedAt.UnixNano()))
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(r.Peer)))
+	frame = append(frame, r.Peer...)
+	frame = append(frame, payload...)
+	return frame, nil
+}
+
+// Reader 顺序读取抓包文件
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader 校验文件头并创建读取器
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, len(Magic))
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, fmt.Errorf("读取抓包文件头失败: %v", err)
+	}
+	if string(head) != Magic {
+		return nil, errors.New("不是抓包文件（文件头不匹配）")
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next 返回下一条记录，文件结束时返回 io.EOF
+func (rd *Reader) Next() (*Record, error) {
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(rd.r, sizeBuf[:]); err != nil {
+		return nil, err // 恰好在记录边界结束时为 io.EOF
+	}
+	size := binary.BigEndian.Uint32(sizeBuf[:])
+	if size < 10 || size > maxRecordSize {
+		return nil, fmt.Errorf("抓包记录长度异常: %d", size)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(rd.r, frame); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	rec := &Record{ReceivedAt: time.Unix(0, int64(binary.BigEndian.Uint64(frame)))}
+	peerLen := int(binary.BigEndian.Uint16(frame[8:]))
+	if 10+peerLen > len(frame) {
+		return nil, fmt.Errorf("抓包记录对端地址长度异常: %d", peerLen)
+	}
+	rec.Peer = string(frame[10 : 10+peerLen])
+	rec.Args = &dialout.PublishArgs{}
+	if err := proto.Unmarshal(frame[10+peerLen:], rec.Args); err != nil {
+		return nil, fmt.Errorf("解码PublishArgs失败: %v", err)
+	}
+	return rec, nil
+}
+
+// ReadFile 读取整个抓包文件，用于回放与解析器回归测试
+func ReadFile(path string) ([]*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rd, err := NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var records []*Record
+	for {
+		rec, err := rd.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, fmt.Errorf("%s: 第 %d 条记录: %v", path, len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+}
@@ -0,0 +1,124 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	dialout "github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+func testRecord(reqID int64, size int) *Record {
+	return &Record{
+		ReceivedAt: time.Unix(1700000000, int64(reqID)),
+		Peer:       "192.0.2.1:50000",
+		Args:       &dialout.PublishArgs{ReqId: reqID, MessageData: &dialout.PublishArgs_Data{Data: bytes.Repeat([]byte{1}, size)}},
+	}
+}
+
+func TestReader_RoundTrip(t *testing.T) {
+	buf := bytes.NewBufferString(Magic)
+	for id := int64(1); id <= 3; id++ {
+		frame, err := testRecord(id, 10).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(frame)
+	}
+	// 进程异常退出留下的半条记录
+	frame, _ := testRecord(4, 10).Encode()
+	buf.Write(frame[:len(frame)-3])
+
+	rd, err := NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := int64(1); id <= 3; id++ {
+		rec, err := rd.Next()
+		if err != nil {
+			t.Fatalf("Next #%d: %v", id, err)
+		}
+		want := testRecord(id, 10)
+		if rec.Args.ReqId != id || !rec.ReceivedAt.Equal(want.ReceivedAt) || rec.Peer != want.Peer || len(rec.Args.GetData()) != 10 {
+			t.Errorf("record #%d = %+v", id, rec)
+		}
+	}
+	if _, err := rd.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated record: err = %v, want ErrUnexpectedEOF", err)
+	}
+
+	if _, err := NewReader(bytes.NewBufferString("NOTCAP00")); err == nil {
+		t.Error("NewReader accepted a file without magic")
+	}
+}
+
+func TestRecorder_RotateAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRecorder(config.CaptureConfig{Dir: dir, FilePrefix: "test", MaxFileSize: 300, MaxFiles: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := int64(1); id <= 20; id++ {
+		if err := r.Write(testRecord(id, 100)); err != nil {
+			t.Fatalf("Write #%d: %v", id, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if records, dropped := r.Stats(); records != 20 || dropped != 0 {
+		t.Errorf("Stats = %d, %d", records, dropped)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "test-*"+FileExt))
+	if len(files) != 3 {
+		t.Fatalf("files = %v, want 3", files)
+	}
+	// 每个文件2条记录，保留的是最后6条
+	var ids []int64
+	for _, f := range files {
+		if info, _ := os.Stat(f); info.Size() > 300 {
+			t.Errorf("%s size = %d, want <= 300", f, info.Size())
+		}
+		records, err := ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rec := range records {
+			ids = append(ids, rec.Args.ReqId)
+		}
+	}
+	if len(ids) != 6 || ids[0] != 15 || ids[5] != 20 {
+		t.Errorf("remaining ReqIds = %v, want 15..20", ids)
+	}
+}
+
+func TestRecorder_Match(t *testing.T) {
+	r := &Recorder{
+		systemIDs:   map[string]struct{}{"PE-01": {}},
+		sensorPaths: []string{"oc-if:"},
+	}
+	tests := []struct {
+		systemID, sensorPath string
+		parsed               bool
+		want                 bool
+	}{
+		{"PE-01", "oc-if:interfaces/interface/state/counters", true, true},
+		{"PE-01", "oc-platform:components/component", true, false},
+		{"PE-02", "oc-if:interfaces/interface/state/counters", true, false},
+		{"", "", false, false},
+	}
+	for _, tt := range tests {
+		if got := r.Match(tt.systemID, tt.sensorPath, tt.parsed); got != tt.want {
+			t.Errorf("Match(%q, %q, %v) = %v, want %v", tt.systemID, tt.sensorPath, tt.parsed, got, tt.want)
+		}
+	}
+	r.includeParseErrors = true
+	if !r.Match("", "", false) {
+		t.Error("parse errors should be captured when include_parse_errors is set")
+	}
+}
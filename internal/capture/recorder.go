@@ -0,0 +1,187 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+// Recorder 将Publish报文写入按大小轮转的抓包文件
+type Recorder struct {
+	dir                string
+	prefix             string
+	maxFileSize        int64
+	maxFiles           int
+	systemIDs          map[string]struct{}
+	sensorPaths        []string
+	includeParseErrors bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	records  int64
+	dropped  int64 // 写入失败的记录数
+	lastName string
+	fileSeq  int // 同一秒内轮转时的文件序号
+}
+
+// NewRecorder 创建抓包记录器并打开第一个文件
+func NewRecorder(cfg config.CaptureConfig) (*Recorder, error) {
+	r := &Recorder{
+		dir:                cfg.Dir,
+		prefix:             cfg.FilePrefix,
+		maxFileSize:        cfg.MaxFileSize,
+		maxFiles:           cfg.MaxFiles,
+		sensorPaths:        cfg.SensorPaths,
+		includeParseErrors: cfg.IncludeParseErrors,
+	}
+	if r.prefix == "" {
+		r.prefix = "publish"
+	}
+	if len(cfg.SystemIDs) > 0 {
+		r.systemIDs = make(map[string]struct{}, len(cfg.SystemIDs))
+		for _, id := range cfg.SystemIDs {
+			r.systemIDs[id] = struct{}{}
+		}
+	}
+	if err := os.MkdirAll(r.dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建抓包目录失败: %v", err)
+	}
+	if err := r.rotate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Match 按system_id与sensor_path（前缀）过滤，解析失败的报文由include_parse_errors决定
+func (r *Recorder) Match(systemID, sensorPath string, parsed bool) bool {
+	if !parsed {
+		return r.includeParseErrors
+	}
+	if r.systemIDs != nil {
+		if _, ok := r.systemIDs[systemID]; !ok {
+			return false
+		}
+	}
+	if len(r.sensorPaths) == 0 {
+		return true
+	}
+	for _, prefix := range r.sensorPaths {
+		if strings.HasPrefix(sensorPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write 写入一条记录，超过文件大小上限时轮转
+func (r *Recorder) Write(rec *Record) error {
+	frame, err := rec.Encode()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("抓包文件已关闭")
+	}
+	if r.maxFileSize > 0 && r.size+int64(len(frame)) > r.maxFileSize && r.size > int64(len(Magic)) {
+		if err := r.rotate(); err != nil {
+			r.dropped++
+			return err
+		}
+	}
+	n, err := r.file.Write(frame)
+	r.size += int64(n)
+	if err != nil {
+		r.dropped++
+		return fmt.Errorf("写入抓包文件失败: %v", err)
+	}
+	r.records++
+	return nil
+}
+
+// Stats 返回已写入与写入失败的记录数
+func (r *Recorder) Stats() (records, dropped int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.records, r.dropped
+}
+
+// Close 关闭当前文件
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// rotate 关闭当前文件，打开新文件并清理超出数量的旧文件（调用方持有锁或尚未共享）
+func (r *Recorder) rotate() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	name := r.prefix + "-" + time.Now().Format("20060102-150405")
+	if name == r.lastName {
+		r.fileSeq++
+	} else {
+		r.lastName, r.fileSeq = name, 0
+	}
+	if r.fileSeq > 0 {
+		name = fmt.Sprintf("%s.%d", name, r.fileSeq)
+	}
+	path := filepath.Join(r.dir, name+FileExt)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+	if err != nil {
+		return fmt.Errorf("创建抓包文件失败: %v", err)
+	}
+	if _, err := f.WriteString(Magic); err != nil {
+		f.Close()
+		return fmt.Errorf("写入抓包文件头失败: %v", err)
+	}
+	r.file = f
+	r.size = int64(len(Magic))
+	r.prune()
+	return nil
+}
+
+// prune 只保留最新的maxFiles个抓包文件
+func (r *Recorder) prune() {
+	if r.maxFiles <= 0 {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(r.dir, r.prefix+"-*"+FileExt))
+	if err != nil || len(files) <= r.maxFiles {
+		return
+	}
+	// 文件名中的时间与序号保证按名称排序即按创建顺序
+	sort.Slice(files, func(i, j int) bool { return fileOrder(files[i]) < fileOrder(files[j]) })
+	for _, f := range files[:len(files)-r.maxFiles] {
+		os.Remove(f)
+	}
+}
+
+// fileOrder 将 prefix-YYYYMMDD-HHMMSS[.N].zcap 转为可排序的键，序号补齐位数
+func fileOrder(path string) string {
+	base := strings.TrimSuffix(filepath.Base(path), FileExt)
+	stamp, seq, _ := strings.Cut(base, ".")
+	n, _ := strconv.Atoi(seq)
+	return fmt.Sprintf("%s.%06d", stamp, n)
+}
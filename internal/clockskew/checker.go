@@ -0,0 +1,213 @@
+package clockskew
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+// 设备时钟偏差检测
+//
+// 所有行的时间戳取自设备的 msg_timestamp。设备NTP失准时数据会落在数小时之前或之后的
+// hypertable分块中。Checker 比较 msg_timestamp 与采集器接收时间，偏差（设备时间 - 接收时间）
+// 超过 max_skew 时按策略处理：
+//   - trust 信任设备时间，只记录偏差
+//   - replace 以接收时间为准，所有行的时间戳平移偏差量
+//   - reject 拒绝该报文
+//
+// 网络与批量发送带来的秒级延迟属于正常偏差，max_skew 应明显大于它。
+
+// Policy 偏差超限时的处理策略
+type Policy string
+
+const (
+	Trust   Policy = "trust"
+	Replace Policy = "replace"
+	Reject  Policy = "reject"
+)
+
+// Violation 时钟偏差超限且策略为reject
+type Violation struct {
+	SystemID string
+	Skew     time.Duration
+	MaxSkew  time.Duration
+}
+
+// Error 返回给设备的错误描述（PublishArgs.errors）
+func (v *Violation) Error() string {
+	direction := "ahead of"
+	skew := v.Skew
+	if skew < 0 {
+		direction, skew = "behind", -skew
+	}
+	return fmt.Sprintf("clock skew: system_id=%s timestamp is %v %s collector, limit %v",
+		v.SystemID, skew.Round(time.Second), direction, v.MaxSkew)
+}
+
+// Decision 单条报文的检查结果
+type Decision struct {
+	Skew    time.Duration // 设备时间 - 接收时间
+	Replace bool          // 时间戳应平移 -Skew
+	Reject  *Violation
+}
+
+// Stats 时钟偏差统计
+type Stats struct {
+	Skew     map[string]time.Duration // 每台设备最近一次的偏差
+	Replaced int64
+	Rejected int64
+}
+
+type rule struct {
+	policy  Policy
+	maxSkew time.Duration
+}
+
+type deviceSkew struct {
+	skew     time.Duration
+	lastSeen time.Time
+}
+
+// Checker 时钟偏差检查器
+type Checker struct {
+	defaults  rule
+	overrides map[string]rule
+	stateTTL  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	devices  map[string]*deviceSkew
+	replaced int64
+	rejected int64
+}
+
+// NewChecker 根据配置创建检查器，策略配置错误时返回错误
+func NewChecker(cfg config.ClockSkewConfig) (*Checker, error) {
+	defaults, err := newRule(cfg.Policy, cfg.MaxSkew, rule{policy: Trust})
+	if err != nil {
+		return nil, err
+	}
+	c := &Checker{
+		defaults:  defaults,
+		overrides: make(map[string]rule, len(cfg.Devices)),
+		stateTTL:  cfg.StateTTL,
+		now:       time.Now,
+		devices:   make(map[string]*deviceSkew),
+	}
+	for _, d := range cfg.Devices {
+		if d.SystemID == "" {
+			return nil, fmt.Errorf("时钟偏差设备配置缺少system_id")
+		}
+		r, err := newRule(d.Policy, d.MaxSkew, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("system_id=%s 时钟偏差配置错误: %v", d.SystemID, err)
+		}
+		c.overrides[d.SystemID] = r
+	}
+	return c, nil
+}
+
+// newRule 校验策略，未配置的字段取fallback
+func newRule(policy string, maxSkew time.Duration, fallback rule) (rule, error) {
+	r := fallback
+	switch Policy(policy) {
+	case Trust, Replace, Reject:
+		r.policy = Policy(policy)
+	case "":
+	default:
+		return r, fmt.Errorf("不支持的时钟偏差策略: %s（可选 trust/replace/reject）", policy)
+	}
+	if maxSkew < 0 {
+		return r, fmt.Errorf("max_skew不能为负数: %v", maxSkew)
+	}
+	if maxSkew > 0 {
+		r.maxSkew = maxSkew
+	}
+	if r.policy != Trust && r.maxSkew == 0 {
+		return r, fmt.Errorf("策略%s需要配置max_skew", r.policy)
+	}
+	return r, nil
+}
+
+// Check 比较报文时间与接收时间，msgTime为零值时不检查
+func (c *Checker) Check(systemID string, msgTime time.Time) Decision {
+	var d Decision
+	if systemID == "" || msgTime.IsZero() {
+		return d
+	}
+	now := c.now()
+	d.Skew = msgTime.Sub(now)
+
+	r, ok := c.overrides[systemID]
+	if !ok {
+		r = c.defaults
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ds, ok := c.devices[systemID]
+	if !ok {
+		ds = &deviceSkew{}
+		c.devices[systemID] = ds
+	}
+	ds.skew = d.Skew
+	ds.lastSeen = now
+
+	if r.policy == Trust || abs(d.Skew) <= r.maxSkew {
+		return d
+	}
+	if r.policy == Replace {
+		d.Replace = true
+		c.replaced++
+		return d
+	}
+	d.Reject = &Violation{SystemID: systemID, Skew: d.Skew, MaxSkew: r.maxSkew}
+	c.rejected++
+	return d
+}
+
+// Expire 清理超过stateTTL未上报的设备偏差，返回清理数量
+func (c *Checker) Expire() int {
+	if c.stateTTL <= 0 {
+		return 0
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for systemID, ds := range c.devices {
+		if now.Sub(ds.lastSeen) > c.stateTTL {
+			delete(c.devices, systemID)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Stats 返回时钟偏差统计
+func (c *Checker) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{
+		Skew:     make(map[string]time.Duration, len(c.devices)),
+		Replaced: c.replaced,
+		Rejected: c.rejected,
+	}
+	for systemID, ds := range c.devices {
+		stats.Skew[systemID] = ds.skew
+	}
+	return stats
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,80 @@
+package clockskew
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+func TestChecker_Check(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c, err := NewChecker(config.ClockSkewConfig{
+		Policy:  "replace",
+		MaxSkew: time.Minute,
+		Devices: []config.ClockSkewOverride{
+			{SystemID: "PE-TRUST", Policy: "trust"},
+			{SystemID: "PE-STRICT", Policy: "reject", MaxSkew: 10 * time.Second},
+		},
+		StateTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return now }
+
+	tests := []struct {
+		systemID    string
+		msgTime     time.Time
+		wantReplace bool
+		wantReject  bool
+	}{
+		{"PE-01", now.Add(-2 * time.Second), false, false},
+		{"PE-01", now.Add(3 * time.Hour), true, false},
+		{"PE-01", now.Add(-2 * time.Hour), true, false},
+		{"PE-TRUST", now.Add(3 * time.Hour), false, false},
+		{"PE-STRICT", now.Add(-30 * time.Second), false, true},
+		{"PE-STRICT", now.Add(5 * time.Second), false, false},
+		{"PE-01", time.Time{}, false, false},
+	}
+	for _, tt := range tests {
+		d := c.Check(tt.systemID, tt.msgTime)
+		if d.Replace != tt.wantReplace || (d.Reject != nil) != tt.wantReject {
+			t.Errorf("Check(%s, %v) = %+v", tt.systemID, tt.msgTime.Sub(now), d)
+		}
+		if !tt.msgTime.IsZero() && d.Skew != tt.msgTime.Sub(now) {
+			t.Errorf("Skew = %v, want %v", d.Skew, tt.msgTime.Sub(now))
+		}
+	}
+
+	stats := c.Stats()
+	if stats.Replaced != 2 || stats.Rejected != 1 || stats.Skew["PE-TRUST"] != 3*time.Hour || len(stats.Skew) != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if removed := c.Expire(); removed != 3 {
+		t.Errorf("Expire = %d, want 3", removed)
+	}
+}
+
+func TestViolation_Error(t *testing.T) {
+	v := &Violation{SystemID: "PE-01", Skew: -90 * time.Minute, MaxSkew: 5 * time.Minute}
+	if got := v.Error(); !strings.Contains(got, "1h30m0s behind collector") || !strings.HasPrefix(got, "clock skew:") {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestNewChecker_Invalid(t *testing.T) {
+	for _, cfg := range []config.ClockSkewConfig{
+		{Policy: "fix"},
+		{Policy: "reject"},
+		{Policy: "trust", Devices: []config.ClockSkewOverride{{Policy: "trust"}}},
+		{Policy: "replace", MaxSkew: -time.Second},
+	} {
+		if _, err := NewChecker(cfg); err == nil {
+			t.Errorf("NewChecker(%+v) should fail", cfg)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package collection
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 采样轮次重组
+//
+// 设备一次采样常拆成几十到上百包，同一轮次的包 collection_id 相同，
+// 只有首包携带 collection_start_time，只有末包携带 collection_end_time。
+// Tracker 按 (system_id, subscription_id, collection_id) 跟踪轮次，
+// 给出轮次开始时间用于统一行时间戳，并统计超时未结束（丢包）的轮次。
+
+// Packet 单个报文的轮次信息
+type Packet struct {
+	SystemID       string
+	SubscriptionID string
+	CollectionID   uint64
+	StartTime      time.Time // 仅首包非零
+	EndTime        time.Time // 仅末包非零
+	MsgTime        time.Time
+}
+
+// Round 轮次状态
+type Round struct {
+	SystemID       string
+	SubscriptionID string
+	CollectionID   uint64
+	Start          time.Time // 轮次开始时间；首包未到时为已收到报文的最早msg_timestamp
+	Packets        int
+	Complete       bool
+}
+
+// Stats 轮次统计（累计值）
+type Stats struct {
+	PacketsTracked   int64
+	RoundsCompleted  int64
+	RoundsIncomplete int64
+	ActiveRounds     int
+}
+
+type roundKey struct {
+	systemID       string
+	subscriptionID string
+	collectionID   uint64
+}
+
+type roundState struct {
+	start      time.Time
+	startKnown bool
+	packets    int
+	lastSeen   time.Time
+}
+
+// Tracker 采样轮次跟踪器
+type Tracker struct {
+	timeout time.Duration
+	now     func() time.Time
+
+	mu     sync.Mutex
+	rounds map[roundKey]*roundState
+
+	packetsTracked   int64
+	roundsCompleted  int64
+	roundsIncomplete int64
+}
+
+// NewTracker 创建轮次跟踪器，timeout为轮次未结束的最长等待时间
+func NewTracker(timeout time.Duration) *Tracker {
+	return &Tracker{
+		timeout: timeout,
+		now:     time.Now,
+		rounds:  make(map[roundKey]*roundState),
+	}
+}
+
+// Observe 记录一个报文并返回所属轮次的状态；collection_id为0时不跟踪，返回零值
+func (t *Tracker) Observe(p Packet) Round {
+	if p.CollectionID == 0 {
+		return Round{}
+	}
+	atomic.AddInt64(&t.packetsTracked, 1)
+
+	key := roundKey{systemID: p.SystemID, subscriptionID: p.SubscriptionID, collectionID: p.CollectionID}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.rounds[key]
+	if !ok {
+		state = &roundState{start: p.MsgTime}
+		t.rounds[key] = state
+	}
+	state.packets++
+	state.lastSeen = t.now()
+
+	switch {
+	case !p.StartTime.IsZero():
+		state.start = p.StartTime
+		state.startKnown = true
+	case !state.startKnown && !p.MsgTime.IsZero() && (state.start.IsZero() || p.MsgTime.Before(state.start)):
+		state.start = p.MsgTime
+	}
+
+	round := Round{
+		SystemID:       p.SystemID,
+		SubscriptionID: p.SubscriptionID,
+		CollectionID:   p.CollectionID,
+		Start:          state.start,
+		Packets:        state.packets,
+	}
+
+	if !p.EndTime.IsZero() {
+		round.Complete = true
+		delete(t.rounds, key)
+		atomic.AddInt64(&t.roundsCompleted, 1)
+	}
+	return round
+}
+
+// Expire 移除超时未结束的轮次并返回，这些轮次计为不完整
+func (t *Tracker) Expire() []Round {
+	deadline := t.now().Add(-t.timeout)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var expired []Round
+	for key, state := range t.rounds {
+		if state.lastSeen.After(deadline) {
+			continue
+		}
+		expired = append(expired, Round{
+			SystemID:       key.systemID,
+			SubscriptionID: key.subscriptionID,
+			CollectionID:   key.collectionID,
+			Start:          state.start,
+			Packets:        state.packets,
+		})
+		delete(t.rounds, key)
+	}
+	atomic.AddInt64(&t.roundsIncomplete, int64(len(expired)))
+	return expired
+}
+
+// Stats 获取轮次统计
+func (t *Tracker) Stats() Stats {
+	t.mu.Lock()
+	active := len(t.rounds)
+	t.mu.Unlock()
+
+	return Stats{
+		PacketsTracked:   atomic.LoadInt64(&t.packetsTracked),
+		RoundsCompleted:  atomic.LoadInt64(&t.roundsCompleted),
+		RoundsIncomplete: atomic.LoadInt64(&t.roundsIncomplete),
+		ActiveRounds:     active,
+	}
+}
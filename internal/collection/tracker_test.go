@@ -0,0 +1,80 @@
+package collection
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker_RoundStartAndComplete(t *testing.T) {
+	tr := NewTracker(time.Minute)
+	start := time.UnixMilli(1700000000000)
+
+	first := tr.Observe(Packet{SystemID: "dev-1", SubscriptionID: "s1", CollectionID: 7, StartTime: start, MsgTime: start.Add(10 * time.Millisecond)})
+	if !first.Start.Equal(start) || first.Complete {
+		t.Fatalf("first = %+v", first)
+	}
+
+	middle := tr.Observe(Packet{SystemID: "dev-1", SubscriptionID: "s1", CollectionID: 7, MsgTime: start.Add(900 * time.Millisecond)})
+	if !middle.Start.Equal(start) || middle.Packets != 2 {
+		t.Fatalf("middle = %+v", middle)
+	}
+
+	// 另一订阅的同一collection_id是独立轮次
+	other := tr.Observe(Packet{SystemID: "dev-1", SubscriptionID: "s2", CollectionID: 7, MsgTime: start.Add(time.Second)})
+	if other.Packets != 1 {
+		t.Fatalf("other = %+v", other)
+	}
+
+	last := tr.Observe(Packet{SystemID: "dev-1", SubscriptionID: "s1", CollectionID: 7, EndTime: start.Add(2 * time.Second), MsgTime: start.Add(2 * time.Second)})
+	if !last.Complete || !last.Start.Equal(start) || last.Packets != 3 {
+		t.Fatalf("last = %+v", last)
+	}
+
+	stats := tr.Stats()
+	if stats.PacketsTracked != 4 || stats.RoundsCompleted != 1 || stats.ActiveRounds != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestTracker_StartBeforeFirstPacket(t *testing.T) {
+	tr := NewTracker(time.Minute)
+	base := time.UnixMilli(1700000000000)
+
+	// 首包未到时使用已收到报文的最早时间
+	r := tr.Observe(Packet{SystemID: "dev-1", CollectionID: 1, MsgTime: base.Add(2 * time.Second)})
+	if !r.Start.Equal(base.Add(2 * time.Second)) {
+		t.Fatalf("Start = %v", r.Start)
+	}
+	r = tr.Observe(Packet{SystemID: "dev-1", CollectionID: 1, MsgTime: base.Add(time.Second)})
+	if !r.Start.Equal(base.Add(time.Second)) {
+		t.Fatalf("Start = %v", r.Start)
+	}
+	r = tr.Observe(Packet{SystemID: "dev-1", CollectionID: 1, StartTime: base, MsgTime: base.Add(3 * time.Second)})
+	if !r.Start.Equal(base) {
+		t.Fatalf("Start = %v, want collection_start_time", r.Start)
+	}
+}
+
+func TestTracker_UntrackedAndExpire(t *testing.T) {
+	tr := NewTracker(time.Minute)
+	now := time.Unix(1700000000, 0)
+	tr.now = func() time.Time { return now }
+
+	if r := tr.Observe(Packet{SystemID: "dev-1", MsgTime: now}); !r.Start.IsZero() {
+		t.Errorf("collection_id=0 should not be tracked: %+v", r)
+	}
+
+	tr.Observe(Packet{SystemID: "dev-1", CollectionID: 9, StartTime: now, MsgTime: now})
+	if expired := tr.Expire(); len(expired) != 0 {
+		t.Fatalf("expired too early: %+v", expired)
+	}
+
+	now = now.Add(2 * time.Minute)
+	expired := tr.Expire()
+	if len(expired) != 1 || expired[0].CollectionID != 9 || expired[0].Packets != 1 {
+		t.Fatalf("expired = %+v", expired)
+	}
+	if stats := tr.Stats(); stats.RoundsIncomplete != 1 || stats.ActiveRounds != 0 || stats.PacketsTracked != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/wwswwsuns/ztelem/internal/access"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+)
+
+func TestSimpleCollector_CheckAccessSpoofedMngIP(t *testing.T) {
+	c := newTestCollector()
+	checker, err := access.NewChecker(config.AccessConfig{
+		Devices: []config.DeviceBinding{
+			{SystemID: "PE-01", Sources: []string{"10.1.1.1"}},
+			{SystemID: "PE-02", LearnSource: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.access = checker
+
+	attacker := &streamPeer{remoteAddr: "10.2.0.9:50000", addr: netip.MustParseAddr("10.2.0.9")}
+	device := &streamPeer{remoteAddr: "10.1.1.2:50000", addr: netip.MustParseAddr("10.1.1.2")}
+	if err := c.checkAccess(&parser.ParseResult{SystemID: "PE-02", MngIPv4: "10.1.1.2"}, device); err != nil {
+		t.Fatalf("first contact from PE-02: %v", err)
+	}
+
+	// 报文自带的管理口地址等于攻击方的对端地址，仍按登记或学习到的来源拒绝
+	for _, systemID := range []string{"PE-01", "PE-02"} {
+		err := c.checkAccess(&parser.ParseResult{SystemID: systemID, MngIPv4: "10.2.0.9"}, attacker)
+		var v *access.Violation
+		if !errors.As(err, &v) || v.Reason != access.ReasonSourceMismatch {
+			t.Errorf("%s spoofed with mng_ipv4 = peer: err = %v, want source_mismatch", systemID, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/cadence"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+)
+
+// observeCadence 记录序列到达，结束该序列的数据缺口
+func (c *SimpleCollector) observeCadence(result *parser.ParseResult) {
+	if c.cadence == nil {
+		return
+	}
+	if gap := c.cadence.Observe(result.SystemID, result.SensorPath, result.CurrentPeriod); gap != nil {
+		c.logger.Infof("数据恢复: system_id=%s, sensor_path=%s, 缺口 %s ~ %s (%v)",
+			gap.SystemID, gap.SensorPath, gap.Start.Format(time.RFC3339), gap.End.Format(time.RFC3339),
+			gap.End.Sub(gap.Start).Round(time.Second))
+	}
+}
+
+// checkCadence 检查迟到的序列并写入数据缺口
+func (c *SimpleCollector) checkCadence() {
+	for _, gap := range c.cadence.Check() {
+		c.logger.Warnf("数据迟到: system_id=%s, sensor_path=%s, 周期=%v, 最后数据=%s",
+			gap.SystemID, gap.SensorPath, gap.ExpectedPeriod, gap.Start.Format(time.RFC3339))
+	}
+	c.persistGaps()
+}
+
+// persistGaps 将数据缺口变化写入存储
+func (c *SimpleCollector) persistGaps() {
+	if c.cadence == nil || c.gapStore == nil {
+		return
+	}
+	if _, err := c.cadence.Flush(c.gapStore); err != nil {
+		c.logger.Errorf("写入数据缺口失败: %v", err)
+	}
+}
+
+// GetCadenceStats 获取上报周期监测统计
+func (c *SimpleCollector) GetCadenceStats() cadence.Stats {
+	if c.cadence == nil {
+		return cadence.Stats{}
+	}
+	return c.cadence.Stats()
+}
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/access"
+	"github.com/wwswwsuns/ztelem/internal/cadence"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+func TestSimpleCollector_RejectedMessageNotCadenceArrival(t *testing.T) {
+	c := newTestCollector()
+	c.cadence = cadence.NewMonitor(3, time.Second, time.Hour)
+	checker, err := access.NewChecker(config.AccessConfig{RequireRegistered: true, MismatchPolicy: "drop_message"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.access = checker
+
+	result := &parser.ParseResult{SystemID: "PE-01", SensorPath: "oc-if:interfaces/interface/state", CurrentPeriod: 10 * time.Second}
+	if err := c.handleParseResults(&proto.PublishArgs{ReqId: 1}, []*parser.ParseResult{result}, &streamPeer{remoteAddr: "10.2.0.9:50000", addr: netip.MustParseAddr("10.2.0.9")}); err == nil {
+		t.Fatal("unregistered system_id accepted")
+	}
+	if n := c.GetCadenceStats().Series; n != 0 {
+		t.Errorf("rejected message tracked %d cadence series", n)
+	}
+}
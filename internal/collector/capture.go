@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/capture"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+// captureMessage 按过滤条件将原始报文写入抓包文件，result为nil表示解析失败
+func (c *SimpleCollector) captureMessage(req *proto.PublishArgs, src *streamPeer, receivedAt time.Time, result *parser.ParseResult) {
+	if c.capture == nil {
+		return
+	}
+	var systemID, sensorPath string
+	if result != nil {
+		systemID, sensorPath = result.SystemID, result.SensorPath
+	}
+	if !c.capture.Match(systemID, sensorPath, result != nil) {
+		return
+	}
+	if err := c.capture.Write(&capture.Record{ReceivedAt: receivedAt, Peer: src.remoteAddr, Args: req}); err != nil {
+		c.logger.WithError(err).Warn("写入抓包文件失败")
+	}
+}
+
+// closeCapture 关闭抓包文件
+func (c *SimpleCollector) closeCapture() {
+	if c.capture == nil {
+		return
+	}
+	records, dropped := c.capture.Stats()
+	if err := c.capture.Close(); err != nil {
+		c.logger.WithError(err).Warn("关闭抓包文件失败")
+	}
+	c.logger.Infof("抓包已关闭: 写入 %d 条, 失败 %d 条", records, dropped)
+}
+
+// GetCaptureStats 获取抓包写入与失败的记录数
+func (c *SimpleCollector) GetCaptureStats() (records, dropped int64) {
+	if c.capture == nil {
+		return 0, 0
+	}
+	return c.capture.Stats()
+}
+
+// ProcessCaptured 将一条抓包记录送入与Publish相同的解析与写入流程，用于本地回放。
+// 同一对端地址的记录视为同一条流。
+func (c *SimpleCollector) ProcessCaptured(rec *capture.Record) error {
+	src := &streamPeer{connID: "replay:" + rec.Peer, remoteAddr: rec.Peer}
+	if ap, err := netip.ParseAddrPort(rec.Peer); err == nil {
+		src.addr = ap.Addr().Unmap()
+	}
+	return c.processPublishArgs(rec.Args, src, rec.ReceivedAt)
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/capture"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+func TestSimpleCollector_CaptureMessage(t *testing.T) {
+	c := newTestCollector()
+	recorder, err := capture.NewRecorder(config.CaptureConfig{Dir: t.TempDir(), SystemIDs: []string{"PE-01"}, IncludeParseErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.capture = recorder
+	src := &streamPeer{connID: "1", remoteAddr: "10.1.1.1:50000"}
+	now := time.Now()
+
+	req := &proto.PublishArgs{ReqId: 1, MessageData: &proto.PublishArgs_Data{Data: []byte("x")}}
+	c.captureMessage(req, src, now, &parser.ParseResult{SystemID: "PE-01"})
+	c.captureMessage(req, src, now, &parser.ParseResult{SystemID: "PE-02"})
+
+	// 无法解析的报文在返回解析错误前抓取
+	bad := &proto.PublishArgs{ReqId: 2, MessageData: &proto.PublishArgs_Data{Data: []byte{0xff, 0xff}}}
+	if err := c.processPublishArgs(bad, src, now); publishErrorReason(err) != ReasonParseError {
+		t.Fatalf("processPublishArgs err = %v, want parse error", err)
+	}
+
+	c.closeCapture()
+	if records, dropped := c.GetCaptureStats(); records != 2 || dropped != 0 {
+		t.Errorf("capture stats = %d, %d, want 2, 0", records, dropped)
+	}
+}
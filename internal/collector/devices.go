@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/wwswwsuns/ztelem/internal/devices"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+)
+
+// observeDevice 将报文中的设备信息记入设备登记，发现重复流时告警
+func (c *SimpleCollector) observeDevice(result *parser.ParseResult, src *streamPeer) {
+	if c.devices == nil {
+		return
+	}
+	dup := c.devices.Observe(devices.Observation{
+		SystemID:       result.SystemID,
+		StreamID:       src.connID,
+		Peer:           src.remoteAddr,
+		SubscriptionID: result.SubscriptionID,
+		SensorPath:     result.SensorPath,
+		MngIPv4:        result.MngIPv4,
+		MngIPv6:        result.MngIPv6,
+		CurrentPeriod:  result.CurrentPeriod,
+	})
+	if dup != nil {
+		c.logger.Warnf("设备重复流: system_id=%s, subscription_id=%s, 流=%s（旧流可能未断开）",
+			dup.SystemID, dup.SubscriptionID, strings.Join(dup.StreamIDs, ","))
+	}
+}
+
+// persistDevices 将有变化的设备写入存储
+func (c *SimpleCollector) persistDevices() {
+	if c.devices == nil || c.deviceStore == nil {
+		return
+	}
+	n, err := c.devices.Flush(c.deviceStore)
+	if err != nil {
+		c.logger.Errorf("写入设备登记失败: %v", err)
+		return
+	}
+	if n > 0 {
+		c.logger.Debugf("设备登记已更新: %d 台", n)
+	}
+}
+
+// ListDevices 返回设备登记，未启用时为nil
+func (c *SimpleCollector) ListDevices() []devices.Device {
+	if c.devices == nil {
+		return nil
+	}
+	return c.devices.Devices()
+}
+
+// GetDeviceStats 获取设备登记统计
+func (c *SimpleCollector) GetDeviceStats() devices.Stats {
+	if c.devices == nil {
+		return devices.Stats{}
+	}
+	return c.devices.Stats()
+}
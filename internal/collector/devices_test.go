@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/access"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+func TestSimpleCollector_ObserveDevice(t *testing.T) {
+	c := newTestCollector()
+	id1, _ := c.registerConnection("10.1.1.1:50000", nil)
+	id2, _ := c.registerConnection("10.1.1.1:50001", nil)
+
+	result := &parser.ParseResult{SystemID: "PE-01", SubscriptionID: "sub-1", SensorPath: "oc-if:interfaces/interface/state",
+		MngIPv4: "10.1.1.1", CurrentPeriod: 10 * time.Second}
+	c.observeDevice(result, &streamPeer{connID: id1, remoteAddr: "10.1.1.1:50000"})
+	c.observeDevice(result, &streamPeer{connID: id2, remoteAddr: "10.1.1.1:50001"})
+
+	list := c.ListDevices()
+	if len(list) != 1 || len(list[0].Streams) != 2 || list[0].DuplicateStreams != 1 || list[0].MngIPv4 != "10.1.1.1" {
+		t.Fatalf("devices = %+v", list)
+	}
+	if stats := c.GetDeviceStats(); stats.DuplicatesDetected != 1 || stats.Reporting != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// 流注销后从设备的当前流中移除
+	c.unregisterConnection(id1)
+	if d := c.ListDevices()[0]; len(d.Streams) != 1 || d.Streams[0].ID != id2 || d.DuplicateStreams != 0 {
+		t.Errorf("device after unregister = %+v", d)
+	}
+}
+
+func TestSimpleCollector_RejectedMessageNotRegistered(t *testing.T) {
+	c := newTestCollector()
+	checker, err := access.NewChecker(config.AccessConfig{RequireRegistered: true, MismatchPolicy: "drop_message"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.access = checker
+	id, _ := c.registerConnection("10.2.0.9:50000", nil)
+
+	result := &parser.ParseResult{SystemID: "PE-01", SubscriptionID: "sub-1", SensorPath: "oc-if:interfaces/interface/state"}
+	src := &streamPeer{connID: id, remoteAddr: "10.2.0.9:50000", addr: netip.MustParseAddr("10.2.0.9")}
+	if err := c.handleParseResults(&proto.PublishArgs{ReqId: 1}, []*parser.ParseResult{result}, src); err == nil {
+		t.Fatal("unregistered system_id accepted")
+	}
+	if list := c.ListDevices(); len(list) != 0 {
+		t.Errorf("rejected message registered devices: %+v", list)
+	}
+}
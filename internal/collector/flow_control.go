@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/access"
+	"github.com/wwswwsuns/ztelem/internal/buffer"
+	"github.com/wwswwsuns/ztelem/internal/clockskew"
+	"github.com/wwswwsuns/ztelem/internal/ratelimit"
+)
+
+// Publish响应错误原因，用作Prometheus标签
+const (
+	ReasonParseError        = "parse_error"
+	ReasonUnknownSensorPath = "unknown_sensor_path"
+	ReasonAccessDenied      = "access_denied"
+	ReasonBufferFull        = "buffer_full"
+	ReasonRateLimited       = "rate_limited"
+	ReasonClockSkew         = "clock_skew"
+	ReasonInternal          = "internal"
+)
+
+// 缓冲区超出上限且overflow_policy拒绝写入时的处理策略（server.flow_control.on_full）
+const (
+	onFullReject   = "reject"
+	onFullDelay    = "delay"
+	onFullWithhold = "withhold"
+)
+
+// flowControlPollInterval delay策略下检查缓冲区是否回落的间隔
+const flowControlPollInterval = 100 * time.Millisecond
+
+// PublishStats Publish响应统计（累计值）
+type PublishStats struct {
+	Errors   map[string]int64 // 按原因统计的错误响应数
+	Delayed  int64            // delay策略下等待过缓冲区回落的报文数
+	Withheld int64            // withhold策略下未应答的报文数
+}
+
+// publishError 单条报文处理失败，错误文本经PublishArgs.errors返回给设备
+type publishError struct {
+	reason string
+	err    error
+}
+
+func (e *publishError) Error() string { return e.err.Error() }
+
+func (e *publishError) Unwrap() error { return e.err }
+
+// publishErrorReason 按错误类型归类响应错误原因
+func publishErrorReason(err error) string {
+	var pe *publishError
+	if errors.As(err, &pe) {
+		return pe.reason
+	}
+	var v *access.Violation
+	if errors.As(err, &v) {
+		return ReasonAccessDenied
+	}
+	var e *ratelimit.Exceeded
+	if errors.As(err, &e) {
+		return ReasonRateLimited
+	}
+	var s *clockskew.Violation
+	if errors.As(err, &s) {
+		return ReasonClockSkew
+	}
+	if errors.Is(err, buffer.ErrBufferFull) {
+		return ReasonBufferFull
+	}
+	return ReasonInternal
+}
+
+// ErrorReason 返回处理错误的归类原因，与Publish错误响应统计的reason一致
+func ErrorReason(err error) string {
+	return publishErrorReason(err)
+}
+
+// validateFlowControl 检查on_full配置
+func validateFlowControl(onFull string) error {
+	switch onFull {
+	case "", onFullReject, onFullDelay, onFullWithhold:
+		return nil
+	}
+	return fmt.Errorf("不支持的flow_control.on_full: %s（可选 reject/delay/withhold）", onFull)
+}
+
+// waitForBuffer delay策略：缓冲区超出上限时暂停读取该流，直到回落或超过max_delay
+// 暂停期间gRPC流控窗口逐渐耗尽，设备侧发送随之阻塞
+func (c *SimpleCollector) waitForBuffer(ctx context.Context) {
+	if c.bufferManager.OverBudget() == "" {
+		return
+	}
+	atomic.AddInt64(&c.publishDelayed, 1)
+
+	deadline := time.NewTimer(c.serverConfig.FlowControl.MaxDelay)
+	defer deadline.Stop()
+	ticker := time.NewTicker(flowControlPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+			if c.bufferManager.OverBudget() == "" {
+				return
+			}
+		}
+	}
+}
+
+// countPublishError 记录一次错误响应
+func (c *SimpleCollector) countPublishError(reason string) {
+	c.publishMu.Lock()
+	c.publishErrors[reason]++
+	c.publishMu.Unlock()
+}
+
+// GetPublishStats 获取Publish响应统计
+func (c *SimpleCollector) GetPublishStats() PublishStats {
+	c.publishMu.Lock()
+	errs := make(map[string]int64, len(c.publishErrors))
+	for reason, n := range c.publishErrors {
+		errs[reason] = n
+	}
+	c.publishMu.Unlock()
+
+	return PublishStats{
+		Errors:   errs,
+		Delayed:  atomic.LoadInt64(&c.publishDelayed),
+		Withheld: atomic.LoadInt64(&c.publishWithheld),
+	}
+}
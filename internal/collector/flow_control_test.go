@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/access"
+	"github.com/wwswwsuns/ztelem/internal/buffer"
+	"github.com/wwswwsuns/ztelem/internal/clockskew"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/internal/ratelimit"
+)
+
+func TestPublishErrorReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&publishError{reason: ReasonParseError, err: errors.New("parse error: bad proto")}, ReasonParseError},
+		{&publishError{reason: ReasonUnknownSensorPath, err: errors.New("unknown sensor_path: foo")}, ReasonUnknownSensorPath},
+		{&access.Violation{Reason: access.ReasonSourceMismatch, Action: access.DropMessage}, ReasonAccessDenied},
+		{&ratelimit.Exceeded{Scope: ratelimit.ScopeDevice, Key: "PE-01", Action: ratelimit.Drop, Rate: 1}, ReasonRateLimited},
+		{&clockskew.Violation{SystemID: "PE-01", Skew: time.Hour, MaxSkew: time.Minute}, ReasonClockSkew},
+		{fmt.Errorf("%w: 10 records buffered, limit 10", buffer.ErrBufferFull), ReasonBufferFull},
+		{errors.New("添加平台指标数据到缓冲区失败"), ReasonInternal},
+	}
+	for _, tt := range tests {
+		if got := publishErrorReason(tt.err); got != tt.want {
+			t.Errorf("publishErrorReason(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+
+	// 响应中的错误文本不带内部原因前缀
+	err := &publishError{reason: ReasonUnknownSensorPath, err: errors.New("unknown sensor_path: foo")}
+	if err.Error() != "unknown sensor_path: foo" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestValidateFlowControl(t *testing.T) {
+	for _, onFull := range []string{"", "reject", "delay", "withhold"} {
+		if err := validateFlowControl(onFull); err != nil {
+			t.Errorf("validateFlowControl(%q) = %v", onFull, err)
+		}
+	}
+	if err := validateFlowControl("block"); err == nil {
+		t.Error("validateFlowControl(block) should fail")
+	}
+}
+
+func TestSimpleCollector_BudgetChecksEventSampleRows(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := newTestCollector()
+	c.bufferManager = buffer.NewFixedBufferManager(nil,
+		config.BufferConfig{FlushInterval: time.Hour, MaxSize: 1, OverflowPolicy: buffer.OverflowDropNewest},
+		config.DatabaseWriterConfig{}, logger)
+	ts := time.Date(2026, 6, 30, 12, 0, 0, 0, time.UTC)
+	if err := c.bufferManager.AddPlatformMetrics([]models.PlatformMetric{{Timestamp: ts, SystemID: "PE-02", ComponentName: "CPU0"}}); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := c.registerConnection("10.1.1.1:50000", nil)
+	src := &streamPeer{connID: id, remoteAddr: "10.1.1.1:50000"}
+	event := models.SelfDefinedEventMetric{Timestamp: ts, SystemID: "PE-01", SensorPath: "oc-platform:components/component/state"}
+
+	// 只有事件行的报文不受上限限制
+	eventOnly := &parser.ParseResult{SystemID: "PE-01", SelfDefinedEventMetrics: []models.SelfDefinedEventMetric{event}}
+	if err := c.handleParseResults(gpbArgs(1, "event"), []*parser.ParseResult{eventOnly}, src); err != nil {
+		t.Fatalf("event rejected over limit: %v", err)
+	}
+
+	// 事件携带的采样行照常检查上限，整条报文被拒绝
+	event.Timestamp = ts.Add(time.Second)
+	withSamples := &parser.ParseResult{SystemID: "PE-01",
+		SelfDefinedEventMetrics: []models.SelfDefinedEventMetric{event},
+		PlatformMetrics:         []models.PlatformMetric{{Timestamp: ts, SystemID: "PE-01", ComponentName: "CPU0"}}}
+	if err := c.handleParseResults(gpbArgs(2, "samples"), []*parser.ParseResult{withSamples}, src); publishErrorReason(err) != ReasonBufferFull {
+		t.Fatalf("err = %v, want buffer full", err)
+	}
+	if n := c.bufferManager.BufferedRecords(); n != 2 {
+		t.Errorf("buffers hold %d records, want 2", n)
+	}
+}
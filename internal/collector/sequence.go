@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"hash/fnv"
+
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/internal/sequence"
+	"github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+// checkSequence 检查报文的ReqId，返回true表示该报文是已写入过的完全重复，应丢弃并照常应答。
+// 需在访问控制等检查通过后调用，result为报文首个解析结果。
+func (c *SimpleCollector) checkSequence(req *proto.PublishArgs, result *parser.ParseResult, src *streamPeer) bool {
+	if c.sequence == nil {
+		return false
+	}
+	reqID := req.ReqId
+	r := c.sequence.Observe(src.connID, result.SystemID, result.SubscriptionID, reqID, publishHash(req))
+	switch {
+	case r.Lost > 0:
+		c.logger.Warnf("ReqId跳号: system_id=%s, 连接=%s, 缺失 %d 条 (%d 之前)", result.SystemID, src.remoteAddr, r.Lost, reqID)
+	case r.Reset:
+		c.logger.Infof("ReqId重新计数: system_id=%s, 连接=%s, ReqId=%d", result.SystemID, src.remoteAddr, reqID)
+	case r.Retry:
+		c.logger.Debugf("ReqId重传(之前未写入): system_id=%s, 连接=%s, ReqId=%d", result.SystemID, src.remoteAddr, reqID)
+	case r.Duplicate:
+		c.logger.Debugf("ReqId重复: system_id=%s, 连接=%s, ReqId=%d, 内容相同=%v", result.SystemID, src.remoteAddr, reqID, r.Exact)
+		if r.Exact && c.sequenceConfig.DropDuplicates {
+			c.sequence.Dropped(src.connID)
+			return true
+		}
+	}
+	return false
+}
+
+// rejectSequence 报文未能写入缓冲区，撤销其ReqId记录
+func (c *SimpleCollector) rejectSequence(req *proto.PublishArgs, src *streamPeer) {
+	if c.sequence == nil {
+		return
+	}
+	c.sequence.Rejected(src.connID, req.ReqId)
+}
+
+// publishHash PublishArgs中GPB与JSON数据的内容摘要
+func publishHash(req *proto.PublishArgs) uint64 {
+	h := fnv.New64a()
+	h.Write(req.GetData())
+	h.Write([]byte(req.GetJsonData()))
+	return h.Sum64()
+}
+
+// GetSequenceCounts 获取按system_id汇总的ReqId统计
+func (c *SimpleCollector) GetSequenceCounts() map[string]sequence.Counts {
+	if c.sequence == nil {
+		return nil
+	}
+	return c.sequence.Counts()
+}
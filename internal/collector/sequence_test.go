@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/buffer"
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/proto/zte_dialout"
+)
+
+func gpbArgs(reqID int64, data string) *proto.PublishArgs {
+	return &proto.PublishArgs{ReqId: reqID, MessageData: &proto.PublishArgs_Data{Data: []byte(data)}}
+}
+
+func TestSimpleCollector_CheckSequence(t *testing.T) {
+	c := newTestCollector()
+	id, _ := c.registerConnection("10.1.1.1:50000", nil)
+	src := &streamPeer{connID: id, remoteAddr: "10.1.1.1:50000"}
+	result := &parser.ParseResult{SystemID: "PE-01", SubscriptionID: "sub-if"}
+
+	if c.checkSequence(gpbArgs(1, "a"), result, src) {
+		t.Fatal("first message dropped")
+	}
+	if c.checkSequence(gpbArgs(4, "d"), result, src) {
+		t.Fatal("message after gap dropped")
+	}
+	// 内容相同的重传被丢弃，内容不同的重复照常处理
+	if !c.checkSequence(gpbArgs(4, "d"), result, src) {
+		t.Error("exact duplicate not dropped")
+	}
+	if c.checkSequence(gpbArgs(4, "x"), result, src) {
+		t.Error("duplicate with different payload dropped")
+	}
+
+	got := c.GetSequenceCounts()["PE-01"]
+	if got.Messages != 4 || got.Lost != 2 || got.Duplicates != 2 || got.Exact != 1 || got.Dropped != 1 {
+		t.Errorf("counts = %+v", got)
+	}
+}
+
+func TestSimpleCollector_WithheldMessageRetransmitted(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	newBuffer := func() *buffer.FixedBufferManager {
+		return buffer.NewFixedBufferManager(nil, config.BufferConfig{FlushInterval: time.Hour, MaxSize: 1, OverflowPolicy: buffer.OverflowDropNewest},
+			config.DatabaseWriterConfig{}, logger)
+	}
+
+	c := newTestCollector()
+	c.serverConfig.FlowControl.OnFull = onFullWithhold
+	c.bufferManager = newBuffer()
+	ts := time.Date(2026, 6, 30, 12, 0, 0, 0, time.UTC)
+	if err := c.bufferManager.AddPlatformMetrics([]models.PlatformMetric{{Timestamp: ts, SystemID: "PE-02", ComponentName: "CPU0"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := c.registerConnection("10.1.1.1:50000", nil)
+	src := &streamPeer{connID: id, remoteAddr: "10.1.1.1:50000"}
+	req := gpbArgs(7, "if-sample")
+	newResult := func() []*parser.ParseResult {
+		return []*parser.ParseResult{{SystemID: "PE-01", SubscriptionID: "sub-if", Timestamp: ts,
+			InterfaceMetrics: []models.InterfaceMetric{{Timestamp: ts, SystemID: "PE-01", InterfaceName: "xgei-0/1/0/1"}}}}
+	}
+
+	// 缓冲区满：不应答，设备稍后重传同一报文
+	if err := c.handleParseResults(req, newResult(), src); publishErrorReason(err) != ReasonBufferFull {
+		t.Fatalf("err = %v, want buffer full", err)
+	}
+
+	c.bufferManager = newBuffer()
+	if err := c.handleParseResults(req, newResult(), src); err != nil {
+		t.Fatalf("retransmit: %v", err)
+	}
+	if n := c.bufferManager.BufferedRecords(); n != 1 {
+		t.Errorf("retransmit buffered %d records, want 1", n)
+	}
+
+	// 写入之后的重传才是可丢弃的重复
+	if err := c.handleParseResults(req, newResult(), src); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.bufferManager.BufferedRecords(); n != 1 {
+		t.Errorf("duplicate buffered, %d records", n)
+	}
+	got := c.GetSequenceCounts()["PE-01"]
+	if got.Retries != 1 || got.Dropped != 1 || got.Exact != 1 {
+		t.Errorf("counts = %+v", got)
+	}
+}
+
+func TestSimpleCollector_RejectedMessageNotPartiallyBuffered(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	newBuffer := func(interfaceLimit int) *buffer.FixedBufferManager {
+		return buffer.NewFixedBufferManager(nil,
+			config.BufferConfig{FlushInterval: time.Hour, InterfaceBufferSize: interfaceLimit, OverflowPolicy: buffer.OverflowDropNewest},
+			config.DatabaseWriterConfig{}, logger)
+	}
+
+	c := newTestCollector()
+	c.bufferManager = newBuffer(1)
+	ts := time.Date(2026, 6, 30, 12, 0, 0, 0, time.UTC)
+	if err := c.bufferManager.AddInterfaceMetrics([]models.InterfaceMetric{{Timestamp: ts, SystemID: "PE-02", InterfaceName: "xgei-0/1/0/1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := c.registerConnection("10.1.1.1:50000", nil)
+	src := &streamPeer{connID: id, remoteAddr: "10.1.1.1:50000"}
+	req := gpbArgs(3, "mixed")
+	newResults := func() []*parser.ParseResult {
+		return []*parser.ParseResult{
+			{SystemID: "PE-01", SubscriptionID: "sub-1", Timestamp: ts,
+				PlatformMetrics: []models.PlatformMetric{{Timestamp: ts, SystemID: "PE-01", ComponentName: "CPU0"}}},
+			{SystemID: "PE-01", SubscriptionID: "sub-1", Timestamp: ts,
+				InterfaceMetrics: []models.InterfaceMetric{{Timestamp: ts, SystemID: "PE-01", InterfaceName: "xgei-0/1/0/1"}}},
+		}
+	}
+
+	// 接口缓冲区已满：整条报文都不写入，平台行不会先行写入
+	if err := c.handleParseResults(req, newResults(), src); publishErrorReason(err) != ReasonBufferFull {
+		t.Fatalf("err = %v, want buffer full", err)
+	}
+	if n := c.bufferManager.BufferedRecords(); n != 1 {
+		t.Fatalf("rejected message buffered %d records", n-1)
+	}
+
+	// 重传时各缓冲区各写入一次
+	c.bufferManager = newBuffer(0)
+	if err := c.handleParseResults(req, newResults(), src); err != nil {
+		t.Fatalf("retransmit: %v", err)
+	}
+	if n := c.bufferManager.BufferedRecords(); n != 2 {
+		t.Errorf("retransmit buffered %d records, want 2", n)
+	}
+}
@@ -1,23 +1,37 @@
 package collector
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/wwswwsuns/ztelem/internal/access"
 	"github.com/wwswwsuns/ztelem/internal/buffer"
+	"github.com/wwswwsuns/ztelem/internal/cadence"
+	"github.com/wwswwsuns/ztelem/internal/capture"
+	"github.com/wwswwsuns/ztelem/internal/clockskew"
 	"github.com/wwswwsuns/ztelem/internal/config"
 	"github.com/wwswwsuns/ztelem/internal/database"
+	"github.com/wwswwsuns/ztelem/internal/devices"
+	"github.com/wwswwsuns/ztelem/internal/collection"
 	"github.com/wwswwsuns/ztelem/internal/parser"
+	"github.com/wwswwsuns/ztelem/internal/ratelimit"
+	"github.com/wwswwsuns/ztelem/internal/rates"
+	"github.com/wwswwsuns/ztelem/internal/sequence"
 	"github.com/wwswwsuns/ztelem/proto/zte_dialout"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 // ConnectionInfo 连接信息
@@ -27,6 +41,33 @@ type ConnectionInfo struct {
 	LastDataTime  time.Time
 	DataCount     int64
 	IsActive      bool
+	Identity      *CertIdentity // 客户端证书身份，未启用TLS或设备未提供证书时为nil
+	RateLimited   int64         // 超出限速被丢弃或拒绝的报文数
+	RowCount      int64         // 写入缓冲区的行数
+
+	// 首条解码报文携带的设备标识
+	SystemID       string
+	SubscriptionID string
+	MngIPv4        string
+
+	// 报文中出现过的 system_id、订阅与sensor_path
+	SystemIDs       map[string]struct{}
+	SubscriptionIDs map[string]struct{}
+	SensorPaths     map[string]struct{}
+
+	LastError     string
+	LastErrorTime time.Time
+
+	// closeCh 通知Publish结束该流，值为关闭原因
+	closeCh chan string
+}
+
+// streamPeer Publish流的对端信息
+type streamPeer struct {
+	connID     string
+	remoteAddr string
+	addr       netip.Addr
+	identity   *CertIdentity
 }
 
 // SimpleCollector 简化的采集器实现
@@ -44,27 +85,121 @@ type SimpleCollector struct {
 	connections     map[string]*ConnectionInfo
 	connectionsMux  sync.RWMutex
 	activeConnCount int64
+	nextConnID      uint64
 	
 	// 数据流超时监控
 	dataTimeout     time.Duration
 	shutdownChan    chan struct{}
 	monitoringDone  chan struct{}
+
+	// 采样轮次重组（未启用时为nil）
+	tracker          *collection.Tracker
+	collectionConfig config.CollectionConfig
+
+	// 计数器速率计算（未启用时为nil）
+	rates *rates.Calculator
+
+	// TLS证书热加载（未启用TLS时为nil）
+	tls *tlsReloader
+	// 已告警过的 证书身份/system_id 不一致组合
+	identityWarned sync.Map
+
+	// 访问控制（未启用时为nil）
+	access       *access.Checker
+	accessConfig config.AccessConfig
+
+	// 报文限速（未启用时为nil）
+	limiter         *ratelimit.Limiter
+	rateLimitConfig config.RateLimitConfig
+
+	// Publish响应统计
+	publishMu       sync.Mutex
+	publishErrors   map[string]int64
+	publishDelayed  int64
+	publishWithheld int64
+
+	// 设备登记（未启用时为nil）
+	devices       *devices.Registry
+	devicesConfig config.DevicesConfig
+	deviceStore   devices.Store
+
+	// 上报周期监测（未启用时为nil）
+	cadence       *cadence.Monitor
+	cadenceConfig config.CadenceConfig
+	gapStore      cadence.Store
+
+	// ReqId跳号与重复检测（未启用时为nil）
+	sequence       *sequence.Monitor
+	sequenceConfig config.SequenceConfig
+
+	// 设备时钟偏差检测（未启用时为nil）
+	clockSkew       *clockskew.Checker
+	clockSkewConfig config.ClockSkewConfig
+
+	// 原始报文抓包（未启用时为nil）
+	capture       *capture.Recorder
+	captureConfig config.CaptureConfig
 }
 
 // NewSimpleCollector 创建简化的采集器
-func NewSimpleCollector(logger *logrus.Logger, bufferManager *buffer.FixedBufferManager, serverConfig config.ServerConfig) *SimpleCollector {
+func NewSimpleCollector(logger *logrus.Logger, bufferManager *buffer.FixedBufferManager, serverConfig config.ServerConfig, parserConfig config.ParserConfig, collectionConfig config.CollectionConfig, ratesConfig config.RatesConfig, accessConfig config.AccessConfig, rateLimitConfig config.RateLimitConfig, devicesConfig config.DevicesConfig, cadenceConfig config.CadenceConfig, sequenceConfig config.SequenceConfig, clockSkewConfig config.ClockSkewConfig, captureConfig config.CaptureConfig) *SimpleCollector {
+	var tracker *collection.Tracker
+	if collectionConfig.Enabled {
+		tracker = collection.NewTracker(collectionConfig.RoundTimeout)
+	}
+	var calculator *rates.Calculator
+	if ratesConfig.Enabled {
+		calculator = rates.NewCalculator(ratesConfig.MaxInterval, ratesConfig.StateTTL)
+	}
+	var registry *devices.Registry
+	if devicesConfig.Enabled {
+		registry = devices.NewRegistry()
+	}
+	var monitor *cadence.Monitor
+	if cadenceConfig.Enabled {
+		monitor = cadence.NewMonitor(cadenceConfig.LateMultiple, cadenceConfig.MinLate, cadenceConfig.StateTTL)
+	}
+	var seq *sequence.Monitor
+	if sequenceConfig.Enabled {
+		seq = sequence.NewMonitor(sequenceConfig.Window, sequenceConfig.ResumeTTL)
+	}
+
 	return &SimpleCollector{
 		logger:         logger,
-		parser:         parser.NewTelemetryParser(logger),
+		parser:         parser.NewTelemetryParserWithConfig(logger, parserConfig),
 		bufferManager:  bufferManager,
 		serverConfig:   serverConfig,
 		connections:    make(map[string]*ConnectionInfo),
 		dataTimeout:    15 * time.Minute, // 15分钟数据超时
 		shutdownChan:   make(chan struct{}),
 		monitoringDone: make(chan struct{}),
+		tracker:          tracker,
+		collectionConfig: collectionConfig,
+		rates:            calculator,
+		accessConfig:     accessConfig,
+		rateLimitConfig:  rateLimitConfig,
+		publishErrors:    make(map[string]int64),
+		devices:          registry,
+		devicesConfig:    devicesConfig,
+		cadence:          monitor,
+		cadenceConfig:    cadenceConfig,
+		sequence:         seq,
+		sequenceConfig:   sequenceConfig,
+		clockSkewConfig:  clockSkewConfig,
+		captureConfig:    captureConfig,
 	}
 }
 
+// SetDeviceStore 设置设备登记的持久化目标，未设置时设备登记只保存在内存中
+func (c *SimpleCollector) SetDeviceStore(store devices.Store) {
+	c.deviceStore = store
+}
+
+// SetGapStore 设置数据缺口的持久化目标，未设置时只记录日志与指标
+func (c *SimpleCollector) SetGapStore(store cadence.Store) {
+	c.gapStore = store
+}
+
 // Start 启动采集服务
 func (c *SimpleCollector) Start(port int) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
@@ -90,6 +225,67 @@ func (c *SimpleCollector) Start(port int) error {
 		grpc.MaxConcurrentStreams(c.serverConfig.MaxConcurrentStreams),
 	}
 
+	if err := validateFlowControl(c.serverConfig.FlowControl.OnFull); err != nil {
+		lis.Close()
+		return err
+	}
+
+	if c.accessConfig.Enabled {
+		checker, err := access.NewChecker(c.accessConfig)
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("初始化访问控制失败: %v", err)
+		}
+		c.access = checker
+		c.logger.Infof("访问控制已启用: allow=%d, deny=%d, 登记设备=%d, 不匹配策略=%s",
+			len(c.accessConfig.Allow), len(c.accessConfig.Deny), len(c.accessConfig.Devices), c.accessConfig.MismatchPolicy)
+	}
+
+	if c.rateLimitConfig.Enabled {
+		limiter, err := ratelimit.NewLimiter(c.rateLimitConfig)
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("初始化报文限速失败: %v", err)
+		}
+		c.limiter = limiter
+		c.logger.Infof("报文限速已启用: peer=%g/s, device=%g/s, sensor_path=%g/s, 覆盖规则=%d",
+			c.rateLimitConfig.Peer.Rate, c.rateLimitConfig.Device.Rate, c.rateLimitConfig.SensorPath.Rate, len(c.rateLimitConfig.Overrides))
+	}
+
+	if c.clockSkewConfig.Enabled {
+		checker, err := clockskew.NewChecker(c.clockSkewConfig)
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("初始化时钟偏差检测失败: %v", err)
+		}
+		c.clockSkew = checker
+		c.logger.Infof("时钟偏差检测已启用: 策略=%s, max_skew=%v, 设备覆盖=%d",
+			c.clockSkewConfig.Policy, c.clockSkewConfig.MaxSkew, len(c.clockSkewConfig.Devices))
+	}
+
+	if c.captureConfig.Enabled {
+		recorder, err := capture.NewRecorder(c.captureConfig)
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("初始化报文抓包失败: %v", err)
+		}
+		c.capture = recorder
+		c.logger.Infof("原始报文抓包已启用: 目录=%s, 单文件上限=%dMB, 保留文件=%d",
+			c.captureConfig.Dir, c.captureConfig.MaxFileSize/(1024*1024), c.captureConfig.MaxFiles)
+	}
+
+	if c.serverConfig.TLS.Enabled {
+		reloader, err := newTLSReloader(c.serverConfig.TLS, c.logger)
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("初始化TLS失败: %v", err)
+		}
+		c.tls = reloader
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
+	} else {
+		c.logger.Warn("gRPC服务未启用TLS，数据以明文传输")
+	}
+
 	c.server = grpc.NewServer(opts...)
 	proto.RegisterZtedialoutServiceServer(c.server, c)
 
@@ -116,6 +312,8 @@ func (c *SimpleCollector) Stop() {
 	case <-time.After(5 * time.Second):
 		c.logger.Warn("等待连接监控停止超时")
 	}
+	c.persistDevices()
+	c.persistGaps()
 	
 	if c.bufferManager != nil {
 		c.bufferManager.Stop()
@@ -126,57 +324,122 @@ func (c *SimpleCollector) Stop() {
 	if c.listener != nil {
 		c.listener.Close()
 	}
+	c.closeCapture()
 	
 	c.logger.Info("采集服务已停止")
 }
 
 // Publish 实现gRPC服务接口
 func (c *SimpleCollector) Publish(stream grpc.BidiStreamingServer[proto.PublishArgs, proto.PublishArgs]) error {
-	// 获取客户端地址
-	var remoteAddr string
+	// 获取客户端地址与证书身份
+	src := &streamPeer{remoteAddr: "unknown"}
 	if p, ok := peer.FromContext(stream.Context()); ok {
-		remoteAddr = p.Addr.String()
-	} else {
-		remoteAddr = "unknown"
+		src.remoteAddr = p.Addr.String()
+		src.addr = access.PeerAddr(p.Addr)
+		src.identity = peerCertIdentity(p)
+	}
+	remoteAddr, identity := src.remoteAddr, src.identity
+
+	// 对端地址检查，不通过时拒绝整个流
+	if c.access != nil {
+		if v := c.access.CheckPeer(src.addr); v != nil {
+			c.logger.Warnf("拒绝设备连接: %s (%s)", remoteAddr, v.Reason)
+			stream.Send(&proto.PublishArgs{Errors: v.Error()})
+			return status.Error(codes.PermissionDenied, v.Error())
+		}
 	}
 	
 	// 注册连接
-	connID := c.registerConnection(remoteAddr)
+	connID, closeCh := c.registerConnection(remoteAddr, identity)
 	defer c.unregisterConnection(connID)
+	src.connID = connID
 	
-	c.logger.Infof("新的设备连接建立: %s (总连接数: %d)", remoteAddr, atomic.LoadInt64(&c.activeConnCount))
+	if identity != nil {
+		c.logger.Infof("新的设备连接建立: %s, 证书: %s (总连接数: %d)", remoteAddr, identity, atomic.LoadInt64(&c.activeConnCount))
+	} else {
+		c.logger.Infof("新的设备连接建立: %s (总连接数: %d)", remoteAddr, atomic.LoadInt64(&c.activeConnCount))
+	}
 
-	// 设置数据接收超时检测
-	ctx, cancel := context.WithTimeout(stream.Context(), c.dataTimeout)
-	defer cancel()
-	
-	// 在单独的goroutine中处理超时
-	go func(connectionID string) {
-		select {
-		case <-ctx.Done():
-			if ctx.Err() == context.DeadlineExceeded {
-				c.logger.Errorf("连接 %s 数据接收超时 (%v)，将断开连接", remoteAddr, c.dataTimeout)
-				// 这里可以添加重启逻辑或其他处理
-				c.handleConnectionTimeout(remoteAddr)
+	// 数据接收超时检测，每收到一条数据重新计时
+	idleTimer := time.AfterFunc(c.dataTimeout, func() {
+		c.logger.Errorf("连接 %s 数据接收超时 (%v)，将断开连接", remoteAddr, c.dataTimeout)
+		c.handleConnectionTimeout(remoteAddr, connID)
+	})
+	defer idleTimer.Stop()
+
+	// Recv在独立goroutine中阻塞，主循环才能响应强制关闭；
+	// Publish返回后流的context被取消，Recv随之返回
+	reqCh := make(chan *proto.PublishArgs)
+	recvErrCh := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErrCh <- err
+				return
+			}
+			select {
+			case reqCh <- req:
+			case <-stream.Context().Done():
+				return
 			}
-		case <-stream.Context().Done():
-			// 正常连接关闭
 		}
-	}(connID)
+	}()
 
 	for {
-		req, err := stream.Recv()
-		if err != nil {
+		var req *proto.PublishArgs
+		select {
+		case req = <-reqCh:
+		case err := <-recvErrCh:
 			c.logger.WithError(err).Errorf("连接 %s 接收数据流错误", remoteAddr)
 			return err
+		case reason := <-closeCh:
+			c.logger.Warnf("连接 %s 被强制关闭: %s", remoteAddr, reason)
+			return status.Error(codes.Aborted, "stream closed by collector: "+reason)
 		}
+		receivedAt := time.Now()
+		idleTimer.Reset(c.dataTimeout)
 
 		// 更新连接的最后数据时间
 		c.updateConnectionActivity(connID)
 
+		// 缓冲区超出预算时暂缓读取，由gRPC流控向设备施加背压
+		if c.serverConfig.FlowControl.OnFull == onFullDelay {
+			c.waitForBuffer(stream.Context())
+		}
+
 		// 处理接收到的数据
-		if err := c.processPublishArgs(req); err != nil {
-			c.logger.WithError(err).Error("处理数据失败")
+		if err := c.processPublishArgs(req, src, receivedAt); err != nil {
+			reason := publishErrorReason(err)
+			c.countPublishError(reason)
+			c.recordStreamError(connID, err)
+			switch reason {
+			case ReasonBufferFull:
+				if c.serverConfig.FlowControl.OnFull == onFullWithhold {
+					// 不应答，由设备的重传/退避降低发送速率
+					atomic.AddInt64(&c.publishWithheld, 1)
+					c.logger.Debugf("缓冲区已满，不应答 %s 的请求 %d: %v", remoteAddr, req.ReqId, err)
+					continue
+				}
+				c.logger.Debugf("缓冲区已满，拒绝 %s 的请求 %d: %v", remoteAddr, req.ReqId, err)
+			case ReasonParseError, ReasonInternal:
+				c.logger.WithError(err).Error("处理数据失败")
+			}
+
+			// 失败原因在响应中告知设备
+			if sendErr := stream.Send(&proto.PublishArgs{ReqId: req.ReqId, Errors: err.Error()}); sendErr != nil {
+				c.logger.WithError(sendErr).Errorf("连接 %s 发送响应失败", remoteAddr)
+				return sendErr
+			}
+			var v *access.Violation
+			if errors.As(err, &v) && v.Action == access.RejectStream {
+				return status.Error(codes.PermissionDenied, v.Error())
+			}
+			var e *ratelimit.Exceeded
+			if errors.As(err, &e) && e.Action == ratelimit.RejectStream {
+				c.logger.Warnf("连接 %s 超出限速，断开流: %v", remoteAddr, e)
+				return status.Error(codes.ResourceExhausted, e.Error())
+			}
 			continue
 		}
 
@@ -190,17 +453,15 @@ func (c *SimpleCollector) Publish(stream grpc.BidiStreamingServer[proto.PublishA
 			c.logger.WithError(err).Errorf("连接 %s 发送响应失败", remoteAddr)
 			return err
 		}
-		
-		// 重置超时计时器
-		cancel()
-		ctx, cancel = context.WithTimeout(stream.Context(), c.dataTimeout)
 	}
 }
 
-// processPublishArgs 处理发布参数
-func (c *SimpleCollector) processPublishArgs(req *proto.PublishArgs) error {
+// processPublishArgs 处理发布参数，receivedAt为报文接收时间（用于抓包）
+func (c *SimpleCollector) processPublishArgs(req *proto.PublishArgs, src *streamPeer, receivedAt time.Time) error {
 	c.logger.Debugf("处理请求ID: %d", req.ReqId)
 
+	var results []*parser.ParseResult
+
 	// 解析GPB数据
 	data := req.GetData()
 	if len(data) > 0 {
@@ -208,87 +469,405 @@ func (c *SimpleCollector) processPublishArgs(req *proto.PublishArgs) error {
 		
 		// 使用解析器解析telemetry数据
 		result, err := c.parser.ParseTelemetryData(data)
+		c.captureMessage(req, src, receivedAt, result)
 		if err != nil {
 			c.logger.WithError(err).Error("解析telemetry数据失败")
+			return &publishError{reason: ReasonParseError, err: fmt.Errorf("parse error: %v", err)}
+		}
+		results = append(results, result)
+	}
+
+	// 处理JSON数据（如果有）
+	jsonData := req.GetJsonData()
+	if jsonData != "" {
+		c.logger.Debugf("收到JSON数据，长度: %d bytes", len(jsonData))
+
+		result, err := c.parser.ParseJSONData(jsonData)
+		c.captureMessage(req, src, receivedAt, result)
+		if err != nil {
+			c.logger.WithError(err).Error("解析JSON telemetry数据失败")
+			return &publishError{reason: ReasonParseError, err: fmt.Errorf("parse error: %v", err)}
+		}
+		results = append(results, result)
+	}
+
+	return c.handleParseResults(req, results, src)
+}
+
+// handleParseResults 访问控制、限速与时钟偏差检查通过后检查ReqId，再写入缓冲区。
+// 同一PublishArgs的GPB与JSON部分一并检查ReqId；未能写入时撤销ReqId记录，设备重传时照常写入。
+func (c *SimpleCollector) handleParseResults(req *proto.PublishArgs, results []*parser.ParseResult, src *streamPeer) error {
+	if len(results) == 0 {
+		return nil
+	}
+	for _, result := range results {
+		if err := c.checkParseResult(result, src); err != nil {
 			return err
 		}
+	}
+	if c.checkSequence(req, results[0], src) {
+		return nil
+	}
+	// 写入前整体检查各缓冲区的上限，避免部分写入后报文被拒绝、设备重传时已写入的部分重复写入
+	if err := c.bufferManager.CheckBudget(budgetRows(results...)); err != nil {
+		c.rejectSequence(req, src)
+		return err
+	}
+	for _, result := range results {
+		if err := c.bufferResult(result, src); err != nil {
+			c.rejectSequence(req, src)
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParseResult 访问控制、限速与时钟偏差检查
+func (c *SimpleCollector) checkParseResult(result *parser.ParseResult, src *streamPeer) error {
+	c.recordStreamResult(src.connID, result)
+	if result.UnknownSensorPath {
+		return &publishError{reason: ReasonUnknownSensorPath, err: fmt.Errorf("unknown sensor_path: %s", result.SensorPath)}
+	}
+	if err := c.checkAccess(result, src); err != nil {
+		return err
+	}
+	if err := c.checkRateLimit(result, src); err != nil {
+		return err
+	}
+	return c.checkClockSkew(result)
+}
+
+// bufferResult 写入缓冲区，缓冲区上限已由调用方检查
+func (c *SimpleCollector) bufferResult(result *parser.ParseResult, src *streamPeer) error {
+	// 通过检查后才记入设备登记（tag_row时为改写后的system_id），被拒绝的报文不影响登记
+	c.observeDevice(result, src)
+	round := c.trackCollection(result)
+	c.computeRates(result)
+	if err := c.bufferParseResult(result); err != nil {
+		return err
+	}
+	// 只有写入缓冲区的报文计为到达，被拒绝或伪造的报文不会掩盖真实设备的数据缺口
+	c.observeCadence(result)
+	c.recordStreamRows(src.connID, result.RowCount())
+	c.completeRound(round)
+	return nil
+}
+
+// budgetRows 解析结果合计的各缓冲区行数，用于写入前的上限检查；
+// 告警、通知与自定义事件行不受上限限制，同一报文中的采样行（如自定义事件携带的采样数据）照常检查
+func budgetRows(results ...*parser.ParseResult) map[string]int {
+	rows := make(map[string]int)
+	for _, result := range results {
+		rows["platform"] += len(result.PlatformMetrics)
+		rows["interface"] += len(result.InterfaceMetrics)
+		rows["subinterface"] += len(result.SubinterfaceMetrics)
+		rows["alarm_report"] += len(result.AlarmReportMetrics)
+		rows["notification_report"] += len(result.NotificationReportMetrics)
+		rows["self_defined_event"] += len(result.SelfDefinedEventMetrics)
+		rows["generic"] += len(result.GenericMetrics)
+		rows["mapped_row"] += len(result.MappedRows)
+		rows["optical_channel"] += len(result.OpticalChannelMetrics)
+	}
+	return rows
+}
+
+// checkAccess 按设备登记检查报文来源，tag_row策略下改写system_id后照常写入
+func (c *SimpleCollector) checkAccess(result *parser.ParseResult, src *streamPeer) error {
+	c.checkSystemID(src.identity, result.SystemID)
+	if c.access == nil {
+		return nil
+	}
+
+	v := c.access.CheckMessage(access.Message{
+		SystemID:     result.SystemID,
+		Peer:         src.addr,
+		CertMismatch: src.identity != nil && !src.identity.Matches(result.SystemID),
+	})
+	if v == nil {
+		return nil
+	}
+	if v.Action == access.TagRow {
+		c.logger.Debugf("报文来源不匹配，标记后写入: %s", v.Error())
+		result.SetSystemID(c.access.TagSystemID(result.SystemID))
+		return nil
+	}
+	c.logger.Warnf("报文来源检查未通过，策略=%s: %s", v.Action, v.Error())
+	return v
+}
+
+// GetAccessRejections 按原因统计的访问控制拒绝次数，未启用时返回nil
+func (c *SimpleCollector) GetAccessRejections() map[string]int64 {
+	if c.access == nil {
+		return nil
+	}
+	return c.access.Rejections()
+}
+
+// checkRateLimit 按对端地址、system_id、sensor_path检查限速，超限时记入连接信息
+func (c *SimpleCollector) checkRateLimit(result *parser.ParseResult, src *streamPeer) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	peerKey := src.remoteAddr
+	if src.addr.IsValid() {
+		peerKey = src.addr.String()
+	}
+	e := c.limiter.Allow(ratelimit.Message{Peer: peerKey, SystemID: result.SystemID, SensorPath: result.SensorPath})
+	if e == nil {
+		return nil
+	}
+
+	c.connectionsMux.RLock()
+	if conn, exists := c.connections[src.connID]; exists {
+		atomic.AddInt64(&conn.RateLimited, 1)
+	}
+	c.connectionsMux.RUnlock()
+	c.logger.Debugf("报文超出限速，策略=%s: %v", e.Action, e)
+	return e
+}
+
+// GetRateLimitCounts 按维度与策略统计的超限报文数，未启用时返回nil
+func (c *SimpleCollector) GetRateLimitCounts() map[ratelimit.CountKey]int64 {
+	if c.limiter == nil {
+		return nil
+	}
+	return c.limiter.Counts()
+}
+
+// checkClockSkew 比较报文时间与接收时间，按策略校正时间戳或拒绝报文
+func (c *SimpleCollector) checkClockSkew(result *parser.ParseResult) error {
+	if c.clockSkew == nil {
+		return nil
+	}
+	d := c.clockSkew.Check(result.SystemID, result.Timestamp)
+	if d.Reject != nil {
+		c.logger.Debugf("设备时钟偏差超限，拒绝报文: %v", d.Reject)
+		return d.Reject
+	}
+	if d.Replace {
+		result.Shift(-d.Skew)
+	}
+	return nil
+}
+
+// GetClockSkewStats 获取时钟偏差统计
+func (c *SimpleCollector) GetClockSkewStats() clockskew.Stats {
+	if c.clockSkew == nil {
+		return clockskew.Stats{}
+	}
+	return c.clockSkew.Stats()
+}
+
+// checkSystemID 核对报文system_id与客户端证书身份，不一致时每个组合只告警一次
+func (c *SimpleCollector) checkSystemID(identity *CertIdentity, systemID string) {
+	if identity == nil || systemID == "" || identity.Matches(systemID) {
+		return
+	}
+	if _, warned := c.identityWarned.LoadOrStore(identity.String()+"|"+systemID, struct{}{}); warned {
+		return
+	}
+	c.logger.Warnf("报文system_id与客户端证书不一致: system_id=%s, 证书: %s", systemID, identity)
+}
+
+// trackCollection 记录报文所属采样轮次，并将采样指标时间戳统一为轮次开始时间
+func (c *SimpleCollector) trackCollection(result *parser.ParseResult) collection.Round {
+	// 告警按事件上报，不参与采样轮次
+	if c.tracker == nil || len(result.AlarmReportMetrics) > 0 || len(result.NotificationReportMetrics) > 0 {
+		return collection.Round{}
+	}
+
+	round := c.tracker.Observe(collection.Packet{
+		SystemID:       result.SystemID,
+		SubscriptionID: result.SubscriptionID,
+		CollectionID:   result.CollectionID,
+		StartTime:      result.CollectionStartTime,
+		EndTime:        result.CollectionEndTime,
+		MsgTime:        result.Timestamp,
+	})
+	if !round.Start.IsZero() {
+		result.Restamp(round.Start)
+	}
+	return round
+}
+
+// completeRound 采样轮次结束后立即写入该设备本轮的缓冲数据
+func (c *SimpleCollector) completeRound(round collection.Round) {
+	if !round.Complete {
+		return
+	}
+	c.logger.Debugf("采样轮次完成: system_id=%s, subscription=%s, collection_id=%d, packets=%d",
+		round.SystemID, round.SubscriptionID, round.CollectionID, round.Packets)
+
+	if !c.collectionConfig.FlushOnComplete {
+		return
+	}
+	go func() {
+		if err := c.bufferManager.FlushRound(round.SystemID, round.Start); err != nil {
+			c.logger.WithError(err).Errorf("写入采样轮次失败: system_id=%s, collection_id=%d", round.SystemID, round.CollectionID)
+		}
+	}()
+}
+
+// expireCollectionRounds 清理超时未结束的采样轮次
+func (c *SimpleCollector) expireCollectionRounds() {
+	for _, round := range c.tracker.Expire() {
+		c.logger.Warnf("采样轮次不完整(未收到末包): system_id=%s, subscription=%s, collection_id=%d, 已收包=%d",
+			round.SystemID, round.SubscriptionID, round.CollectionID, round.Packets)
+	}
+}
+
+// GetCollectionStats 获取采样轮次统计，未启用时返回零值
+func (c *SimpleCollector) GetCollectionStats() collection.Stats {
+	if c.tracker == nil {
+		return collection.Stats{}
+	}
+	return c.tracker.Stats()
+}
+
+// computeRates 根据计数器采样计算速率，需在轮次时间戳统一之后调用
+func (c *SimpleCollector) computeRates(result *parser.ParseResult) {
+	if c.rates == nil {
+		return
+	}
+	c.rates.ObservePlatform(result.PlatformMetrics)
+	c.rates.ApplyInterfaces(result.InterfaceMetrics)
+	c.rates.ApplySubinterfaces(result.SubinterfaceMetrics)
+}
+
+// GetRateStats 获取计数器速率计算统计，未启用时返回零值
+func (c *SimpleCollector) GetRateStats() rates.Stats {
+	if c.rates == nil {
+		return rates.Stats{}
+	}
+	return c.rates.Stats()
+}
+
+// observeSamplePeriod 将设备上报的采样周期告知缓冲区，供 window: period 的缓冲区分桶
+func (c *SimpleCollector) observeSamplePeriod(result *parser.ParseResult) {
+	if result.CurrentPeriod <= 0 {
+		return
+	}
+	for table, rows := range map[string]int{
+		"platform":        len(result.PlatformMetrics),
+		"interface":       len(result.InterfaceMetrics),
+		"subinterface":    len(result.SubinterfaceMetrics),
+		"optical_channel": len(result.OpticalChannelMetrics),
+		"generic":         len(result.GenericMetrics),
+	} {
+		if rows > 0 {
+			c.bufferManager.ObserveSamplePeriod(table, result.SystemID, result.CurrentPeriod)
+		}
+	}
+}
 
-		c.logger.Debugf("解析成功: system_id=%s, sensor_path=%s, platform_metrics=%d, interface_metrics=%d, subinterface_metrics=%d, alarm_reports=%d, notifications=%d",
-			result.SystemID, result.SensorPath, len(result.PlatformMetrics), len(result.InterfaceMetrics), len(result.SubinterfaceMetrics), len(result.AlarmReportMetrics), len(result.NotificationReportMetrics))
+// bufferParseResult 将解析结果写入缓冲区
+func (c *SimpleCollector) bufferParseResult(result *parser.ParseResult) error {
+	c.logger.Debugf("解析成功: system_id=%s, sensor_path=%s, platform_metrics=%d, interface_metrics=%d, subinterface_metrics=%d, alarm_reports=%d, notifications=%d",
+		result.SystemID, result.SensorPath, len(result.PlatformMetrics), len(result.InterfaceMetrics), len(result.SubinterfaceMetrics), len(result.AlarmReportMetrics), len(result.NotificationReportMetrics))
+
+	// 特别记录告警相关的sensor_path
+	if result.SensorPath == "alm:current-alarm-report" || result.SensorPath == "alm:notification-report" {
+		c.logger.Infof("🚨 检测到告警相关数据: sensor_path=%s, system_id=%s, alarm_reports=%d, notifications=%d", 
+			result.SensorPath, result.SystemID, len(result.AlarmReportMetrics), len(result.NotificationReportMetrics))
+	}
 
-		// 特别记录告警相关的sensor_path
-		if result.SensorPath == "alm:current-alarm-report" || result.SensorPath == "alm:notification-report" {
-			c.logger.Infof("🚨 检测到告警相关数据: sensor_path=%s, system_id=%s, alarm_reports=%d, notifications=%d", 
-				result.SensorPath, result.SystemID, len(result.AlarmReportMetrics), len(result.NotificationReportMetrics))
+	c.observeSamplePeriod(result)
+
+	// 添加到缓冲区
+	if len(result.PlatformMetrics) > 0 {
+		if err := c.bufferManager.AddPlatformMetrics(result.PlatformMetrics); err != nil {
+			c.logger.WithError(err).Error("添加平台指标数据到缓冲区失败")
+			return fmt.Errorf("添加平台指标数据到缓冲区失败: %w", err)
 		}
+	}
 
-		// 添加到缓冲区
-		if len(result.PlatformMetrics) > 0 {
-			if err := c.bufferManager.AddPlatformMetrics(result.PlatformMetrics); err != nil {
-				c.logger.WithError(err).Error("添加平台指标数据到缓冲区失败")
-				return fmt.Errorf("添加平台指标数据到缓冲区失败: %v", err)
-			}
+	if len(result.InterfaceMetrics) > 0 {
+		if err := c.bufferManager.AddInterfaceMetrics(result.InterfaceMetrics); err != nil {
+			c.logger.WithError(err).Error("添加接口指标数据到缓冲区失败")
+			return fmt.Errorf("添加接口指标数据到缓冲区失败: %w", err)
 		}
+	}
 
-		if len(result.InterfaceMetrics) > 0 {
-			if err := c.bufferManager.AddInterfaceMetrics(result.InterfaceMetrics); err != nil {
-				c.logger.WithError(err).Error("添加接口指标数据到缓冲区失败")
-				return fmt.Errorf("添加接口指标数据到缓冲区失败: %v", err)
-			}
+	if len(result.SubinterfaceMetrics) > 0 {
+		if err := c.bufferManager.AddSubinterfaceMetrics(result.SubinterfaceMetrics); err != nil {
+			c.logger.WithError(err).Error("添加子接口指标数据到缓冲区失败")
+			return fmt.Errorf("添加子接口指标数据到缓冲区失败: %w", err)
 		}
+	}
 
-		if len(result.SubinterfaceMetrics) > 0 {
-			if err := c.bufferManager.AddSubinterfaceMetrics(result.SubinterfaceMetrics); err != nil {
-				c.logger.WithError(err).Error("添加子接口指标数据到缓冲区失败")
-				return fmt.Errorf("添加子接口指标数据到缓冲区失败: %v", err)
-			}
+	if len(result.AlarmReportMetrics) > 0 {
+		c.logger.Infof("🔥 添加 %d 条告警上报数据到缓冲区", len(result.AlarmReportMetrics))
+		if err := c.bufferManager.AddAlarmReportMetrics(result.AlarmReportMetrics); err != nil {
+			c.logger.WithError(err).Error("添加告警上报数据到缓冲区失败")
+			return fmt.Errorf("添加告警上报数据到缓冲区失败: %w", err)
 		}
+		c.logger.Infof("✅ 成功添加告警上报数据到缓冲区")
+	}
 
-		if len(result.AlarmReportMetrics) > 0 {
-			c.logger.Infof("🔥 添加 %d 条告警上报数据到缓冲区", len(result.AlarmReportMetrics))
-			if err := c.bufferManager.AddAlarmReportMetrics(result.AlarmReportMetrics); err != nil {
-				c.logger.WithError(err).Error("添加告警上报数据到缓冲区失败")
-				return fmt.Errorf("添加告警上报数据到缓冲区失败: %v", err)
-			}
-			c.logger.Infof("✅ 成功添加告警上报数据到缓冲区")
+	if len(result.NotificationReportMetrics) > 0 {
+		c.logger.Infof("🔔 添加 %d 条通知上报数据到缓冲区", len(result.NotificationReportMetrics))
+		if err := c.bufferManager.AddNotificationReportMetrics(result.NotificationReportMetrics); err != nil {
+			c.logger.WithError(err).Error("添加通知上报数据到缓冲区失败")
+			return fmt.Errorf("添加通知上报数据到缓冲区失败: %w", err)
 		}
+		c.logger.Infof("✅ 成功添加通知上报数据到缓冲区")
+	}
 
-		if len(result.NotificationReportMetrics) > 0 {
-			c.logger.Infof("🔔 添加 %d 条通知上报数据到缓冲区", len(result.NotificationReportMetrics))
-			if err := c.bufferManager.AddNotificationReportMetrics(result.NotificationReportMetrics); err != nil {
-				c.logger.WithError(err).Error("添加通知上报数据到缓冲区失败")
-				return fmt.Errorf("添加通知上报数据到缓冲区失败: %v", err)
-			}
-			c.logger.Infof("✅ 成功添加通知上报数据到缓冲区")
+	if len(result.SelfDefinedEventMetrics) > 0 {
+		c.logger.Infof("📌 添加 %d 条自定义事件到缓冲区", len(result.SelfDefinedEventMetrics))
+		if err := c.bufferManager.AddSelfDefinedEventMetrics(result.SelfDefinedEventMetrics); err != nil {
+			c.logger.WithError(err).Error("添加自定义事件到缓冲区失败")
+			return fmt.Errorf("添加自定义事件到缓冲区失败: %w", err)
 		}
 	}
 
-	// 处理JSON数据（如果有）
-	jsonData := req.GetJsonData()
-	if jsonData != "" {
-		c.logger.Debugf("收到JSON数据: %s", jsonData)
-		// TODO: 实现JSON数据解析（GPBKV格式）
+	if len(result.GenericMetrics) > 0 {
+		if err := c.bufferManager.AddGenericMetrics(result.GenericMetrics); err != nil {
+			c.logger.WithError(err).Error("添加通用指标到缓冲区失败")
+			return fmt.Errorf("添加通用指标到缓冲区失败: %w", err)
+		}
+	}
+
+	if len(result.MappedRows) > 0 {
+		if err := c.bufferManager.AddMappedRows(result.MappedRows); err != nil {
+			c.logger.WithError(err).Error("添加映射数据到缓冲区失败")
+			return fmt.Errorf("添加映射数据到缓冲区失败: %w", err)
+		}
+	}
+
+	if len(result.OpticalChannelMetrics) > 0 {
+		if err := c.bufferManager.AddOpticalChannelMetrics(result.OpticalChannelMetrics); err != nil {
+			c.logger.WithError(err).Error("添加光通道指标到缓冲区失败")
+			return fmt.Errorf("添加光通道指标到缓冲区失败: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// registerConnection 注册新连接
-func (c *SimpleCollector) registerConnection(remoteAddr string) string {
+// registerConnection 注册新连接，返回连接ID与强制关闭通知通道
+func (c *SimpleCollector) registerConnection(remoteAddr string, identity *CertIdentity) (string, <-chan string) {
 	c.connectionsMux.Lock()
 	defer c.connectionsMux.Unlock()
 	
-	connID := fmt.Sprintf("%s-%d", remoteAddr, time.Now().UnixNano())
+	connID := strconv.FormatUint(atomic.AddUint64(&c.nextConnID, 1), 10)
+	closeCh := make(chan string, 1)
 	c.connections[connID] = &ConnectionInfo{
-		RemoteAddr:   remoteAddr,
-		ConnectedAt:  time.Now(),
-		LastDataTime: time.Now(),
-		DataCount:    0,
-		IsActive:     true,
+		RemoteAddr:      remoteAddr,
+		ConnectedAt:     time.Now(),
+		LastDataTime:    time.Now(),
+		DataCount:       0,
+		IsActive:        true,
+		Identity:        identity,
+		SystemIDs:       make(map[string]struct{}),
+		SubscriptionIDs: make(map[string]struct{}),
+		SensorPaths:     make(map[string]struct{}),
+		closeCh:         closeCh,
 	}
 	
 	atomic.AddInt64(&c.activeConnCount, 1)
-	return connID
+	return connID, closeCh
 }
 
 // unregisterConnection 注销连接
@@ -304,6 +883,12 @@ func (c *SimpleCollector) unregisterConnection(connID string) {
 		delete(c.connections, connID)
 		atomic.AddInt64(&c.activeConnCount, -1)
 	}
+	if c.devices != nil {
+		c.devices.StreamClosed(connID)
+	}
+	if c.sequence != nil {
+		c.sequence.StreamClosed(connID)
+	}
 }
 
 // updateConnectionActivity 更新连接活动
@@ -323,6 +908,38 @@ func (c *SimpleCollector) startConnectionMonitor() {
 	defer ticker.Stop()
 	defer close(c.monitoringDone)
 	
+	// 采样轮次超时检查，未启用时通道为nil不会触发
+	var roundTick <-chan time.Time
+	if c.tracker != nil {
+		roundTicker := time.NewTicker(c.collectionConfig.RoundTimeout / 2)
+		defer roundTicker.Stop()
+		roundTick = roundTicker.C
+	}
+
+	// 证书文件变化检查，未启用TLS或间隔为0时通道为nil不会触发
+	var tlsTick <-chan time.Time
+	if c.tls != nil && c.serverConfig.TLS.ReloadInterval > 0 {
+		tlsTicker := time.NewTicker(c.serverConfig.TLS.ReloadInterval)
+		defer tlsTicker.Stop()
+		tlsTick = tlsTicker.C
+	}
+
+	// 设备登记持久化，未启用或未设置存储时通道为nil不会触发
+	var deviceTick <-chan time.Time
+	if c.devices != nil && c.deviceStore != nil && c.devicesConfig.PersistInterval > 0 {
+		deviceTicker := time.NewTicker(c.devicesConfig.PersistInterval)
+		defer deviceTicker.Stop()
+		deviceTick = deviceTicker.C
+	}
+
+	// 上报周期检查，未启用时通道为nil不会触发
+	var cadenceTick <-chan time.Time
+	if c.cadence != nil && c.cadenceConfig.CheckInterval > 0 {
+		cadenceTicker := time.NewTicker(c.cadenceConfig.CheckInterval)
+		defer cadenceTicker.Stop()
+		cadenceTick = cadenceTicker.C
+	}
+
 	c.logger.Info("连接监控已启动")
 	
 	for {
@@ -332,6 +949,32 @@ func (c *SimpleCollector) startConnectionMonitor() {
 			return
 		case <-ticker.C:
 			c.checkConnectionHealth()
+			if c.rates != nil {
+				if removed := c.rates.Expire(); removed > 0 {
+					c.logger.Debugf("清理过期计数器基线: %d", removed)
+				}
+			}
+			if c.limiter != nil {
+				if removed := c.limiter.Expire(); removed > 0 {
+					c.logger.Debugf("清理过期限速令牌桶: %d", removed)
+				}
+			}
+			if c.clockSkew != nil {
+				c.clockSkew.Expire()
+			}
+			if c.sequence != nil {
+				if removed := c.sequence.Expire(); removed > 0 {
+					c.logger.Debugf("清理过期ReqId跟踪状态: %d", removed)
+				}
+			}
+		case <-roundTick:
+			c.expireCollectionRounds()
+		case <-tlsTick:
+			c.tls.ReloadIfChanged()
+		case <-deviceTick:
+			c.persistDevices()
+		case <-cadenceTick:
+			c.checkCadence()
 		}
 	}
 }
@@ -370,14 +1013,9 @@ func (c *SimpleCollector) computeConnectionSnapshot() (total, active, stale int,
 	return
 }
 
-// handleConnectionTimeout 处理连接超时
-func (c *SimpleCollector) handleConnectionTimeout(remoteAddr string) {
-	c.logger.Errorf("连接 %s 超时，建议检查网络连接和设备状态", remoteAddr)
-	
-	// 这里可以添加更多的处理逻辑，比如：
-	// 1. 发送告警通知
-	// 2. 记录超时事件到数据库
-	// 3. 如果超时连接过多，可以考虑重启服务
+// handleConnectionTimeout 处理连接超时：只记录日志，可通过管理API查看并关闭僵尸流
+func (c *SimpleCollector) handleConnectionTimeout(remoteAddr, connID string) {
+	c.logger.Errorf("连接 %s 超时，建议检查网络连接和设备状态，可通过管理API POST /streams/%s/close 断开", remoteAddr, connID)
 	
 	c.connectionsMux.RLock()
 	timeoutCount := 0
@@ -395,6 +1033,22 @@ func (c *SimpleCollector) handleConnectionTimeout(remoteAddr string) {
 	}
 }
 
+// ReloadParserConfig 重新加载解析器的proto描述与sensor_path映射
+func (c *SimpleCollector) ReloadParserConfig(cfg config.ParserConfig) error {
+	return c.parser.ReloadDescriptors(cfg)
+}
+
+// ReloadTLS 重新加载TLS证书，已建立的连接不受影响
+func (c *SimpleCollector) ReloadTLS(cfg config.TLSConfig) error {
+	if c.tls == nil {
+		if cfg.Enabled {
+			return fmt.Errorf("启用TLS需要重启服务")
+		}
+		return nil
+	}
+	return c.tls.Reload(cfg)
+}
+
 // GetConnectionStats 获取连接统计信息
 func (c *SimpleCollector) GetConnectionStats() map[string]interface{} {
 	total, active, stale, totalDataCount := c.computeConnectionSnapshot()
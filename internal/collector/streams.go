@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/parser"
+)
+
+// StreamInfo 单个Publish流的状态快照（管理API输出）
+type StreamInfo struct {
+	ID              string     `json:"id"`
+	Peer            string     `json:"peer"`
+	CertIdentity    string     `json:"cert_identity,omitempty"`
+	SystemID        string     `json:"system_id,omitempty"`
+	SubscriptionID  string     `json:"subscription_id,omitempty"`
+	MngIPv4         string     `json:"mng_ipv4,omitempty"`
+	SystemIDs       []string   `json:"system_ids"`
+	SubscriptionIDs []string   `json:"subscription_ids"`
+	SensorPaths     []string   `json:"sensor_paths"`
+	Messages        int64      `json:"messages"`
+	Rows            int64      `json:"rows"`
+	RateLimited     int64      `json:"rate_limited"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorTime   *time.Time `json:"last_error_time,omitempty"`
+	ConnectedAt     time.Time  `json:"connected_at"`
+	LastDataTime    time.Time  `json:"last_data_time"`
+	IdleSeconds     float64    `json:"idle_seconds"`
+	Stale           bool       `json:"stale"`
+}
+
+// ListStreams 返回所有Publish流的状态，按建立时间排序
+func (c *SimpleCollector) ListStreams() []StreamInfo {
+	c.connectionsMux.RLock()
+	defer c.connectionsMux.RUnlock()
+
+	now := time.Now()
+	streams := make([]StreamInfo, 0, len(c.connections))
+	for id, conn := range c.connections {
+		info := StreamInfo{
+			ID:              id,
+			Peer:            conn.RemoteAddr,
+			SystemID:        conn.SystemID,
+			SubscriptionID:  conn.SubscriptionID,
+			MngIPv4:         conn.MngIPv4,
+			SystemIDs:       sortedKeys(conn.SystemIDs),
+			SubscriptionIDs: sortedKeys(conn.SubscriptionIDs),
+			SensorPaths:     sortedKeys(conn.SensorPaths),
+			Messages:        atomic.LoadInt64(&conn.DataCount),
+			Rows:            conn.RowCount,
+			RateLimited:     atomic.LoadInt64(&conn.RateLimited),
+			LastError:       conn.LastError,
+			ConnectedAt:     conn.ConnectedAt,
+			LastDataTime:    conn.LastDataTime,
+			IdleSeconds:     now.Sub(conn.LastDataTime).Seconds(),
+			Stale:           now.Sub(conn.LastDataTime) > c.dataTimeout,
+		}
+		if conn.Identity != nil {
+			info.CertIdentity = conn.Identity.String()
+		}
+		if !conn.LastErrorTime.IsZero() {
+			t := conn.LastErrorTime
+			info.LastErrorTime = &t
+		}
+		streams = append(streams, info)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		a, b := streams[i], streams[j]
+		if !a.ConnectedAt.Equal(b.ConnectedAt) {
+			return a.ConnectedAt.Before(b.ConnectedAt)
+		}
+		// 连接ID为递增序号
+		return len(a.ID) < len(b.ID) || (len(a.ID) == len(b.ID) && a.ID < b.ID)
+	})
+	return streams
+}
+
+// CloseStream 通知指定流结束，流不存在时返回false
+func (c *SimpleCollector) CloseStream(connID, reason string) bool {
+	c.connectionsMux.RLock()
+	conn, exists := c.connections[connID]
+	c.connectionsMux.RUnlock()
+	if !exists {
+		return false
+	}
+
+	select {
+	case conn.closeCh <- reason:
+	default:
+		// 已有关闭通知在途
+	}
+	return true
+}
+
+// CloseStaleStreams 关闭超过数据超时仍未收到数据的流，返回被关闭的连接ID
+func (c *SimpleCollector) CloseStaleStreams(reason string) []string {
+	var stale []string
+	for _, s := range c.ListStreams() {
+		if s.Stale {
+			stale = append(stale, s.ID)
+		}
+	}
+	closed := make([]string, 0, len(stale))
+	for _, id := range stale {
+		if c.CloseStream(id, reason) {
+			closed = append(closed, id)
+		}
+	}
+	return closed
+}
+
+// recordStreamResult 记录报文中的 system_id、订阅与sensor_path，首条带system_id的报文确定流所属设备
+func (c *SimpleCollector) recordStreamResult(connID string, result *parser.ParseResult) {
+	c.connectionsMux.Lock()
+	defer c.connectionsMux.Unlock()
+
+	conn, exists := c.connections[connID]
+	if !exists {
+		return
+	}
+	if conn.SystemID == "" && result.SystemID != "" {
+		conn.SystemID = result.SystemID
+		conn.SubscriptionID = result.SubscriptionID
+		conn.MngIPv4 = result.MngIPv4
+		c.logger.Infof("连接 %s 识别为设备: system_id=%s, subscription_id=%s, mng_ipv4=%s",
+			conn.RemoteAddr, result.SystemID, result.SubscriptionID, result.MngIPv4)
+	}
+	if result.SystemID != "" {
+		conn.SystemIDs[result.SystemID] = struct{}{}
+	}
+	if result.SubscriptionID != "" {
+		conn.SubscriptionIDs[result.SubscriptionID] = struct{}{}
+	}
+	if result.SensorPath != "" {
+		conn.SensorPaths[result.SensorPath] = struct{}{}
+	}
+}
+
+// recordStreamRows 累计写入缓冲区的行数
+func (c *SimpleCollector) recordStreamRows(connID string, rows int) {
+	c.connectionsMux.Lock()
+	defer c.connectionsMux.Unlock()
+
+	if conn, exists := c.connections[connID]; exists {
+		conn.RowCount += int64(rows)
+	}
+}
+
+// recordStreamError 记录流最近一次处理失败的原因
+func (c *SimpleCollector) recordStreamError(connID string, err error) {
+	c.connectionsMux.Lock()
+	defer c.connectionsMux.Unlock()
+
+	if conn, exists := c.connections[connID]; exists {
+		conn.LastError = err.Error()
+		conn.LastErrorTime = time.Now()
+	}
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/parser"
+)
+
+func newTestCollector() *SimpleCollector {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewSimpleCollector(logger, nil, config.ServerConfig{}, config.ParserConfig{}, config.CollectionConfig{},
+		config.RatesConfig{}, config.AccessConfig{}, config.RateLimitConfig{}, config.DevicesConfig{Enabled: true}, config.CadenceConfig{},
+		config.SequenceConfig{Enabled: true, Window: 64, DropDuplicates: true}, config.ClockSkewConfig{}, config.CaptureConfig{})
+}
+
+func TestSimpleCollector_StreamRegistry(t *testing.T) {
+	c := newTestCollector()
+	id1, closeCh := c.registerConnection("10.1.1.1:50000", nil)
+	id2, _ := c.registerConnection("10.1.1.2:50000", nil)
+
+	c.recordStreamResult(id1, &parser.ParseResult{SystemID: "PE-01", SubscriptionID: "sub-1", SensorPath: "oc-if:interfaces/interface/state"})
+	c.recordStreamResult(id1, &parser.ParseResult{SystemID: "PE-01", SubscriptionID: "sub-2", SensorPath: "oc-if:interfaces/interface/state"})
+	c.recordStreamRows(id1, 12)
+	c.recordStreamError(id1, errors.New("unknown sensor_path: foo"))
+
+	// 第二条流超过数据超时
+	c.connections[id2].LastDataTime = time.Now().Add(-2 * c.dataTimeout)
+
+	streams := c.ListStreams()
+	if len(streams) != 2 {
+		t.Fatalf("ListStreams = %d, want 2", len(streams))
+	}
+	s := streams[0]
+	if s.ID != id1 || len(s.SystemIDs) != 1 || len(s.SubscriptionIDs) != 2 || len(s.SensorPaths) != 1 || s.Rows != 12 {
+		t.Errorf("stream = %+v", s)
+	}
+	if s.SystemID != "PE-01" || s.SubscriptionID != "sub-1" {
+		t.Errorf("first identity = %q %q, want PE-01 sub-1", s.SystemID, s.SubscriptionID)
+	}
+	if s.LastError != "unknown sensor_path: foo" || s.LastErrorTime == nil || s.Stale {
+		t.Errorf("stream error/stale = %q %v %v", s.LastError, s.LastErrorTime, s.Stale)
+	}
+	if !streams[1].Stale {
+		t.Errorf("stream %s should be stale", streams[1].ID)
+	}
+
+	if !c.CloseStream(id1, "maintenance") {
+		t.Fatal("CloseStream returned false")
+	}
+	// 重复关闭不阻塞
+	c.CloseStream(id1, "again")
+	if reason := <-closeCh; reason != "maintenance" {
+		t.Errorf("close reason = %q", reason)
+	}
+	if c.CloseStream("missing", "x") {
+		t.Error("CloseStream(missing) = true")
+	}
+
+	if closed := c.CloseStaleStreams("stale"); len(closed) != 1 || closed[0] != id2 {
+		t.Errorf("CloseStaleStreams = %v, want [%s]", closed, id2)
+	}
+}
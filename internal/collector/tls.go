@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+// CertIdentity 设备客户端证书身份
+type CertIdentity struct {
+	CommonName string
+	SANs       []string // DNS、IP、URI、Email 形式的主题备用名称
+}
+
+// Matches system_id 与证书CN或任一SAN相同（不区分大小写）
+func (id *CertIdentity) Matches(systemID string) bool {
+	if strings.EqualFold(id.CommonName, systemID) {
+		return true
+	}
+	for _, san := range id.SANs {
+		if strings.EqualFold(san, systemID) {
+			return true
+		}
+	}
+	return false
+}
+
+// String 用于日志输出
+func (id *CertIdentity) String() string {
+	if len(id.SANs) == 0 {
+		return "CN=" + id.CommonName
+	}
+	return fmt.Sprintf("CN=%s SAN=%s", id.CommonName, strings.Join(id.SANs, ","))
+}
+
+// peerCertIdentity 从gRPC对端信息中取出客户端证书身份，未启用TLS或未提供证书时返回nil
+func peerCertIdentity(p *peer.Peer) *CertIdentity {
+	if p == nil {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := info.State.PeerCertificates[0]
+	id := &CertIdentity{CommonName: cert.Subject.CommonName}
+	id.SANs = append(id.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		id.SANs = append(id.SANs, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		id.SANs = append(id.SANs, uri.String())
+	}
+	id.SANs = append(id.SANs, cert.EmailAddresses...)
+	return id
+}
+
+// tlsReloader 证书热加载
+//
+// gRPC服务端通过 GetConfigForClient 在每次握手时取当前配置，
+// 重新加载只影响之后建立的连接，已建立的流不受影响。
+type tlsReloader struct {
+	logger  *logrus.Logger
+	current atomic.Pointer[tls.Config]
+
+	mu      sync.Mutex
+	cfg     config.TLSConfig
+	modTime map[string]time.Time
+}
+
+// newTLSReloader 创建证书热加载器，首次加载失败时返回错误
+func newTLSReloader(cfg config.TLSConfig, logger *logrus.Logger) (*tlsReloader, error) {
+	r := &tlsReloader{logger: logger}
+	if err := r.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ServerConfig 返回交给gRPC的TLS配置
+func (r *tlsReloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Reload 按给定配置重新加载证书、私钥与客户端CA
+func (r *tlsReloader) Reload(cfg config.TLSConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	r.cfg = cfg
+	r.modTime = fileModTimes(cfg)
+	r.current.Store(tlsConfig)
+	r.logger.Infof("TLS证书已加载: cert=%s, client_auth=%s", cfg.CertFile, clientAuthName(cfg.ClientAuth))
+	return nil
+}
+
+// ReloadIfChanged 证书、私钥或CA文件修改时间变化时重新加载
+func (r *tlsReloader) ReloadIfChanged() {
+	r.mu.Lock()
+	cfg := r.cfg
+	changed := false
+	for path, mod := range fileModTimes(cfg) {
+		if !mod.Equal(r.modTime[path]) {
+			changed = true
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := r.Reload(cfg); err != nil {
+		// 证书可能正在被替换，保留旧配置，下次检查时重试
+		r.logger.WithError(err).Warn("TLS证书文件已变化但重新加载失败，继续使用旧证书")
+	}
+}
+
+// buildTLSConfig 根据配置构造服务端TLS配置
+func buildTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载服务端证书失败: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2"},
+	}
+
+	switch clientAuthName(cfg.ClientAuth) {
+	case "none":
+		tlsConfig.ClientAuth = tls.NoClientCert
+		return tlsConfig, nil
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "required":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("不支持的client_auth: %s（可选 none/optional/required）", cfg.ClientAuth)
+	}
+
+	if cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("client_auth=%s 需要配置client_ca_file", cfg.ClientAuth)
+	}
+	caPEM, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取客户端CA失败: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("客户端CA文件中没有有效证书: %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	return tlsConfig, nil
+}
+
+// clientAuthName 未配置时不校验客户端证书
+func clientAuthName(clientAuth string) string {
+	if clientAuth == "" {
+		return "none"
+	}
+	return strings.ToLower(clientAuth)
+}
+
+// fileModTimes 获取证书相关文件的修改时间，文件不存在时跳过
+func fileModTimes(cfg config.TLSConfig) map[string]time.Time {
+	times := make(map[string]time.Time, 3)
+	for _, path := range []string{cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			times[path] = info.ModTime()
+		}
+	}
+	return times
+}
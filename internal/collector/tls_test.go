@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+// writeTestCert 生成自签名证书写入dir，返回证书与私钥路径
+func writeTestCert(t *testing.T, dir, name, cn string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{cn + ".example.net"},
+		IPAddresses:           []net.IP{net.ParseIP("192.0.2.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server", "collector")
+
+	tests := []struct {
+		name     string
+		cfg      config.TLSConfig
+		wantAuth tls.ClientAuthType
+		wantErr  bool
+	}{
+		{"default none", config.TLSConfig{CertFile: certFile, KeyFile: keyFile}, tls.NoClientCert, false},
+		{"optional", config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, ClientAuth: "optional"}, tls.VerifyClientCertIfGiven, false},
+		{"required", config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, ClientAuth: "REQUIRED"}, tls.RequireAndVerifyClientCert, false},
+		{"required without CA", config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "required"}, 0, true},
+		{"unknown client_auth", config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"}, 0, true},
+		{"missing key", config.TLSConfig{CertFile: certFile, KeyFile: filepath.Join(dir, "none.key")}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := buildTLSConfig(tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.ClientAuth != tt.wantAuth {
+			t.Errorf("%s: ClientAuth = %v, want %v", tt.name, got.ClientAuth, tt.wantAuth)
+		}
+		if len(got.NextProtos) == 0 || got.NextProtos[0] != "h2" {
+			t.Errorf("%s: NextProtos = %v, want h2", tt.name, got.NextProtos)
+		}
+	}
+}
+
+func TestTLSReloader_ReloadIfChanged(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "server", "collector-a")
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	r, err := newTLSReloader(config.TLSConfig{CertFile: certFile, KeyFile: keyFile}, logger)
+	if err != nil {
+		t.Fatalf("newTLSReloader: %v", err)
+	}
+	serverCN := func() string {
+		cfg, err := r.ServerConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatalf("GetConfigForClient: %v", err)
+		}
+		leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatalf("ParseCertificate: %v", err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if cn := serverCN(); cn != "collector-a" {
+		t.Fatalf("CN = %s, want collector-a", cn)
+	}
+
+	// 文件未变化不重新加载
+	r.ReloadIfChanged()
+	if cn := serverCN(); cn != "collector-a" {
+		t.Fatalf("CN = %s, want collector-a", cn)
+	}
+
+	writeTestCert(t, dir, "server", "collector-b")
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+	r.ReloadIfChanged()
+	if cn := serverCN(); cn != "collector-b" {
+		t.Fatalf("CN after reload = %s, want collector-b", cn)
+	}
+
+	// 新证书损坏时保留旧配置
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	later := future.Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	r.ReloadIfChanged()
+	if cn := serverCN(); cn != "collector-b" {
+		t.Fatalf("CN after failed reload = %s, want collector-b", cn)
+	}
+}
+
+func TestCertIdentity_Matches(t *testing.T) {
+	id := &CertIdentity{CommonName: "PE-01", SANs: []string{"pe-01.example.net", "192.0.2.1"}}
+	for _, systemID := range []string{"pe-01", "PE-01.example.net", "192.0.2.1"} {
+		if !id.Matches(systemID) {
+			t.Errorf("Matches(%q) = false, want true", systemID)
+		}
+	}
+	if id.Matches("pe-02") {
+		t.Error("Matches(pe-02) = true, want false")
+	}
+	if got := id.String(); got != "CN=PE-01 SAN=pe-01.example.net,192.0.2.1" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -21,6 +21,17 @@ type Config struct {
 	Compression    CompressionConfig    `yaml:"compression"`
 	Recovery       RecoveryConfig       `yaml:"recovery"`
 	Debug          DebugConfig          `yaml:"debug"`
+	Parser         ParserConfig         `yaml:"parser"`
+	Collection     CollectionConfig     `yaml:"collection"`
+	Rates          RatesConfig          `yaml:"rates"`
+	Access         AccessConfig         `yaml:"access"`
+	RateLimit      RateLimitConfig      `yaml:"rate_limit"`
+	Devices        DevicesConfig        `yaml:"devices"`
+	Cadence        CadenceConfig        `yaml:"cadence"`
+	Sequence       SequenceConfig       `yaml:"sequence"`
+	ClockSkew      ClockSkewConfig      `yaml:"clock_skew"`
+	Capture        CaptureConfig        `yaml:"capture"`
+	DeadLetter     DeadLetterConfig     `yaml:"dead_letter"`
 }
 
 // DatabaseConfig 数据库配置 - 扩展版本
@@ -36,6 +47,10 @@ type DatabaseConfig struct {
 	MaxIdleConns      int           `yaml:"max_idle_conns"`
 	ConnMaxLifetime   time.Duration `yaml:"conn_max_lifetime"`
 	ConnMaxIdleTime   time.Duration `yaml:"conn_max_idle_time"`
+
+	// LegacyStringColumns 过渡期同时写入旧版字符串列（如 in_traffic_rate="12.34 Mbps"），
+	// 数值列（*_mbps/*_kfps、*_v/*_a/*_w）始终写入；迁移完成后关闭即可删除旧列
+	LegacyStringColumns bool `yaml:"legacy_string_columns"`
 }
 
 // ServerConfig 服务器配置
@@ -46,17 +61,66 @@ type ServerConfig struct {
 	MaxConcurrentStreams  uint32        `yaml:"max_concurrent_streams"`
 	KeepaliveTime         time.Duration `yaml:"keepalive_time"`
 	KeepaliveTimeout      time.Duration `yaml:"keepalive_timeout"`
+	TLS                   TLSConfig     `yaml:"tls"`
+	FlowControl           FlowControlConfig `yaml:"flow_control"`
+}
+
+// TLSConfig dialout gRPC服务端TLS配置
+type TLSConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientCAFile 校验设备客户端证书的CA，client_auth为optional/required时必填
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientAuth 客户端证书校验：none（默认）、optional（提供则校验）、required（双向TLS）
+	ClientAuth string `yaml:"client_auth"`
+	// ReloadInterval 检查证书文件变化的间隔，变化后新连接使用新证书，0表示只在SIGHUP时重新加载
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// FlowControlConfig 缓冲区超出上限（buffer.*_buffer_size、buffer.max_size、memory.max_memory_usage）
+// 且overflow_policy拒绝写入时对Publish报文的处理
+type FlowControlConfig struct {
+	// OnFull reject（默认）：丢弃报文并在响应中返回buffer full；
+	// delay：暂停读取该流直到缓冲区回落，再写入并应答；
+	// withhold：丢弃报文且不应答，由设备重传/退避
+	OnFull string `yaml:"on_full"`
+	// MaxDelay delay策略下的最长等待时间，超时后按reject处理
+	MaxDelay time.Duration `yaml:"max_delay"`
 }
 
 // BufferConfig 缓冲区配置 - 扩展版本
 type BufferConfig struct {
+	// MaxSize 所有缓冲区合计记录数上限，达到后按overflow_policy处理，0表示不限制
 	MaxSize                    int `yaml:"max_size"`
 	FlushInterval              time.Duration `yaml:"flush_interval"`
 	BatchSize                  int `yaml:"batch_size"`
 	FlushThreshold             int `yaml:"flush_threshold"`
+	// 平台/接口/子接口缓冲区各自的记录数上限，达到后按overflow_policy处理，0表示不限制
 	PlatformBufferSize         int `yaml:"platform_buffer_size"`
 	InterfaceBufferSize        int `yaml:"interface_buffer_size"`
 	SubinterfaceBufferSize     int `yaml:"subinterface_buffer_size"`
+
+	// OverflowPolicy 超出记录数上限或memory.max_memory_usage时的处理：
+	// block（等待回落，超过block_timeout拒绝）、drop_oldest、drop_newest、spill（只写WAL）
+	OverflowPolicy string `yaml:"overflow_policy"`
+	// BlockTimeout block策略下最长等待时间
+	BlockTimeout time.Duration `yaml:"block_timeout"`
+
+	// Aggregation 按缓冲区类型（platform、interface、subinterface、optical_channel、generic）配置聚合窗口，
+	// 未配置的按秒级时间戳聚合
+	Aggregation map[string]AggregationConfig `yaml:"aggregation"`
+}
+
+// AggregationConfig 单个缓冲区的聚合窗口配置
+type AggregationConfig struct {
+	// Window 时间窗口：时长（如 1s、30s）按时间戳对齐分桶；period 按设备上报的采样周期（current_period）对齐分桶；
+	// collection 按采样轮次分桶（需启用collection，时间戳已统一为轮次开始时间）。为空时为1s
+	Window string `yaml:"window"`
+	// Grace 窗口结束后继续等待迟到数据的时间，collection窗口从轮次开始时间起算
+	Grace time.Duration `yaml:"grace"`
+	// Keys 额外的聚合键字段（JSON字段名），如 power_name
+	Keys []string `yaml:"keys"`
 }
 
 // DatabaseWriterConfig 数据库写入配置
@@ -74,6 +138,7 @@ type DatabaseWriterConfig struct {
 
 // MemoryConfig 内存管理配置
 type MemoryConfig struct {
+	// MaxMemoryUsage 缓冲数据（含等待写库的批次）估算内存上限，如 "2GB"，空或 "0" 表示不限制
 	MaxMemoryUsage   string `yaml:"max_memory_usage"`
 	GCTargetPercent  int    `yaml:"gc_target_percent"`
 	BufferPoolSize   int    `yaml:"buffer_pool_size"`
@@ -99,6 +164,11 @@ type MonitoringConfig struct {
 	PrometheusEnabled  bool                    `yaml:"prometheus_enabled"`
 	PrometheusPort     int                     `yaml:"prometheus_port"`
 	AlertThresholds    AlertThresholdsConfig   `yaml:"alert_thresholds"`
+
+	// AdminBindAddress 管理API（health_check_port）的监听地址，默认只监听本机
+	AdminBindAddress string `yaml:"admin_bind_address"`
+	// AdminToken 非空时管理API要求 Authorization: Bearer <token>
+	AdminToken string `yaml:"admin_token"`
 }
 
 // AlertThresholdsConfig 告警阈值配置
@@ -130,10 +200,18 @@ type CompressionConfig struct {
 
 // RecoveryConfig 故障恢复配置
 type RecoveryConfig struct {
-	EnableDataPersistence bool          `yaml:"enable_data_persistence"`
-	PersistencePath       string        `yaml:"persistence_path"`
-	MaxRecoveryTime       time.Duration `yaml:"max_recovery_time"`
-	CheckpointInterval    time.Duration `yaml:"checkpoint_interval"`
+	// EnableDataPersistence 启用缓冲数据预写日志（WAL）
+	EnableDataPersistence bool   `yaml:"enable_data_persistence"`
+	PersistencePath       string `yaml:"persistence_path"`
+	// MaxRecoveryTime 检查点与停止时等待写库完成的最长时间
+	MaxRecoveryTime    time.Duration `yaml:"max_recovery_time"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+	// SegmentSize WAL单个段文件大小（字节），也是每次载入积压数据的粒度
+	SegmentSize int64 `yaml:"segment_size"`
+	// MaxSize WAL总大小上限（字节），达到后拒绝新数据，0为不限制
+	MaxSize int64 `yaml:"max_size"`
+	// SyncInterval fsync间隔，0为每次写入都fsync
+	SyncInterval time.Duration `yaml:"sync_interval"`
 }
 
 // DebugConfig 调试配置
@@ -145,6 +223,224 @@ type DebugConfig struct {
 	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
 }
 
+// ParserConfig 解析器配置
+type ParserConfig struct {
+	// GenericDispatch 未知sensor_path按proto_path从protobuf注册表动态解码，写入generic_metrics宽表
+	GenericDispatch bool `yaml:"generic_dispatch"`
+	// DescriptorDir 运行时加载的proto描述目录（FileDescriptorSet 或 .proto 文件），SIGHUP时重新加载
+	DescriptorDir string `yaml:"descriptor_dir"`
+	// ProtocPath 加载 .proto 文件使用的protoc，默认从PATH查找
+	ProtocPath string `yaml:"protoc_path"`
+	// SensorMappings sensor_path到描述消息与目标表的映射
+	SensorMappings []SensorMapping `yaml:"sensor_mappings"`
+	// MappingFile 字段到表列的声明式映射文件，SIGHUP时重新加载
+	MappingFile string `yaml:"mapping_file"`
+}
+
+// SensorMapping 单个sensor_path的通用解析映射
+type SensorMapping struct {
+	SensorPath string `yaml:"sensor_path"`
+	Message    string `yaml:"message"` // 消息全名，如 zte.telemetry.qos.QosInfo；为空时使用报文的proto_path
+	Table      string `yaml:"table"`   // 目标表（与generic_metrics同结构），为空时写入generic_metrics
+}
+
+// CollectionConfig 采样轮次重组配置
+type CollectionConfig struct {
+	// Enabled 按 (system_id, subscription_id, collection_id) 重组多包采样，行时间戳统一为轮次开始时间；默认关闭
+	Enabled bool `yaml:"enabled"`
+	// RoundTimeout 轮次超过该时间未收到末包（collection_end_time）视为不完整
+	RoundTimeout time.Duration `yaml:"round_timeout"`
+	// FlushOnComplete 轮次结束后立即写入该设备本轮的缓冲数据
+	FlushOnComplete bool `yaml:"flush_on_complete"`
+}
+
+// RatesConfig 服务端计数器速率计算配置
+type RatesConfig struct {
+	// Enabled 根据相邻两次计数器采样计算每秒速率，写入 *_rate 列
+	Enabled bool `yaml:"enabled"`
+	// MaxInterval 两次采样间隔超过该值时只更新基线，不输出速率
+	MaxInterval time.Duration `yaml:"max_interval"`
+	// StateTTL 接口基线超过该时间无更新则清理
+	StateTTL time.Duration `yaml:"state_ttl"`
+}
+
+// AccessConfig Publish流访问控制配置
+type AccessConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Allow 允许建立连接的对端地址（IP或CIDR），为空表示不限制
+	Allow []string `yaml:"allow"`
+	// Deny 拒绝的对端地址（IP或CIDR），优先于Allow
+	Deny []string `yaml:"deny"`
+	// Devices system_id与允许来源地址的绑定，未登记的设备只做Allow/Deny检查
+	Devices []DeviceBinding `yaml:"devices"`
+	// RequireRegistered 未在Devices中登记的system_id视为不匹配
+	RequireRegistered bool `yaml:"require_registered"`
+	// RequireCertMatch 启用TLS客户端证书时，system_id必须与证书CN或SAN一致
+	RequireCertMatch bool `yaml:"require_cert_match"`
+	// MismatchPolicy 报文来源不匹配时的处理：reject_stream、drop_message、tag_row
+	MismatchPolicy string `yaml:"mismatch_policy"`
+	// TagSuffix tag_row 时追加到system_id后的标记
+	TagSuffix string `yaml:"tag_suffix"`
+}
+
+// DeviceBinding 单台设备允许的来源
+type DeviceBinding struct {
+	SystemID string   `yaml:"system_id"`
+	Sources  []string `yaml:"sources"`
+	// LearnSource 首次通过检查的对端地址记为该设备的来源（进程内有效），此后只接受该地址与Sources
+	LearnSource bool `yaml:"learn_source"`
+	// MatchMngIP 已移除：报文中的mng_ipv4/mng_ipv6由发送方填写，可被伪造。保留字段以便配置中仍有时报错
+	MatchMngIP bool `yaml:"match_mng_ip"`
+}
+
+// RateLimitConfig 采集侧报文限速配置（令牌桶，单位为PublishArgs报文数）
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Device 每个system_id的默认限速
+	Device RateLimitRule `yaml:"device"`
+	// Peer 每个对端地址的默认限速
+	Peer RateLimitRule `yaml:"peer"`
+	// SensorPath 每个 system_id + sensor_path 的默认限速
+	SensorPath RateLimitRule `yaml:"sensor_path"`
+	// Overrides 按system_id、对端地址或sensor_path覆盖默认限速
+	Overrides []RateLimitOverride `yaml:"overrides"`
+	// StateTTL 令牌桶超过该时间未使用则清理
+	StateTTL time.Duration `yaml:"state_ttl"`
+}
+
+// RateLimitRule 单个令牌桶的限速规则
+type RateLimitRule struct {
+	// Rate 每秒允许的报文数，0表示不限制
+	Rate float64 `yaml:"rate"`
+	// Burst 桶容量，不大于0时取 max(1, Rate)
+	Burst int `yaml:"burst"`
+	// Action 超限时的处理：drop（丢弃）、sample（每SampleN条保留1条）、reject_stream（断开流）
+	Action string `yaml:"action"`
+	// SampleN sample策略下的抽样间隔
+	SampleN int `yaml:"sample_n"`
+}
+
+// RateLimitOverride 覆盖规则，scope为device/peer/sensor_path，match为对应的system_id、IP或sensor_path
+type RateLimitOverride struct {
+	Scope         string `yaml:"scope"`
+	Match         string `yaml:"match"`
+	RateLimitRule `yaml:",inline"`
+}
+
+// DevicesConfig 设备登记配置
+type DevicesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// PersistInterval 有变化的设备写入devices表的间隔
+	PersistInterval time.Duration `yaml:"persist_interval"`
+}
+
+// CadenceConfig 上报周期监测配置
+type CadenceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// LateMultiple 超过 current_period 的该倍数仍无数据判定为迟到
+	LateMultiple float64 `yaml:"late_multiple"`
+	// MinLate 迟到阈值下限，避免秒级周期的抖动被误判
+	MinLate time.Duration `yaml:"min_late"`
+	// CheckInterval 检查迟到与写入 telemetry_gaps 的间隔
+	CheckInterval time.Duration `yaml:"check_interval"`
+	// StateTTL 迟到超过该时间的序列不再跟踪，缺口保持未结束
+	StateTTL time.Duration `yaml:"state_ttl"`
+}
+
+// SequenceConfig PublishArgs.ReqId 跳号与重复检测配置
+type SequenceConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Window 用于判断重复与乱序的最近ReqId数
+	Window int `yaml:"window"`
+	// DropDuplicates 丢弃ReqId与内容都相同的重传报文，不写入缓冲区
+	DropDuplicates bool `yaml:"drop_duplicates"`
+	// ResumeTTL 断线后在该时间内重连并继续原序号的流沿用旧流的跟踪状态
+	ResumeTTL time.Duration `yaml:"resume_ttl"`
+}
+
+// ClockSkewConfig 设备时钟偏差检测配置
+type ClockSkewConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Policy 偏差超过MaxSkew时的处理：trust（信任设备时间）、replace（以接收时间为准）、reject（拒绝报文）
+	Policy string `yaml:"policy"`
+	// MaxSkew 允许的最大偏差（设备时间 - 接收时间的绝对值）
+	MaxSkew time.Duration `yaml:"max_skew"`
+	// Devices 按system_id覆盖策略，未配置的字段取全局值
+	Devices []ClockSkewOverride `yaml:"devices"`
+	// StateTTL 设备超过该时间未上报则不再导出其偏差
+	StateTTL time.Duration `yaml:"state_ttl"`
+}
+
+// ClockSkewOverride 单台设备的时钟偏差策略
+type ClockSkewOverride struct {
+	SystemID string        `yaml:"system_id"`
+	Policy   string        `yaml:"policy"`
+	MaxSkew  time.Duration `yaml:"max_skew"`
+}
+
+// CaptureConfig 原始Publish报文抓包配置
+type CaptureConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Dir 抓包文件目录
+	Dir string `yaml:"dir"`
+	// FilePrefix 文件名前缀，文件名为 <prefix>-YYYYMMDD-HHMMSS.zcap
+	FilePrefix string `yaml:"file_prefix"`
+	// MaxFileSize 单个文件大小上限（字节），超过后轮转
+	MaxFileSize int64 `yaml:"max_file_size"`
+	// MaxFiles 保留的文件数，0为不清理
+	MaxFiles int `yaml:"max_files"`
+	// SystemIDs 只抓取这些设备，为空不限制
+	SystemIDs []string `yaml:"system_ids"`
+	// SensorPaths 只抓取以这些前缀开头的sensor_path，为空不限制
+	SensorPaths []string `yaml:"sensor_paths"`
+	// IncludeParseErrors 同时抓取解析失败的报文（无法按system_id/sensor_path过滤）
+	IncludeParseErrors bool `yaml:"include_parse_errors"`
+}
+
+// DeadLetterConfig 死信队列配置：重试耗尽仍写库失败的批次保存到本地文件
+type DeadLetterConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Dir 死信文件目录
+	Dir string `yaml:"dir"`
+	// MaxSize 所有死信文件合计上限（字节），达到后新的失败批次被丢弃，0为不限制
+	MaxSize int64 `yaml:"max_size"`
+}
+
+// ColumnMappingFile 声明式列映射文件
+type ColumnMappingFile struct {
+	Mappings []ColumnMapping `yaml:"mappings"`
+}
+
+// ColumnMapping 单个sensor_path的字段到表列映射
+type ColumnMapping struct {
+	SensorPath string       `yaml:"sensor_path"`
+	Message    string       `yaml:"message"`  // 消息全名；为空时使用报文的proto_path
+	RowPath    string       `yaml:"row_path"` // 重复消息字段路径，每个元素一行；为空时整个消息一行
+	Table      string       `yaml:"table"`
+	Keys       []string     `yaml:"keys"` // 行键字段（列名），用于缓冲去重
+	Columns    []ColumnSpec `yaml:"columns"`
+}
+// ColumnSpec 单列定义
+type ColumnSpec struct {
+	Column    string `yaml:"column"`
+	Field     string `yaml:"field"`     // 字段路径，相对row_path元素；以/开头时相对消息根
+	Transform string `yaml:"transform"` // bytes_to_mb, nanos_to_seconds, nanos_to_timestamp, uptime, enum, ...
+}
+
+// LoadColumnMappings 加载声明式列映射文件
+func LoadColumnMappings(filename string) (*ColumnMappingFile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("读取映射文件失败: %v", err)
+	}
+
+	mappings := &ColumnMappingFile{}
+	if err := yaml.UnmarshalStrict(data, mappings); err != nil {
+		return nil, fmt.Errorf("解析映射文件失败: %v", err)
+	}
+	return mappings, nil
+}
+
 // LoadConfig 加载配置文件 - 扩展版本
 func LoadConfig(filename string) (*Config, error) {
 	// 默认配置
@@ -161,6 +457,8 @@ func LoadConfig(filename string) (*Config, error) {
 			MaxIdleConns:    5,
 			ConnMaxLifetime: 30 * time.Minute,
 			ConnMaxIdleTime: 5 * time.Minute,
+
+			LegacyStringColumns: true,
 		},
 		Server: ServerConfig{
 			Port:                 50051,
@@ -169,15 +467,24 @@ func LoadConfig(filename string) (*Config, error) {
 			MaxConcurrentStreams: 100,
 			KeepaliveTime:        30 * time.Second,
 			KeepaliveTimeout:     5 * time.Second,
+			TLS: TLSConfig{
+				ClientAuth:     "none",
+				ReloadInterval: time.Minute,
+			},
+			FlowControl: FlowControlConfig{
+				OnFull:   "reject",
+				MaxDelay: 5 * time.Second,
+			},
 		},
 		Buffer: BufferConfig{
-			MaxSize:                1000,
 			FlushInterval:          30 * time.Second,
 			BatchSize:              100,
 			FlushThreshold:         500,
 			PlatformBufferSize:     5000,
 			InterfaceBufferSize:    5000,
 			SubinterfaceBufferSize: 5000,
+			OverflowPolicy:         "block",
+			BlockTimeout:           5 * time.Second,
 		},
 		DatabaseWriter: DatabaseWriterConfig{
 			BatchTimeout:              5 * time.Second,
@@ -211,6 +518,7 @@ func LoadConfig(filename string) (*Config, error) {
 			HealthCheckPort:   8080,
 			PrometheusEnabled: false,
 			PrometheusPort:    9090,
+			AdminBindAddress:  "127.0.0.1",
 			AlertThresholds: AlertThresholdsConfig{
 				BufferUsagePercent:       80,
 				DBConnectionUsagePercent: 85,
@@ -238,6 +546,9 @@ func LoadConfig(filename string) (*Config, error) {
 			PersistencePath:       "data/recovery",
 			MaxRecoveryTime:       5 * time.Minute,
 			CheckpointInterval:    30 * time.Second,
+			SegmentSize:           32 * 1024 * 1024,
+			MaxSize:               20 * 1024 * 1024 * 1024,
+			SyncInterval:          1 * time.Second,
 		},
 		Debug: DebugConfig{
 			Enabled:            false,
@@ -246,6 +557,55 @@ func LoadConfig(filename string) (*Config, error) {
 			ProfileEnabled:     false,
 			SlowQueryThreshold: 1 * time.Second,
 		},
+		Collection: CollectionConfig{
+			RoundTimeout:    2 * time.Minute,
+			FlushOnComplete: true,
+		},
+		Rates: RatesConfig{
+			Enabled:     true,
+			MaxInterval: 10 * time.Minute,
+			StateTTL:    30 * time.Minute,
+		},
+		Access: AccessConfig{
+			MismatchPolicy: "drop_message",
+			TagSuffix:      "#untrusted",
+		},
+		RateLimit: RateLimitConfig{
+			StateTTL: 30 * time.Minute,
+		},
+		Devices: DevicesConfig{
+			Enabled:         true,
+			PersistInterval: 1 * time.Minute,
+		},
+		Cadence: CadenceConfig{
+			Enabled:       true,
+			LateMultiple:  3,
+			MinLate:       30 * time.Second,
+			CheckInterval: 10 * time.Second,
+			StateTTL:      24 * time.Hour,
+		},
+		Sequence: SequenceConfig{
+			Enabled:   true,
+			Window:    1024,
+			ResumeTTL: 10 * time.Minute,
+		},
+		ClockSkew: ClockSkewConfig{
+			Enabled:  true,
+			Policy:   "trust",
+			MaxSkew:  5 * time.Minute,
+			StateTTL: 1 * time.Hour,
+		},
+		Capture: CaptureConfig{
+			Dir:                "captures",
+			FilePrefix:         "publish",
+			MaxFileSize:        64 * 1024 * 1024,
+			MaxFiles:           20,
+			IncludeParseErrors: true,
+		},
+		DeadLetter: DeadLetterConfig{
+			Dir:     "data/dlq",
+			MaxSize: 10 * 1024 * 1024 * 1024,
+		},
 	}
 
 	// 如果配置文件存在，则加载
@@ -260,6 +620,11 @@ func LoadConfig(filename string) (*Config, error) {
 		}
 	}
 
+	// 轮次超时检查按 round_timeout/2 的间隔执行
+	if config.Collection.Enabled && config.Collection.RoundTimeout < time.Second {
+		return nil, fmt.Errorf("collection.round_timeout不能小于1s: %v", config.Collection.RoundTimeout)
+	}
+
 	return config, nil
 }
 
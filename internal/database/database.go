@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -18,6 +19,11 @@ import (
 type Database struct {
 	pool   *pgxpool.Pool
 	logger *logrus.Logger
+
+	// legacyStringColumns 为true时同时写入旧版字符串列（速率、电压/电流/功率）
+	legacyStringColumns bool
+	// counterRateColumns 为true时写入计数器速率列（需先执行migrations/002）
+	counterRateColumns bool
 }
 
 // NewDatabase 创建新的数据库连接
@@ -26,6 +32,7 @@ func NewDatabase(host string, port int, user, password, dbname string, logger *l
 		Host: host, Port: port, User: user, Password: password, Database: dbname,
 		MaxOpenConns: 200, MaxIdleConns: 25,
 		ConnMaxLifetime: time.Hour, ConnMaxIdleTime: 30 * time.Minute,
+		LegacyStringColumns: true,
 	}, logger)
 }
 
@@ -76,11 +83,18 @@ func NewDatabaseWithConfig(cfg config.DatabaseConfig, logger *logrus.Logger) (*D
 		poolConfig.MaxConns, poolConfig.MaxConnIdleTime, poolConfig.MaxConnLifetime, poolConfig.MinConns)
 
 	return &Database{
-		pool:   pool,
-		logger: logger,
+		pool:                pool,
+		logger:              logger,
+		legacyStringColumns: cfg.LegacyStringColumns,
 	}, nil
 }
 
+// SetCounterRateColumns 启用计数器速率计算（rates.enabled）时写入接口/子接口的速率列，
+// 未启用时不写这些列，未执行migrations/002的库照常写入
+func (db *Database) SetCounterRateColumns(enabled bool) {
+	db.counterRateColumns = enabled
+}
+
 // Close 关闭数据库连接
 func (db *Database) Close() {
 	if db.pool != nil {
@@ -143,12 +157,12 @@ func (db *Database) BatchInsertInterfaceMetricsWithContext(ctx context.Context,
 			metric.LastClear,
 			metric.InPkts,
 			metric.OutPkts,
-			safeNumericString(metric.InputUtilization),
-			safeNumericString(metric.OutputUtilization),
-			metric.InTrafficRate,
-			metric.InPacketRate,
-			metric.OutTrafficRate,
-			metric.OutPacketRate,
+			metric.InputUtilization,
+			metric.OutputUtilization,
+			metric.InTrafficRateMbps,
+			metric.InPacketRateKfps,
+			metric.OutTrafficRateMbps,
+			metric.OutPacketRateKfps,
 			metric.InV4Octets,
 			metric.OutV4Octets,
 			metric.InV4Pkts,
@@ -157,44 +171,60 @@ func (db *Database) BatchInsertInterfaceMetricsWithContext(ctx context.Context,
 			metric.OutV6Octets,
 			metric.InV6Pkts,
 			metric.OutV6Pkts,
-			metric.InV4TrafficRate,
-			metric.InV4PacketRate,
-			metric.OutV4TrafficRate,
-			metric.OutV4PacketRate,
-			metric.InV6TrafficRate,
-			metric.InV6PacketRate,
-			metric.OutV6TrafficRate,
-			metric.OutV6PacketRate,
-			safeNumericString(metric.InputV4Utilization),
-			safeNumericString(metric.OutputV4Utilization),
-			safeNumericString(metric.InputV6Utilization),
-			safeNumericString(metric.OutputV6Utilization),
+			metric.InV4TrafficRateMbps,
+			metric.InV4PacketRateKfps,
+			metric.OutV4TrafficRateMbps,
+			metric.OutV4PacketRateKfps,
+			metric.InV6TrafficRateMbps,
+			metric.InV6PacketRateKfps,
+			metric.OutV6TrafficRateMbps,
+			metric.OutV6PacketRateKfps,
+			metric.InputV4Utilization,
+			metric.OutputV4Utilization,
+			metric.InputV6Utilization,
+			metric.OutputV6Utilization,
 			metric.InBierOctets,
 			metric.InBierPkts,
 			metric.OutBierOctets,
 			metric.OutBierPkts,
 		}
+		if db.counterRateColumns {
+			row = append(row, counterRateValues(metric.CounterRates)...)
+		}
+		if db.legacyStringColumns {
+			row = append(row, legacyRateValues(
+				metric.InTrafficRateMbps, metric.InPacketRateKfps, metric.OutTrafficRateMbps, metric.OutPacketRateKfps,
+				metric.InV4TrafficRateMbps, metric.InV4PacketRateKfps, metric.OutV4TrafficRateMbps, metric.OutV4PacketRateKfps,
+				metric.InV6TrafficRateMbps, metric.InV6PacketRateKfps, metric.OutV6TrafficRateMbps, metric.OutV6PacketRateKfps,
+			)...)
+		}
 		rows = append(rows, row)
 	}
 
 	// 执行COPY FROM STDIN - 使用完整的字段列表
-	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"interface_metrics"}, 
-		[]string{
-			"timestamp", "system_id", "interface_name", "in_octets", "out_octets", "in_unicast_pkts", "out_unicast_pkts",
-			"in_discards", "out_discards", "in_errors", "out_errors", "in_unknown_protos", "in_multicast_pkts",
-			"out_multicast_pkts", "in_broadcast_pkts", "out_broadcast_pkts", "admin_status", "oper_status",
-			"last_change", "ifindex", "type", "phy_status", "ipv4_oper_status", "logical",
-			"zteif_type", "zteif_ifindex", "zteif_admin_status", "zteif_oper_status", "zteif_phy_status",
-			"zteif_ipv4_oper_status", "zteif_ipv6_oper_status", "in_fcs_errors", "carrier_transitions",
-			"last_clear", "in_pkts", "out_pkts", "input_utilization", "output_utilization",
-			"in_traffic_rate", "in_packet_rate", "out_traffic_rate", "out_packet_rate",
-			"in_v4_octets", "out_v4_octets", "in_v4_pkts", "out_v4_pkts",
-			"in_v6_octets", "out_v6_octets", "in_v6_pkts", "out_v6_pkts",
-			"in_v4_traffic_rate", "in_v4_packet_rate", "out_v4_traffic_rate", "out_v4_packet_rate",
-			"in_v6_traffic_rate", "in_v6_packet_rate", "out_v6_traffic_rate", "out_v6_packet_rate",
-			"input_v4_utilization", "output_v4_utilization", "input_v6_utilization", "output_v6_utilization",
-			"in_bier_octets", "in_bier_pkts", "out_bier_octets", "out_bier_pkts",
-		},
+	columns := []string{
+		"timestamp", "system_id", "interface_name", "in_octets", "out_octets", "in_unicast_pkts", "out_unicast_pkts",
+		"in_discards", "out_discards", "in_errors", "out_errors", "in_unknown_protos", "in_multicast_pkts",
+		"out_multicast_pkts", "in_broadcast_pkts", "out_broadcast_pkts", "admin_status", "oper_status",
+		"last_change", "ifindex", "type", "phy_status", "ipv4_oper_status", "logical",
+		"zteif_type", "zteif_ifindex", "zteif_admin_status", "zteif_oper_status", "zteif_phy_status",
+		"zteif_ipv4_oper_status", "zteif_ipv6_oper_status", "in_fcs_errors", "carrier_transitions",
+		"last_clear", "in_pkts", "out_pkts", "input_utilization", "output_utilization",
+		"in_traffic_rate_mbps", "in_packet_rate_kfps", "out_traffic_rate_mbps", "out_packet_rate_kfps",
+		"in_v4_octets", "out_v4_octets", "in_v4_pkts", "out_v4_pkts",
+		"in_v6_octets", "out_v6_octets", "in_v6_pkts", "out_v6_pkts",
+		"in_v4_traffic_rate_mbps", "in_v4_packet_rate_kfps", "out_v4_traffic_rate_mbps", "out_v4_packet_rate_kfps",
+		"in_v6_traffic_rate_mbps", "in_v6_packet_rate_kfps", "out_v6_traffic_rate_mbps", "out_v6_packet_rate_kfps",
+		"input_v4_utilization", "output_v4_utilization", "input_v6_utilization", "output_v6_utilization",
+		"in_bier_octets", "in_bier_pkts", "out_bier_octets", "out_bier_pkts",
+	}
+	if db.counterRateColumns {
+		columns = append(columns, models.CounterRateColumns...)
+	}
+	if db.legacyStringColumns {
+		columns = append(columns, legacyRateColumns...)
+	}
+	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"interface_metrics"}, columns,
 		pgx.CopyFromRows(rows))
 
 	if err != nil {
@@ -256,12 +286,12 @@ func (db *Database) BatchInsertSubinterfaceMetricsWithContext(ctx context.Contex
 			metric.LastClear,
 			metric.InPkts,
 			metric.OutPkts,
-			safeNumericString(metric.InputUtilization),
-			safeNumericString(metric.OutputUtilization),
-			metric.InTrafficRate,
-			metric.InPacketRate,
-			metric.OutTrafficRate,
-			metric.OutPacketRate,
+			metric.InputUtilization,
+			metric.OutputUtilization,
+			metric.InTrafficRateMbps,
+			metric.InPacketRateKfps,
+			metric.OutTrafficRateMbps,
+			metric.OutPacketRateKfps,
 			metric.InV4Octets,
 			metric.OutV4Octets,
 			metric.InV4Pkts,
@@ -270,43 +300,59 @@ func (db *Database) BatchInsertSubinterfaceMetricsWithContext(ctx context.Contex
 			metric.OutV6Octets,
 			metric.InV6Pkts,
 			metric.OutV6Pkts,
-			metric.InV4TrafficRate,
-			metric.InV4PacketRate,
-			metric.OutV4TrafficRate,
-			metric.OutV4PacketRate,
-			metric.InV6TrafficRate,
-			metric.InV6PacketRate,
-			metric.OutV6TrafficRate,
-			metric.OutV6PacketRate,
-			safeNumericString(metric.InputV4Utilization),
-			safeNumericString(metric.OutputV4Utilization),
-			safeNumericString(metric.InputV6Utilization),
-			safeNumericString(metric.OutputV6Utilization),
+			metric.InV4TrafficRateMbps,
+			metric.InV4PacketRateKfps,
+			metric.OutV4TrafficRateMbps,
+			metric.OutV4PacketRateKfps,
+			metric.InV6TrafficRateMbps,
+			metric.InV6PacketRateKfps,
+			metric.OutV6TrafficRateMbps,
+			metric.OutV6PacketRateKfps,
+			metric.InputV4Utilization,
+			metric.OutputV4Utilization,
+			metric.InputV6Utilization,
+			metric.OutputV6Utilization,
 			metric.InBierOctets,
 			metric.InBierPkts,
 			metric.OutBierOctets,
 			metric.OutBierPkts,
 		}
+		if db.counterRateColumns {
+			row = append(row, counterRateValues(metric.CounterRates)...)
+		}
+		if db.legacyStringColumns {
+			row = append(row, legacyRateValues(
+				metric.InTrafficRateMbps, metric.InPacketRateKfps, metric.OutTrafficRateMbps, metric.OutPacketRateKfps,
+				metric.InV4TrafficRateMbps, metric.InV4PacketRateKfps, metric.OutV4TrafficRateMbps, metric.OutV4PacketRateKfps,
+				metric.InV6TrafficRateMbps, metric.InV6PacketRateKfps, metric.OutV6TrafficRateMbps, metric.OutV6PacketRateKfps,
+			)...)
+		}
 		rows = append(rows, row)
 	}
 
 	// 执行COPY FROM STDIN
-	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"subinterface_metrics"}, 
-		[]string{
-			"timestamp", "system_id", "interface_name", "subinterface_index", "ifindex", "admin_status", "oper_status",
-			"last_change", "logical", "ipv4_oper_status", "zteif_ifindex", "zteif_admin_status", "zteif_oper_status",
-			"zteif_phy_status", "zteif_ipv4_oper_status", "zteif_ipv6_oper_status", "in_octets", "in_unicast_pkts",
-			"in_broadcast_pkts", "in_multicast_pkts", "in_discards", "in_errors", "in_unknown_protos", "in_fcs_errors",
-			"out_octets", "out_unicast_pkts", "out_broadcast_pkts", "out_multicast_pkts", "out_discards", "out_errors",
-			"carrier_transitions", "last_clear", "in_pkts", "out_pkts", "input_utilization", "output_utilization",
-			"in_traffic_rate", "in_packet_rate", "out_traffic_rate", "out_packet_rate",
-			"in_v4_octets", "out_v4_octets", "in_v4_pkts", "out_v4_pkts",
-			"in_v6_octets", "out_v6_octets", "in_v6_pkts", "out_v6_pkts",
-			"in_v4_traffic_rate", "in_v4_packet_rate", "out_v4_traffic_rate", "out_v4_packet_rate",
-			"in_v6_traffic_rate", "in_v6_packet_rate", "out_v6_traffic_rate", "out_v6_packet_rate",
-			"input_v4_utilization", "output_v4_utilization", "input_v6_utilization", "output_v6_utilization",
-			"in_bier_octets", "in_bier_pkts", "out_bier_octets", "out_bier_pkts",
-		},
+	columns := []string{
+		"timestamp", "system_id", "interface_name", "subinterface_index", "ifindex", "admin_status", "oper_status",
+		"last_change", "logical", "ipv4_oper_status", "zteif_ifindex", "zteif_admin_status", "zteif_oper_status",
+		"zteif_phy_status", "zteif_ipv4_oper_status", "zteif_ipv6_oper_status", "in_octets", "in_unicast_pkts",
+		"in_broadcast_pkts", "in_multicast_pkts", "in_discards", "in_errors", "in_unknown_protos", "in_fcs_errors",
+		"out_octets", "out_unicast_pkts", "out_broadcast_pkts", "out_multicast_pkts", "out_discards", "out_errors",
+		"carrier_transitions", "last_clear", "in_pkts", "out_pkts", "input_utilization", "output_utilization",
+		"in_traffic_rate_mbps", "in_packet_rate_kfps", "out_traffic_rate_mbps", "out_packet_rate_kfps",
+		"in_v4_octets", "out_v4_octets", "in_v4_pkts", "out_v4_pkts",
+		"in_v6_octets", "out_v6_octets", "in_v6_pkts", "out_v6_pkts",
+		"in_v4_traffic_rate_mbps", "in_v4_packet_rate_kfps", "out_v4_traffic_rate_mbps", "out_v4_packet_rate_kfps",
+		"in_v6_traffic_rate_mbps", "in_v6_packet_rate_kfps", "out_v6_traffic_rate_mbps", "out_v6_packet_rate_kfps",
+		"input_v4_utilization", "output_v4_utilization", "input_v6_utilization", "output_v6_utilization",
+		"in_bier_octets", "in_bier_pkts", "out_bier_octets", "out_bier_pkts",
+	}
+	if db.counterRateColumns {
+		columns = append(columns, models.CounterRateColumns...)
+	}
+	if db.legacyStringColumns {
+		columns = append(columns, legacyRateColumns...)
+	}
+	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"subinterface_metrics"}, columns,
 		pgx.CopyFromRows(rows))
 
 	if err != nil {
@@ -351,6 +397,22 @@ func (db *Database) BatchInsertNotificationReportMetrics(metrics []models.Notifi
 	return db.BatchInsertNotificationReportMetricsWithContext(context.Background(), metrics)
 }
 
+func (db *Database) BatchInsertSelfDefinedEventMetrics(metrics []models.SelfDefinedEventMetric) error {
+	return db.BatchInsertSelfDefinedEventMetricsWithContext(context.Background(), metrics)
+}
+
+func (db *Database) BatchInsertGenericMetrics(metrics []models.GenericMetric) error {
+	return db.BatchInsertGenericMetricsWithContext(context.Background(), metrics)
+}
+
+func (db *Database) BatchInsertMappedRows(metrics []models.MappedRow) error {
+	return db.BatchInsertMappedRowsWithContext(context.Background(), metrics)
+}
+
+func (db *Database) BatchInsertOpticalChannelMetrics(metrics []models.OpticalChannelMetric) error {
+	return db.BatchInsertOpticalChannelMetricsWithContext(context.Background(), metrics)
+}
+
 // 重命名原有方法为带Context的版本 - 修复版本，正确处理nil指针
 func (db *Database) BatchInsertPlatformMetricsWithContext(ctx context.Context, metrics []models.PlatformMetric) error {
 	if len(metrics) == 0 {
@@ -382,21 +444,21 @@ func (db *Database) BatchInsertPlatformMetricsWithContext(ctx context.Context, m
 			safeString(c.Uptime),
 			safeUint32(c.UsedPower),
 			safeUint32(c.AllocatedPower),
-			safeString(c.CurrentVoltage),
-			safeString(c.CurrentCurrent),
-			safeString(c.TotalCapacity),
-			safeString(c.UsedCapacity),
+			safeFloat64(c.CurrentVoltageV),
+			safeFloat64(c.CurrentCurrentA),
+			safeFloat64(c.TotalCapacityW),
+			safeFloat64(c.UsedCapacityW),
 			safeString(c.Type),
 			safeString(c.RedundancyType),
 			safeString(c.Modules),
-			safeString(c.TotalInputPower),
+			safeFloat64(c.TotalInputPowerW),
 			safeUint32(fan.FanSpeed),
 			safeString(fan.FanState),
 			safeString(fan.FanPhyStatus),
 			safeString(fan.FanWorkMode),
-			safeString(fan.FanCurrentPower),
-			safeString(fan.FanCurrentVoltage),
-			safeString(fan.FanCurrentCurrent),
+			safeFloat64(fan.FanCurrentPowerW),
+			safeFloat64(fan.FanCurrentVoltageV),
+			safeFloat64(fan.FanCurrentCurrentA),
 			safeString(fan.FanSpeedPercent),
 			safeUint64(mem.MemAvailable),
 			safeUint64(mem.MemUtilized),
@@ -466,33 +528,43 @@ func (db *Database) BatchInsertPlatformMetricsWithContext(ctx context.Context, m
 			safeFloat64(opt.OpticalRxThresholdLowAlarm),
 			safeFloat64(opt.OpticalRxThresholdPreLowAlarm),
 		}
+		if db.legacyStringColumns {
+			row = append(row,
+				safeString(c.CurrentVoltage), safeString(c.CurrentCurrent), safeString(c.TotalCapacity),
+				safeString(c.UsedCapacity), safeString(c.TotalInputPower), safeString(fan.FanCurrentPower),
+				safeString(fan.FanCurrentVoltage), safeString(fan.FanCurrentCurrent),
+			)
+		}
 		rows = append(rows, row)
 	}
 
 	// 执行COPY FROM STDIN
-	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"platform_metrics"}, 
-		[]string{
-			"timestamp", "system_id", "component_name", "oper_status", "uptime", "used_power", "allocated_power",
-			"current_voltage", "current_current", "total_capacity", "used_capacity", "type", "redundancy_type",
-			"modules", "total_input_power", "fan_speed", "fan_state", "fan_phy_status", "fan_work_mode",
-			"fan_current_power", "fan_current_voltage", "fan_current_current", "fan_speed_percent",
-			"mem_available", "mem_utilized", "mem_free", "mem_usage", "mem_alarm_status", "storage_availability",
-			"temp_instant", "temp_avg", "temp_min", "temp_max", "temp_interval", "temp_min_time", "temp_max_time",
-			"alarm_status", "temp_alarm_threshold", "temp_alarm_severity", "temp_minor_threshold",
-			"temp_major_threshold", "temp_fatal_threshold", "temp_instant_string", "temp_status",
-			"temp_description", "power_enable", "power_capacity", "power_input_current", "power_input_voltage",
-			"power_output_current", "power_output_voltage", "power_output_power", "power_work_state",
-			"power_name", "power_phy_state", "power_state", "power_com_state", "power_temperature",
-			"power_available", "power_capacity_string", "power_input_power", "power_input2_current",
-			"power_input2_voltage", "power_output2_current", "power_output2_voltage",
-			"linecard_power_admin_state", "cpu_instant", "cpu_avg", "cpu_min", "cpu_max", "cpu_interval",
-			"cpu_min_time", "cpu_max_time", "cpu_alarm_status", "optical_in_power", "optical_out_power",
-			"optical_bias_current", "optical_temperature", "optical_voltage_vol33", "optical_voltage_vol5",
-			"optical_alarm_los_status", "optical_alarm_los_info_event_id", "optical_alarm_los_info_event_interval",
-			"optical_alarm_los_info_in_power", "optical_alarm_los_info_out_power", "optical_online_status",
-			"optical_rx_threshold_high_alarm", "optical_rx_threshold_pre_high_alarm",
-			"optical_rx_threshold_low_alarm", "optical_rx_threshold_pre_low_alarm",
-		},
+	columns := []string{
+		"timestamp", "system_id", "component_name", "oper_status", "uptime", "used_power", "allocated_power",
+		"current_voltage_v", "current_current_a", "total_capacity_w", "used_capacity_w", "type", "redundancy_type",
+		"modules", "total_input_power_w", "fan_speed", "fan_state", "fan_phy_status", "fan_work_mode",
+		"fan_current_power_w", "fan_current_voltage_v", "fan_current_current_a", "fan_speed_percent",
+		"mem_available", "mem_utilized", "mem_free", "mem_usage", "mem_alarm_status", "storage_availability",
+		"temp_instant", "temp_avg", "temp_min", "temp_max", "temp_interval", "temp_min_time", "temp_max_time",
+		"alarm_status", "temp_alarm_threshold", "temp_alarm_severity", "temp_minor_threshold",
+		"temp_major_threshold", "temp_fatal_threshold", "temp_instant_string", "temp_status",
+		"temp_description", "power_enable", "power_capacity", "power_input_current", "power_input_voltage",
+		"power_output_current", "power_output_voltage", "power_output_power", "power_work_state",
+		"power_name", "power_phy_state", "power_state", "power_com_state", "power_temperature",
+		"power_available", "power_capacity_string", "power_input_power", "power_input2_current",
+		"power_input2_voltage", "power_output2_current", "power_output2_voltage",
+		"linecard_power_admin_state", "cpu_instant", "cpu_avg", "cpu_min", "cpu_max", "cpu_interval",
+		"cpu_min_time", "cpu_max_time", "cpu_alarm_status", "optical_in_power", "optical_out_power",
+		"optical_bias_current", "optical_temperature", "optical_voltage_vol33", "optical_voltage_vol5",
+		"optical_alarm_los_status", "optical_alarm_los_info_event_id", "optical_alarm_los_info_event_interval",
+		"optical_alarm_los_info_in_power", "optical_alarm_los_info_out_power", "optical_online_status",
+		"optical_rx_threshold_high_alarm", "optical_rx_threshold_pre_high_alarm",
+		"optical_rx_threshold_low_alarm", "optical_rx_threshold_pre_low_alarm",
+	}
+	if db.legacyStringColumns {
+		columns = append(columns, legacyPlatformColumns...)
+	}
+	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"platform_metrics"}, columns,
 		pgx.CopyFromRows(rows))
 
 	if err != nil {
@@ -559,6 +631,35 @@ func safeNumericString(s *string) interface{} {
 	return nil
 }
 
+// legacyRateColumns 旧版字符串速率列，legacy_string_columns开启时追加在数值列之后写入
+var legacyRateColumns = []string{
+	"in_traffic_rate", "in_packet_rate", "out_traffic_rate", "out_packet_rate",
+	"in_v4_traffic_rate", "in_v4_packet_rate", "out_v4_traffic_rate", "out_v4_packet_rate",
+	"in_v6_traffic_rate", "in_v6_packet_rate", "out_v6_traffic_rate", "out_v6_packet_rate",
+}
+
+// legacyPlatformColumns 旧版电压/电流/功率字符串列，顺序与平台指标行末尾追加的值一致
+var legacyPlatformColumns = []string{
+	"current_voltage", "current_current", "total_capacity", "used_capacity",
+	"total_input_power", "fan_current_power", "fan_current_voltage", "fan_current_current",
+}
+
+// legacyRateValues 按旧格式生成速率字符串（"12.34 Mbps"/"3.20 Kfps"），参数顺序与legacyRateColumns一致
+func legacyRateValues(rates ...*float64) []interface{} {
+	values := make([]interface{}, len(rates))
+	for i, rate := range rates {
+		if rate == nil {
+			continue
+		}
+		if i%2 == 0 {
+			values[i] = models.FormatTrafficRate(*rate)
+		} else {
+			values[i] = models.FormatPacketRate(*rate)
+		}
+	}
+	return values
+}
+
 // safeDeref 安全解引用指针，nil 返回零值
 func safeDeref[T any](p *T) T {
 	if p == nil {
@@ -568,6 +669,20 @@ func safeDeref[T any](p *T) T {
 	return *p
 }
 
+// counterRateValues 按 models.CounterRateColumns 顺序展开计数器速率，未计算时全部为NULL
+func counterRateValues(r *models.CounterRates) []interface{} {
+	values := make([]interface{}, len(models.CounterRateColumns))
+	if r == nil {
+		return values
+	}
+	for i, f := range r.Fields() {
+		if *f != nil {
+			values[i] = **f
+		}
+	}
+	return values
+}
+
 // safeCommon 安全获取 CommonState
 func safeCommon(m *models.PlatformMetric) *models.CommonState {
 	if m.CommonState == nil {
@@ -756,6 +871,224 @@ func (db *Database) BatchInsertNotificationReportMetricsWithContext(ctx context.
 	return nil
 }
 
+// BatchInsertSelfDefinedEventMetricsWithContext 批量插入自定义事件数据（带Context）
+func (db *Database) BatchInsertSelfDefinedEventMetricsWithContext(ctx context.Context, metrics []models.SelfDefinedEventMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	// 获取连接
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %v", err)
+	}
+	defer conn.Release()
+
+	// 准备数据
+	var rows [][]interface{}
+	for _, metric := range metrics {
+		row := []interface{}{
+			metric.Timestamp,
+			metric.SystemID,
+			metric.Level,
+			safeString(metric.Description),
+			safeString(metric.FilterRelation),
+			safeString(metric.Filters),
+			metric.SensorPath,
+			safeString(metric.ProtoPath),
+			safeString(metric.Instance),
+			metric.ContentEncoding,
+		}
+		rows = append(rows, row)
+	}
+
+	// 执行COPY FROM STDIN
+	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"telemetry", "self_defined_event"},
+		[]string{
+			"timestamp", "system_id", "level", "description", "filter_relation", "filters",
+			"sensor_path", "proto_path", "instance", "content_encoding",
+		},
+		pgx.CopyFromRows(rows))
+
+	if err != nil {
+		return fmt.Errorf("COPY FROM STDIN 插入自定义事件失败: %v", err)
+	}
+
+	db.logger.Debugf("成功批量插入自定义事件 %d 条", len(metrics))
+	return nil
+}
+
+// BatchInsertGenericMetricsWithContext 批量插入通用指标数据（带Context），按目标表分组写入
+func (db *Database) BatchInsertGenericMetricsWithContext(ctx context.Context, metrics []models.GenericMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	// 获取连接
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %v", err)
+	}
+	defer conn.Release()
+
+	// 准备数据 - sensor_mappings 配置的表与 generic_metrics 结构相同
+	tables := make(map[string][][]interface{})
+	var order []string
+	for _, metric := range metrics {
+		table := metric.Table
+		if table == "" {
+			table = "generic_metrics"
+		}
+		if _, ok := tables[table]; !ok {
+			order = append(order, table)
+		}
+		tables[table] = append(tables[table], []interface{}{
+			metric.Timestamp,
+			metric.SystemID,
+			metric.SensorPath,
+			safeString(metric.ProtoPath),
+			safeString(metric.InstanceKeys),
+			metric.Field,
+			safeFloat64(metric.ValueNum),
+			safeString(metric.ValueStr),
+		})
+	}
+
+	// 执行COPY FROM STDIN
+	for _, table := range order {
+		rows := tables[table]
+		_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"telemetry", table},
+			[]string{
+				"timestamp", "system_id", "sensor_path", "proto_path", "instance_keys",
+				"field", "value_num", "value_str",
+			},
+			pgx.CopyFromRows(rows))
+
+		if err != nil {
+			return fmt.Errorf("COPY FROM STDIN 插入通用指标失败(表 %s): %v", table, err)
+		}
+	}
+
+	db.logger.Debugf("成功批量插入通用指标 %d 条 (表 %d 个)", len(metrics), len(order))
+	return nil
+}
+
+// BatchInsertMappedRowsWithContext 批量插入映射文件生成的行（带Context），按目标表和列分组写入
+func (db *Database) BatchInsertMappedRowsWithContext(ctx context.Context, rows []models.MappedRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// 获取连接
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %v", err)
+	}
+	defer conn.Release()
+
+	// 准备数据 - 映射文件重新加载后同一张表可能出现不同的列集合
+	type copyPlan struct {
+		table   string
+		columns []string
+		rows    [][]interface{}
+	}
+	plans := make(map[string]*copyPlan)
+	var order []*copyPlan
+	for _, row := range rows {
+		sig := row.Table + ":" + strings.Join(row.Columns, ",")
+		plan, ok := plans[sig]
+		if !ok {
+			plan = &copyPlan{
+				table:   row.Table,
+				columns: append([]string{"timestamp", "system_id"}, row.Columns...),
+			}
+			plans[sig] = plan
+			order = append(order, plan)
+		}
+		values := make([]interface{}, 0, len(row.Values)+2)
+		values = append(values, row.Timestamp, row.SystemID)
+		plan.rows = append(plan.rows, append(values, row.Values...))
+	}
+
+	// 执行COPY FROM STDIN
+	for _, plan := range order {
+		_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"telemetry", plan.table},
+			plan.columns, pgx.CopyFromRows(plan.rows))
+
+		if err != nil {
+			return fmt.Errorf("COPY FROM STDIN 插入映射数据失败(表 %s): %v", plan.table, err)
+		}
+	}
+
+	db.logger.Debugf("成功批量插入映射数据 %d 条 (表 %d 个)", len(rows), len(order))
+	return nil
+}
+
+// BatchInsertOpticalChannelMetricsWithContext 批量插入光通道指标（带Context）
+func (db *Database) BatchInsertOpticalChannelMetricsWithContext(ctx context.Context, metrics []models.OpticalChannelMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	// 获取连接
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %v", err)
+	}
+	defer conn.Release()
+
+	// 准备数据 - 正确处理nil指针
+	rows := make([][]interface{}, 0, len(metrics))
+	for _, metric := range metrics {
+		opt := metric.OpticalData
+		if opt == nil {
+			opt = &models.OpticalData{}
+		}
+		rows = append(rows, []interface{}{
+			metric.Timestamp,
+			metric.SystemID,
+			metric.ComponentName,
+			int64(metric.ChannelIndex),
+			safeFloat64(opt.OpticalInPower),
+			safeFloat64(opt.OpticalOutPower),
+			safeFloat64(opt.OpticalBiasCurrent),
+			safeFloat64(opt.OpticalTemperature),
+			safeFloat64(opt.OpticalVoltageVol33),
+			safeFloat64(opt.OpticalVoltageVol5),
+			safeString(opt.OpticalAlarmLosStatus),
+			safeUint32(opt.OpticalAlarmLosInfoEventID),
+			safeUint32(opt.OpticalAlarmLosInfoEventInterval),
+			safeFloat64(opt.OpticalAlarmLosInfoInPower),
+			safeFloat64(opt.OpticalAlarmLosInfoOutPower),
+			safeString(opt.OpticalOnlineStatus),
+			safeFloat64(opt.OpticalRxThresholdHighAlarm),
+			safeFloat64(opt.OpticalRxThresholdPreHighAlarm),
+			safeFloat64(opt.OpticalRxThresholdLowAlarm),
+			safeFloat64(opt.OpticalRxThresholdPreLowAlarm),
+		})
+	}
+
+	// 执行COPY FROM STDIN
+	_, err = conn.Conn().CopyFrom(ctx, pgx.Identifier{"telemetry", "optical_channel_metrics"},
+		[]string{
+			"timestamp", "system_id", "component_name", "channel_index",
+			"optical_in_power", "optical_out_power", "optical_bias_current", "optical_temperature",
+			"optical_voltage_vol33", "optical_voltage_vol5", "optical_alarm_los_status",
+			"optical_alarm_los_info_event_id", "optical_alarm_los_info_event_interval",
+			"optical_alarm_los_info_in_power", "optical_alarm_los_info_out_power", "optical_online_status",
+			"optical_rx_threshold_high_alarm", "optical_rx_threshold_pre_high_alarm",
+			"optical_rx_threshold_low_alarm", "optical_rx_threshold_pre_low_alarm",
+		},
+		pgx.CopyFromRows(rows))
+
+	if err != nil {
+		return fmt.Errorf("COPY FROM STDIN 插入光通道指标失败: %v", err)
+	}
+
+	db.logger.Debugf("成功批量插入光通道指标 %d 条", len(metrics))
+	return nil
+}
+
 // 保持向后兼容的函数别名
 func NewFixedConnection(host string, port int, user, password, dbname string, logger *logrus.Logger) (*Database, error) {
 	return NewDatabase(host, port, user, password, dbname, logger)
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wwswwsuns/ztelem/internal/devices"
+)
+
+const upsertDeviceSQL = `INSERT INTO telemetry.devices
+	(system_id, mng_ipv4, mng_ipv6, first_seen, last_seen, streams, sensor_paths, duplicate_streams, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7::jsonb, $8, NOW())
+ON CONFLICT (system_id) DO UPDATE SET
+	mng_ipv4 = COALESCE(NULLIF(EXCLUDED.mng_ipv4, ''), devices.mng_ipv4),
+	mng_ipv6 = COALESCE(NULLIF(EXCLUDED.mng_ipv6, ''), devices.mng_ipv6),
+	first_seen = LEAST(devices.first_seen, EXCLUDED.first_seen),
+	last_seen = GREATEST(devices.last_seen, EXCLUDED.last_seen),
+	streams = EXCLUDED.streams,
+	sensor_paths = devices.sensor_paths || EXCLUDED.sensor_paths,
+	duplicate_streams = EXCLUDED.duplicate_streams,
+	updated_at = NOW()`
+
+// UpsertDevicesWithContext 写入设备登记（带Context），已存在的设备合并sensor_path并保留最早的first_seen
+func (db *Database) UpsertDevicesWithContext(ctx context.Context, list []devices.Device) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	// 获取连接
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %v", err)
+	}
+	defer conn.Release()
+
+	batch := &pgx.Batch{}
+	for _, d := range list {
+		streams, err := json.Marshal(d.Streams)
+		if err != nil {
+			return fmt.Errorf("序列化设备流信息失败(%s): %v", d.SystemID, err)
+		}
+		sensorPaths, err := json.Marshal(d.SensorPaths)
+		if err != nil {
+			return fmt.Errorf("序列化设备sensor_path失败(%s): %v", d.SystemID, err)
+		}
+		batch.Queue(upsertDeviceSQL, d.SystemID, d.MngIPv4, d.MngIPv6, d.FirstSeen, d.LastSeen,
+			string(streams), string(sensorPaths), d.DuplicateStreams)
+	}
+
+	if err := conn.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("写入设备登记失败: %v", err)
+	}
+
+	db.logger.Debugf("成功写入设备登记 %d 台", len(list))
+	return nil
+}
+
+// UpsertDevices 写入设备登记
+func (db *Database) UpsertDevices(list []devices.Device) error {
+	return db.UpsertDevicesWithContext(context.Background(), list)
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/wwswwsuns/ztelem/internal/cadence"
+)
+
+const upsertGapSQL = `INSERT INTO telemetry.telemetry_gaps
+	(system_id, sensor_path, expected_period_ms, gap_start, gap_end, detected_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+ON CONFLICT (system_id, sensor_path, gap_start) DO UPDATE SET
+	gap_end = COALESCE(EXCLUDED.gap_end, telemetry_gaps.gap_end)`
+
+// UpsertGapsWithContext 写入数据缺口（带Context），同一缺口结束后更新gap_end
+func (db *Database) UpsertGapsWithContext(ctx context.Context, gaps []cadence.Gap) error {
+	if len(gaps) == 0 {
+		return nil
+	}
+
+	// 获取连接
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("获取数据库连接失败: %v", err)
+	}
+	defer conn.Release()
+
+	batch := &pgx.Batch{}
+	for _, gap := range gaps {
+		var end interface{}
+		if !gap.End.IsZero() {
+			end = gap.End
+		}
+		batch.Queue(upsertGapSQL, gap.SystemID, gap.SensorPath, gap.ExpectedPeriod.Milliseconds(), gap.Start, end)
+	}
+
+	if err := conn.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("写入数据缺口失败: %v", err)
+	}
+
+	db.logger.Debugf("成功写入数据缺口 %d 条", len(gaps))
+	return nil
+}
+
+// UpsertGaps 写入数据缺口
+func (db *Database) UpsertGaps(gaps []cadence.Gap) error {
+	return db.UpsertGapsWithContext(context.Background(), gaps)
+}
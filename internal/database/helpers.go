@@ -267,13 +267,13 @@ func getInterfaceFieldValue(metric *models.InterfaceMetric, fieldIndex int) inte
 	case 38:
 		return metric.OutputUtilization
 	case 39:
-		return metric.InTrafficRate
+		return metric.InTrafficRateMbps
 	case 40:
-		return metric.InPacketRate
+		return metric.InPacketRateKfps
 	case 41:
-		return metric.OutTrafficRate
+		return metric.OutTrafficRateMbps
 	case 42:
-		return metric.OutPacketRate
+		return metric.OutPacketRateKfps
 	case 43:
 		return metric.InV4Octets
 	case 44:
@@ -291,21 +291,21 @@ func getInterfaceFieldValue(metric *models.InterfaceMetric, fieldIndex int) inte
 	case 50:
 		return metric.OutV6Pkts
 	case 51:
-		return metric.InV4TrafficRate
+		return metric.InV4TrafficRateMbps
 	case 52:
-		return metric.InV4PacketRate
+		return metric.InV4PacketRateKfps
 	case 53:
-		return metric.OutV4TrafficRate
+		return metric.OutV4TrafficRateMbps
 	case 54:
-		return metric.OutV4PacketRate
+		return metric.OutV4PacketRateKfps
 	case 55:
-		return metric.InV6TrafficRate
+		return metric.InV6TrafficRateMbps
 	case 56:
-		return metric.InV6PacketRate
+		return metric.InV6PacketRateKfps
 	case 57:
-		return metric.OutV6TrafficRate
+		return metric.OutV6TrafficRateMbps
 	case 58:
-		return metric.OutV6PacketRate
+		return metric.OutV6PacketRateKfps
 	case 59:
 		return metric.InputV4Utilization
 	case 60:
@@ -395,13 +395,13 @@ func getSubinterfaceFieldValue(metric *models.SubinterfaceMetric, fieldIndex int
 	case 36:
 		return metric.OutputUtilization
 	case 37:
-		return metric.InTrafficRate
+		return metric.InTrafficRateMbps
 	case 38:
-		return metric.InPacketRate
+		return metric.InPacketRateKfps
 	case 39:
-		return metric.OutTrafficRate
+		return metric.OutTrafficRateMbps
 	case 40:
-		return metric.OutPacketRate
+		return metric.OutPacketRateKfps
 	case 41:
 		return metric.InV4Octets
 	case 42:
@@ -419,21 +419,21 @@ func getSubinterfaceFieldValue(metric *models.SubinterfaceMetric, fieldIndex int
 	case 48:
 		return metric.OutV6Pkts
 	case 49:
-		return metric.InV4TrafficRate
+		return metric.InV4TrafficRateMbps
 	case 50:
-		return metric.InV4PacketRate
+		return metric.InV4PacketRateKfps
 	case 51:
-		return metric.OutV4TrafficRate
+		return metric.OutV4TrafficRateMbps
 	case 52:
-		return metric.OutV4PacketRate
+		return metric.OutV4PacketRateKfps
 	case 53:
-		return metric.InV6TrafficRate
+		return metric.InV6TrafficRateMbps
 	case 54:
-		return metric.InV6PacketRate
+		return metric.InV6PacketRateKfps
 	case 55:
-		return metric.OutV6TrafficRate
+		return metric.OutV6TrafficRateMbps
 	case 56:
-		return metric.OutV6PacketRate
+		return metric.OutV6PacketRateKfps
 	case 57:
 		return metric.InputV4Utilization
 	case 58:
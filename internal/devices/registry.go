@@ -0,0 +1,303 @@
+package devices
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 设备登记
+//
+// 从每条流解码出的报文中学习 system_id、订阅、管理地址以及各 sensor_path 的采样周期，
+// 回答"哪些设备在上报、上报什么"。同一 system_id + subscription_id 同时出现在多条流上
+// 视为重复流，常见于设备重连而旧流尚未断开。
+// 结构变化（新流、新sensor_path、周期或管理地址变化）或 last_seen 前进的设备由 Flush 批量写入 devices 表。
+
+// Observation 单条报文携带的设备信息
+type Observation struct {
+	SystemID       string
+	StreamID       string
+	Peer           string
+	SubscriptionID string
+	SensorPath     string
+	MngIPv4        string
+	MngIPv6        string
+	CurrentPeriod  time.Duration
+	At             time.Time
+}
+
+// SensorPathInfo 设备上报的单个sensor_path
+type SensorPathInfo struct {
+	SubscriptionID  string    `json:"subscription_id,omitempty"`
+	CurrentPeriodMs int64     `json:"current_period_ms"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
+// StreamRef 设备当前的一条Publish流
+type StreamRef struct {
+	ID              string   `json:"id"`
+	Peer            string   `json:"peer"`
+	SubscriptionIDs []string `json:"subscription_ids"`
+}
+
+// Device 设备登记信息快照
+type Device struct {
+	SystemID         string                    `json:"system_id"`
+	MngIPv4          string                    `json:"mng_ipv4,omitempty"`
+	MngIPv6          string                    `json:"mng_ipv6,omitempty"`
+	FirstSeen        time.Time                 `json:"first_seen"`
+	LastSeen         time.Time                 `json:"last_seen"`
+	Streams          []StreamRef               `json:"streams"`
+	SensorPaths      map[string]SensorPathInfo `json:"sensor_paths"`
+	DuplicateStreams int                       `json:"duplicate_streams"` // 同一订阅同时出现在多条流上的订阅数
+}
+
+// Duplicate 新发现的重复流
+type Duplicate struct {
+	SystemID       string
+	SubscriptionID string
+	StreamIDs      []string
+}
+
+// Stats 设备登记统计
+type Stats struct {
+	Devices            int   // 已知设备数
+	Reporting          int   // 当前有流的设备数
+	DuplicatesDetected int64 // 累计发现的重复流次数
+}
+
+// Store 设备登记持久化
+type Store interface {
+	UpsertDevices(devices []Device) error
+}
+
+type stream struct {
+	peer string
+	subs map[string]struct{}
+}
+
+type device struct {
+	systemID      string
+	mngIPv4       string
+	mngIPv6       string
+	firstSeen     time.Time
+	lastSeen      time.Time
+	streams       map[string]*stream
+	sensorPaths   map[string]*SensorPathInfo
+	dirty         bool
+	persistedSeen time.Time
+}
+
+// Registry 设备登记表
+type Registry struct {
+	mu                 sync.Mutex
+	devices            map[string]*device
+	streamDevices      map[string]map[string]struct{} // 流ID -> 该流上出现过的system_id
+	duplicatesDetected int64
+}
+
+// NewRegistry 创建设备登记表
+func NewRegistry() *Registry {
+	return &Registry{
+		devices:       make(map[string]*device),
+		streamDevices: make(map[string]map[string]struct{}),
+	}
+}
+
+// Observe 记录一条报文，该订阅首次在第二条流上出现时返回重复流信息
+func (r *Registry) Observe(o Observation) *Duplicate {
+	if o.SystemID == "" {
+		return nil
+	}
+	if o.At.IsZero() {
+		o.At = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.devices[o.SystemID]
+	if !ok {
+		d = &device{
+			systemID:    o.SystemID,
+			firstSeen:   o.At,
+			streams:     make(map[string]*stream),
+			sensorPaths: make(map[string]*SensorPathInfo),
+			dirty:       true,
+		}
+		r.devices[o.SystemID] = d
+	}
+	if o.At.After(d.lastSeen) {
+		d.lastSeen = o.At
+	}
+	if o.MngIPv4 != "" && o.MngIPv4 != d.mngIPv4 {
+		d.mngIPv4 = o.MngIPv4
+		d.dirty = true
+	}
+	if o.MngIPv6 != "" && o.MngIPv6 != d.mngIPv6 {
+		d.mngIPv6 = o.MngIPv6
+		d.dirty = true
+	}
+
+	if o.SensorPath != "" {
+		period := o.CurrentPeriod.Milliseconds()
+		sp, ok := d.sensorPaths[o.SensorPath]
+		if !ok {
+			sp = &SensorPathInfo{}
+			d.sensorPaths[o.SensorPath] = sp
+			d.dirty = true
+		}
+		if sp.SubscriptionID != o.SubscriptionID || (period > 0 && sp.CurrentPeriodMs != period) {
+			sp.SubscriptionID = o.SubscriptionID
+			if period > 0 {
+				sp.CurrentPeriodMs = period
+			}
+			d.dirty = true
+		}
+		if o.At.After(sp.LastSeen) {
+			sp.LastSeen = o.At
+		}
+	}
+
+	if o.StreamID == "" {
+		return nil
+	}
+	s, ok := d.streams[o.StreamID]
+	if !ok {
+		s = &stream{peer: o.Peer, subs: make(map[string]struct{})}
+		d.streams[o.StreamID] = s
+		d.dirty = true
+		if r.streamDevices[o.StreamID] == nil {
+			r.streamDevices[o.StreamID] = make(map[string]struct{})
+		}
+		r.streamDevices[o.StreamID][o.SystemID] = struct{}{}
+	}
+	if _, seen := s.subs[o.SubscriptionID]; seen {
+		return nil
+	}
+	s.subs[o.SubscriptionID] = struct{}{}
+	d.dirty = true
+
+	ids := d.streamsWithSubscription(o.SubscriptionID)
+	if len(ids) < 2 {
+		return nil
+	}
+	r.duplicatesDetected++
+	return &Duplicate{SystemID: o.SystemID, SubscriptionID: o.SubscriptionID, StreamIDs: ids}
+}
+
+// StreamClosed 流结束后从相关设备的当前流中移除
+func (r *Registry) StreamClosed(streamID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for systemID := range r.streamDevices[streamID] {
+		if d, ok := r.devices[systemID]; ok {
+			delete(d.streams, streamID)
+			d.dirty = true
+		}
+	}
+	delete(r.streamDevices, streamID)
+}
+
+// Devices 返回所有设备的快照，按system_id排序
+func (r *Registry) Devices() []Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	devices := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		devices = append(devices, d.snapshot())
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].SystemID < devices[j].SystemID })
+	return devices
+}
+
+// Flush 将有变化的设备写入store，写入失败时保留变化标记等待下次重试
+func (r *Registry) Flush(store Store) (int, error) {
+	r.mu.Lock()
+	var changed []Device
+	for _, d := range r.devices {
+		if d.dirty || d.lastSeen.After(d.persistedSeen) {
+			changed = append(changed, d.snapshot())
+			d.dirty = false
+			d.persistedSeen = d.lastSeen
+		}
+	}
+	r.mu.Unlock()
+
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	if err := store.UpsertDevices(changed); err != nil {
+		r.mu.Lock()
+		for _, c := range changed {
+			if d, ok := r.devices[c.SystemID]; ok {
+				d.dirty = true
+			}
+		}
+		r.mu.Unlock()
+		return 0, err
+	}
+	return len(changed), nil
+}
+
+// Stats 返回设备登记统计
+func (r *Registry) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := Stats{Devices: len(r.devices), DuplicatesDetected: r.duplicatesDetected}
+	for _, d := range r.devices {
+		if len(d.streams) > 0 {
+			stats.Reporting++
+		}
+	}
+	return stats
+}
+
+// streamsWithSubscription 当前携带该订阅的流ID，已排序
+func (d *device) streamsWithSubscription(sub string) []string {
+	var ids []string
+	for id, s := range d.streams {
+		if _, ok := s.subs[sub]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (d *device) snapshot() Device {
+	out := Device{
+		SystemID:    d.systemID,
+		MngIPv4:     d.mngIPv4,
+		MngIPv6:     d.mngIPv6,
+		FirstSeen:   d.firstSeen,
+		LastSeen:    d.lastSeen,
+		Streams:     make([]StreamRef, 0, len(d.streams)),
+		SensorPaths: make(map[string]SensorPathInfo, len(d.sensorPaths)),
+	}
+
+	subStreams := make(map[string]int)
+	for id, s := range d.streams {
+		ref := StreamRef{ID: id, Peer: s.peer, SubscriptionIDs: make([]string, 0, len(s.subs))}
+		for sub := range s.subs {
+			ref.SubscriptionIDs = append(ref.SubscriptionIDs, sub)
+			subStreams[sub]++
+		}
+		sort.Strings(ref.SubscriptionIDs)
+		out.Streams = append(out.Streams, ref)
+	}
+	sort.Slice(out.Streams, func(i, j int) bool { return out.Streams[i].ID < out.Streams[j].ID })
+	for _, n := range subStreams {
+		if n > 1 {
+			out.DuplicateStreams++
+		}
+	}
+
+	for path, sp := range d.sensorPaths {
+		out.SensorPaths[path] = *sp
+	}
+	return out
+}
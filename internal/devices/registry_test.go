@@ -0,0 +1,118 @@
+package devices
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	upserts [][]Device
+	err     error
+}
+
+func (f *fakeStore) UpsertDevices(devices []Device) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.upserts = append(f.upserts, devices)
+	return nil
+}
+
+func TestRegistry_Observe(t *testing.T) {
+	r := NewRegistry()
+	base := time.Unix(1700000000, 0)
+
+	r.Observe(Observation{SystemID: "PE-01", StreamID: "1", Peer: "10.1.1.1:50000", SubscriptionID: "sub-if",
+		SensorPath: "oc-if:interfaces/interface/state/counters", MngIPv4: "10.1.1.1", CurrentPeriod: 10 * time.Second, At: base})
+	r.Observe(Observation{SystemID: "PE-01", StreamID: "1", SubscriptionID: "sub-pf",
+		SensorPath: "oc-platform:components/component/state", CurrentPeriod: time.Minute, At: base.Add(time.Second)})
+	r.Observe(Observation{SystemID: "PE-02", StreamID: "2", SubscriptionID: "sub-if", At: base})
+
+	devices := r.Devices()
+	if len(devices) != 2 {
+		t.Fatalf("Devices = %d, want 2", len(devices))
+	}
+	d := devices[0]
+	if d.SystemID != "PE-01" || d.MngIPv4 != "10.1.1.1" || !d.FirstSeen.Equal(base) || !d.LastSeen.Equal(base.Add(time.Second)) {
+		t.Errorf("device = %+v", d)
+	}
+	if len(d.Streams) != 1 || len(d.Streams[0].SubscriptionIDs) != 2 || d.Streams[0].Peer != "10.1.1.1:50000" {
+		t.Errorf("streams = %+v", d.Streams)
+	}
+	if sp := d.SensorPaths["oc-if:interfaces/interface/state/counters"]; sp.CurrentPeriodMs != 10000 || sp.SubscriptionID != "sub-if" {
+		t.Errorf("sensor path = %+v", sp)
+	}
+
+	if stats := r.Stats(); stats.Devices != 2 || stats.Reporting != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	r.StreamClosed("2")
+	if stats := r.Stats(); stats.Reporting != 1 {
+		t.Errorf("Reporting after close = %d, want 1", stats.Reporting)
+	}
+}
+
+func TestRegistry_Duplicate(t *testing.T) {
+	r := NewRegistry()
+
+	if dup := r.Observe(Observation{SystemID: "PE-01", StreamID: "1", SubscriptionID: "sub-if"}); dup != nil {
+		t.Fatalf("first stream duplicate = %+v", dup)
+	}
+	// 同一设备不同订阅的两条流不算重复
+	if dup := r.Observe(Observation{SystemID: "PE-01", StreamID: "2", SubscriptionID: "sub-pf"}); dup != nil {
+		t.Fatalf("different subscription duplicate = %+v", dup)
+	}
+	dup := r.Observe(Observation{SystemID: "PE-01", StreamID: "3", SubscriptionID: "sub-if"})
+	if dup == nil || dup.SubscriptionID != "sub-if" || len(dup.StreamIDs) != 2 {
+		t.Fatalf("duplicate = %+v", dup)
+	}
+	// 同一流的后续报文不重复上报
+	if dup := r.Observe(Observation{SystemID: "PE-01", StreamID: "3", SubscriptionID: "sub-if"}); dup != nil {
+		t.Fatalf("repeated duplicate = %+v", dup)
+	}
+	if d := r.Devices()[0]; d.DuplicateStreams != 1 {
+		t.Errorf("DuplicateStreams = %d, want 1", d.DuplicateStreams)
+	}
+
+	r.StreamClosed("1")
+	if d := r.Devices()[0]; d.DuplicateStreams != 0 {
+		t.Errorf("DuplicateStreams after close = %d, want 0", d.DuplicateStreams)
+	}
+	if stats := r.Stats(); stats.DuplicatesDetected != 1 {
+		t.Errorf("DuplicatesDetected = %d, want 1", stats.DuplicatesDetected)
+	}
+}
+
+func TestRegistry_Flush(t *testing.T) {
+	r := NewRegistry()
+	store := &fakeStore{}
+	base := time.Unix(1700000000, 0)
+	obs := Observation{SystemID: "PE-01", StreamID: "1", SubscriptionID: "sub-if", SensorPath: "oc-if:interfaces/interface/state", At: base}
+
+	r.Observe(obs)
+	if n, err := r.Flush(store); n != 1 || err != nil {
+		t.Fatalf("Flush = %d, %v", n, err)
+	}
+	// 无变化不写入
+	r.Observe(obs)
+	if n, _ := r.Flush(store); n != 0 {
+		t.Fatalf("Flush without change = %d", n)
+	}
+
+	// last_seen 前进后写入；写入失败下次重试
+	obs.At = base.Add(time.Minute)
+	r.Observe(obs)
+	store.err = errors.New("db down")
+	if _, err := r.Flush(store); err == nil {
+		t.Fatal("Flush should fail")
+	}
+	store.err = nil
+	if n, err := r.Flush(store); n != 1 || err != nil {
+		t.Fatalf("Flush retry = %d, %v", n, err)
+	}
+	if got := store.upserts[1][0].LastSeen; !got.Equal(base.Add(time.Minute)) {
+		t.Errorf("persisted LastSeen = %v", got)
+	}
+}
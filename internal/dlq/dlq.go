@@ -0,0 +1,433 @@
+package dlq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+// 死信队列：重试耗尽仍写库失败的批次
+//
+// 每个批次一个文件 <table>-<失败时间Unix纳秒>-<序号>.dlq，文件名去掉扩展名即批次ID：
+//
+//	[8]byte  Magic "ZTDLQ01\n"
+//	uint32   文件头长度（大端）
+//	[]byte   文件头，JSON：table/rows/reason/failed_at
+//	[]byte   批次数据，gob编码的 wal.Entry（与WAL记录相同）
+//
+// 文件先写入临时文件再改名，目录中的 .dlq 文件总是完整的。
+
+// Magic 死信文件头
+const Magic = "ZTDLQ01\n"
+
+// FileExt 死信文件扩展名
+const FileExt = ".dlq"
+
+// maxHeaderSize 文件头长度上限
+const maxHeaderSize = 64 << 10
+
+var (
+	// ErrNotFound 批次不存在
+	ErrNotFound = errors.New("dead letter not found")
+	// ErrFull 死信目录达到大小上限
+	ErrFull = errors.New("dead letter queue full")
+)
+
+// Batch 死信批次描述
+type Batch struct {
+	ID       string    `json:"id"`
+	Table    string    `json:"table"`
+	Rows     int       `json:"rows"`
+	Reason   string    `json:"reason"`
+	FailedAt time.Time `json:"failed_at"`
+	Bytes    int64     `json:"bytes"`
+}
+
+// header 文件头，ID与大小取自文件名与文件信息
+type header struct {
+	Table    string    `json:"table"`
+	Rows     int       `json:"rows"`
+	Reason   string    `json:"reason"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// TableStats 单个表的死信统计
+type TableStats struct {
+	Batches int64
+	Rows    int64
+	Bytes   int64
+}
+
+// Writer 重新写库所需的数据库接口
+type Writer interface {
+	BatchInsertPlatformMetrics(data []models.PlatformMetric) error
+	BatchInsertInterfaceMetrics(data []models.InterfaceMetric) error
+	BatchInsertSubinterfaceMetrics(data []models.SubinterfaceMetric) error
+	BatchInsertAlarmReportMetrics(data []models.AlarmReportMetric) error
+	BatchInsertNotificationReportMetrics(data []models.NotificationReportMetric) error
+	BatchInsertSelfDefinedEventMetrics(data []models.SelfDefinedEventMetric) error
+	BatchInsertGenericMetrics(data []models.GenericMetric) error
+	BatchInsertMappedRows(data []models.MappedRow) error
+	BatchInsertOpticalChannelMetrics(data []models.OpticalChannelMetric) error
+}
+
+// Insert 将批次写入数据库
+func Insert(db Writer, e *wal.Entry) error {
+	switch {
+	case len(e.Platform) > 0:
+		return db.BatchInsertPlatformMetrics(e.Platform)
+	case len(e.Interface) > 0:
+		return db.BatchInsertInterfaceMetrics(e.Interface)
+	case len(e.Subinterface) > 0:
+		return db.BatchInsertSubinterfaceMetrics(e.Subinterface)
+	case len(e.AlarmReport) > 0:
+		return db.BatchInsertAlarmReportMetrics(e.AlarmReport)
+	case len(e.NotificationReport) > 0:
+		return db.BatchInsertNotificationReportMetrics(e.NotificationReport)
+	case len(e.SelfDefinedEvent) > 0:
+		return db.BatchInsertSelfDefinedEventMetrics(e.SelfDefinedEvent)
+	case len(e.Generic) > 0:
+		return db.BatchInsertGenericMetrics(e.Generic)
+	case len(e.MappedRow) > 0:
+		return db.BatchInsertMappedRows(e.MappedRow)
+	case len(e.OpticalChannel) > 0:
+		return db.BatchInsertOpticalChannelMetrics(e.OpticalChannel)
+	}
+	return nil
+}
+
+// Store 死信目录
+type Store struct {
+	dir     string
+	maxSize int64
+
+	mu    sync.Mutex
+	seq   uint64
+	cache map[string]Batch // 已读取的文件头，按ID
+}
+
+// Open 打开死信目录，maxSize为所有文件合计上限（字节），0为不限制
+func Open(dir string, maxSize int64) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建死信目录失败: %v", err)
+	}
+	// 清理上次写入中断的临时文件
+	if tmps, err := filepath.Glob(filepath.Join(dir, ".*.tmp")); err == nil {
+		for _, tmp := range tmps {
+			os.Remove(tmp)
+		}
+	}
+	return &Store{dir: dir, maxSize: maxSize, cache: make(map[string]Batch)}, nil
+}
+
+// Dir 死信目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Put 保存一个写库失败的批次
+func (s *Store) Put(e *wal.Entry, reason string, failedAt time.Time) (Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxSize > 0 {
+		batches, err := s.scan()
+		if err != nil {
+			return Batch{}, err
+		}
+		var total int64
+		for _, b := range batches {
+			total += b.Bytes
+		}
+		if total >= s.maxSize {
+			return Batch{}, fmt.Errorf("%w: %d bytes, limit %d", ErrFull, total, s.maxSize)
+		}
+	}
+
+	s.seq++
+	b := Batch{
+		ID:       fmt.Sprintf("%s-%d-%d", e.Table(), failedAt.UnixNano(), s.seq),
+		Table:    e.Table(),
+		Rows:     e.Rows(),
+		Reason:   reason,
+		FailedAt: failedAt,
+	}
+	data, err := encode(b, e)
+	if err != nil {
+		return Batch{}, err
+	}
+	b.Bytes = int64(len(data))
+
+	tmp := filepath.Join(s.dir, "."+b.ID+".tmp")
+	if err := writeFileSync(tmp, data); err != nil {
+		os.Remove(tmp)
+		return Batch{}, fmt.Errorf("写入死信文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, s.path(b.ID)); err != nil {
+		os.Remove(tmp)
+		return Batch{}, fmt.Errorf("写入死信文件失败: %v", err)
+	}
+	s.cache[b.ID] = b
+	return b, nil
+}
+
+// List 按失败时间列出批次，table为空时列出所有表
+func (s *Store) List(table string) ([]Batch, error) {
+	s.mu.Lock()
+	batches, err := s.scan()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Batch, 0, len(batches))
+	for _, b := range batches {
+		if table == "" || b.Table == table {
+			list = append(list, b)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].FailedAt.Equal(list[j].FailedAt) {
+			return list[i].FailedAt.Before(list[j].FailedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// Get 读取批次描述与数据
+func (s *Store) Get(id string) (Batch, *wal.Entry, error) {
+	if !validID(id) {
+		return Batch{}, nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return Batch{}, nil, ErrNotFound
+	}
+	if err != nil {
+		return Batch{}, nil, fmt.Errorf("读取死信文件失败: %v", err)
+	}
+	h, body, err := decodeHeader(data)
+	if err != nil {
+		return Batch{}, nil, fmt.Errorf("死信文件 %s: %v", id, err)
+	}
+	var e wal.Entry
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&e); err != nil {
+		return Batch{}, nil, fmt.Errorf("死信文件 %s: 解码批次失败: %v", id, err)
+	}
+	return h.batch(id, int64(len(data))), &e, nil
+}
+
+// Remove 删除批次
+func (s *Store) Remove(id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("删除死信文件失败: %v", err)
+	}
+	delete(s.cache, id)
+	return nil
+}
+
+// Redrive 用insert重新写库，成功后删除批次
+func (s *Store) Redrive(id string, insert func(*wal.Entry) error) (Batch, error) {
+	b, e, err := s.Get(id)
+	if err != nil {
+		return Batch{}, err
+	}
+	if err := insert(e); err != nil {
+		return b, err
+	}
+	if err := s.Remove(id); err != nil && !errors.Is(err, ErrNotFound) {
+		return b, err
+	}
+	return b, nil
+}
+
+// Purge 删除table（为空时所有表）中失败时间早于before的批次，before为零值时不限时间，返回删除的批次
+func (s *Store) Purge(table string, before time.Time) ([]Batch, error) {
+	list, err := s.List(table)
+	if err != nil {
+		return nil, err
+	}
+	var purged []Batch
+	for _, b := range list {
+		if !before.IsZero() && !b.FailedAt.Before(before) {
+			continue
+		}
+		if err := s.Remove(b.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return purged, err
+		}
+		purged = append(purged, b)
+	}
+	return purged, nil
+}
+
+// Stats 按表统计目录中的批次
+func (s *Store) Stats() (map[string]TableStats, error) {
+	s.mu.Lock()
+	batches, err := s.scan()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]TableStats)
+	for _, b := range batches {
+		st := stats[b.Table]
+		st.Batches++
+		st.Rows += int64(b.Rows)
+		st.Bytes += b.Bytes
+		stats[b.Table] = st
+	}
+	return stats, nil
+}
+
+// scan 读取目录中所有批次的描述，文件头只在第一次出现时读取（调用方持有锁）。
+// 其他进程（如telemetry-dlq）可能同时增删文件，每次都以目录为准。
+func (s *Store) scan() ([]Batch, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取死信目录失败: %v", err)
+	}
+	seen := make(map[string]bool, len(files))
+	batches := make([]Batch, 0, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, FileExt) {
+			continue
+		}
+		id := strings.TrimSuffix(name, FileExt)
+		seen[id] = true
+		if b, ok := s.cache[id]; ok {
+			batches = append(batches, b)
+			continue
+		}
+		b, err := readBatch(s.path(id), id)
+		if err != nil {
+			// 读取失败（如刚被删除）时跳过，下次重试
+			continue
+		}
+		s.cache[id] = b
+		batches = append(batches, b)
+	}
+	for id := range s.cache {
+		if !seen[id] {
+			delete(s.cache, id)
+		}
+	}
+	return batches, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+FileExt)
+}
+
+func (h header) batch(id string, size int64) Batch {
+	return Batch{ID: id, Table: h.Table, Rows: h.Rows, Reason: h.Reason, FailedAt: h.FailedAt, Bytes: size}
+}
+
+// validID ID只能是目录中的文件名
+func validID(id string) bool {
+	return id != "" && !strings.HasPrefix(id, ".") && !strings.ContainsAny(id, `/\`)
+}
+
+func encode(b Batch, e *wal.Entry) ([]byte, error) {
+	h, err := json.Marshal(header{Table: b.Table, Rows: b.Rows, Reason: b.Reason, FailedAt: b.FailedAt})
+	if err != nil {
+		return nil, fmt.Errorf("编码死信文件头失败: %v", err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(Magic)
+	binary.Write(&buf, binary.BigEndian, uint32(len(h)))
+	buf.Write(h)
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return nil, fmt.Errorf("编码死信批次失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeHeader(data []byte) (header, []byte, error) {
+	var h header
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		return h, nil, errors.New("不是死信文件")
+	}
+	data = data[len(Magic):]
+	if len(data) < 4 {
+		return h, nil, io.ErrUnexpectedEOF
+	}
+	size := binary.BigEndian.Uint32(data)
+	if size > maxHeaderSize || int(size) > len(data)-4 {
+		return h, nil, io.ErrUnexpectedEOF
+	}
+	if err := json.Unmarshal(data[4:4+size], &h); err != nil {
+		return h, nil, fmt.Errorf("解析文件头失败: %v", err)
+	}
+	return h, data[4+size:], nil
+}
+
+// readBatch 只读取文件头
+func readBatch(path, id string) (Batch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Batch{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Batch{}, err
+	}
+	prefix := make([]byte, len(Magic)+4)
+	if _, err := io.ReadFull(f, prefix); err != nil {
+		return Batch{}, err
+	}
+	size := binary.BigEndian.Uint32(prefix[len(Magic):])
+	if size > maxHeaderSize {
+		return Batch{}, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, len(prefix)+int(size))
+	copy(buf, prefix)
+	if _, err := io.ReadFull(f, buf[len(prefix):]); err != nil {
+		return Batch{}, err
+	}
+	h, _, err := decodeHeader(buf)
+	if err != nil {
+		return Batch{}, err
+	}
+	return h.batch(id, info.Size()), nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,146 @@
+package dlq
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+// recordingWriter 记录重新写入的平台指标
+type recordingWriter struct {
+	Writer
+	platform []models.PlatformMetric
+	err      error
+}
+
+func (w *recordingWriter) BatchInsertPlatformMetrics(data []models.PlatformMetric) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.platform = append(w.platform, data...)
+	return nil
+}
+
+func platformEntry(n int) *wal.Entry {
+	ts := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	e := &wal.Entry{}
+	for i := 0; i < n; i++ {
+		e.Platform = append(e.Platform, models.PlatformMetric{Timestamp: ts, SystemID: "dev-1", ComponentName: "CPU0"})
+	}
+	return e
+}
+
+func TestPutListRedrive(t *testing.T) {
+	s, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	first, err := s.Put(platformEntry(3), "copy: connection refused", t0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(&wal.Entry{Generic: []models.GenericMetric{{SystemID: "dev-1"}}}, "timeout", t0.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].ID != first.ID || all[0].Rows != 3 || all[0].Table != "platform" || all[0].Bytes == 0 {
+		t.Fatalf("List() = %+v", all)
+	}
+	if generic, _ := s.List("generic"); len(generic) != 1 || generic[0].Reason != "timeout" {
+		t.Fatalf(`List("generic") = %+v`, generic)
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := stats["platform"]; st.Batches != 1 || st.Rows != 3 {
+		t.Errorf("platform stats = %+v", st)
+	}
+
+	// 写库失败时批次保留
+	w := &recordingWriter{err: errors.New("still down")}
+	insert := func(e *wal.Entry) error { return Insert(w, e) }
+	if _, err := s.Redrive(first.ID, insert); err == nil {
+		t.Fatal("Redrive with failing writer succeeded")
+	}
+	if _, _, err := s.Get(first.ID); err != nil {
+		t.Fatalf("batch removed after failed redrive: %v", err)
+	}
+
+	w.err = nil
+	b, err := s.Redrive(first.ID, insert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Rows != 3 || len(w.platform) != 3 || w.platform[0].ComponentName != "CPU0" {
+		t.Errorf("redrive wrote %+v (batch %+v)", w.platform, b)
+	}
+	if _, _, err := s.Get(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after redrive: err = %v, want ErrNotFound", err)
+	}
+	if stats, _ := s.Stats(); stats["platform"].Batches != 0 {
+		t.Errorf("platform stats after redrive = %+v", stats["platform"])
+	}
+}
+
+func TestPurgeAndExternalRemoval(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		b, err := s.Put(platformEntry(1), "err", t0.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, b.ID)
+	}
+
+	purged, err := s.Purge("", t0.Add(90*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 2 {
+		t.Fatalf("purged %d batches, want 2", len(purged))
+	}
+
+	// 其他进程删除文件后统计随目录更新
+	if err := os.Remove(filepath.Join(dir, ids[2]+FileExt)); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := s.Stats(); len(stats) != 0 {
+		t.Errorf("Stats() = %+v after external removal, want empty", stats)
+	}
+}
+
+func TestMaxSizeAndInvalidID(t *testing.T) {
+	s, err := Open(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(platformEntry(1), "err", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(platformEntry(1), "err", time.Now()); !errors.Is(err, ErrFull) {
+		t.Errorf("second Put err = %v, want ErrFull", err)
+	}
+	for _, id := range []string{"", "../etc/passwd", ".hidden", `a\b`} {
+		if _, _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) err = %v, want ErrNotFound", id, err)
+		}
+	}
+}
@@ -197,6 +197,13 @@ type CommonState struct {
 	RedundancyType  *string `json:"redundancy_type,omitempty"`
 	Modules         *string `json:"modules,omitempty"`
 	TotalInputPower *string `json:"total_input_power,omitempty"`
+
+	// 由上述字符串解析出的数值，单位见字段名后缀（V/A/W）
+	CurrentVoltageV  *float64 `json:"current_voltage_v,omitempty"`
+	CurrentCurrentA  *float64 `json:"current_current_a,omitempty"`
+	TotalCapacityW   *float64 `json:"total_capacity_w,omitempty"`
+	UsedCapacityW    *float64 `json:"used_capacity_w,omitempty"`
+	TotalInputPowerW *float64 `json:"total_input_power_w,omitempty"`
 }
 
 // CPUData CPU 数据
@@ -251,6 +258,11 @@ type FanData struct {
 	FanCurrentVoltage *string `json:"fan_current_voltage,omitempty"`
 	FanCurrentCurrent *string `json:"fan_current_current,omitempty"`
 	FanSpeedPercent   *string `json:"fan_speed_percent,omitempty"`
+
+	// 由上述字符串解析出的数值（W/V/A）
+	FanCurrentPowerW   *float64 `json:"fan_current_power_w,omitempty"`
+	FanCurrentVoltageV *float64 `json:"fan_current_voltage_v,omitempty"`
+	FanCurrentCurrentA *float64 `json:"fan_current_current_a,omitempty"`
 }
 
 // PowerData 电源数据
@@ -298,6 +310,16 @@ type OpticalData struct {
 	OpticalRxThresholdPreLowAlarm    *float64 `json:"optical_rx_threshold_pre_low_alarm,omitempty"`
 }
 
+// OpticalChannelMetric 光通道指标，每个组件的每个光通道一行
+type OpticalChannelMetric struct {
+	Timestamp     time.Time `json:"timestamp" db:"timestamp"`
+	SystemID      string    `json:"system_id" db:"system_id"`
+	ComponentName string    `json:"component_name" db:"component_name"`
+	ChannelIndex  uint32    `json:"channel_index" db:"channel_index"`
+
+	*OpticalData
+}
+
 // InterfaceMetric 接口指标数据结构
 type InterfaceMetric struct {
 	Timestamp     time.Time `json:"timestamp" db:"timestamp"`
@@ -342,12 +364,12 @@ type InterfaceMetric struct {
 	LastClear            *time.Time `json:"last_clear,omitempty"`
 	InPkts               *uint64    `json:"in_pkts,omitempty"`
 	OutPkts              *uint64    `json:"out_pkts,omitempty"`
-	InputUtilization     *string `json:"input_utilization,omitempty"`     // %
-	OutputUtilization    *string `json:"output_utilization,omitempty"`    // %
-	InTrafficRate        *string `json:"in_traffic_rate,omitempty"`       // Mbps
-	InPacketRate         *string `json:"in_packet_rate,omitempty"`        // Kfps
-	OutTrafficRate       *string `json:"out_traffic_rate,omitempty"`      // Mbps
-	OutPacketRate        *string `json:"out_packet_rate,omitempty"`       // Kfps
+	InputUtilization     *float64 `json:"input_utilization,omitempty"`     // %
+	OutputUtilization    *float64 `json:"output_utilization,omitempty"`    // %
+	InTrafficRateMbps    *float64 `json:"in_traffic_rate,omitempty"`       // Mbps
+	InPacketRateKfps     *float64 `json:"in_packet_rate,omitempty"`        // Kfps
+	OutTrafficRateMbps   *float64 `json:"out_traffic_rate,omitempty"`      // Mbps
+	OutPacketRateKfps    *float64 `json:"out_packet_rate,omitempty"`       // Kfps
 	InV4Octets           *uint64 `json:"in_v4_octets,omitempty"`
 	OutV4Octets          *uint64 `json:"out_v4_octets,omitempty"`
 	InV4Pkts             *uint64 `json:"in_v4_pkts,omitempty"`
@@ -356,22 +378,25 @@ type InterfaceMetric struct {
 	OutV6Octets          *uint64 `json:"out_v6_octets,omitempty"`
 	InV6Pkts             *uint64 `json:"in_v6_pkts,omitempty"`
 	OutV6Pkts            *uint64 `json:"out_v6_pkts,omitempty"`
-	InV4TrafficRate      *string `json:"in_v4_traffic_rate,omitempty"`    // Mbps
-	InV4PacketRate       *string `json:"in_v4_packet_rate,omitempty"`     // Kfps
-	OutV4TrafficRate     *string `json:"out_v4_traffic_rate,omitempty"`   // Mbps
-	OutV4PacketRate      *string `json:"out_v4_packet_rate,omitempty"`    // Kfps
-	InV6TrafficRate      *string `json:"in_v6_traffic_rate,omitempty"`    // Mbps
-	InV6PacketRate       *string `json:"in_v6_packet_rate,omitempty"`     // Kfps
-	OutV6TrafficRate     *string `json:"out_v6_traffic_rate,omitempty"`   // Mbps
-	OutV6PacketRate      *string `json:"out_v6_packet_rate,omitempty"`    // Kfps
-	InputV4Utilization   *string `json:"input_v4_utilization,omitempty"`  // %
-	OutputV4Utilization  *string `json:"output_v4_utilization,omitempty"` // %
-	InputV6Utilization   *string `json:"input_v6_utilization,omitempty"`  // %
-	OutputV6Utilization  *string `json:"output_v6_utilization,omitempty"` // %
+	InV4TrafficRateMbps  *float64 `json:"in_v4_traffic_rate,omitempty"`    // Mbps
+	InV4PacketRateKfps   *float64 `json:"in_v4_packet_rate,omitempty"`     // Kfps
+	OutV4TrafficRateMbps *float64 `json:"out_v4_traffic_rate,omitempty"`   // Mbps
+	OutV4PacketRateKfps  *float64 `json:"out_v4_packet_rate,omitempty"`    // Kfps
+	InV6TrafficRateMbps  *float64 `json:"in_v6_traffic_rate,omitempty"`    // Mbps
+	InV6PacketRateKfps   *float64 `json:"in_v6_packet_rate,omitempty"`     // Kfps
+	OutV6TrafficRateMbps *float64 `json:"out_v6_traffic_rate,omitempty"`   // Mbps
+	OutV6PacketRateKfps  *float64 `json:"out_v6_packet_rate,omitempty"`    // Kfps
+	InputV4Utilization   *float64 `json:"input_v4_utilization,omitempty"`  // %
+	OutputV4Utilization  *float64 `json:"output_v4_utilization,omitempty"` // %
+	InputV6Utilization   *float64 `json:"input_v6_utilization,omitempty"`  // %
+	OutputV6Utilization  *float64 `json:"output_v6_utilization,omitempty"` // %
 	InBierOctets         *uint64 `json:"in_bier_octets,omitempty"`
 	InBierPkts           *uint64 `json:"in_bier_pkts,omitempty"`
 	OutBierOctets        *uint64 `json:"out_bier_octets,omitempty"`
 	OutBierPkts          *uint64 `json:"out_bier_pkts,omitempty"`
+
+	// 服务端计算的计数器速率
+	*CounterRates
 }
 
 // SubinterfaceMetric 子接口指标数据结构
@@ -417,12 +442,12 @@ type SubinterfaceMetric struct {
 	LastClear             *time.Time `json:"last_clear,omitempty" db:"last_clear"`
 	InPkts                *uint64    `json:"in_pkts,omitempty" db:"in_pkts"`
 	OutPkts               *uint64    `json:"out_pkts,omitempty" db:"out_pkts"`
-	InputUtilization      *string    `json:"input_utilization,omitempty" db:"input_utilization"`      // %
-	OutputUtilization     *string    `json:"output_utilization,omitempty" db:"output_utilization"`    // %
-	InTrafficRate         *string    `json:"in_traffic_rate,omitempty" db:"in_traffic_rate"`          // Mbps
-	InPacketRate          *string    `json:"in_packet_rate,omitempty" db:"in_packet_rate"`            // Kfps
-	OutTrafficRate        *string    `json:"out_traffic_rate,omitempty" db:"out_traffic_rate"`        // Mbps
-	OutPacketRate         *string    `json:"out_packet_rate,omitempty" db:"out_packet_rate"`          // Kfps
+	InputUtilization      *float64   `json:"input_utilization,omitempty" db:"input_utilization"`          // %
+	OutputUtilization     *float64   `json:"output_utilization,omitempty" db:"output_utilization"`        // %
+	InTrafficRateMbps     *float64   `json:"in_traffic_rate,omitempty" db:"in_traffic_rate_mbps"`         // Mbps
+	InPacketRateKfps      *float64   `json:"in_packet_rate,omitempty" db:"in_packet_rate_kfps"`           // Kfps
+	OutTrafficRateMbps    *float64   `json:"out_traffic_rate,omitempty" db:"out_traffic_rate_mbps"`       // Mbps
+	OutPacketRateKfps     *float64   `json:"out_packet_rate,omitempty" db:"out_packet_rate_kfps"`         // Kfps
 	InV4Octets            *uint64    `json:"in_v4_octets,omitempty" db:"in_v4_octets"`
 	OutV4Octets           *uint64    `json:"out_v4_octets,omitempty" db:"out_v4_octets"`
 	InV4Pkts              *uint64    `json:"in_v4_pkts,omitempty" db:"in_v4_pkts"`
@@ -431,22 +456,108 @@ type SubinterfaceMetric struct {
 	OutV6Octets           *uint64    `json:"out_v6_octets,omitempty" db:"out_v6_octets"`
 	InV6Pkts              *uint64    `json:"in_v6_pkts,omitempty" db:"in_v6_pkts"`
 	OutV6Pkts             *uint64    `json:"out_v6_pkts,omitempty" db:"out_v6_pkts"`
-	InV4TrafficRate       *string    `json:"in_v4_traffic_rate,omitempty" db:"in_v4_traffic_rate"`    // Mbps
-	InV4PacketRate        *string    `json:"in_v4_packet_rate,omitempty" db:"in_v4_packet_rate"`      // Kfps
-	OutV4TrafficRate      *string    `json:"out_v4_traffic_rate,omitempty" db:"out_v4_traffic_rate"`  // Mbps
-	OutV4PacketRate       *string    `json:"out_v4_packet_rate,omitempty" db:"out_v4_packet_rate"`    // Kfps
-	InV6TrafficRate       *string    `json:"in_v6_traffic_rate,omitempty" db:"in_v6_traffic_rate"`    // Mbps
-	InV6PacketRate        *string    `json:"in_v6_packet_rate,omitempty" db:"in_v6_packet_rate"`      // Kfps
-	OutV6TrafficRate      *string    `json:"out_v6_traffic_rate,omitempty" db:"out_v6_traffic_rate"`  // Mbps
-	OutV6PacketRate       *string    `json:"out_v6_packet_rate,omitempty" db:"out_v6_packet_rate"`    // Kfps
-	InputV4Utilization    *string    `json:"input_v4_utilization,omitempty" db:"input_v4_utilization"`  // %
-	OutputV4Utilization   *string    `json:"output_v4_utilization,omitempty" db:"output_v4_utilization"` // %
-	InputV6Utilization    *string    `json:"input_v6_utilization,omitempty" db:"input_v6_utilization"`  // %
-	OutputV6Utilization   *string    `json:"output_v6_utilization,omitempty" db:"output_v6_utilization"` // %
+	InV4TrafficRateMbps   *float64   `json:"in_v4_traffic_rate,omitempty" db:"in_v4_traffic_rate_mbps"`   // Mbps
+	InV4PacketRateKfps    *float64   `json:"in_v4_packet_rate,omitempty" db:"in_v4_packet_rate_kfps"`     // Kfps
+	OutV4TrafficRateMbps  *float64   `json:"out_v4_traffic_rate,omitempty" db:"out_v4_traffic_rate_mbps"` // Mbps
+	OutV4PacketRateKfps   *float64   `json:"out_v4_packet_rate,omitempty" db:"out_v4_packet_rate_kfps"`   // Kfps
+	InV6TrafficRateMbps   *float64   `json:"in_v6_traffic_rate,omitempty" db:"in_v6_traffic_rate_mbps"`   // Mbps
+	InV6PacketRateKfps    *float64   `json:"in_v6_packet_rate,omitempty" db:"in_v6_packet_rate_kfps"`     // Kfps
+	OutV6TrafficRateMbps  *float64   `json:"out_v6_traffic_rate,omitempty" db:"out_v6_traffic_rate_mbps"` // Mbps
+	OutV6PacketRateKfps   *float64   `json:"out_v6_packet_rate,omitempty" db:"out_v6_packet_rate_kfps"`   // Kfps
+	InputV4Utilization    *float64   `json:"input_v4_utilization,omitempty" db:"input_v4_utilization"`    // %
+	OutputV4Utilization   *float64   `json:"output_v4_utilization,omitempty" db:"output_v4_utilization"`  // %
+	InputV6Utilization    *float64   `json:"input_v6_utilization,omitempty" db:"input_v6_utilization"`    // %
+	OutputV6Utilization   *float64   `json:"output_v6_utilization,omitempty" db:"output_v6_utilization"`  // %
 	InBierOctets          *uint64    `json:"in_bier_octets,omitempty" db:"in_bier_octets"`
 	InBierPkts            *uint64    `json:"in_bier_pkts,omitempty" db:"in_bier_pkts"`
 	OutBierOctets         *uint64    `json:"out_bier_octets,omitempty" db:"out_bier_octets"`
 	OutBierPkts           *uint64    `json:"out_bier_pkts,omitempty" db:"out_bier_pkts"`
+
+	// 服务端计算的计数器速率
+	*CounterRates
+}
+
+// CounterRates 服务端根据相邻两次计数器采样计算的每秒速率（字节/秒、包/秒）
+// 字段顺序与 CounterRateColumns、InterfaceMetric.CounterValues 一致
+type CounterRates struct {
+	InOctetsRate         *float64 `json:"in_octets_rate,omitempty" db:"in_octets_rate"`
+	InUnicastPktsRate    *float64 `json:"in_unicast_pkts_rate,omitempty" db:"in_unicast_pkts_rate"`
+	InBroadcastPktsRate  *float64 `json:"in_broadcast_pkts_rate,omitempty" db:"in_broadcast_pkts_rate"`
+	InMulticastPktsRate  *float64 `json:"in_multicast_pkts_rate,omitempty" db:"in_multicast_pkts_rate"`
+	InDiscardsRate       *float64 `json:"in_discards_rate,omitempty" db:"in_discards_rate"`
+	InErrorsRate         *float64 `json:"in_errors_rate,omitempty" db:"in_errors_rate"`
+	InUnknownProtosRate  *float64 `json:"in_unknown_protos_rate,omitempty" db:"in_unknown_protos_rate"`
+	InFcsErrorsRate      *float64 `json:"in_fcs_errors_rate,omitempty" db:"in_fcs_errors_rate"`
+	OutOctetsRate        *float64 `json:"out_octets_rate,omitempty" db:"out_octets_rate"`
+	OutUnicastPktsRate   *float64 `json:"out_unicast_pkts_rate,omitempty" db:"out_unicast_pkts_rate"`
+	OutBroadcastPktsRate *float64 `json:"out_broadcast_pkts_rate,omitempty" db:"out_broadcast_pkts_rate"`
+	OutMulticastPktsRate *float64 `json:"out_multicast_pkts_rate,omitempty" db:"out_multicast_pkts_rate"`
+	OutDiscardsRate      *float64 `json:"out_discards_rate,omitempty" db:"out_discards_rate"`
+	OutErrorsRate        *float64 `json:"out_errors_rate,omitempty" db:"out_errors_rate"`
+	InPktsRate           *float64 `json:"in_pkts_rate,omitempty" db:"in_pkts_rate"`
+	OutPktsRate          *float64 `json:"out_pkts_rate,omitempty" db:"out_pkts_rate"`
+	InV4OctetsRate       *float64 `json:"in_v4_octets_rate,omitempty" db:"in_v4_octets_rate"`
+	OutV4OctetsRate      *float64 `json:"out_v4_octets_rate,omitempty" db:"out_v4_octets_rate"`
+	InV4PktsRate         *float64 `json:"in_v4_pkts_rate,omitempty" db:"in_v4_pkts_rate"`
+	OutV4PktsRate        *float64 `json:"out_v4_pkts_rate,omitempty" db:"out_v4_pkts_rate"`
+	InV6OctetsRate       *float64 `json:"in_v6_octets_rate,omitempty" db:"in_v6_octets_rate"`
+	OutV6OctetsRate      *float64 `json:"out_v6_octets_rate,omitempty" db:"out_v6_octets_rate"`
+	InV6PktsRate         *float64 `json:"in_v6_pkts_rate,omitempty" db:"in_v6_pkts_rate"`
+	OutV6PktsRate        *float64 `json:"out_v6_pkts_rate,omitempty" db:"out_v6_pkts_rate"`
+	InBierOctetsRate     *float64 `json:"in_bier_octets_rate,omitempty" db:"in_bier_octets_rate"`
+	InBierPktsRate       *float64 `json:"in_bier_pkts_rate,omitempty" db:"in_bier_pkts_rate"`
+	OutBierOctetsRate    *float64 `json:"out_bier_octets_rate,omitempty" db:"out_bier_octets_rate"`
+	OutBierPktsRate      *float64 `json:"out_bier_pkts_rate,omitempty" db:"out_bier_pkts_rate"`
+}
+
+// CounterRateColumns 速率列名，顺序与 CounterRates 字段一致
+var CounterRateColumns = []string{
+	"in_octets_rate", "in_unicast_pkts_rate", "in_broadcast_pkts_rate", "in_multicast_pkts_rate",
+	"in_discards_rate", "in_errors_rate", "in_unknown_protos_rate", "in_fcs_errors_rate",
+	"out_octets_rate", "out_unicast_pkts_rate", "out_broadcast_pkts_rate", "out_multicast_pkts_rate",
+	"out_discards_rate", "out_errors_rate", "in_pkts_rate", "out_pkts_rate",
+	"in_v4_octets_rate", "out_v4_octets_rate", "in_v4_pkts_rate", "out_v4_pkts_rate",
+	"in_v6_octets_rate", "out_v6_octets_rate", "in_v6_pkts_rate", "out_v6_pkts_rate",
+	"in_bier_octets_rate", "in_bier_pkts_rate", "out_bier_octets_rate", "out_bier_pkts_rate",
+}
+
+// Fields 按 CounterRateColumns 顺序返回各速率字段的地址
+func (r *CounterRates) Fields() []**float64 {
+	return []**float64{
+		&r.InOctetsRate, &r.InUnicastPktsRate, &r.InBroadcastPktsRate, &r.InMulticastPktsRate,
+		&r.InDiscardsRate, &r.InErrorsRate, &r.InUnknownProtosRate, &r.InFcsErrorsRate,
+		&r.OutOctetsRate, &r.OutUnicastPktsRate, &r.OutBroadcastPktsRate, &r.OutMulticastPktsRate,
+		&r.OutDiscardsRate, &r.OutErrorsRate, &r.InPktsRate, &r.OutPktsRate,
+		&r.InV4OctetsRate, &r.OutV4OctetsRate, &r.InV4PktsRate, &r.OutV4PktsRate,
+		&r.InV6OctetsRate, &r.OutV6OctetsRate, &r.InV6PktsRate, &r.OutV6PktsRate,
+		&r.InBierOctetsRate, &r.InBierPktsRate, &r.OutBierOctetsRate, &r.OutBierPktsRate,
+	}
+}
+
+// CounterValues 按 CounterRateColumns 顺序返回参与速率计算的计数器
+func (m *InterfaceMetric) CounterValues() []*uint64 {
+	return []*uint64{
+		m.InOctets, m.InUnicastPkts, m.InBroadcastPkts, m.InMulticastPkts,
+		m.InDiscards, m.InErrors, m.InUnknownProtos, m.InFcsErrors,
+		m.OutOctets, m.OutUnicastPkts, m.OutBroadcastPkts, m.OutMulticastPkts,
+		m.OutDiscards, m.OutErrors, m.InPkts, m.OutPkts,
+		m.InV4Octets, m.OutV4Octets, m.InV4Pkts, m.OutV4Pkts,
+		m.InV6Octets, m.OutV6Octets, m.InV6Pkts, m.OutV6Pkts,
+		m.InBierOctets, m.InBierPkts, m.OutBierOctets, m.OutBierPkts,
+	}
+}
+
+// CounterValues 按 CounterRateColumns 顺序返回参与速率计算的计数器
+func (m *SubinterfaceMetric) CounterValues() []*uint64 {
+	return []*uint64{
+		m.InOctets, m.InUnicastPkts, m.InBroadcastPkts, m.InMulticastPkts,
+		m.InDiscards, m.InErrors, m.InUnknownProtos, m.InFcsErrors,
+		m.OutOctets, m.OutUnicastPkts, m.OutBroadcastPkts, m.OutMulticastPkts,
+		m.OutDiscards, m.OutErrors, m.InPkts, m.OutPkts,
+		m.InV4Octets, m.OutV4Octets, m.InV4Pkts, m.OutV4Pkts,
+		m.InV6Octets, m.OutV6Octets, m.InV6Pkts, m.OutV6Pkts,
+		m.InBierOctets, m.InBierPkts, m.OutBierOctets, m.OutBierPkts,
+	}
 }
 
 // 辅助函数：格式化uptime为dd:hh:mm:ss格式
@@ -566,6 +677,60 @@ type NotificationReportMetric struct {
 	Caption     *string `json:"caption,omitempty" db:"caption"`         // 通知标题
 }
 
+// SelfDefinedEventMetric 自定义事件数据结构（设备侧阈值触发的事件）
+type SelfDefinedEventMetric struct {
+	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+	SystemID  string    `json:"system_id" db:"system_id"`
+
+	// 事件基本信息
+	Level       uint32  `json:"level" db:"level"`                       // 自定义事件级别
+	Description *string `json:"description,omitempty" db:"description"` // 事件描述
+
+	// 触发条件
+	FilterRelation *string `json:"filter_relation,omitempty" db:"filter_relation"` // 过滤条件之间的关系
+	Filters        *string `json:"filters,omitempty" db:"filters"`                 // 过滤条件(JSON数组)
+
+	// 触发采样
+	SensorPath      string  `json:"sensor_path" db:"sensor_path"`                 // 触发事件的采样路径
+	ProtoPath       *string `json:"proto_path,omitempty" db:"proto_path"`         // 采样数据对应的proto消息
+	Instance        *string `json:"instance,omitempty" db:"instance"`             // 触发事件的实例路径
+	ContentEncoding string  `json:"content_encoding" db:"content_encoding"`       // 采样编码: gpb/gpbkv/json
+}
+
+// GenericMetric 通用键值指标（无类型解析器的sensor_path按叶子展开）
+type GenericMetric struct {
+	Timestamp  time.Time `json:"timestamp" db:"timestamp"`
+	SystemID   string    `json:"system_id" db:"system_id"`
+	SensorPath string    `json:"sensor_path" db:"sensor_path"`
+	ProtoPath  *string   `json:"proto_path,omitempty" db:"proto_path"`
+
+	// 实例键，如 {"name":"gei-1/2/1","subinterface/index":"9"} (JSON对象)
+	InstanceKeys *string `json:"instance_keys,omitempty" db:"instance_keys"`
+
+	// 叶子字段路径及取值，数值与字符串二选一（枚举两者都有）
+	Field    string   `json:"field" db:"field"`
+	ValueNum *float64 `json:"value_num,omitempty" db:"value_num"`
+	ValueStr *string  `json:"value_str,omitempty" db:"value_str"`
+
+	// 目标表，由sensor_mappings指定；为空时写入generic_metrics
+	Table string `json:"table,omitempty" db:"-"`
+}
+
+// MappedRow 按声明式映射文件生成的行，列由映射文件决定
+type MappedRow struct {
+	Timestamp  time.Time `json:"timestamp" db:"timestamp"`
+	SystemID   string    `json:"system_id" db:"system_id"`
+	SensorPath string    `json:"sensor_path" db:"-"`
+
+	// 目标表及映射列（不含 timestamp/system_id），Values 与 Columns 一一对应
+	Table   string        `json:"table" db:"-"`
+	Columns []string      `json:"columns" db:"-"`
+	Values  []interface{} `json:"values" db:"-"`
+
+	// 行键（keys列取值拼接），用于缓冲去重
+	Key string `json:"key" db:"-"`
+}
+
 // 辅助函数：格式化利用率（从浮点数转换为百分比）
 func FormatUtilization(value float64) string {
 	percentage := value * 100
@@ -0,0 +1,306 @@
+package monitoring
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/buffer"
+	"github.com/wwswwsuns/ztelem/internal/collector"
+	"github.com/wwswwsuns/ztelem/internal/devices"
+	"github.com/wwswwsuns/ztelem/internal/dlq"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+// StreamRegistry 管理API查询与关闭Publish流所需的采集器接口
+type StreamRegistry interface {
+	ListStreams() []collector.StreamInfo
+	CloseStream(id, reason string) bool
+	CloseStaleStreams(reason string) []string
+	ListDevices() []devices.Device
+}
+
+// DeadLetterQueue 管理API查看与处理死信批次所需的缓冲区接口
+type DeadLetterQueue interface {
+	ListDeadLetters(table string) ([]dlq.Batch, error)
+	GetDeadLetter(id string) (dlq.Batch, *wal.Entry, error)
+	RedriveDeadLetter(id string) (dlq.Batch, error)
+	PurgeDeadLetters(table string, before time.Time) ([]dlq.Batch, error)
+}
+
+// AdminServer 管理API（监听health_check_port）
+//
+//	GET  /health                 健康检查
+//	GET  /streams[?stale=true]   列出Publish流
+//	GET  /streams/{id}           单个流详情
+//	POST /streams/{id}/close     强制关闭指定流，设备随后重连
+//	POST /streams/close-stale    关闭所有超过数据超时的流
+//	GET  /devices                设备登记
+//	GET  /dlq[?table=]           列出死信批次
+//	GET  /dlq/{id}               死信批次及其数据
+//	POST /dlq/{id}/redrive       重新写库，成功后删除
+//	POST /dlq/redrive[?table=]   按失败时间依次重新写库，遇到失败停止
+//	POST /dlq/purge              删除死信批次（?table= &before=RFC3339，两者都不指定时需 all=true）
+type AdminServer struct {
+	server      *http.Server
+	logger      *logrus.Logger
+	registry    StreamRegistry
+	deadLetters DeadLetterQueue
+	token       string
+}
+
+// NewAdminServer 创建管理API服务器，token非空时要求 Authorization: Bearer <token>。
+// deadLetters为nil时死信接口返回404。
+func NewAdminServer(addr, token string, registry StreamRegistry, deadLetters DeadLetterQueue, logger *logrus.Logger) *AdminServer {
+	as := &AdminServer{
+		logger:      logger,
+		registry:    registry,
+		deadLetters: deadLetters,
+		token:       token,
+	}
+	as.server = &http.Server{
+		Addr:         addr,
+		Handler:      as.Handler(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	return as
+}
+
+// Handler 返回管理API路由
+func (as *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("GET /streams", as.authorized(as.listStreams))
+	mux.HandleFunc("GET /streams/{id}", as.authorized(as.getStream))
+	mux.HandleFunc("POST /streams/{id}/close", as.authorized(as.closeStream))
+	mux.HandleFunc("POST /streams/close-stale", as.authorized(as.closeStaleStreams))
+	mux.HandleFunc("GET /devices", as.authorized(as.listDevices))
+	mux.HandleFunc("GET /dlq", as.authorized(as.listDeadLetters))
+	mux.HandleFunc("GET /dlq/{id}", as.authorized(as.getDeadLetter))
+	mux.HandleFunc("POST /dlq/{id}/redrive", as.authorized(as.redriveDeadLetter))
+	mux.HandleFunc("POST /dlq/redrive", as.authorized(as.redriveDeadLetters))
+	mux.HandleFunc("POST /dlq/purge", as.authorized(as.purgeDeadLetters))
+	return mux
+}
+
+// Start 启动管理API服务器
+func (as *AdminServer) Start() error {
+	as.logger.WithField("addr", as.server.Addr).Info("启动管理API服务器")
+	go func() {
+		if err := as.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			as.logger.WithError(err).Error("管理API服务器启动失败")
+		}
+	}()
+	return nil
+}
+
+// Stop 停止管理API服务器
+func (as *AdminServer) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	as.logger.Info("停止管理API服务器")
+	return as.server.Shutdown(ctx)
+}
+
+// authorized 校验Bearer token，未配置token时不校验
+func (as *AdminServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if as.token != "" {
+			want := "Bearer " + as.token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (as *AdminServer) listStreams(w http.ResponseWriter, r *http.Request) {
+	streams := as.registry.ListStreams()
+	if r.URL.Query().Get("stale") == "true" {
+		stale := streams[:0]
+		for _, s := range streams {
+			if s.Stale {
+				stale = append(stale, s)
+			}
+		}
+		streams = stale
+	}
+	writeJSON(w, http.StatusOK, streams)
+}
+
+func (as *AdminServer) getStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, s := range as.registry.ListStreams() {
+		if s.ID == id {
+			writeJSON(w, http.StatusOK, s)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": "stream not found"})
+}
+
+func (as *AdminServer) closeStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	reason := closeReason(r)
+	if !as.registry.CloseStream(id, reason) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "stream not found"})
+		return
+	}
+	as.logger.Warnf("管理API强制关闭流: id=%s, 原因=%s, 来源=%s", id, reason, r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, map[string][]string{"closed": {id}})
+}
+
+func (as *AdminServer) closeStaleStreams(w http.ResponseWriter, r *http.Request) {
+	closed := as.registry.CloseStaleStreams(closeReason(r))
+	if len(closed) > 0 {
+		as.logger.Warnf("管理API关闭僵尸流: %d 条, 来源=%s", len(closed), r.RemoteAddr)
+	}
+	writeJSON(w, http.StatusAccepted, map[string][]string{"closed": closed})
+}
+
+func (as *AdminServer) listDevices(w http.ResponseWriter, r *http.Request) {
+	list := as.registry.ListDevices()
+	if list == nil {
+		list = []devices.Device{}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (as *AdminServer) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if as.deadLetters == nil {
+		writeDeadLetterError(w, buffer.ErrDeadLetterDisabled)
+		return
+	}
+	list, err := as.deadLetters.ListDeadLetters(r.URL.Query().Get("table"))
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (as *AdminServer) getDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if as.deadLetters == nil {
+		writeDeadLetterError(w, buffer.ErrDeadLetterDisabled)
+		return
+	}
+	b, e, err := as.deadLetters.GetDeadLetter(r.PathValue("id"))
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		dlq.Batch
+		Data *wal.Entry `json:"data"`
+	}{b, e})
+}
+
+func (as *AdminServer) redriveDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if as.deadLetters == nil {
+		writeDeadLetterError(w, buffer.ErrDeadLetterDisabled)
+		return
+	}
+	b, err := as.deadLetters.RedriveDeadLetter(r.PathValue("id"))
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	as.logger.Infof("管理API重新写库死信批次: id=%s, 来源=%s", b.ID, r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"redriven": []dlq.Batch{b}})
+}
+
+func (as *AdminServer) redriveDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if as.deadLetters == nil {
+		writeDeadLetterError(w, buffer.ErrDeadLetterDisabled)
+		return
+	}
+	list, err := as.deadLetters.ListDeadLetters(r.URL.Query().Get("table"))
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	redriven := []dlq.Batch{}
+	for _, b := range list {
+		if _, err := as.deadLetters.RedriveDeadLetter(b.ID); err != nil {
+			if errors.Is(err, dlq.ErrNotFound) {
+				// 已被其他请求或telemetry-dlq处理
+				continue
+			}
+			as.logger.WithError(err).Warnf("管理API重新写库死信批次失败: id=%s", b.ID)
+			writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+				"redriven": redriven,
+				"failed":   b.ID,
+				"error":    err.Error(),
+			})
+			return
+		}
+		redriven = append(redriven, b)
+	}
+	as.logger.Infof("管理API重新写库死信批次: %d 个, 来源=%s", len(redriven), r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"redriven": redriven})
+}
+
+func (as *AdminServer) purgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if as.deadLetters == nil {
+		writeDeadLetterError(w, buffer.ErrDeadLetterDisabled)
+		return
+	}
+	q := r.URL.Query()
+	var before time.Time
+	if v := q.Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "before must be RFC3339"})
+			return
+		}
+		before = t
+	}
+	if q.Get("table") == "" && before.IsZero() && q.Get("all") != "true" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "specify table, before or all=true"})
+		return
+	}
+	purged, err := as.deadLetters.PurgeDeadLetters(q.Get("table"), before)
+	if err != nil {
+		writeDeadLetterError(w, err)
+		return
+	}
+	if purged == nil {
+		purged = []dlq.Batch{}
+	}
+	as.logger.Warnf("管理API清除死信批次: %d 个, 来源=%s", len(purged), r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string][]dlq.Batch{"purged": purged})
+}
+
+// writeDeadLetterError 未启用或批次不存在时返回404，其他错误（如重新写库失败）返回502
+func writeDeadLetterError(w http.ResponseWriter, err error) {
+	code := http.StatusBadGateway
+	if errors.Is(err, dlq.ErrNotFound) || errors.Is(err, buffer.ErrDeadLetterDisabled) {
+		code = http.StatusNotFound
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// closeReason 关闭原因会随gRPC状态返回给设备
+func closeReason(r *http.Request) string {
+	if reason := r.URL.Query().Get("reason"); reason != "" {
+		return reason
+	}
+	return "closed by admin"
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
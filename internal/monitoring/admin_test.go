@@ -0,0 +1,268 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/collector"
+	"github.com/wwswwsuns/ztelem/internal/devices"
+	"github.com/wwswwsuns/ztelem/internal/dlq"
+	"github.com/wwswwsuns/ztelem/internal/wal"
+)
+
+type fakeRegistry struct {
+	streams []collector.StreamInfo
+	closed  map[string]string
+}
+
+func (f *fakeRegistry) ListStreams() []collector.StreamInfo {
+	return append([]collector.StreamInfo(nil), f.streams...)
+}
+
+func (f *fakeRegistry) CloseStream(id, reason string) bool {
+	for _, s := range f.streams {
+		if s.ID == id {
+			f.closed[id] = reason
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeRegistry) CloseStaleStreams(reason string) []string {
+	closed := []string{}
+	for _, s := range f.streams {
+		if s.Stale {
+			f.closed[s.ID] = reason
+			closed = append(closed, s.ID)
+		}
+	}
+	return closed
+}
+
+func (f *fakeRegistry) ListDevices() []devices.Device {
+	return []devices.Device{{SystemID: "PE-01", Streams: []devices.StreamRef{{ID: "1", Peer: "10.1.1.1:50000"}}}}
+}
+
+func newTestAdminServer(token string) (*AdminServer, *fakeRegistry) {
+	registry := &fakeRegistry{
+		streams: []collector.StreamInfo{
+			{ID: "1", Peer: "10.1.1.1:50000", SystemIDs: []string{"PE-01"}},
+			{ID: "2", Peer: "10.1.1.2:50000", Stale: true},
+		},
+		closed: make(map[string]string),
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewAdminServer("127.0.0.1:0", token, registry, nil, logger), registry
+}
+
+func TestAdminServer_Streams(t *testing.T) {
+	as, registry := newTestAdminServer("")
+	h := as.Handler()
+
+	tests := []struct {
+		method, path string
+		wantCode     int
+		wantIDs      []string
+	}{
+		{"GET", "/streams", http.StatusOK, []string{"1", "2"}},
+		{"GET", "/streams?stale=true", http.StatusOK, []string{"2"}},
+		{"GET", "/streams/1", http.StatusOK, []string{"1"}},
+		{"GET", "/streams/9", http.StatusNotFound, nil},
+		{"POST", "/streams/9/close", http.StatusNotFound, nil},
+		{"DELETE", "/streams", http.StatusMethodNotAllowed, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s %s: code = %d, want %d", tt.method, tt.path, rec.Code, tt.wantCode)
+			continue
+		}
+		if tt.wantIDs == nil {
+			continue
+		}
+		var streams []collector.StreamInfo
+		if tt.path == "/streams/1" {
+			var s collector.StreamInfo
+			json.Unmarshal(rec.Body.Bytes(), &s)
+			streams = append(streams, s)
+		} else if err := json.Unmarshal(rec.Body.Bytes(), &streams); err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if len(streams) != len(tt.wantIDs) {
+			t.Errorf("%s: got %d streams, want %v", tt.path, len(streams), tt.wantIDs)
+			continue
+		}
+		for i, s := range streams {
+			if s.ID != tt.wantIDs[i] {
+				t.Errorf("%s: streams[%d].ID = %s, want %s", tt.path, i, s.ID, tt.wantIDs[i])
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/streams/1/close?reason=maintenance", nil))
+	if rec.Code != http.StatusAccepted || registry.closed["1"] != "maintenance" {
+		t.Errorf("close stream: code = %d, closed = %v", rec.Code, registry.closed)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/devices", nil))
+	var list []devices.Device
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK || len(list) != 1 || list[0].SystemID != "PE-01" {
+		t.Errorf("devices: code = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/streams/close-stale", nil))
+	var resp map[string][]string
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusAccepted || len(resp["closed"]) != 1 || resp["closed"][0] != "2" {
+		t.Errorf("close stale: code = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAdminServer_Token(t *testing.T) {
+	as, _ := newTestAdminServer("secret")
+	h := as.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/streams", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: code = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/streams", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("with token: code = %d, want 200", rec.Code)
+	}
+
+	// 健康检查不需要token
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("health: code = %d, want 200", rec.Code)
+	}
+}
+
+// fakeDeadLetters 按失败时间排序的死信批次，ID为"bad"的批次重新写库失败
+type fakeDeadLetters struct {
+	batches []dlq.Batch
+}
+
+func (f *fakeDeadLetters) ListDeadLetters(table string) ([]dlq.Batch, error) {
+	var list []dlq.Batch
+	for _, b := range f.batches {
+		if table == "" || b.Table == table {
+			list = append(list, b)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeDeadLetters) GetDeadLetter(id string) (dlq.Batch, *wal.Entry, error) {
+	for _, b := range f.batches {
+		if b.ID == id {
+			return b, &wal.Entry{}, nil
+		}
+	}
+	return dlq.Batch{}, nil, dlq.ErrNotFound
+}
+
+func (f *fakeDeadLetters) RedriveDeadLetter(id string) (dlq.Batch, error) {
+	if id == "bad" {
+		return dlq.Batch{}, errors.New("copy failed")
+	}
+	for i, b := range f.batches {
+		if b.ID == id {
+			f.batches = append(f.batches[:i], f.batches[i+1:]...)
+			return b, nil
+		}
+	}
+	return dlq.Batch{}, dlq.ErrNotFound
+}
+
+func (f *fakeDeadLetters) PurgeDeadLetters(table string, before time.Time) ([]dlq.Batch, error) {
+	var purged, kept []dlq.Batch
+	for _, b := range f.batches {
+		if (table == "" || b.Table == table) && (before.IsZero() || b.FailedAt.Before(before)) {
+			purged = append(purged, b)
+		} else {
+			kept = append(kept, b)
+		}
+	}
+	f.batches = kept
+	return purged, nil
+}
+
+func TestAdminServer_DeadLetters(t *testing.T) {
+	t0 := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	dl := &fakeDeadLetters{batches: []dlq.Batch{
+		{ID: "a", Table: "platform", Rows: 10, FailedAt: t0},
+		{ID: "bad", Table: "platform", Rows: 5, FailedAt: t0.Add(time.Minute)},
+		{ID: "c", Table: "interface", Rows: 7, FailedAt: t0.Add(2 * time.Minute)},
+	}}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	h := NewAdminServer("127.0.0.1:0", "", &fakeRegistry{}, dl, logger).Handler()
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	var list []dlq.Batch
+	rec := do("GET", "/dlq?table=platform")
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 2 {
+		t.Errorf("list: code = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("GET", "/dlq/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("get missing: code = %d, want 404", rec.Code)
+	}
+	if rec := do("GET", "/dlq/a"); rec.Code != http.StatusOK {
+		t.Errorf("get: code = %d", rec.Code)
+	}
+
+	// 按失败时间重新写库，遇到失败停止
+	rec = do("POST", "/dlq/redrive")
+	var resp struct {
+		Redriven []dlq.Batch `json:"redriven"`
+		Failed   string      `json:"failed"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusBadGateway || len(resp.Redriven) != 1 || resp.Redriven[0].ID != "a" || resp.Failed != "bad" {
+		t.Errorf("redrive all: code = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if rec := do("POST", "/dlq/c/redrive"); rec.Code != http.StatusOK || len(dl.batches) != 1 {
+		t.Errorf("redrive c: code = %d, remaining = %+v", rec.Code, dl.batches)
+	}
+
+	if rec := do("POST", "/dlq/purge"); rec.Code != http.StatusBadRequest {
+		t.Errorf("purge without filter: code = %d, want 400", rec.Code)
+	}
+	if rec := do("POST", "/dlq/purge?before=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("purge with bad before: code = %d, want 400", rec.Code)
+	}
+	if rec := do("POST", "/dlq/purge?all=true"); rec.Code != http.StatusOK || len(dl.batches) != 0 {
+		t.Errorf("purge all: code = %d, remaining = %+v", rec.Code, dl.batches)
+	}
+
+	// 未启用死信队列
+	h = NewAdminServer("127.0.0.1:0", "", &fakeRegistry{}, nil, logger).Handler()
+	if rec := do("GET", "/dlq"); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled: code = %d, want 404", rec.Code)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+
+	"github.com/wwswwsuns/ztelem/internal/dlq"
 )
 
 // PrometheusServer Prometheus指标服务器
@@ -22,6 +24,32 @@ type PrometheusServer struct {
 	systemGoroutines prometheus.Gauge
 	grpcConnections  *prometheus.GaugeVec
 	zombieRatio      prometheus.Gauge
+	collectionRounds *prometheus.CounterVec
+	collectionPackets prometheus.Counter
+	activeRounds     prometheus.Gauge
+	counterRateEvents *prometheus.CounterVec
+	accessRejections *prometheus.CounterVec
+	publishErrors    *prometheus.CounterVec
+	flowControl      *prometheus.CounterVec
+	rateLimited      *prometheus.CounterVec
+	devices          *prometheus.GaugeVec
+	duplicateStreams prometheus.Counter
+	dataGaps         *prometheus.CounterVec
+	lateSeries       prometheus.Gauge
+	reqIDEvents      *prometheus.CounterVec
+	clockSkew        *prometheus.GaugeVec
+	clockSkewActions *prometheus.CounterVec
+	walBytes         prometheus.Gauge
+	walPending       prometheus.Gauge
+	walSpilling      prometheus.Gauge
+	walCheckpoints   *prometheus.CounterVec
+	dlqBatches       *prometheus.GaugeVec
+	dlqRows          *prometheus.GaugeVec
+	dlqBytes         *prometheus.GaugeVec
+	dlqRowEvents     *prometheus.CounterVec
+	bufferBytes      *prometheus.GaugeVec
+	bufferMemLimit   prometheus.Gauge
+	bufferOverflow   *prometheus.CounterVec
 }
 
 // NewPrometheusServer 创建Prometheus指标服务器
@@ -80,6 +108,206 @@ func NewPrometheusServer(port int, logger *logrus.Logger) *PrometheusServer {
 		},
 	)
 
+	collectionRounds := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_collection_rounds_total",
+			Help: "采样轮次数 (complete/incomplete)",
+		},
+		[]string{"status"},
+	)
+
+	collectionPackets := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "telemetry_collection_packets_total",
+			Help: "按collection_id跟踪的报文数",
+		},
+	)
+
+	activeRounds := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "telemetry_collection_active_rounds",
+			Help: "尚未结束的采样轮次数",
+		},
+	)
+
+	counterRateEvents := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_counter_rate_events_total",
+			Help: "计数器速率计算事件 (computed/wrap/reset/restart)",
+		},
+		[]string{"event"},
+	)
+
+	accessRejections := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_access_rejections_total",
+			Help: "访问控制检查未通过次数 (peer_denied/unknown_device/source_mismatch/cert_mismatch)",
+		},
+		[]string{"reason"},
+	)
+
+	publishErrors := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_publish_errors_total",
+			Help: "Publish错误响应数 (parse_error/unknown_sensor_path/access_denied/rate_limited/clock_skew/buffer_full/internal)",
+		},
+		[]string{"reason"},
+	)
+
+	flowControl := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_flow_control_total",
+			Help: "缓冲区超出预算时的流控动作 (delayed/withheld)",
+		},
+		[]string{"action"},
+	)
+
+	rateLimited := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_rate_limited_total",
+			Help: "超出限速的报文数 (scope: peer/device/sensor_path, action: drop/sample/reject_stream)",
+		},
+		[]string{"scope", "action"},
+	)
+
+	devices := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_devices",
+			Help: "设备登记中的设备数 (known/reporting)",
+		},
+		[]string{"state"},
+	)
+
+	duplicateStreams := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "telemetry_duplicate_streams_total",
+			Help: "同一设备同一订阅同时出现在多条流上的次数",
+		},
+	)
+
+	dataGaps := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_data_gaps_total",
+			Help: "超过上报周期未收到数据而打开的缺口数",
+		},
+		[]string{"sensor_path"},
+	)
+
+	lateSeries := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "telemetry_late_series",
+			Help: "当前迟到的 system_id + sensor_path 数",
+		},
+	)
+
+	reqIDEvents := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_reqid_events_total",
+			Help: "按设备统计的ReqId异常 (lost/duplicate/exact_duplicate/dropped/reordered/reset/retry)",
+		},
+		[]string{"system_id", "event"},
+	)
+
+	clockSkew := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_clock_skew_seconds",
+			Help: "设备时钟偏差（msg_timestamp - 接收时间，正值表示设备时间超前）",
+		},
+		[]string{"system_id"},
+	)
+
+	clockSkewActions := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_clock_skew_actions_total",
+			Help: "时钟偏差超限的处理次数 (replaced/rejected)",
+		},
+		[]string{"action"},
+	)
+
+	walBytes := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "telemetry_wal_bytes",
+			Help: "预写日志所有段的总大小",
+		},
+	)
+
+	walPending := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "telemetry_wal_pending_segments",
+			Help: "等待载入写库的WAL段数",
+		},
+	)
+
+	walSpilling := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "telemetry_wal_spilling",
+			Help: "是否处于溢出模式（1表示写库失败，新数据只写WAL）",
+		},
+	)
+
+	walCheckpoints := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_wal_checkpoints_total",
+			Help: "WAL检查点次数 (ok/failed)",
+		},
+		[]string{"result"},
+	)
+
+	dlqBatches := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_dlq_batches",
+			Help: "死信队列中的批次数 (按表)",
+		},
+		[]string{"table"},
+	)
+
+	dlqRows := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_dlq_rows",
+			Help: "死信队列中的行数 (按表)",
+		},
+		[]string{"table"},
+	)
+
+	dlqBytes := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_dlq_bytes",
+			Help: "死信文件大小 (按表)",
+		},
+		[]string{"table"},
+	)
+
+	dlqRowEvents := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_dlq_rows_total",
+			Help: "重试耗尽批次的行数 (stored写入死信队列/dropped丢弃/redriven重新写库成功)",
+		},
+		[]string{"event"},
+	)
+
+	bufferBytes := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_buffer_bytes",
+			Help: "缓冲区估算内存 (含等待写库的批次)",
+		},
+		[]string{"type"},
+	)
+
+	bufferMemLimit := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "telemetry_buffer_memory_limit_bytes",
+			Help: "缓冲区合计内存上限 (memory.max_memory_usage)，0为不限制",
+		},
+	)
+
+	bufferOverflow := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_buffer_overflow_rows_total",
+			Help: "缓冲区超出上限时处理的行数 (blocked/timeout/dropped/evicted/spilled)",
+		},
+		[]string{"type", "action"},
+	)
+
 	prometheus.MustRegister(
 		dbPoolConnections,
 		recordsProcessed,
@@ -88,6 +316,32 @@ func NewPrometheusServer(port int, logger *logrus.Logger) *PrometheusServer {
 		systemGoroutines,
 		grpcConnections,
 		zombieRatio,
+		collectionRounds,
+		collectionPackets,
+		activeRounds,
+		counterRateEvents,
+		accessRejections,
+		publishErrors,
+		flowControl,
+		rateLimited,
+		devices,
+		duplicateStreams,
+		dataGaps,
+		lateSeries,
+		reqIDEvents,
+		clockSkew,
+		clockSkewActions,
+		walBytes,
+		walPending,
+		walSpilling,
+		walCheckpoints,
+		dlqBatches,
+		dlqRows,
+		dlqBytes,
+		dlqRowEvents,
+		bufferBytes,
+		bufferMemLimit,
+		bufferOverflow,
 	)
 
 	mux := http.NewServeMux()
@@ -115,6 +369,28 @@ func NewPrometheusServer(port int, logger *logrus.Logger) *PrometheusServer {
 <li><strong>telemetry_zombie_ratio</strong> - 僵尸连接比例</li>
 <li><strong>telemetry_system_memory_bytes</strong> - 内存使用</li>
 <li><strong>telemetry_system_goroutines</strong> - Goroutine数量</li>
+<li><strong>telemetry_collection_rounds_total</strong> - 采样轮次 (complete/incomplete，incomplete表示丢包)</li>
+<li><strong>telemetry_counter_rate_events_total</strong> - 计数器速率计算 (computed/wrap/reset/restart)</li>
+<li><strong>telemetry_access_rejections_total</strong> - 访问控制拒绝次数 (按原因)</li>
+<li><strong>telemetry_publish_errors_total</strong> - Publish错误响应数 (按原因)</li>
+<li><strong>telemetry_rate_limited_total</strong> - 超出限速的报文数 (按维度与策略)</li>
+<li><strong>telemetry_flow_control_total</strong> - 缓冲区超出预算时的流控动作 (delayed/withheld)</li>
+<li><strong>telemetry_devices</strong> - 设备数 (known/reporting)</li>
+<li><strong>telemetry_duplicate_streams_total</strong> - 设备重复流次数</li>
+<li><strong>telemetry_data_gaps_total</strong> - 数据缺口数 (按sensor_path)</li>
+<li><strong>telemetry_late_series</strong> - 当前迟到的序列数</li>
+<li><strong>telemetry_reqid_events_total</strong> - ReqId跳号/重复/重置 (按设备)</li>
+<li><strong>telemetry_clock_skew_seconds</strong> - 设备时钟偏差 (按设备)</li>
+<li><strong>telemetry_clock_skew_actions_total</strong> - 时钟偏差超限的处理 (replaced/rejected)</li>
+<li><strong>telemetry_wal_bytes</strong> - 预写日志大小</li>
+<li><strong>telemetry_wal_pending_segments</strong> - 等待载入写库的WAL段数</li>
+<li><strong>telemetry_wal_spilling</strong> - 数据库不可用、数据只写WAL时为1</li>
+<li><strong>telemetry_wal_checkpoints_total</strong> - WAL检查点 (ok/failed)</li>
+<li><strong>telemetry_dlq_batches</strong> / <strong>telemetry_dlq_rows</strong> / <strong>telemetry_dlq_bytes</strong> - 死信队列积压 (按表)</li>
+<li><strong>telemetry_dlq_rows_total</strong> - 写库重试耗尽的行数 (stored/dropped/redriven)</li>
+<li><strong>telemetry_buffer_bytes</strong> - 缓冲区估算内存 (含等待写库的批次)</li>
+<li><strong>telemetry_buffer_memory_limit_bytes</strong> - 缓冲区合计内存上限</li>
+<li><strong>telemetry_buffer_overflow_rows_total</strong> - 缓冲区超出上限时处理的行数 (blocked/timeout/dropped/evicted/spilled)</li>
 </ul>
 </body></html>`))
 	})
@@ -136,6 +412,32 @@ func NewPrometheusServer(port int, logger *logrus.Logger) *PrometheusServer {
 		systemGoroutines: systemGoroutines,
 		grpcConnections:  grpcConnections,
 		zombieRatio:      zombieRatio,
+		collectionRounds: collectionRounds,
+		collectionPackets: collectionPackets,
+		activeRounds:     activeRounds,
+		counterRateEvents: counterRateEvents,
+		accessRejections: accessRejections,
+		publishErrors:    publishErrors,
+		flowControl:      flowControl,
+		rateLimited:      rateLimited,
+		devices:          devices,
+		duplicateStreams: duplicateStreams,
+		dataGaps:         dataGaps,
+		lateSeries:       lateSeries,
+		reqIDEvents:      reqIDEvents,
+		clockSkew:        clockSkew,
+		clockSkewActions: clockSkewActions,
+		walBytes:         walBytes,
+		walPending:       walPending,
+		walSpilling:      walSpilling,
+		walCheckpoints:   walCheckpoints,
+		dlqBatches:       dlqBatches,
+		dlqRows:          dlqRows,
+		dlqBytes:         dlqBytes,
+		dlqRowEvents:     dlqRowEvents,
+		bufferBytes:      bufferBytes,
+		bufferMemLimit:   bufferMemLimit,
+		bufferOverflow:   bufferOverflow,
 	}
 
 	return ps
@@ -197,3 +499,128 @@ func (ps *PrometheusServer) UpdateGRPCConnections(state string, count float64) {
 func (ps *PrometheusServer) UpdateZombieRatio(percent float64) {
 	ps.zombieRatio.Set(percent)
 }
+
+// UpdateCollectionRounds 更新采样轮次统计（增量）
+func (ps *PrometheusServer) UpdateCollectionRounds(status string, count float64) {
+	ps.collectionRounds.WithLabelValues(status).Add(count)
+}
+
+// UpdateCollectionPackets 更新按轮次跟踪的报文数（增量）
+func (ps *PrometheusServer) UpdateCollectionPackets(count float64) {
+	ps.collectionPackets.Add(count)
+}
+
+// UpdateActiveCollectionRounds 更新未结束的采样轮次数
+func (ps *PrometheusServer) UpdateActiveCollectionRounds(count float64) {
+	ps.activeRounds.Set(count)
+}
+
+// UpdateCounterRateEvents 更新计数器速率计算事件（增量）
+func (ps *PrometheusServer) UpdateCounterRateEvents(event string, count float64) {
+	ps.counterRateEvents.WithLabelValues(event).Add(count)
+}
+
+// UpdateAccessRejections 更新访问控制拒绝次数（增量）
+func (ps *PrometheusServer) UpdateAccessRejections(reason string, count float64) {
+	ps.accessRejections.WithLabelValues(reason).Add(count)
+}
+
+// UpdatePublishErrors 更新Publish错误响应数（增量）
+func (ps *PrometheusServer) UpdatePublishErrors(reason string, count float64) {
+	ps.publishErrors.WithLabelValues(reason).Add(count)
+}
+
+// UpdateFlowControl 更新流控动作次数（增量）
+func (ps *PrometheusServer) UpdateFlowControl(action string, count float64) {
+	ps.flowControl.WithLabelValues(action).Add(count)
+}
+
+// UpdateRateLimited 更新超出限速的报文数（增量）
+func (ps *PrometheusServer) UpdateRateLimited(scope, action string, count float64) {
+	ps.rateLimited.WithLabelValues(scope, action).Add(count)
+}
+
+// UpdateDevices 更新设备数
+func (ps *PrometheusServer) UpdateDevices(known, reporting float64) {
+	ps.devices.WithLabelValues("known").Set(known)
+	ps.devices.WithLabelValues("reporting").Set(reporting)
+}
+
+// UpdateDuplicateStreams 更新设备重复流次数（增量）
+func (ps *PrometheusServer) UpdateDuplicateStreams(count float64) {
+	ps.duplicateStreams.Add(count)
+}
+
+// UpdateDataGaps 更新数据缺口数（增量）
+func (ps *PrometheusServer) UpdateDataGaps(sensorPath string, count float64) {
+	ps.dataGaps.WithLabelValues(sensorPath).Add(count)
+}
+
+// UpdateLateSeries 更新当前迟到的序列数
+func (ps *PrometheusServer) UpdateLateSeries(count float64) {
+	ps.lateSeries.Set(count)
+}
+
+// UpdateReqIDEvents 更新设备的ReqId异常数（增量）
+func (ps *PrometheusServer) UpdateReqIDEvents(systemID, event string, count float64) {
+	ps.reqIDEvents.WithLabelValues(systemID, event).Add(count)
+}
+
+// UpdateClockSkew 更新各设备的时钟偏差（秒），不在skews中的设备不再导出
+func (ps *PrometheusServer) UpdateClockSkew(skews map[string]float64) {
+	ps.clockSkew.Reset()
+	for systemID, skew := range skews {
+		ps.clockSkew.WithLabelValues(systemID).Set(skew)
+	}
+}
+
+// UpdateClockSkewActions 更新时钟偏差超限的处理次数（增量）
+func (ps *PrometheusServer) UpdateClockSkewActions(action string, count float64) {
+	ps.clockSkewActions.WithLabelValues(action).Add(count)
+}
+
+// UpdateWAL 更新预写日志大小、积压段数与溢出状态
+func (ps *PrometheusServer) UpdateWAL(bytes, pendingSegments float64, spilling bool) {
+	ps.walBytes.Set(bytes)
+	ps.walPending.Set(pendingSegments)
+	if spilling {
+		ps.walSpilling.Set(1)
+	} else {
+		ps.walSpilling.Set(0)
+	}
+}
+
+// UpdateWALCheckpoints 更新WAL检查点次数（增量）
+func (ps *PrometheusServer) UpdateWALCheckpoints(result string, count float64) {
+	ps.walCheckpoints.WithLabelValues(result).Add(count)
+}
+
+// UpdateDeadLetters 更新死信队列各表的积压，不在tables中的表不再导出
+func (ps *PrometheusServer) UpdateDeadLetters(tables map[string]dlq.TableStats) {
+	ps.dlqBatches.Reset()
+	ps.dlqRows.Reset()
+	ps.dlqBytes.Reset()
+	for table, st := range tables {
+		ps.dlqBatches.WithLabelValues(table).Set(float64(st.Batches))
+		ps.dlqRows.WithLabelValues(table).Set(float64(st.Rows))
+		ps.dlqBytes.WithLabelValues(table).Set(float64(st.Bytes))
+	}
+}
+
+// UpdateDeadLetterRows 更新重试耗尽批次的行数（增量）
+func (ps *PrometheusServer) UpdateDeadLetterRows(event string, count float64) {
+	ps.dlqRowEvents.WithLabelValues(event).Add(count)
+}
+
+// UpdateBufferMemory 更新各缓冲区估算内存与合计上限
+func (ps *PrometheusServer) UpdateBufferMemory(bytes map[string]int64, limit float64) {
+	for bufferType, n := range bytes {
+		ps.bufferBytes.WithLabelValues(bufferType).Set(float64(n))
+	}
+	ps.bufferMemLimit.Set(limit)
+}
+
+// UpdateBufferOverflow 更新缓冲区超出上限时处理的行数（增量）
+func (ps *PrometheusServer) UpdateBufferOverflow(bufferType, action string, count float64) {
+	ps.bufferOverflow.WithLabelValues(bufferType, action).Add(count)
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wwswwsuns/ztelem/internal/capture"
+)
+
+var updateGolden = flag.Bool("update", false, "重新生成 testdata/*.zcap.golden")
+
+// TestCaptureFixtures 解析 testdata 下的抓包文件，结果摘要与 .golden 文件比对。
+// 现场抓取的报文放入 testdata 后执行 go test -run TestCaptureFixtures -update 生成摘要。
+func TestCaptureFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*"+capture.FileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("testdata 中没有抓包文件")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			records, err := capture.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := newTestParser()
+			var b strings.Builder
+			for _, rec := range records {
+				var result *ParseResult
+				var err error
+				if data := rec.Args.GetData(); len(data) > 0 {
+					result, err = p.ParseTelemetryData(data)
+				} else {
+					result, err = p.ParseJSONData(rec.Args.GetJsonData())
+				}
+				if err != nil {
+					fmt.Fprintf(&b, "req_id=%d error=%v\n", rec.Args.ReqId, err)
+					continue
+				}
+				fmt.Fprintf(&b, "req_id=%d system_id=%s sensor_path=%s rows=%d\n",
+					rec.Args.ReqId, result.SystemID, result.SensorPath, result.RowCount())
+			}
+
+			golden := file + ".golden"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(b.String()), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("读取golden文件失败（可用 -update 生成）: %v", err)
+			}
+			if b.String() != string(want) {
+				t.Errorf("解析结果与 %s 不一致:\ngot:\n%s\nwant:\n%s", golden, b.String(), want)
+			}
+		})
+	}
+}
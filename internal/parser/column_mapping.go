@@ -0,0 +1,323 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// 声明式列映射 (parser.mapping_file)
+//
+// 映射文件按sensor_path声明源消息、行路径、目标表、键和列，列取值可做与helpers.go相同的转换。
+// 加载时对照proto描述编译为解析计划，报文按计划动态解码并生成 MappedRow，
+// 新增列只需修改映射文件和目标表，无需发布新版本。映射的sensor_path优先于其他解析方式。
+
+// columnTransforms 映射文件支持的转换（空为原值）
+var columnTransforms = map[string]bool{
+	"":                   true,
+	"bytes_to_mb":        true,
+	"nanos_to_seconds":   true,
+	"nanos_to_timestamp": true,
+	"uptime":             true,
+	"enum":               true,
+	"admin_status":       true,
+	"oper_status":        true,
+	"utilization":        true,
+}
+
+// tablePlan 单个sensor_path编译后的解析计划
+type tablePlan struct {
+	sensorPath string
+	message    protoreflect.MessageDescriptor
+	rowPath    []protoreflect.FieldDescriptor // 最后一个为重复消息字段
+	table      string
+	columns    []string // 列名，与fields一一对应
+	fields     []columnField
+	keyIdx     []int
+}
+
+// columnField 单列的字段路径与转换
+type columnField struct {
+	path      []protoreflect.FieldDescriptor
+	fromRoot  bool
+	transform string
+}
+
+// compileTablePlan 对照消息描述编译映射
+func compileTablePlan(m config.ColumnMapping, resolver filesChain) (*tablePlan, error) {
+	if m.SensorPath == "" || m.Message == "" {
+		return nil, fmt.Errorf("sensor_path和message不能为空")
+	}
+	if !tableNamePattern.MatchString(m.Table) {
+		return nil, fmt.Errorf("非法表名 %q", m.Table)
+	}
+	if len(m.Columns) == 0 {
+		return nil, fmt.Errorf("未定义列")
+	}
+
+	desc, err := resolver.FindDescriptorByName(protoreflect.FullName(m.Message))
+	if err != nil {
+		return nil, fmt.Errorf("消息 %s 未注册: %v", m.Message, err)
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s 不是消息类型", m.Message)
+	}
+
+	plan := &tablePlan{sensorPath: m.SensorPath, message: md, table: m.Table}
+
+	rowMsg := md
+	if m.RowPath != "" {
+		path, err := resolveFieldPath(md, m.RowPath)
+		if err != nil {
+			return nil, fmt.Errorf("row_path %s: %v", m.RowPath, err)
+		}
+		last := path[len(path)-1]
+		if !last.IsList() || last.Message() == nil {
+			return nil, fmt.Errorf("row_path %s 不是重复消息字段", m.RowPath)
+		}
+		plan.rowPath = path
+		rowMsg = last.Message()
+	}
+
+	seen := make(map[string]int, len(m.Columns))
+	for _, col := range m.Columns {
+		if !tableNamePattern.MatchString(col.Column) || col.Column == "timestamp" || col.Column == "system_id" {
+			return nil, fmt.Errorf("非法列名 %q", col.Column)
+		}
+		if _, dup := seen[col.Column]; dup {
+			return nil, fmt.Errorf("列 %s 重复", col.Column)
+		}
+		if !columnTransforms[col.Transform] {
+			return nil, fmt.Errorf("列 %s: 不支持的转换 %q", col.Column, col.Transform)
+		}
+
+		base, fieldPath := rowMsg, col.Field
+		fromRoot := strings.HasPrefix(fieldPath, "/")
+		if fromRoot {
+			base, fieldPath = md, strings.TrimPrefix(fieldPath, "/")
+		}
+		path, err := resolveFieldPath(base, fieldPath)
+		if err != nil {
+			return nil, fmt.Errorf("列 %s: %v", col.Column, err)
+		}
+		if last := path[len(path)-1]; last.IsList() || last.IsMap() || last.Message() != nil {
+			return nil, fmt.Errorf("列 %s: 字段 %s 不是标量", col.Column, col.Field)
+		}
+
+		seen[col.Column] = len(plan.columns)
+		plan.columns = append(plan.columns, col.Column)
+		plan.fields = append(plan.fields, columnField{path: path, fromRoot: fromRoot, transform: col.Transform})
+	}
+
+	for _, key := range m.Keys {
+		idx, ok := seen[key]
+		if !ok {
+			return nil, fmt.Errorf("键 %s 不在列定义中", key)
+		}
+		plan.keyIdx = append(plan.keyIdx, idx)
+	}
+	return plan, nil
+}
+
+// resolveFieldPath 按 a/b/c 解析字段路径，中间字段必须是单值消息（最后一个可以是重复字段）
+func resolveFieldPath(md protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, error) {
+	if path == "" {
+		return nil, fmt.Errorf("字段路径为空")
+	}
+	parts := strings.Split(path, "/")
+	fds := make([]protoreflect.FieldDescriptor, 0, len(parts))
+	for i, part := range parts {
+		fd := md.Fields().ByName(protoreflect.Name(part))
+		if fd == nil {
+			return nil, fmt.Errorf("%s 中不存在字段 %s", md.FullName(), part)
+		}
+		fds = append(fds, fd)
+		if i == len(parts)-1 {
+			break
+		}
+		if fd.IsList() || fd.IsMap() || fd.Message() == nil {
+			return nil, fmt.Errorf("字段 %s 不是单值消息", part)
+		}
+		md = fd.Message()
+	}
+	return fds, nil
+}
+
+// parseMappedData 按映射文件解码GPB数据，成功解析返回true
+func (p *TelemetryParser) parseMappedData(msg *zteTelemetry.Telemetry, result *ParseResult) bool {
+	plan, ok := p.descriptors.TablePlan(msg.SensorPath)
+	if !ok || len(msg.DataGpb) == 0 {
+		return false
+	}
+
+	before := len(result.MappedRows)
+	for i, dataGpb := range msg.DataGpb {
+		root := dynamicpb.NewMessage(plan.message)
+		if err := proto.Unmarshal(dataGpb.GetContent(), root); err != nil {
+			p.logger.Warnf("按映射解码第 %d 个GPB数据失败: sensor_path=%s, %v", i+1, msg.SensorPath, err)
+			continue
+		}
+
+		timestamp := time.UnixMilli(int64(msg.MsgTimestamp))
+		if dataGpb.GetTimestamp() > 0 {
+			timestamp = time.UnixMilli(int64(dataGpb.GetTimestamp()))
+		}
+
+		for _, row := range plan.rows(root) {
+			values := plan.values(root, row)
+			result.MappedRows = append(result.MappedRows, models.MappedRow{
+				Timestamp:  timestamp,
+				SystemID:   msg.SystemId,
+				SensorPath: msg.SensorPath,
+				Table:      plan.table,
+				Columns:    plan.columns,
+				Values:     values,
+				Key:        plan.rowKey(values),
+			})
+		}
+	}
+
+	p.logger.Debugf("✅ 映射解析: sensor_path=%s, table=%s, rows=%d",
+		msg.SensorPath, plan.table, len(result.MappedRows)-before)
+	return true
+}
+
+// rows 返回行消息：无row_path时为消息本身，否则为重复字段的每个元素
+func (plan *tablePlan) rows(root protoreflect.Message) []protoreflect.Message {
+	if len(plan.rowPath) == 0 {
+		return []protoreflect.Message{root}
+	}
+	m := root
+	for _, fd := range plan.rowPath[:len(plan.rowPath)-1] {
+		if !m.Has(fd) {
+			return nil
+		}
+		m = m.Get(fd).Message()
+	}
+	list := m.Get(plan.rowPath[len(plan.rowPath)-1]).List()
+	rows := make([]protoreflect.Message, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		rows = append(rows, list.Get(i).Message())
+	}
+	return rows
+}
+
+// values 按列取值，缺失字段为nil
+func (plan *tablePlan) values(root, row protoreflect.Message) []interface{} {
+	values := make([]interface{}, len(plan.fields))
+	for i, f := range plan.fields {
+		m := row
+		if f.fromRoot {
+			m = root
+		}
+		values[i] = f.value(m)
+	}
+	return values
+}
+
+// rowKey 键列取值拼接
+func (plan *tablePlan) rowKey(values []interface{}) string {
+	parts := make([]string, len(plan.keyIdx))
+	for i, idx := range plan.keyIdx {
+		parts[i] = leafToString(values[idx])
+	}
+	return strings.Join(parts, "|")
+}
+
+// value 沿字段路径取值并转换
+func (f columnField) value(m protoreflect.Message) interface{} {
+	for _, fd := range f.path[:len(f.path)-1] {
+		if !m.Has(fd) {
+			return nil
+		}
+		m = m.Get(fd).Message()
+	}
+	fd := f.path[len(f.path)-1]
+	if fd.HasPresence() && !m.Has(fd) {
+		return nil
+	}
+	return transformColumnValue(f.transform, fd, m.Get(fd))
+}
+
+// transformColumnValue 按转换名处理字段值，复用helpers.go中的转换
+func transformColumnValue(transform string, fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch transform {
+	case "bytes_to_mb":
+		return bytesToMB(uintColumnValue(fd, v))
+	case "nanos_to_seconds":
+		return nanosToSeconds(uintColumnValue(fd, v))
+	case "nanos_to_timestamp":
+		return nanosToTimestamp(uintColumnValue(fd, v))
+	case "uptime":
+		return formatUptime(uint32(uintColumnValue(fd, v)))
+	case "enum":
+		return enumColumnValue(fd, v)
+	case "admin_status":
+		return adminStatusToString(int32(uintColumnValue(fd, v)))
+	case "oper_status":
+		return operStatusToString(int32(uintColumnValue(fd, v)))
+	case "utilization":
+		return utilizationToNumeric(float32(floatColumnValue(fd, v)))
+	}
+	return nativeColumnValue(fd, v)
+}
+
+// nativeColumnValue 不转换：标量按原类型写入，枚举写入编号
+func nativeColumnValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return int32(v.Enum())
+	case protoreflect.BytesKind:
+		return leafToString(v.Bytes())
+	case protoreflect.FloatKind:
+		return float32(v.Float())
+	}
+	return v.Interface()
+}
+
+// enumColumnValue 枚举转名称，未知编号按数值字符串
+func enumColumnValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	if fd.Kind() != protoreflect.EnumKind {
+		return leafToString(v.Interface())
+	}
+	if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+		return string(ev.Name())
+	}
+	return leafToString(int32(v.Enum()))
+}
+
+// uintColumnValue 整数字段按uint64取值
+func uintColumnValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) uint64 {
+	switch fd.Kind() {
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return v.Uint()
+	case protoreflect.EnumKind:
+		return uint64(v.Enum())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return uint64(v.Float())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return uint64(v.Int())
+	}
+	return 0
+}
+
+// floatColumnValue 数值字段按float64取值
+func floatColumnValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) float64 {
+	switch fd.Kind() {
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(v.Int())
+	}
+	return float64(uintColumnValue(fd, v))
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+)
+
+const testColumnMappingFile = `
+mappings:
+  - sensor_path: "zte-qos:queues"
+    message: test.qos.QosInfo
+    row_path: queues
+    table: qos_queues
+    keys: [if_name, queue_id]
+    columns:
+      - {column: if_name, field: /name}
+      - {column: queue_id, field: id}
+      - {column: out_mb, field: out_octets, transform: bytes_to_mb}
+      - {column: state, field: state, transform: enum}
+      - {column: uptime, field: uptime, transform: uptime}
+  - sensor_path: "zte-qos:bad"
+    message: test.qos.QosInfo
+    table: qos_bad
+    columns:
+      - {column: x, field: name, transform: no_such_transform}
+`
+
+// writeTestQosDescriptorSet 写入 test.qos.QosInfo{name, repeated Queue queues}
+func writeTestQosDescriptorSet(t *testing.T, dir string) {
+	t.Helper()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("test/qos.proto"),
+		Package: proto.String("test.qos"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("QueueState"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("QUEUE_DOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("QUEUE_UP"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Queue"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("id"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_UINT32.Enum(), Label: optional},
+					{Name: proto.String("out_octets"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_UINT64.Enum(), Label: optional},
+					{Name: proto.String("state"), Number: proto.Int32(3), Type: descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(), TypeName: proto.String(".test.qos.QueueState"), Label: optional},
+					{Name: proto.String("uptime"), Number: proto.Int32(4), Type: descriptorpb.FieldDescriptorProto_TYPE_UINT32.Enum(), Label: optional},
+				},
+			},
+			{
+				Name: proto.String("QosInfo"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("name"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: optional},
+					{Name: proto.String("queues"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".test.qos.Queue"), Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()},
+				},
+			},
+		},
+	}}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("proto.Marshal(set): %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "qos.pb"), data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestParseTelemetryData_ColumnMapping(t *testing.T) {
+	dir := t.TempDir()
+	writeTestQosDescriptorSet(t, dir)
+	mappingFile := filepath.Join(dir, "mappings.yaml")
+	if err := os.WriteFile(mappingFile, []byte(testColumnMappingFile), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	p := NewTelemetryParserWithConfig(logger, config.ParserConfig{DescriptorDir: dir, MappingFile: mappingFile})
+
+	if _, ok := p.descriptors.TablePlan("zte-qos:bad"); ok {
+		t.Error("mapping with unknown transform should be skipped")
+	}
+
+	desc, err := p.descriptors.FindDescriptorByName("test.qos.QosInfo")
+	if err != nil {
+		t.Fatalf("FindDescriptorByName: %v", err)
+	}
+	md := desc.(protoreflect.MessageDescriptor)
+	info := dynamicpb.NewMessage(md)
+	info.Set(md.Fields().ByName("name"), protoreflect.ValueOfString("gei-1/2/1"))
+	queues := info.Mutable(md.Fields().ByName("queues")).List()
+	qd := md.Fields().ByName("queues").Message()
+	for i := uint32(0); i < 2; i++ {
+		q := dynamicpb.NewMessage(qd)
+		q.Set(qd.Fields().ByName("id"), protoreflect.ValueOfUint32(i))
+		q.Set(qd.Fields().ByName("out_octets"), protoreflect.ValueOfUint64(uint64(i+1)*3*1024*1024))
+		q.Set(qd.Fields().ByName("state"), protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)))
+		q.Set(qd.Fields().ByName("uptime"), protoreflect.ValueOfUint32(90061))
+		queues.Append(protoreflect.ValueOfMessage(q))
+	}
+	content, err := proto.Marshal(info)
+	if err != nil {
+		t.Fatalf("proto.Marshal(info): %v", err)
+	}
+	data, err := proto.Marshal(&zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   "zte-qos:queues",
+		MsgTimestamp: 1700000000000,
+		DataGpb:      []*zteTelemetry.NotificationGpb{{Content: content}},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+
+	result, err := p.ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.MappedRows) != 2 {
+		t.Fatalf("len(MappedRows) = %d, want 2", len(result.MappedRows))
+	}
+
+	row := result.MappedRows[1]
+	if row.Table != "qos_queues" || row.SystemID != "dev-1" || row.Timestamp.UnixMilli() != 1700000000000 {
+		t.Errorf("row identity = %+v", row)
+	}
+	if row.Key != "gei-1/2/1|1" {
+		t.Errorf("Key = %q, want gei-1/2/1|1", row.Key)
+	}
+	want := []interface{}{"gei-1/2/1", uint32(1), uint64(6), "QUEUE_UP", "01:01:01:01"}
+	for i, col := range []string{"if_name", "queue_id", "out_mb", "state", "uptime"} {
+		if row.Columns[i] != col {
+			t.Errorf("Columns[%d] = %s, want %s", i, row.Columns[i], col)
+		}
+		if row.Values[i] != want[i] {
+			t.Errorf("%s = %#v, want %#v", col, row.Values[i], want[i])
+		}
+	}
+	if len(result.GenericMetrics) != 0 {
+		t.Errorf("mapped sensor_path should not produce generic metrics")
+	}
+}
@@ -0,0 +1,281 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+// 运行时proto描述注册表
+//
+// 从 parser.descriptor_dir 加载 FileDescriptorSet（.pb/.desc/.protoset/.binpb）
+// 或 .proto 文件（需要protoc），新消息无需重新生成 proto/ 下的Go代码即可按proto_path解码。
+// 查找时优先使用加载的描述，找不到再回退到编译进二进制的protoregistry.GlobalFiles。
+// parser.mapping_file 的列映射在描述加载后编译，随描述一起重新加载。
+
+// descriptorSetExts FileDescriptorSet文件扩展名
+var descriptorSetExts = map[string]bool{
+	".pb":       true,
+	".desc":     true,
+	".protoset": true,
+	".binpb":    true,
+}
+
+// tableNamePattern 映射目标表名（写入COPY标识符前校验）
+var tableNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// DescriptorRegistry 运行时加载的proto描述及sensor_path映射
+type DescriptorRegistry struct {
+	logger *logrus.Logger
+
+	mu       sync.RWMutex
+	files    *protoregistry.Files
+	mappings map[string]config.SensorMapping
+	plans    map[string]*tablePlan
+}
+
+// NewDescriptorRegistry 创建空的描述注册表
+func NewDescriptorRegistry(logger *logrus.Logger) *DescriptorRegistry {
+	return &DescriptorRegistry{
+		logger:   logger,
+		files:    new(protoregistry.Files),
+		mappings: make(map[string]config.SensorMapping),
+		plans:    make(map[string]*tablePlan),
+	}
+}
+
+// Load 按配置加载描述目录和映射，成功后整体替换；目录不可读时保留原有注册表
+func (r *DescriptorRegistry) Load(cfg config.ParserConfig) error {
+	files := new(protoregistry.Files)
+	if cfg.DescriptorDir != "" {
+		sets, err := r.readDescriptorDir(cfg.DescriptorDir, cfg.ProtocPath)
+		if err != nil {
+			return err
+		}
+		files = r.buildFiles(sets)
+	}
+
+	mappings := make(map[string]config.SensorMapping, len(cfg.SensorMappings))
+	for _, m := range cfg.SensorMappings {
+		if m.SensorPath == "" {
+			continue
+		}
+		if m.Table != "" && !tableNamePattern.MatchString(m.Table) {
+			r.logger.Warnf("忽略sensor_path映射 %s: 非法表名 %q", m.SensorPath, m.Table)
+			continue
+		}
+		mappings[m.SensorPath] = m
+	}
+
+	plans := make(map[string]*tablePlan)
+	if cfg.MappingFile != "" {
+		mappingFile, err := config.LoadColumnMappings(cfg.MappingFile)
+		if err != nil {
+			return err
+		}
+		resolver := filesChain{files, protoregistry.GlobalFiles}
+		for _, m := range mappingFile.Mappings {
+			plan, err := compileTablePlan(m, resolver)
+			if err != nil {
+				r.logger.Warnf("忽略列映射 %s: %v", m.SensorPath, err)
+				continue
+			}
+			plans[m.SensorPath] = plan
+		}
+	}
+
+	r.mu.Lock()
+	r.files = files
+	r.mappings = mappings
+	r.plans = plans
+	r.mu.Unlock()
+
+	r.logger.Infof("proto描述加载完成: 文件=%d, sensor_path映射=%d, 列映射=%d", files.NumFiles(), len(mappings), len(plans))
+	return nil
+}
+
+// FindDescriptorByName 按全名查找描述，加载的描述优先
+func (r *DescriptorRegistry) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	r.mu.RLock()
+	files := r.files
+	r.mu.RUnlock()
+
+	if desc, err := files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// Mapping 查找sensor_path的映射
+func (r *DescriptorRegistry) Mapping(sensorPath string) (config.SensorMapping, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.mappings[sensorPath]
+	return m, ok
+}
+
+// TablePlan 查找sensor_path的列映射计划
+func (r *DescriptorRegistry) TablePlan(sensorPath string) (*tablePlan, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	plan, ok := r.plans[sensorPath]
+	return plan, ok
+}
+
+// readDescriptorDir 读取目录下所有描述文件，单个文件失败只记录日志
+func (r *DescriptorRegistry) readDescriptorDir(dir, protoc string) ([]*descriptorpb.FileDescriptorSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取proto描述目录失败: %v", err)
+	}
+
+	var sets []*descriptorpb.FileDescriptorSet
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+
+		var set *descriptorpb.FileDescriptorSet
+		switch {
+		case descriptorSetExts[ext]:
+			set, err = readDescriptorSet(path)
+		case ext == ".proto":
+			set, err = compileProtoFile(protoc, dir, entry.Name())
+		default:
+			continue
+		}
+		if err != nil {
+			r.logger.Warnf("加载proto描述失败: %s: %v", path, err)
+			continue
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// buildFiles 将描述集合注册到新的Files，依赖未就绪的文件延后重试
+func (r *DescriptorRegistry) buildFiles(sets []*descriptorpb.FileDescriptorSet) *protoregistry.Files {
+	files := new(protoregistry.Files)
+	resolver := filesChain{files, protoregistry.GlobalFiles}
+
+	pending := make(map[string]*descriptorpb.FileDescriptorProto)
+	for _, set := range sets {
+		for _, fdp := range set.GetFile() {
+			pending[fdp.GetName()] = fdp
+		}
+	}
+
+	lastErr := make(map[string]error)
+	for len(pending) > 0 {
+		progress := false
+		names := make([]string, 0, len(pending))
+		for name := range pending {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			// 已编译进二进制的文件（含google/protobuf/*）直接使用内置版本
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+				delete(pending, name)
+				progress = true
+				continue
+			}
+			fd, err := protodesc.NewFile(pending[name], resolver)
+			if err != nil {
+				lastErr[name] = err
+				continue
+			}
+			if err := files.RegisterFile(fd); err != nil {
+				lastErr[name] = err
+				delete(pending, name)
+				continue
+			}
+			delete(pending, name)
+			delete(lastErr, name)
+			progress = true
+		}
+		if !progress {
+			break
+		}
+	}
+
+	for name, err := range lastErr {
+		r.logger.Warnf("注册proto描述失败: %s: %v", name, err)
+	}
+	return files
+}
+
+// readDescriptorSet 读取二进制FileDescriptorSet
+func readDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("解析FileDescriptorSet失败: %v", err)
+	}
+	return set, nil
+}
+
+// compileProtoFile 调用protoc将.proto编译为FileDescriptorSet
+func compileProtoFile(protoc, dir, name string) (*descriptorpb.FileDescriptorSet, error) {
+	if protoc == "" {
+		protoc = "protoc"
+	}
+	bin, err := exec.LookPath(protoc)
+	if err != nil {
+		return nil, fmt.Errorf("未找到protoc，无法加载.proto文件: %v", err)
+	}
+
+	out, err := os.CreateTemp("", "telemetry-descriptor-*.pb")
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	cmd := exec.Command(bin, "--include_imports", "--descriptor_set_out="+out.Name(), "-I", dir, name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("protoc编译失败: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return readDescriptorSet(out.Name())
+}
+
+// filesChain 依次在多个Files中查找，用于解析跨注册表的import
+type filesChain []*protoregistry.Files
+
+func (c filesChain) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	for _, files := range c {
+		if fd, err := files.FindFileByPath(path); err == nil {
+			return fd, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+func (c filesChain) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	for _, files := range c {
+		if desc, err := files.FindDescriptorByName(name); err == nil {
+			return desc, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
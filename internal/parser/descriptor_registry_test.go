@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+)
+
+// writeTestDescriptorSet 写入包含 test.bfd.Session 的FileDescriptorSet
+func writeTestDescriptorSet(t *testing.T, dir string) {
+	t.Helper()
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("test/bfd.proto"),
+		Package: proto.String("test.bfd"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Session"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("name"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				{Name: proto.String("up_count"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_UINT64.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			},
+		}},
+	}}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("proto.Marshal(set): %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bfd.pb"), data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func newDescriptorTestRegistry() *DescriptorRegistry {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewDescriptorRegistry(logger)
+}
+
+func TestDescriptorRegistry_Load(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDescriptorSet(t, dir)
+	// 没有protoc时.proto文件只记录日志，不影响其他描述
+	if err := os.WriteFile(filepath.Join(dir, "broken.proto"), []byte("syntax = \"proto3\";"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	r := newDescriptorTestRegistry()
+	err := r.Load(config.ParserConfig{
+		DescriptorDir: dir,
+		ProtocPath:    filepath.Join(dir, "no-such-protoc"),
+		SensorMappings: []config.SensorMapping{
+			{SensorPath: "zte-bfd:sessions", Message: "test.bfd.Session", Table: "bfd_sessions"},
+			{SensorPath: "zte-bad:table", Table: "bad; DROP TABLE x"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if _, err := r.FindDescriptorByName("test.bfd.Session"); err != nil {
+		t.Errorf("test.bfd.Session not found: %v", err)
+	}
+	if _, err := r.FindDescriptorByName("zte.telemetry.Telemetry"); err != nil {
+		t.Errorf("built-in descriptor not found: %v", err)
+	}
+	if m, ok := r.Mapping("zte-bfd:sessions"); !ok || m.Table != "bfd_sessions" {
+		t.Errorf("Mapping = %+v, %v", m, ok)
+	}
+	if _, ok := r.Mapping("zte-bad:table"); ok {
+		t.Error("mapping with invalid table name should be skipped")
+	}
+
+	if err := r.Load(config.ParserConfig{DescriptorDir: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("Load with missing dir should fail")
+	}
+	if _, err := r.FindDescriptorByName("test.bfd.Session"); err != nil {
+		t.Error("failed reload should keep previous descriptors")
+	}
+}
+
+func TestParseTelemetryData_SensorMapping(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDescriptorSet(t, dir)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	p := NewTelemetryParserWithConfig(logger, config.ParserConfig{
+		DescriptorDir: dir,
+		SensorMappings: []config.SensorMapping{
+			{SensorPath: "zte-bfd:sessions", Message: "test.bfd.Session", Table: "bfd_sessions"},
+		},
+	})
+
+	desc, err := p.descriptors.FindDescriptorByName("test.bfd.Session")
+	if err != nil {
+		t.Fatalf("FindDescriptorByName: %v", err)
+	}
+	md := desc.(protoreflect.MessageDescriptor)
+	session := dynamicpb.NewMessage(md)
+	session.Set(md.Fields().ByName("name"), protoreflect.ValueOfString("bfd-1"))
+	session.Set(md.Fields().ByName("up_count"), protoreflect.ValueOfUint64(12))
+	content, err := proto.Marshal(session)
+	if err != nil {
+		t.Fatalf("proto.Marshal(session): %v", err)
+	}
+	data, err := proto.Marshal(&zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   "zte-bfd:sessions",
+		MsgTimestamp: 1700000000000,
+		DataGpb:      []*zteTelemetry.NotificationGpb{{Content: content}},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+
+	result, err := p.ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.GenericMetrics) != 1 {
+		t.Fatalf("len(GenericMetrics) = %d, want 1", len(result.GenericMetrics))
+	}
+	m := result.GenericMetrics[0]
+	if m.Field != "up_count" || m.ValueNum == nil || *m.ValueNum != 12 || m.Table != "bfd_sessions" {
+		t.Errorf("metric = %+v", m)
+	}
+	if m.ProtoPath == nil || *m.ProtoPath != "test.bfd.Session" {
+		t.Errorf("ProtoPath = %v", m.ProtoPath)
+	}
+	if m.InstanceKeys == nil || *m.InstanceKeys != `{"name":"bfd-1"}` {
+		t.Errorf("InstanceKeys = %v", m.InstanceKeys)
+	}
+}
@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// 通用解析 (generic dispatch)
+//
+// 类型解析器未覆盖的sensor_path，按 Telemetry.proto_path 在protobuf注册表中查找消息描述，
+// 用dynamicpb动态解码 data_gpb.content，并把每个叶子展开为一行键值写入 generic_metrics。
+// 列表元素中的键字段 (name/index/id/sub_port) 作为实例键，不单独成行。
+// parser.sensor_mappings 可为sensor_path指定消息与目标表，映射的路径优先于类型解析器。
+
+// genericKeyFields 作为实例键的字段名
+var genericKeyFields = map[string]bool{
+	"name":  true,
+	"index": true,
+	"id":    true,
+	// ZXR10子接口以子端口号为键
+	"sub_port": true,
+}
+
+// resolveMessageDescriptor 按proto_path查找消息描述
+func (p *TelemetryParser) resolveMessageDescriptor(protoPath string) (protoreflect.MessageDescriptor, error) {
+	desc, err := p.descriptors.FindDescriptorByName(protoreflect.FullName(protoPath))
+	if err != nil {
+		return nil, err
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s 不是消息类型", protoPath)
+	}
+	return md, nil
+}
+
+// parseGenericData 按proto_path（或sensor_path映射的消息）动态解码GPB数据，成功解析返回true
+func (p *TelemetryParser) parseGenericData(msg *zteTelemetry.Telemetry, result *ParseResult) bool {
+	protoPath, table := msg.ProtoPath, ""
+	if m, ok := p.descriptors.Mapping(msg.SensorPath); ok {
+		if m.Message != "" {
+			protoPath = m.Message
+		}
+		table = m.Table
+	}
+	if protoPath == "" || len(msg.DataGpb) == 0 {
+		return false
+	}
+
+	md, err := p.resolveMessageDescriptor(protoPath)
+	if err != nil {
+		p.logger.Debugf("proto_path未注册: %s (%v)", protoPath, err)
+		return false
+	}
+
+	before := len(result.GenericMetrics)
+	for i, dataGpb := range msg.DataGpb {
+		m := dynamicpb.NewMessage(md)
+		if err := proto.Unmarshal(dataGpb.GetContent(), m); err != nil {
+			p.logger.Warnf("动态解码第 %d 个GPB数据失败: proto_path=%s, %v", i+1, protoPath, err)
+			continue
+		}
+
+		timestamp := time.UnixMilli(int64(msg.MsgTimestamp))
+		if dataGpb.GetTimestamp() > 0 {
+			timestamp = time.UnixMilli(int64(dataGpb.GetTimestamp()))
+		}
+
+		flattenGenericMessage(m, "", nil, func(field string, keys map[string]string, num *float64, str *string) {
+			result.GenericMetrics = append(result.GenericMetrics, models.GenericMetric{
+				Timestamp:    timestamp,
+				SystemID:     msg.SystemId,
+				SensorPath:   msg.SensorPath,
+				ProtoPath:    stringPtr(protoPath),
+				InstanceKeys: marshalInstanceKeys(keys),
+				Field:        field,
+				ValueNum:     num,
+				ValueStr:     str,
+				Table:        table,
+			})
+		})
+	}
+
+	p.logger.Debugf("✅ 通用解析: sensor_path=%s, proto_path=%s, rows=%d",
+		msg.SensorPath, protoPath, len(result.GenericMetrics)-before)
+	return true
+}
+
+// genericEmitFunc 输出一个叶子
+type genericEmitFunc func(field string, keys map[string]string, num *float64, str *string)
+
+// flattenGenericMessage 递归展开消息，prefix为字段路径，keys为祖先实例键
+func flattenGenericMessage(m protoreflect.Message, prefix string, keys map[string]string, emit genericEmitFunc) {
+	keys = collectGenericKeys(m, prefix, keys)
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		path := name
+		if prefix != "" {
+			path = prefix + "/" + name
+		}
+
+		switch {
+		case fd.IsList():
+			list := v.List()
+			if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+				for i := 0; i < list.Len(); i++ {
+					flattenGenericMessage(list.Get(i).Message(), path, keys, emit)
+				}
+				return true
+			}
+			// leaf-list 以逗号拼接
+			parts := make([]string, 0, list.Len())
+			for i := 0; i < list.Len(); i++ {
+				num, str := genericScalar(fd, list.Get(i))
+				parts = append(parts, genericScalarString(num, str))
+			}
+			joined := strings.Join(parts, ",")
+			emit(path, keys, nil, &joined)
+
+		case fd.IsMap():
+			v.Map().Range(func(mk protoreflect.MapKey, mv protoreflect.Value) bool {
+				if fd.MapValue().Kind() == protoreflect.MessageKind {
+					entryKeys := copyGenericKeys(keys)
+					entryKeys[path+"/key"] = mk.String()
+					flattenGenericMessage(mv.Message(), path, entryKeys, emit)
+					return true
+				}
+				num, str := genericScalar(fd.MapValue(), mv)
+				emit(path+"/"+mk.String(), keys, num, str)
+				return true
+			})
+
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			flattenGenericMessage(v.Message(), path, keys, emit)
+
+		default:
+			if genericKeyFields[name] {
+				return true
+			}
+			num, str := genericScalar(fd, v)
+			emit(path, keys, num, str)
+		}
+		return true
+	})
+}
+
+// collectGenericKeys 收集消息自身的键字段，返回新的键集合
+func collectGenericKeys(m protoreflect.Message, prefix string, keys map[string]string) map[string]string {
+	var own map[string]string
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := string(fd.Name())
+		if !genericKeyFields[name] || fd.IsList() || fd.IsMap() || fd.Kind() == protoreflect.MessageKind {
+			continue
+		}
+		if !m.Has(fd) {
+			continue
+		}
+		if own == nil {
+			own = copyGenericKeys(keys)
+		}
+		keyName := name
+		if prefix != "" {
+			keyName = prefix + "/" + name
+		}
+		num, str := genericScalar(fd, m.Get(fd))
+		own[keyName] = genericScalarString(num, str)
+	}
+	if own == nil {
+		return keys
+	}
+	return own
+}
+
+func copyGenericKeys(keys map[string]string) map[string]string {
+	out := make(map[string]string, len(keys)+1)
+	for k, v := range keys {
+		out[k] = v
+	}
+	return out
+}
+
+// genericScalar 标量字段转为数值/字符串，枚举同时保留编号和名称
+func genericScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) (*float64, *string) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		f := 0.0
+		if v.Bool() {
+			f = 1
+		}
+		return &f, nil
+	case protoreflect.EnumKind:
+		f := float64(v.Enum())
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			s := string(ev.Name())
+			return &f, &s
+		}
+		return &f, nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		f := float64(v.Int())
+		return &f, nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		f := float64(v.Uint())
+		return &f, nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, nil
+		}
+		return &f, nil
+	case protoreflect.StringKind:
+		s := v.String()
+		return nil, &s
+	case protoreflect.BytesKind:
+		s := leafToString(v.Bytes())
+		return nil, &s
+	}
+	return nil, nil
+}
+
+// genericScalarString 标量的字符串形式（实例键、leaf-list使用）
+func genericScalarString(num *float64, str *string) string {
+	if str != nil {
+		return *str
+	}
+	if num != nil {
+		return leafToString(*num)
+	}
+	return ""
+}
+
+// genericLeafValue GPB-KV/JSON叶子值转为数值/字符串
+func genericLeafValue(v interface{}) (*float64, *string) {
+	switch x := v.(type) {
+	case string:
+		return nil, &x
+	case []byte:
+		s := leafToString(x)
+		return nil, &s
+	case nil:
+		return nil, nil
+	}
+	if f, ok := leafToFloat64(v); ok {
+		return &f, nil
+	}
+	s := leafToString(v)
+	return nil, &s
+}
+
+// appendGenericLeaves 未映射到类型模型的GPB-KV/JSON叶子写入通用指标，返回写入条数
+func (p *TelemetryParser) appendGenericLeaves(result *ParseResult, lm *leafMetrics, sensorPath, protoPath string) int {
+	m, _ := p.descriptors.Mapping(sensorPath)
+	for _, leaf := range lm.unmatchedLeaves {
+		keys := make(map[string]string)
+		for _, elem := range leaf.elems {
+			for k, v := range elem.Keys {
+				keys[elem.Name+"/"+k] = v
+			}
+		}
+		num, str := genericLeafValue(leaf.value)
+		result.GenericMetrics = append(result.GenericMetrics, models.GenericMetric{
+			Timestamp:    leaf.timestamp,
+			SystemID:     lm.systemID,
+			SensorPath:   sensorPath,
+			ProtoPath:    stringPtr(protoPath),
+			InstanceKeys: marshalInstanceKeys(keys),
+			Field:        leaf.name,
+			ValueNum:     num,
+			ValueStr:     str,
+			Table:        m.Table,
+		})
+	}
+	return len(lm.unmatchedLeaves)
+}
+
+// keepGenericLeaves 是否保留未映射叶子：开启通用解析或sensor_path配置了映射
+func (p *TelemetryParser) keepGenericLeaves(sensorPath string) bool {
+	if p.genericDispatch {
+		return true
+	}
+	_, ok := p.descriptors.Mapping(sensorPath)
+	return ok
+}
+
+// ReloadDescriptors 重新加载proto描述目录与sensor_path映射
+func (p *TelemetryParser) ReloadDescriptors(cfg config.ParserConfig) error {
+	return p.descriptors.Load(cfg)
+}
+
+// marshalInstanceKeys 实例键序列化为JSON对象，无键时返回nil
+func marshalInstanceKeys(keys map[string]string) *string {
+	if len(keys) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return nil
+	}
+	s := string(data)
+	return &s
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+	"github.com/wwswwsuns/ztelem/internal/models"
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+	interfaceProto "github.com/wwswwsuns/ztelem/proto/zxr10_interfaces"
+)
+
+func newGenericTestParser() *TelemetryParser {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewTelemetryParserWithConfig(logger, config.ParserConfig{GenericDispatch: true})
+}
+
+func findGenericMetric(metrics []models.GenericMetric, field string) *models.GenericMetric {
+	for i := range metrics {
+		if metrics[i].Field == field {
+			return &metrics[i]
+		}
+	}
+	return nil
+}
+
+func marshalGenericInterfaceTelemetry(t *testing.T, protoPath string) []byte {
+	t.Helper()
+	content, err := proto.Marshal(&interfaceProto.InterfaceInfo{
+		Name:     "gei-1/2/1",
+		Counters: []*interfaceProto.InterfaceCounters{{InOctets: 100}},
+		Subinterface: []*interfaceProto.SubinterfaceInfo{
+			{SubPort: 9, Counters: []*interfaceProto.SubinterfaceCounters{{InPkts: 7}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal(content): %v", err)
+	}
+	data, err := proto.Marshal(&zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   "zte-if:interfaces/interface/custom",
+		ProtoPath:    protoPath,
+		MsgTimestamp: 1700000000000,
+		DataGpb:      []*zteTelemetry.NotificationGpb{{Content: content}},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+	return data
+}
+
+func TestParseTelemetryData_GenericDispatch(t *testing.T) {
+	data := marshalGenericInterfaceTelemetry(t, "zte.telemetry.interfaces.InterfaceInfo")
+
+	result, err := newGenericTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.InterfaceMetrics) != 0 {
+		t.Errorf("unknown sensor_path should not produce typed metrics: %+v", result.InterfaceMetrics)
+	}
+
+	octets := findGenericMetric(result.GenericMetrics, "counters/in_octets")
+	if octets == nil {
+		t.Fatalf("counters/in_octets not found in %+v", result.GenericMetrics)
+	}
+	if octets.ValueNum == nil || *octets.ValueNum != 100 {
+		t.Errorf("in_octets = %v, want 100", octets.ValueNum)
+	}
+	if octets.InstanceKeys == nil || *octets.InstanceKeys != `{"name":"gei-1/2/1"}` {
+		t.Errorf("InstanceKeys = %v", octets.InstanceKeys)
+	}
+	if octets.SystemID != "dev-1" || octets.SensorPath != "zte-if:interfaces/interface/custom" || octets.Timestamp.UnixMilli() != 1700000000000 {
+		t.Errorf("row identity = %+v", octets)
+	}
+
+	pkts := findGenericMetric(result.GenericMetrics, "subinterface/counters/in_pkts")
+	if pkts == nil || pkts.ValueNum == nil || *pkts.ValueNum != 7 {
+		t.Fatalf("subinterface/counters/in_pkts = %+v", pkts)
+	}
+	if pkts.InstanceKeys == nil || *pkts.InstanceKeys != `{"name":"gei-1/2/1","subinterface/sub_port":"9"}` {
+		t.Errorf("InstanceKeys = %v", pkts.InstanceKeys)
+	}
+	if findGenericMetric(result.GenericMetrics, "name") != nil {
+		t.Error("key fields should not be emitted as rows")
+	}
+}
+
+func TestParseTelemetryData_GenericDispatchDisabled(t *testing.T) {
+	data := marshalGenericInterfaceTelemetry(t, "zte.telemetry.interfaces.InterfaceInfo")
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.GenericMetrics) != 0 {
+		t.Errorf("GenericMetrics = %d, want 0 when generic_dispatch is off", len(result.GenericMetrics))
+	}
+}
+
+func TestParseTelemetryData_GenericDispatchUnknownProto(t *testing.T) {
+	data := marshalGenericInterfaceTelemetry(t, "zte.telemetry.unknown.Message")
+
+	result, err := newGenericTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.GenericMetrics) != 0 {
+		t.Errorf("GenericMetrics = %d, want 0 for unregistered proto_path", len(result.GenericMetrics))
+	}
+	if !result.UnknownSensorPath {
+		t.Error("UnknownSensorPath = false, want true")
+	}
+}
+
+func TestParseTelemetryData_GenericGpbKvLeaves(t *testing.T) {
+	data, err := proto.Marshal(&zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   "oc-unknown:foo",
+		MsgTimestamp: 1700000000000,
+		DataGpbkv: []*zteTelemetry.NotificationGpbKv{
+			{InstancePath: "oc-unknown:foo/bar[id=1]", Value: []*zteTelemetry.KeyValue{kvUint64("x", 5), kvString("state/y", "up")}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+
+	result, err := newGenericTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.GenericMetrics) != 2 {
+		t.Fatalf("len(GenericMetrics) = %d, want 2", len(result.GenericMetrics))
+	}
+	x := findGenericMetric(result.GenericMetrics, "x")
+	if x == nil || x.ValueNum == nil || *x.ValueNum != 5 || x.InstanceKeys == nil || *x.InstanceKeys != `{"bar/id":"1"}` {
+		t.Errorf("x = %+v", x)
+	}
+	y := findGenericMetric(result.GenericMetrics, "state/y")
+	if y == nil || y.ValueStr == nil || *y.ValueStr != "up" {
+		t.Errorf("state/y = %+v", y)
+	}
+}
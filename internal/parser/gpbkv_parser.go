@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"time"
+
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+)
+
+// parseGpbKvData 解析GPB-KV编码数据 (Telemetry.data_gpbkv)
+// 每个NotificationGpbKv对应一个instance_path，叶子按路径元素路由到平台/接口/子接口模型
+func (p *TelemetryParser) parseGpbKvData(msg *zteTelemetry.Telemetry, result *ParseResult) {
+	lm := newLeafMetrics(msg.SystemId, time.UnixMilli(int64(msg.MsgTimestamp)))
+	lm.keepUnmatched = p.keepGenericLeaves(msg.SensorPath)
+
+	for _, kv := range msg.DataGpbkv {
+		path := kv.GetInstancePath()
+		if path == "" {
+			// 未携带instance_path时退回sensor_path
+			path = msg.SensorPath
+		}
+
+		inst := leafInstance{Path: path, Leaves: make([]leafValue, 0, len(kv.GetValue()))}
+		// 条目携带采样时间时以其为准，与GPB编码的行时间戳一致
+		if ts := kv.GetTimestamp(); ts > 0 {
+			inst.Timestamp = time.UnixMilli(int64(ts))
+		}
+		for _, leaf := range kv.GetValue() {
+			value, ok := typedValueToInterface(leaf.GetElement())
+			if !ok {
+				continue
+			}
+			inst.Leaves = append(inst.Leaves, leafValue{Name: leaf.GetKeyname(), Value: value})
+		}
+		lm.apply(inst)
+	}
+
+	generic := p.appendGenericLeaves(result, lm, msg.SensorPath, msg.ProtoPath)
+	if lm.count() == 0 && generic == 0 {
+		p.logger.Warnf("未知的sensor_path: %s (GPB-KV, 条目数=%d)", msg.SensorPath, len(msg.DataGpbkv))
+		result.UnknownSensorPath = true
+		return
+	}
+	if lm.unmatched > generic {
+		p.logger.Debugf("GPB-KV存在未映射叶子: sensor_path=%s, 数量=%d", msg.SensorPath, lm.unmatched)
+	}
+
+	lm.appendTo(result)
+	p.logger.Debugf("✅ 成功解析GPB-KV数据: platform=%d, interface=%d, subinterface=%d",
+		len(lm.platformOrder), len(lm.ifaceOrder), len(lm.subifaceOrder))
+}
+
+// typedValueToInterface 将TypedValue转换为Go基础类型
+func typedValueToInterface(tv *zteTelemetry.TypedValue) (interface{}, bool) {
+	if tv == nil {
+		return nil, false
+	}
+	switch v := tv.GetValue().(type) {
+	case *zteTelemetry.TypedValue_StringVal:
+		return v.StringVal, true
+	case *zteTelemetry.TypedValue_Int64Val:
+		return v.Int64Val, true
+	case *zteTelemetry.TypedValue_Uint64Val:
+		return v.Uint64Val, true
+	case *zteTelemetry.TypedValue_Int32Val:
+		return v.Int32Val, true
+	case *zteTelemetry.TypedValue_Uint32Val:
+		return v.Uint32Val, true
+	case *zteTelemetry.TypedValue_FloatVal:
+		return v.FloatVal, true
+	case *zteTelemetry.TypedValue_DoubleVal:
+		return v.DoubleVal, true
+	case *zteTelemetry.TypedValue_BytesVal:
+		return v.BytesVal, true
+	}
+	return nil, false
+}
@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+)
+
+func newTestParser() *TelemetryParser {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewTelemetryParser(logger)
+}
+
+func kvString(name, v string) *zteTelemetry.KeyValue {
+	return &zteTelemetry.KeyValue{Keyname: name, Element: &zteTelemetry.TypedValue{Value: &zteTelemetry.TypedValue_StringVal{StringVal: v}}}
+}
+
+func kvUint64(name string, v uint64) *zteTelemetry.KeyValue {
+	return &zteTelemetry.KeyValue{Keyname: name, Element: &zteTelemetry.TypedValue{Value: &zteTelemetry.TypedValue_Uint64Val{Uint64Val: v}}}
+}
+
+func kvUint32(name string, v uint32) *zteTelemetry.KeyValue {
+	return &zteTelemetry.KeyValue{Keyname: name, Element: &zteTelemetry.TypedValue{Value: &zteTelemetry.TypedValue_Uint32Val{Uint32Val: v}}}
+}
+
+func kvFloat(name string, v float32) *zteTelemetry.KeyValue {
+	return &zteTelemetry.KeyValue{Keyname: name, Element: &zteTelemetry.TypedValue{Value: &zteTelemetry.TypedValue_FloatVal{FloatVal: v}}}
+}
+
+func TestParseInstancePath(t *testing.T) {
+	elems := parseInstancePath("oc-if:interfaces/interface[name=gei-1/2/1]/subinterfaces/subinterface[index=9]/state")
+	if len(elems) != 5 {
+		t.Fatalf("len(elems) = %d, want 5: %+v", len(elems), elems)
+	}
+	if elems[0].Name != "interfaces" {
+		t.Errorf("elems[0].Name = %s, want interfaces", elems[0].Name)
+	}
+	if elems[1].Name != "interface" || elems[1].Keys["name"] != "gei-1/2/1" {
+		t.Errorf("elems[1] = %+v, want interface[name=gei-1/2/1]", elems[1])
+	}
+	if elems[3].Name != "subinterface" || elems[3].Keys["index"] != "9" {
+		t.Errorf("elems[3] = %+v, want subinterface[index=9]", elems[3])
+	}
+
+	multi := parseInstancePath("/a[x='1'][y=\"2\"]/b")
+	if len(multi) != 2 || multi[0].Keys["x"] != "1" || multi[0].Keys["y"] != "2" {
+		t.Errorf("parseInstancePath with multiple keys = %+v", multi)
+	}
+
+	if got := parseInstancePath(""); got != nil {
+		t.Errorf("parseInstancePath(\"\") = %+v, want nil", got)
+	}
+}
+
+func TestClassifyLeafPath(t *testing.T) {
+	tests := []struct {
+		path      string
+		kind      leafKind
+		tagPrefix string
+	}{
+		{"oc-if:interfaces/interface[name=gei-1/2/1]/state/counters", leafKindInterface, ""},
+		{"interface[name=gei-1/2/1]/zte-if:state-period", leafKindInterface, "zteif_"},
+		{"interface[name=gei-1/2/1]/subinterfaces/subinterface[index=9]/state", leafKindSubinterface, ""},
+		{"oc-platform:components/component[name=CPU0]/cpu/oc-cpu:utilization/state", leafKindPlatform, "cpu_"},
+		{"oc-platform:components/component[name=PS1]/power-supply/state", leafKindPlatform, "power_"},
+		{"oc-platform:components/component[name=T1]/state/temperature", leafKindPlatform, "temp_"},
+		{"oc-platform:components/component[name=SYS]/state", leafKindPlatform, ""},
+		{"oc-unknown:foo/bar", leafKindUnknown, ""},
+	}
+
+	for _, tt := range tests {
+		got := classifyLeafPath(parseInstancePath(tt.path))
+		if got.kind != tt.kind || got.tagPrefix != tt.tagPrefix {
+			t.Errorf("classifyLeafPath(%s) = {kind:%d prefix:%q}, want {kind:%d prefix:%q}",
+				tt.path, got.kind, got.tagPrefix, tt.kind, tt.tagPrefix)
+		}
+	}
+}
+
+func TestParseTelemetryData_GpbKv(t *testing.T) {
+	msg := &zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   "oc-if:interfaces/interface/state/counters",
+		MsgTimestamp: 1700000000000,
+		DataGpbkv: []*zteTelemetry.NotificationGpbKv{
+			{
+				InstancePath: "oc-if:interfaces/interface[name=gei-1/2/1]/state/counters",
+				Value: []*zteTelemetry.KeyValue{
+					kvUint64("in-octets", 1000),
+					kvUint64("out-octets", 2000),
+					kvFloat("in-traffic-rate", 12.5),
+					kvFloat("input-utilization", 0.25),
+					kvString("unknown-leaf", "x"),
+				},
+			},
+			{
+				InstancePath: "interface[name=gei-1/2/1]/subinterfaces/subinterface[index=9]",
+				Value: []*zteTelemetry.KeyValue{
+					kvUint32("state/admin-status", 1),
+					kvString("state/oper-status", "DOWN"),
+					kvUint64("state/counters/in-pkts", 42),
+				},
+			},
+			{
+				InstancePath: "oc-platform:components/component[name=CPU0]",
+				Value: []*zteTelemetry.KeyValue{
+					kvFloat("cpu/oc-cpu:utilization/state/instant", 37.5),
+					kvUint64("state/memory/available", 4*1024*1024),
+					kvUint32("state/uptime", 90061),
+				},
+			},
+		},
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+
+	if len(result.InterfaceMetrics) != 1 {
+		t.Fatalf("len(InterfaceMetrics) = %d, want 1", len(result.InterfaceMetrics))
+	}
+	im := result.InterfaceMetrics[0]
+	if im.SystemID != "dev-1" || im.InterfaceName != "gei-1/2/1" {
+		t.Errorf("interface identity = %s/%s", im.SystemID, im.InterfaceName)
+	}
+	if im.Timestamp.UnixMilli() != 1700000000000 {
+		t.Errorf("Timestamp = %d, want 1700000000000", im.Timestamp.UnixMilli())
+	}
+	if im.InOctets == nil || *im.InOctets != 1000 || im.OutOctets == nil || *im.OutOctets != 2000 {
+		t.Errorf("InOctets/OutOctets = %v/%v", im.InOctets, im.OutOctets)
+	}
+	if im.InTrafficRateMbps == nil || *im.InTrafficRateMbps != 12.5 {
+		t.Errorf("InTrafficRateMbps = %v, want 12.5", im.InTrafficRateMbps)
+	}
+	if im.InputUtilization == nil || *im.InputUtilization != 25 {
+		t.Errorf("InputUtilization = %v, want 25", im.InputUtilization)
+	}
+
+	if len(result.SubinterfaceMetrics) != 1 {
+		t.Fatalf("len(SubinterfaceMetrics) = %d, want 1", len(result.SubinterfaceMetrics))
+	}
+	sm := result.SubinterfaceMetrics[0]
+	if sm.InterfaceName != "gei-1/2/1" || sm.SubinterfaceName != "9" {
+		t.Errorf("subinterface identity = %s.%s", sm.InterfaceName, sm.SubinterfaceName)
+	}
+	if sm.AdminStatusStr == nil || *sm.AdminStatusStr != "ADMIN_STATUS_UP" {
+		t.Errorf("AdminStatusStr = %v, want ADMIN_STATUS_UP", sm.AdminStatusStr)
+	}
+	if sm.OperStatusStr == nil || *sm.OperStatusStr != "OPER_STATUS_DOWN" {
+		t.Errorf("OperStatusStr = %v, want OPER_STATUS_DOWN", sm.OperStatusStr)
+	}
+	if sm.InPkts == nil || *sm.InPkts != 42 {
+		t.Errorf("InPkts = %v, want 42", sm.InPkts)
+	}
+
+	if len(result.PlatformMetrics) != 1 {
+		t.Fatalf("len(PlatformMetrics) = %d, want 1", len(result.PlatformMetrics))
+	}
+	pm := result.PlatformMetrics[0]
+	if pm.ComponentName != "CPU0" {
+		t.Errorf("ComponentName = %s, want CPU0", pm.ComponentName)
+	}
+	if pm.CPUData == nil || pm.CPUInstant == nil || *pm.CPUInstant != 37.5 {
+		t.Errorf("CPUInstant not decoded: %+v", pm.CPUData)
+	}
+	if pm.MemData == nil || pm.MemAvailable == nil || *pm.MemAvailable != 4 {
+		t.Errorf("MemAvailable not converted to MB: %+v", pm.MemData)
+	}
+	if pm.CommonState == nil || pm.Uptime == nil || *pm.Uptime != "01:01:01:01" {
+		t.Errorf("Uptime not formatted: %+v", pm.CommonState)
+	}
+}
+
+func TestParseTelemetryData_GpbKvEntryTimestamp(t *testing.T) {
+	msg := &zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   "oc-if:interfaces/interface/state/counters",
+		MsgTimestamp: 1700000000000,
+		DataGpbkv: []*zteTelemetry.NotificationGpbKv{
+			{
+				Timestamp:    1700000001000,
+				InstancePath: "oc-if:interfaces/interface[name=gei-1/2/1]/state/counters",
+				Value:        []*zteTelemetry.KeyValue{kvUint64("in-octets", 1000)},
+			},
+			{
+				Timestamp:    1700000002000,
+				InstancePath: "oc-if:interfaces/interface[name=gei-1/2/1]/state/counters",
+				Value:        []*zteTelemetry.KeyValue{kvUint64("in-octets", 3000)},
+			},
+			{
+				InstancePath: "oc-if:interfaces/interface[name=gei-1/2/2]/state/counters",
+				Value:        []*zteTelemetry.KeyValue{kvUint64("in-octets", 5000)},
+			},
+		},
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+
+	// 条目时间戳不同的同一接口各成一行，未携带时间戳的条目使用消息时间戳
+	want := []struct {
+		name     string
+		ts       int64
+		inOctets uint64
+	}{
+		{"gei-1/2/1", 1700000001000, 1000},
+		{"gei-1/2/1", 1700000002000, 3000},
+		{"gei-1/2/2", 1700000000000, 5000},
+	}
+	if len(result.InterfaceMetrics) != len(want) {
+		t.Fatalf("len(InterfaceMetrics) = %d, want %d", len(result.InterfaceMetrics), len(want))
+	}
+	for i, w := range want {
+		im := result.InterfaceMetrics[i]
+		if im.InterfaceName != w.name || im.Timestamp.UnixMilli() != w.ts || im.InOctets == nil || *im.InOctets != w.inOctets {
+			t.Errorf("row %d = %s@%d in_octets=%v, want %s@%d in_octets=%d",
+				i, im.InterfaceName, im.Timestamp.UnixMilli(), im.InOctets, w.name, w.ts, w.inOctets)
+		}
+	}
+}
+
+func TestParseTelemetryData_GpbKvUnknownPath(t *testing.T) {
+	msg := &zteTelemetry.Telemetry{
+		SystemId:   "dev-1",
+		SensorPath: "oc-unknown:foo",
+		DataGpbkv: []*zteTelemetry.NotificationGpbKv{
+			{InstancePath: "oc-unknown:foo/bar[id=1]", Value: []*zteTelemetry.KeyValue{kvUint64("x", 1)}},
+		},
+	}
+	data, _ := proto.Marshal(msg)
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.PlatformMetrics)+len(result.InterfaceMetrics)+len(result.SubinterfaceMetrics) != 0 {
+		t.Errorf("unexpected metrics for unknown path: %+v", result)
+	}
+	if !result.UnknownSensorPath {
+		t.Error("UnknownSensorPath = false, want true")
+	}
+}
+
+func TestParseTelemetryData_CollectionRestamp(t *testing.T) {
+	msg := &zteTelemetry.Telemetry{
+		SystemId:            "dev-1",
+		SubscriptionId:      "sub-1",
+		SensorPath:          "oc-if:interfaces/interface/state/counters",
+		CollectionId:        42,
+		CollectionStartTime: 1700000000000,
+		MsgTimestamp:        1700000000800,
+		DataGpbkv: []*zteTelemetry.NotificationGpbKv{
+			{InstancePath: "oc-if:interfaces/interface[name=gei-1/2/1]/state/counters", Timestamp: 1700000000900, Value: []*zteTelemetry.KeyValue{kvUint64("in-octets", 1)}},
+		},
+	}
+	data, _ := proto.Marshal(msg)
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if result.SubscriptionID != "sub-1" || result.CollectionID != 42 {
+		t.Errorf("collection = %s/%d", result.SubscriptionID, result.CollectionID)
+	}
+	if result.CollectionStartTime.UnixMilli() != 1700000000000 || !result.CollectionEndTime.IsZero() {
+		t.Errorf("start/end = %v/%v", result.CollectionStartTime, result.CollectionEndTime)
+	}
+	if len(result.InterfaceMetrics) != 1 {
+		t.Fatalf("len(InterfaceMetrics) = %d, want 1", len(result.InterfaceMetrics))
+	}
+
+	result.Restamp(result.CollectionStartTime)
+	if got := result.InterfaceMetrics[0].Timestamp.UnixMilli(); got != 1700000000000 {
+		t.Errorf("restamped Timestamp = %d, want 1700000000000", got)
+	}
+}
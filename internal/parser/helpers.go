@@ -2,7 +2,13 @@ package parser
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
 )
 
 // formatUptime 将秒数转换为dd:hh:mm:ss格式
@@ -47,6 +53,14 @@ func nanosToTimestamp(nanos uint64) time.Time {
 	return time.Unix(seconds, nanosRemainder)
 }
 
+// msToTime 毫秒时间戳转换为time.Time，0表示未填写，返回零值
+func msToTime(ms uint64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(ms))
+}
+
 // addUnitMbps 添加Mbps单位
 func addUnitMbps(value float32) string {
 	return fmt.Sprintf("%.2f Mbps", value)
@@ -255,6 +269,45 @@ func utilizationToNumeric(utilization float32) float64 {
 	return float64(utilization) * 100.0
 }
 
+// roundRate 速率/利用率保留两位小数，与原字符串格式精度一致，同时去掉float32转换带来的尾数
+func roundRate(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// measurementToNumeric 解析设备以字符串上报的电压/电流/功率，如 "12.5"、"12.5V"、"300 W"
+// 去掉末尾单位后按数值解析，空串或无法解析时返回nil
+func measurementToNumeric(s *string) *float64 {
+	if s == nil {
+		return nil
+	}
+	v := strings.TrimRightFunc(strings.TrimSpace(*s), func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsSpace(r)
+	})
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+// fillPlatformMeasurements 根据电压/电流/功率字符串填充对应的数值字段
+func fillPlatformMeasurements(metrics []models.PlatformMetric) {
+	for i := range metrics {
+		if c := metrics[i].CommonState; c != nil {
+			c.CurrentVoltageV = measurementToNumeric(c.CurrentVoltage)
+			c.CurrentCurrentA = measurementToNumeric(c.CurrentCurrent)
+			c.TotalCapacityW = measurementToNumeric(c.TotalCapacity)
+			c.UsedCapacityW = measurementToNumeric(c.UsedCapacity)
+			c.TotalInputPowerW = measurementToNumeric(c.TotalInputPower)
+		}
+		if f := metrics[i].FanData; f != nil {
+			f.FanCurrentPowerW = measurementToNumeric(f.FanCurrentPower)
+			f.FanCurrentVoltageV = measurementToNumeric(f.FanCurrentVoltage)
+			f.FanCurrentCurrentA = measurementToNumeric(f.FanCurrentCurrent)
+		}
+	}
+}
+
 // percentageToNumeric 将uint32百分比转换为数字百分比(不带%符号)
 func percentageToNumeric(percentage uint32) float64 {
 	return float64(percentage)
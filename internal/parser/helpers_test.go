@@ -299,6 +299,46 @@ func TestUtilizationToNumeric(t *testing.T) {
 	}
 }
 
+func TestRoundRate(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{12.5, 12.5},
+		{1.005001, 1.01},
+		{33.333333, 33.33},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := roundRate(tt.in); got != tt.want {
+			t.Errorf("roundRate(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMeasurementToNumeric(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *float64
+	}{
+		{"12.5", float64Ptr(12.5)},
+		{"12.5V", float64Ptr(12.5)},
+		{" 300 W ", float64Ptr(300)},
+		{"-0.25A", float64Ptr(-0.25)},
+		{"", nil},
+		{"N/A", nil},
+	}
+	for _, tt := range tests {
+		in := tt.in
+		got := measurementToNumeric(&in)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("measurementToNumeric(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	if measurementToNumeric(nil) != nil {
+		t.Error("measurementToNumeric(nil) should return nil")
+	}
+}
+
 func TestSafeStringValue(t *testing.T) {
 	if safeStringValue(nil) != "" {
 		t.Error("safeStringValue(nil) should return empty string")
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+)
+
+// JSON编码 (RFC7951) 解析
+//
+// Telemetry层报文: {"system_id","subscription_id","json_ietf_val":{...}}
+// 业务层报文 (json_ietf_val): {"data":[{"timestamp","instance","content"}], "self_defined_event":[...]}
+// content 中的叶子与 GPB-KV 一样按 instance 路径路由到平台/接口/子接口模型。
+
+// jsonTelemetry Telemetry层JSON编码报文
+type jsonTelemetry struct {
+	SystemID            string          `json:"system_id"`
+	SubscriptionID      string          `json:"subscription_id"`
+	MngIPv4             string          `json:"mng_ipv4"`
+	MngIPv6             string          `json:"mng_ipv6"`
+	SensorPath          string          `json:"sensor_path"`
+	CollectionID        json.Number     `json:"collection_id"`
+	CollectionStartTime json.Number     `json:"collection_start_time"`
+	CollectionEndTime   json.Number     `json:"collection_end_time"`
+	MsgTimestamp        json.Number     `json:"msg_timestamp"`
+	CurrentPeriod       json.Number     `json:"current_period"`
+	JSONIetfVal         json.RawMessage `json:"json_ietf_val"`
+}
+
+// jsonIetfVal 业务层JSON编码报文
+type jsonIetfVal struct {
+	Data             []jsonDataEntry   `json:"data"`
+	SelfDefinedEvent []json.RawMessage `json:"self_defined_event"`
+}
+
+// jsonDataEntry 单个业务实例的采样数据
+type jsonDataEntry struct {
+	Timestamp string                 `json:"timestamp"`
+	Instance  string                 `json:"instance"`
+	Content   map[string]interface{} `json:"content"`
+}
+
+// ParseJSONData 解析PublishArgs.json_data (Telemetry层JSON编码报文)
+func (p *TelemetryParser) ParseJSONData(jsonData string) (*ParseResult, error) {
+	var msg jsonTelemetry
+	if err := decodeJSON([]byte(jsonData), &msg); err != nil {
+		return nil, fmt.Errorf("解析JSON telemetry消息失败: %v", err)
+	}
+
+	fallback := time.Now()
+	if ms, err := msg.MsgTimestamp.Int64(); err == nil && ms > 0 {
+		fallback = time.UnixMilli(ms)
+	}
+
+	result := &ParseResult{
+		SystemID:            msg.SystemID,
+		SensorPath:          msg.SensorPath,
+		Timestamp:           fallback,
+		SubscriptionID:      msg.SubscriptionID,
+		MngIPv4:             msg.MngIPv4,
+		MngIPv6:             msg.MngIPv6,
+		CollectionID:        jsonNumberUint64(msg.CollectionID),
+		CollectionStartTime: msToTime(jsonNumberUint64(msg.CollectionStartTime)),
+		CollectionEndTime:   msToTime(jsonNumberUint64(msg.CollectionEndTime)),
+		CurrentPeriod:       time.Duration(jsonNumberUint64(msg.CurrentPeriod)) * time.Millisecond,
+	}
+
+	// json_ietf_val 可能是对象，也可能是RFC7951文本字符串；缺省时整个报文即为业务层数据
+	ietfVal := []byte(jsonData)
+	if len(msg.JSONIetfVal) > 0 {
+		ietfVal = msg.JSONIetfVal
+		var text string
+		if err := json.Unmarshal(msg.JSONIetfVal, &text); err == nil {
+			ietfVal = []byte(text)
+		}
+	}
+
+	if err := p.parseJSONIetfVal(result, ietfVal, fallback); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// jsonNumberUint64 JSON数值转为uint64，缺省或非法时为0
+func jsonNumberUint64(n json.Number) uint64 {
+	v, err := strconv.ParseUint(n.String(), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// parseJSONTelemetry 解析GPB Telemetry消息中携带的json_ietf_val
+func (p *TelemetryParser) parseJSONTelemetry(msg *zteTelemetry.Telemetry, result *ParseResult) error {
+	return p.parseJSONIetfVal(result, []byte(msg.JsonIetfVal), time.UnixMilli(int64(msg.MsgTimestamp)))
+}
+
+// parseJSONIetfVal 解析业务层JSON数据并追加到解析结果
+func (p *TelemetryParser) parseJSONIetfVal(result *ParseResult, data []byte, fallback time.Time) error {
+	var val jsonIetfVal
+	if err := decodeJSON(data, &val); err != nil {
+		return fmt.Errorf("解析json_ietf_val失败: %v", err)
+	}
+
+	produced := 0
+	for _, entry := range val.Data {
+		produced += p.applyJSONEntry(result, entry, fallback)
+	}
+
+	for _, raw := range val.SelfDefinedEvent {
+		if err := p.applyJSONSelfDefinedEvent(result, raw, fallback); err != nil {
+			p.logger.Warnf("%v", err)
+			continue
+		}
+		produced++
+	}
+
+	if produced == 0 {
+		p.logger.Warnf("未知的sensor_path: %s (JSON, 条目数=%d)", result.SensorPath, len(val.Data))
+		result.UnknownSensorPath = true
+		return nil
+	}
+
+	p.logger.Debugf("✅ 成功解析JSON数据: platform=%d, interface=%d, subinterface=%d, self_defined_event=%d",
+		len(result.PlatformMetrics), len(result.InterfaceMetrics), len(result.SubinterfaceMetrics), len(result.SelfDefinedEventMetrics))
+	return nil
+}
+
+// applyJSONEntry 将单个data条目解析为指标，返回生成的指标条数
+func (p *TelemetryParser) applyJSONEntry(result *ParseResult, entry jsonDataEntry, fallback time.Time) int {
+	timestamp := fallback
+	if entry.Timestamp != "" {
+		if t, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+			timestamp = t
+		} else {
+			p.logger.Debugf("JSON时间戳格式无法识别: %s", entry.Timestamp)
+		}
+	}
+
+	if result.SensorPath == "" {
+		result.SensorPath = schemaPath(entry.Instance)
+	}
+
+	inst := leafInstance{Path: entry.Instance}
+	flattenJSONContent("", entry.Content, &inst.Leaves)
+
+	lm := newLeafMetrics(result.SystemID, timestamp)
+	sensorPath := schemaPath(entry.Instance)
+	lm.keepUnmatched = p.keepGenericLeaves(sensorPath)
+	lm.apply(inst)
+	generic := p.appendGenericLeaves(result, lm, sensorPath, "")
+	if lm.unmatched > generic {
+		p.logger.Debugf("JSON存在未映射叶子: instance=%s, 数量=%d", entry.Instance, lm.unmatched)
+	}
+	lm.appendTo(result)
+	return lm.count() + generic
+}
+
+// flattenJSONContent 将嵌套的YANG容器/列表展开为带相对路径的叶子
+// 列表元素以 name/index/id 作为键，如 subinterface[index=9]/state/in-octets
+func flattenJSONContent(prefix string, content map[string]interface{}, leaves *[]leafValue) {
+	// 按名称排序，保证输出稳定
+	names := make([]string, 0, len(content))
+	for name := range content {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := name
+		if prefix != "" {
+			path = prefix + "/" + name
+		}
+
+		switch v := content[name].(type) {
+		case map[string]interface{}:
+			flattenJSONContent(path, v, leaves)
+		case []interface{}:
+			if !flattenJSONList(path, v, leaves) {
+				*leaves = append(*leaves, leafValue{Name: path, Value: joinJSONScalars(v)})
+			}
+		default:
+			*leaves = append(*leaves, leafValue{Name: path, Value: v})
+		}
+	}
+}
+
+// flattenJSONList 展开对象列表，非对象列表返回false
+func flattenJSONList(path string, list []interface{}, leaves *[]leafValue) bool {
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+
+	for _, item := range list {
+		obj := item.(map[string]interface{})
+		elemPath := path
+		for _, key := range []string{"name", "index", "id"} {
+			if kv, ok := obj[key]; ok {
+				elemPath = fmt.Sprintf("%s[%s=%s]", path, key, leafToString(kv))
+				break
+			}
+		}
+		flattenJSONContent(elemPath, obj, leaves)
+	}
+	return true
+}
+
+// joinJSONScalars leaf-list 以逗号拼接为字符串
+func joinJSONScalars(list []interface{}) string {
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		parts = append(parts, leafToString(item))
+	}
+	return strings.Join(parts, ",")
+}
+
+// decodeJSON 解码JSON并保留数值精度（64位计数器不能经过float64）
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
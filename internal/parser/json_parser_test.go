@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+)
+
+const testJSONIetfVal = `{
+  "data":[
+    {
+      "timestamp":"2021-02-07T06:28:16.000+08:00",
+      "instance":"oc-if:interfaces/interface[name=fei-0/1/0/6]/state/counters",
+      "content":{"in-octet":6113457,"out-octets":"18446744073709551615","in-discards":0,"in-traffic-rate":1.5}
+    },
+    {
+      "timestamp":"2021-02-07T06:28:16.000+08:00",
+      "instance":"oc-if:interfaces/interface[name=fei-0/1/0/7]",
+      "content":{
+        "state":{"admin-status":"UP","oper-status":"DOWN"},
+        "subinterfaces":{"subinterface":[{"index":3,"state":{"counters":{"in-pkts":7}}}]}
+      }
+    }
+  ]
+}`
+
+func TestParseJSONData(t *testing.T) {
+	payload := `{"system_id":"ZXR10","subscription_id":"aSub","current_period":10000,"json_ietf_val":` + testJSONIetfVal + `}`
+
+	result, err := newTestParser().ParseJSONData(payload)
+	if err != nil {
+		t.Fatalf("ParseJSONData: %v", err)
+	}
+	if result.SystemID != "ZXR10" {
+		t.Errorf("SystemID = %s, want ZXR10", result.SystemID)
+	}
+	if result.CurrentPeriod != 10*time.Second {
+		t.Errorf("CurrentPeriod = %v, want 10s", result.CurrentPeriod)
+	}
+	if result.SensorPath != "oc-if:interfaces/interface/state/counters" {
+		t.Errorf("SensorPath = %s", result.SensorPath)
+	}
+
+	if len(result.InterfaceMetrics) != 2 {
+		t.Fatalf("len(InterfaceMetrics) = %d, want 2", len(result.InterfaceMetrics))
+	}
+	m := result.InterfaceMetrics[0]
+	if m.InterfaceName != "fei-0/1/0/6" {
+		t.Errorf("InterfaceName = %s", m.InterfaceName)
+	}
+	if m.Timestamp.UTC().Format("2006-01-02T15:04:05") != "2021-02-06T22:28:16" {
+		t.Errorf("Timestamp = %v", m.Timestamp)
+	}
+	if m.InOctets == nil || *m.InOctets != 6113457 {
+		t.Errorf("InOctets = %v, want 6113457", m.InOctets)
+	}
+	if m.OutOctets == nil || *m.OutOctets != 18446744073709551615 {
+		t.Errorf("OutOctets lost uint64 precision: %v", m.OutOctets)
+	}
+	if m.InDiscards == nil || *m.InDiscards != 0 {
+		t.Errorf("InDiscards = %v, want explicit 0", m.InDiscards)
+	}
+	if m.InTrafficRateMbps == nil || *m.InTrafficRateMbps != 1.5 {
+		t.Errorf("InTrafficRateMbps = %v, want 1.5", m.InTrafficRateMbps)
+	}
+
+	state := result.InterfaceMetrics[1]
+	if state.AdminStatusStr == nil || *state.AdminStatusStr != "ADMIN_STATUS_UP" {
+		t.Errorf("AdminStatusStr = %v, want ADMIN_STATUS_UP", state.AdminStatusStr)
+	}
+
+	if len(result.SubinterfaceMetrics) != 1 {
+		t.Fatalf("len(SubinterfaceMetrics) = %d, want 1", len(result.SubinterfaceMetrics))
+	}
+	sub := result.SubinterfaceMetrics[0]
+	if sub.InterfaceName != "fei-0/1/0/7" || sub.SubinterfaceName != "3" {
+		t.Errorf("subinterface identity = %s.%s", sub.InterfaceName, sub.SubinterfaceName)
+	}
+	if sub.InPkts == nil || *sub.InPkts != 7 {
+		t.Errorf("InPkts = %v, want 7", sub.InPkts)
+	}
+}
+
+func TestParseJSONData_StringIetfVal(t *testing.T) {
+	payload := `{"system_id":"ZXR10","json_ietf_val":"{\"data\":[{\"instance\":\"oc-if:interfaces/interface[name=x]/state/counters\",\"content\":{\"in-pkts\":1}}]}"}`
+
+	result, err := newTestParser().ParseJSONData(payload)
+	if err != nil {
+		t.Fatalf("ParseJSONData: %v", err)
+	}
+	if len(result.InterfaceMetrics) != 1 || result.InterfaceMetrics[0].InPkts == nil {
+		t.Fatalf("InterfaceMetrics = %+v", result.InterfaceMetrics)
+	}
+}
+
+func TestParseJSONData_Invalid(t *testing.T) {
+	if _, err := newTestParser().ParseJSONData("{not json"); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestParseTelemetryData_JSONIetfVal(t *testing.T) {
+	msg := &zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		MsgTimestamp: 1700000000000,
+		JsonIetfVal:  testJSONIetfVal,
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.InterfaceMetrics) != 2 || len(result.SubinterfaceMetrics) != 1 {
+		t.Errorf("metrics = %d interface / %d subinterface, want 2/1",
+			len(result.InterfaceMetrics), len(result.SubinterfaceMetrics))
+	}
+	if result.InterfaceMetrics[0].SystemID != "dev-1" {
+		t.Errorf("SystemID = %s, want dev-1", result.InterfaceMetrics[0].SystemID)
+	}
+}
+
+func TestSchemaPath(t *testing.T) {
+	got := schemaPath("oc-if:interfaces/interface[name=gei-1/2/1]/subinterfaces/subinterface[index=9]/state")
+	want := "oc-if:interfaces/interface/subinterfaces/subinterface/state"
+	if got != want {
+		t.Errorf("schemaPath = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,723 @@
+package parser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+// 叶子节点映射 — GPB-KV / JSON 等自描述编码共用
+//
+// 自描述编码只携带 instance_path + 叶子名/值，这里按路径元素确定目标模型，
+// 按模型json标签定位字段，并复用GPB解析中的单位与枚举转换，保证两种编码入库结果一致。
+
+// pathElem instance_path 中的一个路径元素，如 interface[name=gei-1/2/1]
+type pathElem struct {
+	Name string
+	Keys map[string]string
+}
+
+// parseInstancePath 解析instance_path，方括号内的 '/' 不作为分隔符
+// 例: interface[name=gei-1/2/1]/subinterfaces/subinterface[index=9]
+func parseInstancePath(path string) []pathElem {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return nil
+	}
+
+	var elems []pathElem
+	depth := 0
+	start := 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				if i > start {
+					elems = append(elems, parsePathElem(path[start:i]))
+				}
+				start = i + 1
+			}
+		}
+	}
+	if start < len(path) {
+		elems = append(elems, parsePathElem(path[start:]))
+	}
+	return elems
+}
+
+// parsePathElem 解析单个路径元素及其 [k=v] 键
+func parsePathElem(s string) pathElem {
+	idx := strings.IndexByte(s, '[')
+	if idx < 0 {
+		return pathElem{Name: stripModulePrefix(s)}
+	}
+
+	elem := pathElem{Name: stripModulePrefix(s[:idx]), Keys: make(map[string]string)}
+	rest := s[idx:]
+	for len(rest) > 0 && rest[0] == '[' {
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			end = len(rest)
+		}
+		kv := rest[1:end]
+		if eq := strings.IndexByte(kv, '='); eq > 0 {
+			key := stripModulePrefix(strings.TrimSpace(kv[:eq]))
+			elem.Keys[key] = strings.Trim(strings.TrimSpace(kv[eq+1:]), `'"`)
+		}
+		if end >= len(rest) {
+			break
+		}
+		rest = rest[end+1:]
+	}
+	return elem
+}
+
+// schemaPath 去掉instance_path中的键值，得到sensor_path形式的路径
+// 例: oc-if:interfaces/interface[name=gei-1/2/1]/state -> oc-if:interfaces/interface/state
+func schemaPath(path string) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0:
+			b.WriteByte(c)
+		}
+	}
+	return strings.Trim(b.String(), "/")
+}
+
+// stripModulePrefix 去掉YANG模块前缀，如 oc-if:interfaces -> interfaces
+func stripModulePrefix(name string) string {
+	if idx := strings.LastIndexByte(name, ':'); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
+
+// normalizeLeafName 叶子名归一化为模型json标签风格，如 oc-if:in-octets -> in_octets
+func normalizeLeafName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(stripModulePrefix(name), "-", "_"))
+}
+
+// leafKind 叶子所属的模型类型
+type leafKind int
+
+const (
+	leafKindUnknown leafKind = iota
+	leafKindPlatform
+	leafKindInterface
+	leafKindSubinterface
+)
+
+// leafTarget 叶子的目标模型、实例标识与字段前缀
+type leafTarget struct {
+	kind          leafKind
+	tagPrefix     string // 子容器对应的json标签前缀，如 cpu_、zteif_
+	componentName string
+	interfaceName string
+	subinterface  string
+}
+
+// classifyLeafPath 按路径元素确定目标模型，等价于GPB解析中的sensor_path路由
+func classifyLeafPath(elems []pathElem) leafTarget {
+	var target leafTarget
+	names := make(map[string]bool, len(elems))
+	for _, e := range elems {
+		names[e.Name] = true
+		switch e.Name {
+		case "component":
+			target.componentName = e.Keys["name"]
+		case "interface":
+			target.interfaceName = e.Keys["name"]
+		case "subinterface":
+			target.subinterface = e.Keys["index"]
+		}
+	}
+
+	switch {
+	case names["subinterface"]:
+		target.kind = leafKindSubinterface
+		if names["state-period"] {
+			target.tagPrefix = "zteif_"
+		}
+	case names["interface"]:
+		target.kind = leafKindInterface
+		if names["state-period"] {
+			target.tagPrefix = "zteif_"
+		}
+	case names["component"]:
+		target.kind = leafKindPlatform
+		switch {
+		case names["transceiver"]:
+			target.tagPrefix = "optical_"
+		case names["utilization"] || names["cpu"]:
+			target.tagPrefix = "cpu_"
+		case names["linecard"]:
+			target.tagPrefix = "linecard_"
+		case names["power-supply"]:
+			target.tagPrefix = "power_"
+		case names["fan"]:
+			target.tagPrefix = "fan_"
+		case names["memory"]:
+			target.tagPrefix = "mem_"
+		case names["storage"]:
+			target.tagPrefix = "storage_"
+		case names["temperature"]:
+			target.tagPrefix = "temp_"
+		}
+	}
+	return target
+}
+
+// leafValue 单个叶子节点（叶子名可带相对路径，如 state/counters/in-octets）
+type leafValue struct {
+	Name  string
+	Value interface{}
+}
+
+// leafInstance 同一instance_path下的一组叶子
+type leafInstance struct {
+	Path   string
+	Leaves []leafValue
+	// Timestamp 实例自身的采样时间，零值时使用消息时间戳
+	Timestamp time.Time
+}
+
+// leafFormat 叶子值预处理（单位/枚举转换）
+type leafFormat func(v interface{}) interface{}
+
+// leafField 模型字段在结构体中的位置（含内嵌子结构体）
+type leafField struct {
+	index []int
+}
+
+// leafSchema 按json标签索引的模型字段表
+type leafSchema struct {
+	fields  map[string]leafField
+	formats map[string]leafFormat
+	aliases map[string]string
+}
+
+// newLeafSchema 通过反射建立json标签到字段的索引
+func newLeafSchema(t reflect.Type, formats map[string]leafFormat, aliases map[string]string) *leafSchema {
+	s := &leafSchema{
+		fields:  make(map[string]leafField),
+		formats: formats,
+		aliases: aliases,
+	}
+	s.collect(t, nil)
+	return s
+}
+
+func (s *leafSchema) collect(t reflect.Type, prefix []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append([]int(nil), prefix...), i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct {
+			s.collect(f.Type.Elem(), index)
+			continue
+		}
+		if f.Type.Kind() != reflect.Ptr {
+			continue // 标识字段（Timestamp/SystemID/名称）不接受叶子赋值
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		s.fields[tag] = leafField{index: index}
+	}
+}
+
+// lookup 按子容器前缀查找字段标签
+func (s *leafSchema) lookup(prefix, leaf string) (string, bool) {
+	candidates := []string{prefix + leaf}
+	if prefix != "" && strings.HasPrefix(leaf, prefix) {
+		candidates = append(candidates, leaf)
+	}
+	for _, tag := range candidates {
+		if alias, ok := s.aliases[tag]; ok {
+			tag = alias
+		}
+		if _, ok := s.fields[tag]; ok {
+			return tag, true
+		}
+	}
+	return "", false
+}
+
+// set 将叶子值写入模型字段，metric必须为结构体指针
+func (s *leafSchema) set(metric interface{}, tag string, v interface{}) bool {
+	field, ok := s.fields[tag]
+	if !ok {
+		return false
+	}
+	if format, ok := s.formats[tag]; ok {
+		v = format(v)
+	}
+
+	fv := reflect.ValueOf(metric).Elem()
+	for _, i := range field.index {
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		fv = fv.Field(i)
+	}
+	return assignLeaf(fv, v)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// assignLeaf 按字段类型转换并赋值（字段均为指针类型）
+func assignLeaf(field reflect.Value, v interface{}) bool {
+	elem := field.Type().Elem()
+	ptr := reflect.New(elem)
+
+	switch {
+	case elem == timeType:
+		t, ok := leafToTime(v)
+		if !ok {
+			return false
+		}
+		ptr.Elem().Set(reflect.ValueOf(t))
+	case elem.Kind() == reflect.String:
+		ptr.Elem().SetString(leafToString(v))
+	case elem.Kind() == reflect.Bool:
+		b, ok := leafToBool(v)
+		if !ok {
+			return false
+		}
+		ptr.Elem().SetBool(b)
+	case elem.Kind() == reflect.Uint32 || elem.Kind() == reflect.Uint64:
+		u, ok := leafToUint64(v)
+		if !ok {
+			return false
+		}
+		ptr.Elem().SetUint(u)
+	case elem.Kind() == reflect.Float64:
+		f, ok := leafToFloat64(v)
+		if !ok {
+			return false
+		}
+		ptr.Elem().SetFloat(f)
+	default:
+		return false
+	}
+
+	field.Set(ptr)
+	return true
+}
+
+// leafToFloat64 叶子值转浮点数（RFC7951中64位整数以字符串编码）
+func leafToFloat64(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// leafToUint64 叶子值转无符号整数
+func leafToUint64(v interface{}) (uint64, bool) {
+	switch x := v.(type) {
+	case uint64:
+		return x, true
+	case uint32:
+		return uint64(x), true
+	case int64:
+		return uint64(x), x >= 0
+	case int32:
+		return uint64(x), x >= 0
+	case int:
+		return uint64(x), x >= 0
+	case json.Number:
+		if u, err := strconv.ParseUint(string(x), 10, 64); err == nil {
+			return u, true
+		}
+	case string:
+		if u, err := strconv.ParseUint(strings.TrimSpace(x), 10, 64); err == nil {
+			return u, true
+		}
+	}
+	f, ok := leafToFloat64(v)
+	if !ok || f < 0 || f > math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+// leafToBool 叶子值转布尔
+func leafToBool(v interface{}) (bool, bool) {
+	switch x := v.(type) {
+	case bool:
+		return x, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(x))
+		return b, err == nil
+	}
+	f, ok := leafToFloat64(v)
+	return f != 0, ok
+}
+
+// leafToString 叶子值转字符串，非UTF-8字节按十六进制编码
+func leafToString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case json.Number:
+		return string(x)
+	case []byte:
+		if utf8.Valid(x) {
+			return string(x)
+		}
+		return hex.EncodeToString(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// leafToTime 叶子值转时间：数值按纳秒时间戳处理，字符串按RFC3339解析
+func leafToTime(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s)); err == nil {
+			return t, true
+		}
+	}
+	u, ok := leafToUint64(v)
+	if !ok || u == 0 {
+		return time.Time{}, false
+	}
+	return nanosToTimestamp(u), true
+}
+
+// 叶子值预处理 — 与GPB解析保持相同的单位与枚举格式
+
+// enumFormat 枚举值转换：数值走GPB枚举转换函数，名称补齐统一前缀
+func enumFormat(prefix string, convert func(int32) string) leafFormat {
+	return func(v interface{}) interface{} {
+		if s, ok := v.(string); ok {
+			if _, err := strconv.Atoi(strings.TrimSpace(s)); err != nil {
+				name := strings.ToUpper(strings.ReplaceAll(stripModulePrefix(s), "-", "_"))
+				if strings.HasPrefix(name, prefix) {
+					return name
+				}
+				return prefix + name
+			}
+		}
+		if f, ok := leafToFloat64(v); ok {
+			return convert(int32(f))
+		}
+		return v
+	}
+}
+
+// numericFormat 数值型叶子按固定格式输出，非数值原样保留
+func numericFormat(format func(float64) interface{}) leafFormat {
+	return func(v interface{}) interface{} {
+		if s, ok := v.(string); ok {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+				return v
+			}
+		}
+		if f, ok := leafToFloat64(v); ok {
+			return format(f)
+		}
+		return v
+	}
+}
+
+var (
+	rateFormat = numericFormat(func(f float64) interface{} { return roundRate(f) })
+	utilFormat = numericFormat(func(f float64) interface{} {
+		return roundRate(utilizationToNumeric(float32(f)))
+	})
+	uptimeFormat     = numericFormat(func(f float64) interface{} { return formatUptime(uint32(f)) })
+	bytesToMBFormat  = numericFormat(func(f float64) interface{} { return bytesToMB(uint64(f)) })
+	nanosToSecFormat = numericFormat(func(f float64) interface{} { return nanosToSeconds(uint64(f)) })
+
+	adminStatusFormat    = enumFormat("ADMIN_STATUS_", convertAdminStatus)
+	operStatusFormat     = enumFormat("OPER_STATUS_", convertOperStatus)
+	phyStatusFormat      = enumFormat("PHY_STATUS_", convertPhyStatus)
+	ipv4OperStatusFormat = enumFormat("IPV4OPERSTATUS_STATUS_", convertIPv4OperStatus)
+	ipv6OperStatusFormat = enumFormat("IPV6OPERSTATUS_STATUS_", convertIPv6OperStatus)
+	alarmStatusFormat    = enumFormat("", convertAlarmStatus)
+)
+
+// counterFormats 接口/子接口计数器容器的速率（Mbps/Kfps）与利用率（%）格式
+var counterFormats = map[string]leafFormat{
+	"input_utilization":     utilFormat,
+	"output_utilization":    utilFormat,
+	"input_v4_utilization":  utilFormat,
+	"output_v4_utilization": utilFormat,
+	"input_v6_utilization":  utilFormat,
+	"output_v6_utilization": utilFormat,
+	"in_traffic_rate":       rateFormat,
+	"out_traffic_rate":      rateFormat,
+	"in_v4_traffic_rate":    rateFormat,
+	"out_v4_traffic_rate":   rateFormat,
+	"in_v6_traffic_rate":    rateFormat,
+	"out_v6_traffic_rate":   rateFormat,
+	"in_packet_rate":        rateFormat,
+	"out_packet_rate":       rateFormat,
+	"in_v4_packet_rate":     rateFormat,
+	"out_v4_packet_rate":    rateFormat,
+	"in_v6_packet_rate":     rateFormat,
+	"out_v6_packet_rate":    rateFormat,
+}
+
+// interfaceStatusFormats 接口/子接口状态枚举格式
+var interfaceStatusFormats = map[string]leafFormat{
+	"admin_status":           adminStatusFormat,
+	"oper_status":            operStatusFormat,
+	"phy_status":             phyStatusFormat,
+	"ipv4_oper_status":       ipv4OperStatusFormat,
+	"zteif_admin_status":     adminStatusFormat,
+	"zteif_oper_status":      operStatusFormat,
+	"zteif_phy_status":       phyStatusFormat,
+	"zteif_ipv4_oper_status": ipv4OperStatusFormat,
+	"zteif_ipv6_oper_status": ipv6OperStatusFormat,
+}
+
+// interfaceLeafAliases 设备实际上报的叶子名与YANG定义不一致时的别名
+var interfaceLeafAliases = map[string]string{
+	"in_octet":  "in_octets",
+	"out_octet": "out_octets",
+}
+
+func mergeFormats(maps ...map[string]leafFormat) map[string]leafFormat {
+	merged := make(map[string]leafFormat)
+	for _, m := range maps {
+		for k, v := range m {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+var (
+	platformLeafSchema = newLeafSchema(reflect.TypeOf(models.PlatformMetric{}), map[string]leafFormat{
+		"uptime":                   uptimeFormat,
+		"mem_available":            bytesToMBFormat,
+		"mem_utilized":             bytesToMBFormat,
+		"mem_free":                 bytesToMBFormat,
+		"cpu_interval":             nanosToSecFormat,
+		"temp_interval":            nanosToSecFormat,
+		"cpu_alarm_status":         alarmStatusFormat,
+		"mem_alarm_status":         alarmStatusFormat,
+		"optical_alarm_los_status": alarmStatusFormat,
+	}, map[string]string{
+		"temp_alarm_status":  "alarm_status",
+		"optical_los_status": "optical_alarm_los_status",
+		"power_power_name":   "power_name",
+		"power_power_state":  "power_state",
+	})
+
+	interfaceLeafSchema = newLeafSchema(reflect.TypeOf(models.InterfaceMetric{}),
+		mergeFormats(counterFormats, interfaceStatusFormats), interfaceLeafAliases)
+
+	subinterfaceLeafSchema = newLeafSchema(reflect.TypeOf(models.SubinterfaceMetric{}),
+		mergeFormats(counterFormats, interfaceStatusFormats), interfaceLeafAliases)
+)
+
+// leafMetrics 单条消息内按实例聚合的指标
+type leafMetrics struct {
+	systemID  string
+	timestamp time.Time
+
+	platform      map[string]*models.PlatformMetric
+	platformOrder []string
+	iface         map[string]*models.InterfaceMetric
+	ifaceOrder    []string
+	subiface      map[string]*models.SubinterfaceMetric
+	subifaceOrder []string
+
+	unmatched int
+
+	// keepUnmatched 为true时保留未映射叶子，供通用解析写入generic_metrics
+	keepUnmatched   bool
+	unmatchedLeaves []unmatchedLeaf
+}
+
+// unmatchedLeaf 未映射到类型模型的叶子
+type unmatchedLeaf struct {
+	elems     []pathElem
+	name      string
+	value     interface{}
+	timestamp time.Time
+}
+
+func newLeafMetrics(systemID string, timestamp time.Time) *leafMetrics {
+	return &leafMetrics{
+		systemID:  systemID,
+		timestamp: timestamp,
+		platform:  make(map[string]*models.PlatformMetric),
+		iface:     make(map[string]*models.InterfaceMetric),
+		subiface:  make(map[string]*models.SubinterfaceMetric),
+	}
+}
+
+// apply 将一个实例下的叶子写入对应模型
+func (lm *leafMetrics) apply(inst leafInstance) {
+	base := parseInstancePath(inst.Path)
+	ts := lm.timestamp
+	if !inst.Timestamp.IsZero() {
+		ts = inst.Timestamp
+	}
+
+	for _, leaf := range inst.Leaves {
+		elems := base
+		name := leaf.Name
+		// 叶子名带相对路径时拼接到instance_path后参与路由
+		if idx := strings.LastIndexByte(name, '/'); idx >= 0 {
+			elems = append(append([]pathElem(nil), base...), parseInstancePath(name[:idx])...)
+			name = name[idx+1:]
+		}
+		if !lm.applyLeaf(classifyLeafPath(elems), normalizeLeafName(name), leaf.Value, ts) {
+			lm.unmatched++
+			if lm.keepUnmatched {
+				lm.unmatchedLeaves = append(lm.unmatchedLeaves, unmatchedLeaf{elems: elems, name: leaf.Name, value: leaf.Value, timestamp: ts})
+			}
+		}
+	}
+}
+
+// instanceKey 实例在消息内的聚合键，采样时间与消息时间戳不同的实例单独成行
+func (lm *leafMetrics) instanceKey(name string, ts time.Time) string {
+	if ts.Equal(lm.timestamp) {
+		return name
+	}
+	return name + "@" + strconv.FormatInt(ts.UnixMilli(), 10)
+}
+
+func (lm *leafMetrics) applyLeaf(target leafTarget, leaf string, v interface{}, ts time.Time) bool {
+	switch target.kind {
+	case leafKindPlatform:
+		if target.componentName == "" {
+			return false
+		}
+		tag, ok := platformLeafSchema.lookup(target.tagPrefix, leaf)
+		if !ok {
+			return false
+		}
+		key := lm.instanceKey(target.componentName, ts)
+		metric, exists := lm.platform[key]
+		if !exists {
+			metric = &models.PlatformMetric{
+				Timestamp:     ts,
+				SystemID:      lm.systemID,
+				ComponentName: target.componentName,
+			}
+			lm.platform[key] = metric
+			lm.platformOrder = append(lm.platformOrder, key)
+		}
+		return platformLeafSchema.set(metric, tag, v)
+
+	case leafKindInterface:
+		if target.interfaceName == "" {
+			return false
+		}
+		tag, ok := interfaceLeafSchema.lookup(target.tagPrefix, leaf)
+		if !ok {
+			return false
+		}
+		key := lm.instanceKey(target.interfaceName, ts)
+		metric, exists := lm.iface[key]
+		if !exists {
+			metric = &models.InterfaceMetric{
+				Timestamp:     ts,
+				SystemID:      lm.systemID,
+				InterfaceName: target.interfaceName,
+			}
+			lm.iface[key] = metric
+			lm.ifaceOrder = append(lm.ifaceOrder, key)
+		}
+		return interfaceLeafSchema.set(metric, tag, v)
+
+	case leafKindSubinterface:
+		if target.interfaceName == "" || target.subinterface == "" {
+			return false
+		}
+		tag, ok := subinterfaceLeafSchema.lookup(target.tagPrefix, leaf)
+		if !ok {
+			return false
+		}
+		key := lm.instanceKey(target.interfaceName+"."+target.subinterface, ts)
+		metric, exists := lm.subiface[key]
+		if !exists {
+			metric = &models.SubinterfaceMetric{
+				Timestamp:        ts,
+				SystemID:         lm.systemID,
+				InterfaceName:    target.interfaceName,
+				SubinterfaceName: target.subinterface,
+			}
+			lm.subiface[key] = metric
+			lm.subifaceOrder = append(lm.subifaceOrder, key)
+		}
+		return subinterfaceLeafSchema.set(metric, tag, v)
+	}
+	return false
+}
+
+// count 已生成的指标条数
+func (lm *leafMetrics) count() int {
+	return len(lm.platformOrder) + len(lm.ifaceOrder) + len(lm.subifaceOrder)
+}
+
+// appendTo 按出现顺序追加到解析结果
+func (lm *leafMetrics) appendTo(result *ParseResult) {
+	for _, key := range lm.platformOrder {
+		result.PlatformMetrics = append(result.PlatformMetrics, *lm.platform[key])
+	}
+	for _, key := range lm.ifaceOrder {
+		result.InterfaceMetrics = append(result.InterfaceMetrics, *lm.iface[key])
+	}
+	for _, key := range lm.subifaceOrder {
+		result.SubinterfaceMetrics = append(result.SubinterfaceMetrics, *lm.subiface[key])
+	}
+}
@@ -0,0 +1,287 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+	"google.golang.org/protobuf/proto"
+)
+
+// 自定义事件 (SelfDefinedEvent) 解析
+//
+// 设备侧配置阈值后，满足过滤条件时以 sensor_path=zte-telemetry:SelfDefinedEvent 上报，
+// data_gpb.content 为 SelfDefinedEvent，JSON编码时放在 json_ietf_val.self_defined_event。
+// 事件本身写入 self_defined_event 表，触发事件的采样同时按常规采样解析为指标。
+
+const (
+	selfDefinedEventSensorPath = "zte-telemetry:SelfDefinedEvent"
+	selfDefinedEventProtoPath  = "zte.telemetry.SelfDefinedEvent"
+)
+
+// selfDefinedFilterRecord 过滤条件入库格式（枚举使用名称）
+type selfDefinedFilterRecord struct {
+	ConditionRelation string                       `json:"condition_relation"`
+	Condition         []selfDefinedConditionRecord `json:"condition"`
+}
+
+// selfDefinedConditionRecord 单个过滤条件
+type selfDefinedConditionRecord struct {
+	OpField          string      `json:"op_field"`
+	OpType           string      `json:"op_type"`
+	ThresholdOpValue interface{} `json:"threshold_op_value,omitempty"`
+	OpValue          interface{} `json:"op_value,omitempty"`
+}
+
+// jsonSelfDefinedEvent JSON编码的自定义事件
+type jsonSelfDefinedEvent struct {
+	SensorPath     string                  `json:"sensor_path"`
+	Level          interface{}             `json:"level"`
+	Description    string                  `json:"description"`
+	FilterRelation interface{}             `json:"filter_relation"`
+	Filter         []jsonSelfDefinedFilter `json:"filter"`
+	ContentJSON    *jsonDataEntry          `json:"content_json"`
+}
+
+// jsonSelfDefinedFilter JSON编码的过滤条件，枚举可能是数值也可能是名称
+type jsonSelfDefinedFilter struct {
+	ConditionRelation interface{} `json:"condition_relation"`
+	Condition         []struct {
+		OpField          string      `json:"op_field"`
+		OpType           interface{} `json:"op_type"`
+		ThresholdOpValue interface{} `json:"threshold_op_value"`
+		OpValue          interface{} `json:"op_value"`
+	} `json:"condition"`
+}
+
+// isSelfDefinedEvent 判断是否为自定义事件消息
+func isSelfDefinedEvent(msg *zteTelemetry.Telemetry) bool {
+	return msg.SensorPath == selfDefinedEventSensorPath || msg.ProtoPath == selfDefinedEventProtoPath
+}
+
+// parseSelfDefinedEvents 解析自定义事件消息，事件与触发采样均追加到result
+func (p *TelemetryParser) parseSelfDefinedEvents(msg *zteTelemetry.Telemetry, result *ParseResult) error {
+	// JSON编码的自定义事件随业务层数据一起解析
+	if len(msg.DataGpb) == 0 && msg.JsonIetfVal != "" {
+		return p.parseJSONTelemetry(msg, result)
+	}
+
+	for i, dataGpb := range msg.DataGpb {
+		evt := &zteTelemetry.SelfDefinedEvent{}
+		if err := proto.Unmarshal(dataGpb.GetContent(), evt); err != nil {
+			p.logger.Warnf("解析第 %d 个自定义事件失败: %v", i+1, err)
+			continue
+		}
+
+		timestamp := time.UnixMilli(int64(msg.MsgTimestamp))
+		if dataGpb.GetTimestamp() > 0 {
+			timestamp = time.UnixMilli(int64(dataGpb.GetTimestamp()))
+		}
+
+		metric := models.SelfDefinedEventMetric{
+			Timestamp:      timestamp,
+			SystemID:       msg.SystemId,
+			Level:          evt.GetLevel(),
+			Description:    stringPtr(evt.GetDescription()),
+			FilterRelation: stringPtr(evt.GetFilterRelation().String()),
+			Filters:        marshalSelfDefinedFilters(gpbSelfDefinedFilters(evt.GetFilter())),
+			SensorPath:     evt.GetSensorPath(),
+			ProtoPath:      stringPtr(evt.GetProtoPath()),
+		}
+
+		p.parseSelfDefinedEventSample(msg, evt, timestamp, &metric, result)
+		result.SelfDefinedEventMetrics = append(result.SelfDefinedEventMetrics, metric)
+	}
+
+	p.logger.Debugf("✅ 成功解析自定义事件: events=%d", len(result.SelfDefinedEventMetrics))
+	return nil
+}
+
+// parseSelfDefinedEventSample 解析事件携带的触发采样，并补齐事件的实例与编码信息
+func (p *TelemetryParser) parseSelfDefinedEventSample(msg *zteTelemetry.Telemetry, evt *zteTelemetry.SelfDefinedEvent,
+	timestamp time.Time, metric *models.SelfDefinedEventMetric, result *ParseResult) {
+	sample := &zteTelemetry.Telemetry{
+		SystemId:     msg.SystemId,
+		SensorPath:   evt.GetSensorPath(),
+		ProtoPath:    evt.GetProtoPath(),
+		MsgTimestamp: uint64(timestamp.UnixMilli()),
+	}
+
+	switch {
+	case len(evt.GetContentGpb()) > 0:
+		metric.ContentEncoding = "gpb"
+		if evt.GetSensorPath() == "" {
+			p.logger.Debugf("自定义事件未携带sensor_path，跳过GPB触发采样解析")
+			return
+		}
+		platform, iface, subiface := len(result.PlatformMetrics), len(result.InterfaceMetrics), len(result.SubinterfaceMetrics)
+		sample.DataGpb = []*zteTelemetry.NotificationGpb{{Timestamp: sample.MsgTimestamp, Content: evt.GetContentGpb()}}
+		if err := p.parseSampleData(sample, result); err != nil {
+			p.logger.Warnf("解析自定义事件触发采样失败: %v", err)
+			return
+		}
+		metric.Instance = stringPtr(sampleInstance(result, platform, iface, subiface))
+
+	case evt.GetContentGpbkv() != nil:
+		metric.ContentEncoding = "gpbkv"
+		instance := evt.GetContentGpbkv().GetInstancePath()
+		metric.Instance = stringPtr(instance)
+		if metric.SensorPath == "" {
+			metric.SensorPath = schemaPath(instance)
+		}
+		sample.DataGpbkv = []*zteTelemetry.NotificationGpbKv{evt.GetContentGpbkv()}
+		p.parseGpbKvData(sample, result)
+
+	case evt.GetContentJson() != "":
+		metric.ContentEncoding = "json"
+		var entry jsonDataEntry
+		if err := decodeJSON([]byte(evt.GetContentJson()), &entry); err != nil {
+			p.logger.Warnf("解析自定义事件content_json失败: %v", err)
+			return
+		}
+		metric.Instance = stringPtr(entry.Instance)
+		if metric.SensorPath == "" {
+			metric.SensorPath = schemaPath(entry.Instance)
+		}
+		p.applyJSONEntry(result, entry, timestamp)
+	}
+}
+
+// applyJSONSelfDefinedEvent 解析json_ietf_val.self_defined_event中的单个事件
+func (p *TelemetryParser) applyJSONSelfDefinedEvent(result *ParseResult, raw json.RawMessage, fallback time.Time) error {
+	var evt jsonSelfDefinedEvent
+	if err := decodeJSON(raw, &evt); err != nil {
+		return fmt.Errorf("解析自定义事件失败: %v", err)
+	}
+
+	level, _ := leafToUint64(evt.Level)
+	metric := models.SelfDefinedEventMetric{
+		Timestamp:      fallback,
+		SystemID:       result.SystemID,
+		Level:          uint32(level),
+		Description:    stringPtr(evt.Description),
+		FilterRelation: stringPtr(enumName(evt.FilterRelation, zteTelemetry.Relation_name)),
+		Filters:        marshalSelfDefinedFilters(jsonSelfDefinedFilters(evt.Filter)),
+		SensorPath:     evt.SensorPath,
+	}
+
+	if entry := evt.ContentJSON; entry != nil {
+		metric.ContentEncoding = "json"
+		metric.Instance = stringPtr(entry.Instance)
+		if metric.SensorPath == "" {
+			metric.SensorPath = schemaPath(entry.Instance)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+			metric.Timestamp = t
+		}
+		p.applyJSONEntry(result, *entry, fallback)
+	}
+
+	result.SelfDefinedEventMetrics = append(result.SelfDefinedEventMetrics, metric)
+	return nil
+}
+
+// gpbSelfDefinedFilters 转换GPB过滤条件
+func gpbSelfDefinedFilters(filters []*zteTelemetry.SelfDefinedFilter) []selfDefinedFilterRecord {
+	records := make([]selfDefinedFilterRecord, 0, len(filters))
+	for _, f := range filters {
+		record := selfDefinedFilterRecord{ConditionRelation: f.GetConditionRelation().String()}
+		for _, c := range f.GetCondition() {
+			record.Condition = append(record.Condition, selfDefinedConditionRecord{
+				OpField:          c.GetOpField(),
+				OpType:           c.GetOpType().String(),
+				ThresholdOpValue: filterValue(c.GetThresholdOpValue()),
+				OpValue:          filterValue(c.GetOpValue()),
+			})
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// jsonSelfDefinedFilters 转换JSON过滤条件，枚举统一为名称
+func jsonSelfDefinedFilters(filters []jsonSelfDefinedFilter) []selfDefinedFilterRecord {
+	records := make([]selfDefinedFilterRecord, 0, len(filters))
+	for _, f := range filters {
+		record := selfDefinedFilterRecord{ConditionRelation: enumName(f.ConditionRelation, zteTelemetry.Relation_name)}
+		for _, c := range f.Condition {
+			record.Condition = append(record.Condition, selfDefinedConditionRecord{
+				OpField:          c.OpField,
+				OpType:           enumName(c.OpType, zteTelemetry.ThresholdOpType_name),
+				ThresholdOpValue: unwrapJSONTypedValue(c.ThresholdOpValue),
+				OpValue:          unwrapJSONTypedValue(c.OpValue),
+			})
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// marshalSelfDefinedFilters 过滤条件序列化为JSON，无条件时返回nil
+func marshalSelfDefinedFilters(records []selfDefinedFilterRecord) *string {
+	if len(records) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return nil
+	}
+	s := string(data)
+	return &s
+}
+
+// filterValue TypedValue转为可序列化的值，字节按字符串处理
+func filterValue(tv *zteTelemetry.TypedValue) interface{} {
+	v, ok := typedValueToInterface(tv)
+	if !ok {
+		return nil
+	}
+	if b, isBytes := v.([]byte); isBytes {
+		return leafToString(b)
+	}
+	return v
+}
+
+// unwrapJSONTypedValue 展开RFC7951形式的TypedValue，如 {"uint64_val": 80}
+func unwrapJSONTypedValue(v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return v
+	}
+	for k, inner := range obj {
+		if strings.HasSuffix(k, "_val") {
+			return inner
+		}
+	}
+	return v
+}
+
+// enumName 枚举值转名称：数值按枚举表转换，名称原样返回
+func enumName(v interface{}, names map[int32]string) string {
+	if v == nil {
+		return ""
+	}
+	if n, ok := leafToUint64(v); ok {
+		if name, found := names[int32(n)]; found {
+			return name
+		}
+	}
+	return leafToString(v)
+}
+
+// sampleInstance 根据新追加的指标推导触发采样的实例路径
+func sampleInstance(result *ParseResult, platform, iface, subiface int) string {
+	switch {
+	case len(result.SubinterfaceMetrics) > subiface:
+		m := result.SubinterfaceMetrics[subiface]
+		return fmt.Sprintf("interface[name=%s]/subinterfaces/subinterface[index=%s]", m.InterfaceName, m.SubinterfaceName)
+	case len(result.InterfaceMetrics) > iface:
+		return fmt.Sprintf("interface[name=%s]", result.InterfaceMetrics[iface].InterfaceName)
+	case len(result.PlatformMetrics) > platform:
+		return fmt.Sprintf("component[name=%s]", result.PlatformMetrics[platform].ComponentName)
+	}
+	return ""
+}
@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+	interfaceProto "github.com/wwswwsuns/ztelem/proto/zxr10_interfaces"
+)
+
+func marshalSelfDefinedEventTelemetry(t *testing.T, events ...*zteTelemetry.SelfDefinedEvent) []byte {
+	t.Helper()
+	msg := &zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   selfDefinedEventSensorPath,
+		MsgTimestamp: 1700000000000,
+	}
+	for _, evt := range events {
+		content, err := proto.Marshal(evt)
+		if err != nil {
+			t.Fatalf("proto.Marshal(event): %v", err)
+		}
+		msg.DataGpb = append(msg.DataGpb, &zteTelemetry.NotificationGpb{Timestamp: 1700000000500, Content: content})
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+	return data
+}
+
+func TestParseTelemetryData_SelfDefinedEventGpbKv(t *testing.T) {
+	evt := &zteTelemetry.SelfDefinedEvent{
+		Level:          3,
+		Description:    "in-octets over threshold",
+		FilterRelation: zteTelemetry.Relation_RELATION_AND,
+		Filter: []*zteTelemetry.SelfDefinedFilter{{
+			ConditionRelation: zteTelemetry.Relation_RELATION_OR,
+			Condition: []*zteTelemetry.FilterCondition{{
+				OpField:          "in-octets",
+				OpType:           zteTelemetry.ThresholdOpType_THRESHOLD_OPTYPE_GT,
+				ThresholdOpValue: kvUint64("", 500).Element,
+				OpValue:          kvUint64("", 1000).Element,
+			}},
+		}},
+		ContentGpbkv: &zteTelemetry.NotificationGpbKv{
+			InstancePath: "oc-if:interfaces/interface[name=gei-1/2/1]/state/counters",
+			Value:        []*zteTelemetry.KeyValue{kvUint64("in-octets", 1000)},
+		},
+	}
+
+	result, err := newTestParser().ParseTelemetryData(marshalSelfDefinedEventTelemetry(t, evt))
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+
+	if len(result.SelfDefinedEventMetrics) != 1 {
+		t.Fatalf("len(SelfDefinedEventMetrics) = %d, want 1", len(result.SelfDefinedEventMetrics))
+	}
+	e := result.SelfDefinedEventMetrics[0]
+	if e.SystemID != "dev-1" || e.Level != 3 || e.ContentEncoding != "gpbkv" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Timestamp.UnixMilli() != 1700000000500 {
+		t.Errorf("Timestamp = %d, want 1700000000500", e.Timestamp.UnixMilli())
+	}
+	if e.SensorPath != "oc-if:interfaces/interface/state/counters" {
+		t.Errorf("SensorPath = %s", e.SensorPath)
+	}
+	if e.Instance == nil || *e.Instance != "oc-if:interfaces/interface[name=gei-1/2/1]/state/counters" {
+		t.Errorf("Instance = %v", e.Instance)
+	}
+	if e.FilterRelation == nil || *e.FilterRelation != "RELATION_AND" {
+		t.Errorf("FilterRelation = %v, want RELATION_AND", e.FilterRelation)
+	}
+
+	if e.Filters == nil {
+		t.Fatal("Filters = nil")
+	}
+	var filters []selfDefinedFilterRecord
+	if err := json.Unmarshal([]byte(*e.Filters), &filters); err != nil {
+		t.Fatalf("Filters is not valid JSON: %v", err)
+	}
+	if len(filters) != 1 || filters[0].ConditionRelation != "RELATION_OR" || len(filters[0].Condition) != 1 {
+		t.Fatalf("filters = %+v", filters)
+	}
+	if c := filters[0].Condition[0]; c.OpField != "in-octets" || c.OpType != "THRESHOLD_OPTYPE_GT" {
+		t.Errorf("condition = %+v", c)
+	}
+
+	// 触发采样同时作为常规指标输出
+	if len(result.InterfaceMetrics) != 1 {
+		t.Fatalf("len(InterfaceMetrics) = %d, want 1", len(result.InterfaceMetrics))
+	}
+	if m := result.InterfaceMetrics[0]; m.InterfaceName != "gei-1/2/1" || m.InOctets == nil || *m.InOctets != 1000 {
+		t.Errorf("embedded sample = %+v", m)
+	}
+}
+
+func TestParseTelemetryData_SelfDefinedEventGpb(t *testing.T) {
+	sample, err := proto.Marshal(&interfaceProto.InterfaceInfo{
+		Name:     "gei-1/2/2",
+		Counters: []*interfaceProto.InterfaceCounters{{InOctets: 77}},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal(sample): %v", err)
+	}
+	evt := &zteTelemetry.SelfDefinedEvent{
+		SensorPath: "oc-if:interfaces/interface/state/counters",
+		ProtoPath:  "zte.telemetry.interface.InterfaceInfo",
+		Level:      1,
+		ContentGpb: sample,
+	}
+
+	result, err := newTestParser().ParseTelemetryData(marshalSelfDefinedEventTelemetry(t, evt))
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+
+	if len(result.SelfDefinedEventMetrics) != 1 {
+		t.Fatalf("len(SelfDefinedEventMetrics) = %d, want 1", len(result.SelfDefinedEventMetrics))
+	}
+	e := result.SelfDefinedEventMetrics[0]
+	if e.ContentEncoding != "gpb" || e.ProtoPath == nil || e.Filters != nil {
+		t.Errorf("event = %+v", e)
+	}
+	if e.Instance == nil || *e.Instance != "interface[name=gei-1/2/2]" {
+		t.Errorf("Instance = %v, want interface[name=gei-1/2/2]", e.Instance)
+	}
+	if len(result.InterfaceMetrics) != 1 || result.InterfaceMetrics[0].InOctets == nil || *result.InterfaceMetrics[0].InOctets != 77 {
+		t.Errorf("embedded sample = %+v", result.InterfaceMetrics)
+	}
+}
+
+func TestParseJSONData_SelfDefinedEvent(t *testing.T) {
+	payload := `{"system_id":"ZXR10","json_ietf_val":{"self_defined_event":[{
+		"level":3,
+		"description":"This is a test for self-defined-event.",
+		"filter_relation":1,
+		"filter":[{"condition_relation":"RELATION_OR","condition":[{"op_field":"in-octet","op_type":3,"threshold_op_value":{"uint64_val":100},"op_value":34512}]}],
+		"content_json":{
+			"timestamp":"2021-02-07T06:28:16.000+08:00",
+			"instance":"oc-if:interfaces/interface[name=fei-0/1/0/7]/state/counters",
+			"content":{"in-octet":34512}
+		}
+	}]}}`
+
+	result, err := newTestParser().ParseJSONData(payload)
+	if err != nil {
+		t.Fatalf("ParseJSONData: %v", err)
+	}
+
+	if len(result.SelfDefinedEventMetrics) != 1 {
+		t.Fatalf("len(SelfDefinedEventMetrics) = %d, want 1", len(result.SelfDefinedEventMetrics))
+	}
+	e := result.SelfDefinedEventMetrics[0]
+	if e.SystemID != "ZXR10" || e.Level != 3 || e.ContentEncoding != "json" {
+		t.Errorf("event = %+v", e)
+	}
+	if e.FilterRelation == nil || *e.FilterRelation != "RELATION_AND" {
+		t.Errorf("FilterRelation = %v, want RELATION_AND", e.FilterRelation)
+	}
+	if e.SensorPath != "oc-if:interfaces/interface/state/counters" {
+		t.Errorf("SensorPath = %s", e.SensorPath)
+	}
+	if e.Timestamp.UTC().Format("2006-01-02T15:04:05") != "2021-02-06T22:28:16" {
+		t.Errorf("Timestamp = %v", e.Timestamp)
+	}
+	want := `[{"condition_relation":"RELATION_OR","condition":[{"op_field":"in-octet","op_type":"THRESHOLD_OPTYPE_GT","threshold_op_value":100,"op_value":34512}]}]`
+	if e.Filters == nil || *e.Filters != want {
+		t.Errorf("Filters = %v, want %s", e.Filters, want)
+	}
+
+	if len(result.InterfaceMetrics) != 1 || result.InterfaceMetrics[0].InOctets == nil || *result.InterfaceMetrics[0].InOctets != 34512 {
+		t.Errorf("embedded sample = %+v", result.InterfaceMetrics)
+	}
+}
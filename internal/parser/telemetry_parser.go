@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/wwswwsuns/ztelem/internal/config"
 	"github.com/wwswwsuns/ztelem/internal/models"
 	interfaceProto "github.com/wwswwsuns/ztelem/proto/zxr10_interfaces"
 	platformProto "github.com/wwswwsuns/ztelem/proto/openconfig_platform"
@@ -119,11 +120,123 @@ type ParseResult struct {
 	SystemID                 string
 	SensorPath               string
 	Timestamp                time.Time
+	SubscriptionID           string
+	MngIPv4                  string // 设备上报的管理口地址
+	MngIPv6                  string
+	CollectionID             uint64
+	CurrentPeriod            time.Duration // 设备上报的采样周期（current_period），未上报时为0
+	CollectionStartTime      time.Time // 仅采样轮次首包携带
+	CollectionEndTime        time.Time // 仅采样轮次末包携带
+	UnknownSensorPath        bool      // 没有解析器能处理该sensor_path，未产生数据
 	PlatformMetrics          []models.PlatformMetric
+	OpticalChannelMetrics    []models.OpticalChannelMetric
 	InterfaceMetrics         []models.InterfaceMetric
 	SubinterfaceMetrics      []models.SubinterfaceMetric
 	AlarmReportMetrics       []models.AlarmReportMetric
 	NotificationReportMetrics []models.NotificationReportMetric
+	SelfDefinedEventMetrics  []models.SelfDefinedEventMetric
+	GenericMetrics           []models.GenericMetric
+	MappedRows               []models.MappedRow
+}
+
+// Restamp 将采样指标的时间戳统一为ts（采样轮次开始时间），告警/事件保留原时间
+func (r *ParseResult) Restamp(ts time.Time) {
+	for i := range r.PlatformMetrics {
+		r.PlatformMetrics[i].Timestamp = ts
+	}
+	for i := range r.OpticalChannelMetrics {
+		r.OpticalChannelMetrics[i].Timestamp = ts
+	}
+	for i := range r.InterfaceMetrics {
+		r.InterfaceMetrics[i].Timestamp = ts
+	}
+	for i := range r.SubinterfaceMetrics {
+		r.SubinterfaceMetrics[i].Timestamp = ts
+	}
+	for i := range r.GenericMetrics {
+		r.GenericMetrics[i].Timestamp = ts
+	}
+	for i := range r.MappedRows {
+		r.MappedRows[i].Timestamp = ts
+	}
+}
+
+// Shift 将报文时间、采样轮次时间及所有行的时间戳平移d（设备时钟偏差校正），告警中设备上报的发生时间等字段不变
+func (r *ParseResult) Shift(d time.Duration) {
+	shift := func(t *time.Time) {
+		if !t.IsZero() {
+			*t = t.Add(d)
+		}
+	}
+	shift(&r.Timestamp)
+	shift(&r.CollectionStartTime)
+	shift(&r.CollectionEndTime)
+	for i := range r.PlatformMetrics {
+		shift(&r.PlatformMetrics[i].Timestamp)
+	}
+	for i := range r.OpticalChannelMetrics {
+		shift(&r.OpticalChannelMetrics[i].Timestamp)
+	}
+	for i := range r.InterfaceMetrics {
+		shift(&r.InterfaceMetrics[i].Timestamp)
+	}
+	for i := range r.SubinterfaceMetrics {
+		shift(&r.SubinterfaceMetrics[i].Timestamp)
+	}
+	for i := range r.AlarmReportMetrics {
+		shift(&r.AlarmReportMetrics[i].Timestamp)
+	}
+	for i := range r.NotificationReportMetrics {
+		shift(&r.NotificationReportMetrics[i].Timestamp)
+	}
+	for i := range r.SelfDefinedEventMetrics {
+		shift(&r.SelfDefinedEventMetrics[i].Timestamp)
+	}
+	for i := range r.GenericMetrics {
+		shift(&r.GenericMetrics[i].Timestamp)
+	}
+	for i := range r.MappedRows {
+		shift(&r.MappedRows[i].Timestamp)
+	}
+}
+
+// SetSystemID 将结果及所有行的system_id改为id
+func (r *ParseResult) SetSystemID(id string) {
+	r.SystemID = id
+	for i := range r.PlatformMetrics {
+		r.PlatformMetrics[i].SystemID = id
+	}
+	for i := range r.OpticalChannelMetrics {
+		r.OpticalChannelMetrics[i].SystemID = id
+	}
+	for i := range r.InterfaceMetrics {
+		r.InterfaceMetrics[i].SystemID = id
+	}
+	for i := range r.SubinterfaceMetrics {
+		r.SubinterfaceMetrics[i].SystemID = id
+	}
+	for i := range r.AlarmReportMetrics {
+		r.AlarmReportMetrics[i].SystemID = id
+	}
+	for i := range r.NotificationReportMetrics {
+		r.NotificationReportMetrics[i].SystemID = id
+	}
+	for i := range r.SelfDefinedEventMetrics {
+		r.SelfDefinedEventMetrics[i].SystemID = id
+	}
+	for i := range r.GenericMetrics {
+		r.GenericMetrics[i].SystemID = id
+	}
+	for i := range r.MappedRows {
+		r.MappedRows[i].SystemID = id
+	}
+}
+
+// RowCount 结果中各类数据的总行数
+func (r *ParseResult) RowCount() int {
+	return len(r.PlatformMetrics) + len(r.OpticalChannelMetrics) + len(r.InterfaceMetrics) +
+		len(r.SubinterfaceMetrics) + len(r.AlarmReportMetrics) + len(r.NotificationReportMetrics) +
+		len(r.SelfDefinedEventMetrics) + len(r.GenericMetrics) + len(r.MappedRows)
 }
 
 // TelemetryParser telemetry数据解析器
@@ -132,12 +245,28 @@ type TelemetryParser struct {
 	telemetryPool   sync.Pool
 	componentPool   sync.Pool
 	interfacePool   sync.Pool
+
+	// 通用解析：未知sensor_path按proto_path动态解码
+	genericDispatch bool
+	descriptors     *DescriptorRegistry
 }
 
 // NewTelemetryParser 创建新的解析器
 func NewTelemetryParser(logger *logrus.Logger) *TelemetryParser {
+	return NewTelemetryParserWithConfig(logger, config.ParserConfig{})
+}
+
+// NewTelemetryParserWithConfig 按解析器配置创建解析器
+func NewTelemetryParserWithConfig(logger *logrus.Logger, cfg config.ParserConfig) *TelemetryParser {
+	descriptors := NewDescriptorRegistry(logger)
+	if err := descriptors.Load(cfg); err != nil {
+		logger.WithError(err).Warn("加载proto描述失败，仅使用内置消息")
+	}
+
 	return &TelemetryParser{
-		logger: logger,
+		logger:          logger,
+		genericDispatch: cfg.GenericDispatch,
+		descriptors:     descriptors,
 		telemetryPool: sync.Pool{
 			New: func() interface{} { return new(zteTelemetry.Telemetry) },
 		},
@@ -173,9 +302,16 @@ func (p *TelemetryParser) ParseTelemetryData(data []byte) (*ParseResult, error)
 	}
 
 	result := &ParseResult{
-		SystemID:    telemetryMsg.SystemId,
-		SensorPath:  telemetryMsg.SensorPath,
-		Timestamp:   time.UnixMilli(int64(telemetryMsg.MsgTimestamp)),
+		SystemID:            telemetryMsg.SystemId,
+		SensorPath:          telemetryMsg.SensorPath,
+		Timestamp:           time.UnixMilli(int64(telemetryMsg.MsgTimestamp)),
+		SubscriptionID:      telemetryMsg.SubscriptionId,
+		MngIPv4:             telemetryMsg.MngIpv4,
+		MngIPv6:             telemetryMsg.MngIpv6,
+		CollectionID:        telemetryMsg.CollectionId,
+		CollectionStartTime: msToTime(telemetryMsg.CollectionStartTime),
+		CollectionEndTime:   msToTime(telemetryMsg.CollectionEndTime),
+		CurrentPeriod:       time.Duration(telemetryMsg.CurrentPeriod) * time.Millisecond,
 	}
 
 	// 首先检查data_type，告警数据优先处理
@@ -199,6 +335,49 @@ func (p *TelemetryParser) ParseTelemetryData(data []byte) (*ParseResult, error)
 		return result, nil
 	}
 
+	// 自定义事件：content为SelfDefinedEvent
+	if isSelfDefinedEvent(telemetryMsg) {
+		if err := p.parseSelfDefinedEvents(telemetryMsg, result); err != nil {
+			return nil, err
+		}
+		// 事件本身已写入，内嵌的触发采样无法解析不视为未知路径
+		result.UnknownSensorPath = false
+		fillPlatformMeasurements(result.PlatformMetrics)
+		return result, nil
+	}
+
+	if err := p.parseSampleData(telemetryMsg, result); err != nil {
+		return nil, err
+	}
+	fillPlatformMeasurements(result.PlatformMetrics)
+
+	return result, nil
+}
+
+// parseSampleData 按编码与sensor_path解析采样数据，结果追加到result
+// 自定义事件中携带的触发采样也经由此处解析
+func (p *TelemetryParser) parseSampleData(msg *zteTelemetry.Telemetry, result *ParseResult) error {
+	// GPB-KV编码：设备只推送data_gpbkv时按instance_path解析
+	if len(msg.DataGpb) == 0 && len(msg.DataGpbkv) > 0 {
+		p.parseGpbKvData(msg, result)
+		return nil
+	}
+
+	// JSON编码：业务数据以RFC7951文本放在json_ietf_val中
+	if len(msg.DataGpb) == 0 && msg.JsonIetfVal != "" {
+		return p.parseJSONTelemetry(msg, result)
+	}
+
+	// 映射文件声明的sensor_path按列映射解码，优先于其他解析方式
+	if p.parseMappedData(msg, result) {
+		return nil
+	}
+
+	// 配置了sensor_path映射的按描述动态解码，优先于类型解析器
+	if _, ok := p.descriptors.Mapping(msg.SensorPath); ok && p.parseGenericData(msg, result) {
+		return nil
+	}
+
 	// sensor_path 路由表 — 按前缀长度降序排列，长前缀优先匹配
 	type routeEntry struct {
 		prefix  string
@@ -208,8 +387,9 @@ func (p *TelemetryParser) ParseTelemetryData(data []byte) (*ParseResult, error)
 
 	routes := []routeEntry{
 		// 精确匹配
-		{"oc-platform:components/component", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseComponentsData(m) }, true},
+		{"oc-platform:components/component", func(m *zteTelemetry.Telemetry) (interface{}, error) { return componentRows(p.parseComponentsData(m)) }, true},
 		// 平台组件 — 长前缀优先
+		{"oc-platform:components/component/oc-transceiver:transceiver/physical-channels", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseComponentOpticalChannels(m) }, false},
 		{"oc-platform:components/component/oc-transceiver:transceiver/state", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseComponentTransceiverState(m) }, false},
 		{"oc-platform:components/component/cpu/oc-cpu:utilization/state", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseComponentCPUState(m) }, false},
 		{"oc-platform:components/component/oc-linecard:linecard/state", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseComponentLinecardState(m) }, false},
@@ -224,12 +404,12 @@ func (p *TelemetryParser) ParseTelemetryData(data []byte) (*ParseResult, error)
 		{"oc-if:interfaces/interface/subinterfaces/subinterface/zte-if:state-period", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseSubinterfaceZteState(m) }, false},
 		{"oc-if:interfaces/interface/subinterfaces/subinterface/state", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseSubinterfaceState(m) }, false},
 		// 接口
-		{"oc-if:interfaces/interface/state/counters", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseInterfaceCounters(m) }, false},
-		{"oc-if:interfaces/interface/zte-if:state-period", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseInterfaceZteState(m) }, false},
-		{"oc-if:interfaces/interface/state", func(m *zteTelemetry.Telemetry) (interface{}, error) { return p.parseInterfaceState(m) }, false},
+		{"oc-if:interfaces/interface/state/counters", func(m *zteTelemetry.Telemetry) (interface{}, error) { return interfaceRows(p.parseInterfaceCounters(m)) }, false},
+		{"oc-if:interfaces/interface/zte-if:state-period", func(m *zteTelemetry.Telemetry) (interface{}, error) { return interfaceRows(p.parseInterfaceZteState(m)) }, false},
+		{"oc-if:interfaces/interface/state", func(m *zteTelemetry.Telemetry) (interface{}, error) { return interfaceRows(p.parseInterfaceState(m)) }, false},
 	}
 
-	sensorPath := telemetryMsg.SensorPath
+	sensorPath := msg.SensorPath
 	for _, route := range routes {
 		if route.exact {
 			if sensorPath != route.prefix {
@@ -239,24 +419,55 @@ func (p *TelemetryParser) ParseTelemetryData(data []byte) (*ParseResult, error)
 			continue
 		}
 
-		val, err := route.handler(telemetryMsg)
+		val, err := route.handler(msg)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		switch v := val.(type) {
 		case []models.PlatformMetric:
-			result.PlatformMetrics = v
+			result.PlatformMetrics = append(result.PlatformMetrics, v...)
 		case []models.InterfaceMetric:
-			result.InterfaceMetrics = v
+			result.InterfaceMetrics = append(result.InterfaceMetrics, v...)
 		case []models.SubinterfaceMetric:
-			result.SubinterfaceMetrics = v
+			result.SubinterfaceMetrics = append(result.SubinterfaceMetrics, v...)
+		case []models.OpticalChannelMetric:
+			result.OpticalChannelMetrics = append(result.OpticalChannelMetrics, v...)
+		case sampleRows:
+			result.PlatformMetrics = append(result.PlatformMetrics, v.platform...)
+			result.OpticalChannelMetrics = append(result.OpticalChannelMetrics, v.opticalChannels...)
+			result.InterfaceMetrics = append(result.InterfaceMetrics, v.interfaces...)
+			result.SubinterfaceMetrics = append(result.SubinterfaceMetrics, v.subinterfaces...)
 		}
-		return result, nil
+		return nil
 	}
 
-	p.logger.Warnf("未知的sensor_path: %s", telemetryMsg.SensorPath)
+	// 通用解析：按proto_path动态解码，写入generic_metrics
+	if p.genericDispatch && p.parseGenericData(msg, result) {
+		return nil
+	}
 
-	return result, nil
+	p.logger.Warnf("未知的sensor_path: %s", msg.SensorPath)
+	result.UnknownSensorPath = true
+
+	return nil
+}
+
+// sampleRows 单个报文解析出的多类采样指标（如接口消息内嵌子接口、组件内嵌光通道）
+type sampleRows struct {
+	platform        []models.PlatformMetric
+	opticalChannels []models.OpticalChannelMetric
+	interfaces      []models.InterfaceMetric
+	subinterfaces   []models.SubinterfaceMetric
+}
+
+// componentRows 合并组件综合解析器的组件与光通道结果
+func componentRows(platform []models.PlatformMetric, channels []models.OpticalChannelMetric, err error) (interface{}, error) {
+	return sampleRows{platform: platform, opticalChannels: channels}, err
+}
+
+// interfaceRows 合并接口解析器的接口与内嵌子接口结果
+func interfaceRows(interfaces []models.InterfaceMetric, subinterfaces []models.SubinterfaceMetric, err error) (interface{}, error) {
+	return sampleRows{interfaces: interfaces, subinterfaces: subinterfaces}, err
 }
 
 // parseComponentState 解析组件通用状态数据
@@ -308,13 +519,14 @@ func (p *TelemetryParser) parseComponentState(msg *zteTelemetry.Telemetry) ([]mo
 }
 
 // parseComponentsData 解析组件综合数据 (处理oc-platform:components/component路径)
-// 这个函数处理包含多个组件信息的数据，包括CPU、内存等
-func (p *TelemetryParser) parseComponentsData(msg *zteTelemetry.Telemetry) ([]models.PlatformMetric, error) {
+// 这个函数处理包含多个组件信息的数据，包括CPU、内存等；组件内的光通道单独输出
+func (p *TelemetryParser) parseComponentsData(msg *zteTelemetry.Telemetry) ([]models.PlatformMetric, []models.OpticalChannelMetric, error) {
 	if len(msg.DataGpb) == 0 {
-		return nil, fmt.Errorf("DataGpb为空")
+		return nil, nil, fmt.Errorf("DataGpb为空")
 	}
 
 	var metrics []models.PlatformMetric
+	var channels []models.OpticalChannelMetric
 	
 	// 遍历所有DataGpb条目，每个可能包含一个或多个组件的信息
 	for _, dataGpb := range msg.DataGpb {
@@ -446,63 +658,12 @@ func (p *TelemetryParser) parseComponentsData(msg *zteTelemetry.Telemetry) ([]mo
 
 		// 解析光模块信息
 		if opticalInfo := componentInfo.GetOpticalInfo(); opticalInfo != nil {
-			metric.OpticalData = &models.OpticalData{}
-			if inPower := opticalInfo.GetInPower(); inPower != nil {
-				metric.OpticalInPower = opticalPowerPtr(float64(inPower.GetInstant()), true)
-			} else {
-				metric.OpticalInPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-			}
-			if outPower := opticalInfo.GetOutPower(); outPower != nil {
-				metric.OpticalOutPower = opticalPowerPtr(float64(outPower.GetInstant()), true)
-			} else {
-				metric.OpticalOutPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-			}
-			if biasCurrent := opticalInfo.GetBiasCurrent(); biasCurrent != nil {
-				metric.OpticalBiasCurrent = float64Ptr(float64(biasCurrent.GetInstant()))
-			}
-			if temperature := opticalInfo.GetTemperature(); temperature != nil {
-				metric.OpticalTemperature = float64Ptr(float64(temperature.GetInstant()))
-			}
-			if voltage := opticalInfo.GetVoltage(); voltage != nil {
-				metric.OpticalVoltageVol33 = float64Ptr(float64(voltage.GetVol33()))
-				metric.OpticalVoltageVol5 = float64Ptr(float64(voltage.GetVol5()))
-			}
-			
-			if opticalAlarm := opticalInfo.GetAlarm(); opticalAlarm != nil {
-				if opticalAlarm.GetLosStatus() != 0 {
-					alarmStatusStr := convertAlarmStatus(int32(opticalAlarm.GetLosStatus()))
-					metric.OpticalAlarmLosStatus = &alarmStatusStr
-				}
-				
-				if losInfo := opticalAlarm.GetLosInfo(); losInfo != nil {
-					metric.OpticalAlarmLosInfoEventID = uint32Ptr(losInfo.GetEventId())
-					metric.OpticalAlarmLosInfoEventInterval = uint32Ptr(losInfo.GetEventInterval())
-					
-					if len(losInfo.GetOptInPower()) > 0 {
-						metric.OpticalAlarmLosInfoInPower = opticalPowerPtr(float64(losInfo.GetOptInPower()[0].GetInstant()), true)
-					} else {
-						metric.OpticalAlarmLosInfoInPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-					}
-					if len(losInfo.GetOptOutPower()) > 0 {
-						metric.OpticalAlarmLosInfoOutPower = opticalPowerPtr(float64(losInfo.GetOptOutPower()[0].GetInstant()), true)
-					} else {
-						metric.OpticalAlarmLosInfoOutPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-					}
-				}
-			}
-			
-			if onlineStatus := opticalInfo.GetOnlineStatus(); onlineStatus != nil {
-				metric.OpticalOnlineStatus = stringPtr(onlineStatus.GetOnlineStatus())
-			}
-			
-			if rxThreshold := opticalInfo.GetRxThreshold(); rxThreshold != nil {
-				metric.OpticalRxThresholdHighAlarm = float64Ptr(float64(rxThreshold.GetHighAlarm()))
-				metric.OpticalRxThresholdPreHighAlarm = float64Ptr(float64(rxThreshold.GetPreHighAlarm()))
-				metric.OpticalRxThresholdLowAlarm = float64Ptr(float64(rxThreshold.GetLowAlarm()))
-				metric.OpticalRxThresholdPreLowAlarm = float64Ptr(float64(rxThreshold.GetPreLowAlarm()))
-			}
+			metric.OpticalData = parseOpticalData(opticalInfo)
 		}
 
+		// 解析光通道信息
+		channels = append(channels, opticalChannelMetrics(msg, componentInfo)...)
+
 		// 解析线卡信息
 		if linecardInfo := componentInfo.GetPowerAdminState(); linecardInfo != nil {
 			if metric.PowerData == nil {
@@ -518,7 +679,7 @@ func (p *TelemetryParser) parseComponentsData(msg *zteTelemetry.Telemetry) ([]mo
 		p.componentPool.Put(componentInfo)
 	}
 
-	return metrics, nil
+	return metrics, channels, nil
 }
 
 // parseComponentFanState 解析组件风扇状态数据
@@ -862,68 +1023,37 @@ func (p *TelemetryParser) parseComponentTransceiverState(msg *zteTelemetry.Telem
 				Timestamp:     time.UnixMilli(int64(msg.MsgTimestamp)),
 				SystemID:      msg.SystemId,
 				ComponentName: componentInfo.GetName(),
-				OpticalData:   &models.OpticalData{},
+				OpticalData:   parseOpticalData(opticalInfo),
 			}
 
-			if inPower := opticalInfo.GetInPower(); inPower != nil {
-				metric.OpticalInPower = opticalPowerPtr(float64(inPower.GetInstant()), true)
-			} else {
-				metric.OpticalInPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-			}
-			if outPower := opticalInfo.GetOutPower(); outPower != nil {
-				metric.OpticalOutPower = opticalPowerPtr(float64(outPower.GetInstant()), true)
-			} else {
-				metric.OpticalOutPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-			}
-			if biasCurrent := opticalInfo.GetBiasCurrent(); biasCurrent != nil {
-				metric.OpticalBiasCurrent = float64Ptr(float64(biasCurrent.GetInstant()))
-			}
-			if temperature := opticalInfo.GetTemperature(); temperature != nil {
-				metric.OpticalTemperature = float64Ptr(float64(temperature.GetInstant()))
-			}
-			if voltage := opticalInfo.GetVoltage(); voltage != nil {
-				metric.OpticalVoltageVol33 = float64Ptr(float64(voltage.GetVol33()))
-				metric.OpticalVoltageVol5 = float64Ptr(float64(voltage.GetVol5()))
-			}
+			metrics = append(metrics, metric)
+		}
 
-			// 解析告警数据
-			if alarm := opticalInfo.GetAlarm(); alarm != nil {
-				if alarm.GetLosStatus() != 0 {
-					alarmStatusStr := convertAlarmStatus(int32(alarm.GetLosStatus()))
-					metric.OpticalAlarmLosStatus = &alarmStatusStr
-				}
-				
-				if alarmInfo := alarm.GetLosInfo(); alarmInfo != nil {
-					metric.OpticalAlarmLosInfoEventID = uint32Ptr(alarmInfo.GetEventId())
-					metric.OpticalAlarmLosInfoEventInterval = uint32Ptr(alarmInfo.GetEventInterval())
-					
-					if inPowers := alarmInfo.GetOptInPower(); len(inPowers) > 0 {
-						metric.OpticalAlarmLosInfoInPower = opticalPowerPtr(float64(inPowers[0].GetInstant()), true)
-					} else {
-						metric.OpticalAlarmLosInfoInPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-					}
-					if outPowers := alarmInfo.GetOptOutPower(); len(outPowers) > 0 {
-						metric.OpticalAlarmLosInfoOutPower = opticalPowerPtr(float64(outPowers[0].GetInstant()), true)
-					} else {
-						metric.OpticalAlarmLosInfoOutPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
-					}
-				}
-			}
+		proto.Reset(componentInfo)
+		p.componentPool.Put(componentInfo)
+	}
 
-			if onlineStatus := opticalInfo.GetOnlineStatus(); onlineStatus != nil {
-				metric.OpticalOnlineStatus = stringPtr(onlineStatus.GetOnlineStatus())
-			}
+	return metrics, nil
+}
 
-			if rxThreshold := opticalInfo.GetRxThreshold(); rxThreshold != nil {
-				metric.OpticalRxThresholdHighAlarm = float64Ptr(float64(rxThreshold.GetHighAlarm()))
-				metric.OpticalRxThresholdPreHighAlarm = float64Ptr(float64(rxThreshold.GetPreHighAlarm()))
-				metric.OpticalRxThresholdLowAlarm = float64Ptr(float64(rxThreshold.GetLowAlarm()))
-				metric.OpticalRxThresholdPreLowAlarm = float64Ptr(float64(rxThreshold.GetPreLowAlarm()))
-			}
+// parseComponentOpticalChannels 解析组件光通道数据 (GetOpticalchanInfo为数组，每个通道一行)
+func (p *TelemetryParser) parseComponentOpticalChannels(msg *zteTelemetry.Telemetry) ([]models.OpticalChannelMetric, error) {
+	if len(msg.DataGpb) == 0 {
+		return nil, fmt.Errorf("DataGpb为空")
+	}
 
-			metrics = append(metrics, metric)
+	var metrics []models.OpticalChannelMetric
+
+	// 遍历所有DataGpb条目
+	for _, dataGpb := range msg.DataGpb {
+		componentInfo := p.componentPool.Get().(*platformProto.ComponentInfo)
+		if err := proto.Unmarshal(dataGpb.GetContent(), componentInfo); err != nil {
+			p.componentPool.Put(componentInfo)
+			return nil, fmt.Errorf("解析组件信息失败: %v", err)
 		}
 
+		metrics = append(metrics, opticalChannelMetrics(msg, componentInfo)...)
+
 		proto.Reset(componentInfo)
 		p.componentPool.Put(componentInfo)
 	}
@@ -931,25 +1061,107 @@ func (p *TelemetryParser) parseComponentTransceiverState(msg *zteTelemetry.Telem
 	return metrics, nil
 }
 
-// parseInterfaceState 解析接口状态数据
-func (p *TelemetryParser) parseInterfaceState(msg *zteTelemetry.Telemetry) ([]models.InterfaceMetric, error) {
+// opticalChannelMetrics 提取组件内的光通道指标
+func opticalChannelMetrics(msg *zteTelemetry.Telemetry, componentInfo *platformProto.ComponentInfo) []models.OpticalChannelMetric {
+	var metrics []models.OpticalChannelMetric
+	for _, channel := range componentInfo.GetOpticalchanInfo() {
+		opticalInfo := channel.GetOptchanInfo()
+		if opticalInfo == nil {
+			continue
+		}
+		metrics = append(metrics, models.OpticalChannelMetric{
+			Timestamp:     time.UnixMilli(int64(msg.MsgTimestamp)),
+			SystemID:      msg.SystemId,
+			ComponentName: componentInfo.GetName(),
+			ChannelIndex:  channel.GetIndex(),
+			OpticalData:   parseOpticalData(opticalInfo),
+		})
+	}
+	return metrics
+}
+
+// parseOpticalData 解析光模块/光通道的光功率、告警及门限数据
+func parseOpticalData(opticalInfo *platformProto.OpticalInfo) *models.OpticalData {
+	data := &models.OpticalData{}
+
+	if inPower := opticalInfo.GetInPower(); inPower != nil {
+		data.OpticalInPower = opticalPowerPtr(float64(inPower.GetInstant()), true)
+	} else {
+		data.OpticalInPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
+	}
+	if outPower := opticalInfo.GetOutPower(); outPower != nil {
+		data.OpticalOutPower = opticalPowerPtr(float64(outPower.GetInstant()), true)
+	} else {
+		data.OpticalOutPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
+	}
+	if biasCurrent := opticalInfo.GetBiasCurrent(); biasCurrent != nil {
+		data.OpticalBiasCurrent = float64Ptr(float64(biasCurrent.GetInstant()))
+	}
+	if temperature := opticalInfo.GetTemperature(); temperature != nil {
+		data.OpticalTemperature = float64Ptr(float64(temperature.GetInstant()))
+	}
+	if voltage := opticalInfo.GetVoltage(); voltage != nil {
+		data.OpticalVoltageVol33 = float64Ptr(float64(voltage.GetVol33()))
+		data.OpticalVoltageVol5 = float64Ptr(float64(voltage.GetVol5()))
+	}
+
+	// 解析告警数据
+	if alarm := opticalInfo.GetAlarm(); alarm != nil {
+		if alarm.GetLosStatus() != 0 {
+			alarmStatusStr := convertAlarmStatus(int32(alarm.GetLosStatus()))
+			data.OpticalAlarmLosStatus = &alarmStatusStr
+		}
+
+		if alarmInfo := alarm.GetLosInfo(); alarmInfo != nil {
+			data.OpticalAlarmLosInfoEventID = uint32Ptr(alarmInfo.GetEventId())
+			data.OpticalAlarmLosInfoEventInterval = uint32Ptr(alarmInfo.GetEventInterval())
+
+			if inPowers := alarmInfo.GetOptInPower(); len(inPowers) > 0 {
+				data.OpticalAlarmLosInfoInPower = opticalPowerPtr(float64(inPowers[0].GetInstant()), true)
+			} else {
+				data.OpticalAlarmLosInfoInPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
+			}
+			if outPowers := alarmInfo.GetOptOutPower(); len(outPowers) > 0 {
+				data.OpticalAlarmLosInfoOutPower = opticalPowerPtr(float64(outPowers[0].GetInstant()), true)
+			} else {
+				data.OpticalAlarmLosInfoOutPower = opticalPowerPtr(0, false) // 无光功率数据，设为-60
+			}
+		}
+	}
+
+	if onlineStatus := opticalInfo.GetOnlineStatus(); onlineStatus != nil {
+		data.OpticalOnlineStatus = stringPtr(onlineStatus.GetOnlineStatus())
+	}
+
+	if rxThreshold := opticalInfo.GetRxThreshold(); rxThreshold != nil {
+		data.OpticalRxThresholdHighAlarm = float64Ptr(float64(rxThreshold.GetHighAlarm()))
+		data.OpticalRxThresholdPreHighAlarm = float64Ptr(float64(rxThreshold.GetPreHighAlarm()))
+		data.OpticalRxThresholdLowAlarm = float64Ptr(float64(rxThreshold.GetLowAlarm()))
+		data.OpticalRxThresholdPreLowAlarm = float64Ptr(float64(rxThreshold.GetPreLowAlarm()))
+	}
+
+	return data
+}
+
+// parseInterfaceState 解析接口状态数据，接口消息内嵌的子接口同时输出为子接口指标
+func (p *TelemetryParser) parseInterfaceState(msg *zteTelemetry.Telemetry) ([]models.InterfaceMetric, []models.SubinterfaceMetric, error) {
 	if len(msg.DataGpb) == 0 {
-		return nil, fmt.Errorf("DataGpb为空")
+		return nil, nil, fmt.Errorf("DataGpb为空")
 	}
 	
 	var metrics []models.InterfaceMetric
+	var subMetrics []models.SubinterfaceMetric
 
 	// 遍历所有DataGpb条目
 	for _, dataGpb := range msg.DataGpb {
 		interfaceInfo := p.interfacePool.Get().(*interfaceProto.InterfaceInfo)
 		if err := proto.Unmarshal(dataGpb.GetContent(), interfaceInfo); err != nil {
 			p.interfacePool.Put(interfaceInfo)
-			return nil, fmt.Errorf("解析接口信息失败: %v", err)
+			return nil, nil, fmt.Errorf("解析接口信息失败: %v", err)
 		}
 
-		// 解析InterfaceState数据 (GetState返回数组，取第一个)
-		if states := interfaceInfo.GetState(); len(states) > 0 {
-			state := states[0]
+		// 解析InterfaceState数据 (GetState返回数组，逐个输出)
+		for _, state := range interfaceInfo.GetState() {
 			metric := models.InterfaceMetric{
 				Timestamp:     time.UnixMilli(int64(msg.MsgTimestamp)),
 				SystemID:      msg.SystemId,
@@ -978,32 +1190,35 @@ func (p *TelemetryParser) parseInterfaceState(msg *zteTelemetry.Telemetry) ([]mo
 			metrics = append(metrics, metric)
 		}
 
+		// 接口消息内嵌的子接口
+		subMetrics = append(subMetrics, subinterfaceStateMetrics(msg, interfaceInfo, false)...)
+
 		proto.Reset(interfaceInfo)
 		p.interfacePool.Put(interfaceInfo)
 	}
 
-	return metrics, nil
+	return metrics, subMetrics, nil
 }
 
-// parseInterfaceZteState 解析接口ZTE扩展状态数据
-func (p *TelemetryParser) parseInterfaceZteState(msg *zteTelemetry.Telemetry) ([]models.InterfaceMetric, error) {
+// parseInterfaceZteState 解析接口ZTE扩展状态数据，接口消息内嵌的子接口同时输出为子接口指标
+func (p *TelemetryParser) parseInterfaceZteState(msg *zteTelemetry.Telemetry) ([]models.InterfaceMetric, []models.SubinterfaceMetric, error) {
 	if len(msg.DataGpb) == 0 {
-		return nil, fmt.Errorf("DataGpb为空")
+		return nil, nil, fmt.Errorf("DataGpb为空")
 	}
 	
 	var metrics []models.InterfaceMetric
+	var subMetrics []models.SubinterfaceMetric
 
 	// 遍历所有DataGpb条目
 	for _, dataGpb := range msg.DataGpb {
 		interfaceInfo := p.interfacePool.Get().(*interfaceProto.InterfaceInfo)
 		if err := proto.Unmarshal(dataGpb.GetContent(), interfaceInfo); err != nil {
 			p.interfacePool.Put(interfaceInfo)
-			return nil, fmt.Errorf("解析接口信息失败: %v", err)
+			return nil, nil, fmt.Errorf("解析接口信息失败: %v", err)
 		}
 
-		// 解析ZTE扩展状态数据
-		if zteStates := interfaceInfo.GetStatePeriod(); len(zteStates) > 0 {
-			zteState := zteStates[0]
+		// 解析ZTE扩展状态数据 (GetStatePeriod返回数组，逐个输出)
+		for _, zteState := range interfaceInfo.GetStatePeriod() {
 			metric := models.InterfaceMetric{
 				Timestamp:     time.UnixMilli(int64(msg.MsgTimestamp)),
 				SystemID:      msg.SystemId,
@@ -1032,20 +1247,24 @@ func (p *TelemetryParser) parseInterfaceZteState(msg *zteTelemetry.Telemetry) ([
 			metrics = append(metrics, metric)
 		}
 
+		// 接口消息内嵌的子接口
+		subMetrics = append(subMetrics, subinterfaceZteStateMetrics(msg, interfaceInfo, false)...)
+
 		proto.Reset(interfaceInfo)
 		p.interfacePool.Put(interfaceInfo)
 	}
 
-	return metrics, nil
+	return metrics, subMetrics, nil
 }
 
-// parseInterfaceCounters 解析接口计数器数据
-func (p *TelemetryParser) parseInterfaceCounters(msg *zteTelemetry.Telemetry) ([]models.InterfaceMetric, error) {
+// parseInterfaceCounters 解析接口计数器数据，接口消息内嵌的子接口同时输出为子接口指标
+func (p *TelemetryParser) parseInterfaceCounters(msg *zteTelemetry.Telemetry) ([]models.InterfaceMetric, []models.SubinterfaceMetric, error) {
 	if len(msg.DataGpb) == 0 {
-		return nil, fmt.Errorf("DataGpb为空")
+		return nil, nil, fmt.Errorf("DataGpb为空")
 	}
 	
 	var metrics []models.InterfaceMetric
+	var subMetrics []models.SubinterfaceMetric
 
 	// 遍历所有DataGpb条目，每个可能包含一个或多个接口的信息
 	for _, dataGpb := range msg.DataGpb {
@@ -1056,9 +1275,8 @@ func (p *TelemetryParser) parseInterfaceCounters(msg *zteTelemetry.Telemetry) ([
 			continue
 		}
 
-		// 解析Counters数据 (GetCounters返回数组，取第一个)
-		if countersList := interfaceInfo.GetCounters(); len(countersList) > 0 {
-			counters := countersList[0]
+		// 解析Counters数据 (GetCounters返回数组，逐个输出)
+		for _, counters := range interfaceInfo.GetCounters() {
 			metric := models.InterfaceMetric{
 				Timestamp:     time.UnixMilli(int64(msg.MsgTimestamp)),
 				SystemID:      msg.SystemId,
@@ -1083,12 +1301,12 @@ func (p *TelemetryParser) parseInterfaceCounters(msg *zteTelemetry.Telemetry) ([
 			metric.LastClear = timePtr(nanosToTimestamp(counters.GetLastClear()))
 			metric.InPkts = uint64Ptr(counters.GetInPkts())
 			metric.OutPkts = uint64Ptr(counters.GetOutPkts())
-			metric.InputUtilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetInputUtilization())))
-			metric.OutputUtilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetOutputUtilization())))
-			metric.InTrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetInTrafficRate()))
-			metric.InPacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetInPacketRate()))
-			metric.OutTrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetOutTrafficRate()))
-			metric.OutPacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetOutPacketRate()))
+			metric.InputUtilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetInputUtilization())))
+			metric.OutputUtilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetOutputUtilization())))
+			metric.InTrafficRateMbps = float64Ptr(roundRate(float64(counters.GetInTrafficRate())))
+			metric.InPacketRateKfps = float64Ptr(roundRate(float64(counters.GetInPacketRate())))
+			metric.OutTrafficRateMbps = float64Ptr(roundRate(float64(counters.GetOutTrafficRate())))
+			metric.OutPacketRateKfps = float64Ptr(roundRate(float64(counters.GetOutPacketRate())))
 			metric.InV4Octets = uint64Ptr(counters.GetInV4Octets())
 			metric.OutV4Octets = uint64Ptr(counters.GetOutV4Octets())
 			metric.InV4Pkts = uint64Ptr(counters.GetInV4Pkts())
@@ -1097,18 +1315,18 @@ func (p *TelemetryParser) parseInterfaceCounters(msg *zteTelemetry.Telemetry) ([
 			metric.OutV6Octets = uint64Ptr(counters.GetOutV6Octets())
 			metric.InV6Pkts = uint64Ptr(counters.GetInV6Pkts())
 			metric.OutV6Pkts = uint64Ptr(counters.GetOutV6Pkts())
-			metric.InV4TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetInV4TrafficRate()))
-			metric.InV4PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetInV4PacketRate()))
-			metric.OutV4TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetOutV4TrafficRate()))
-			metric.OutV4PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetOutV4PacketRate()))
-			metric.InV6TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetInV6TrafficRate()))
-			metric.InV6PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetInV6PacketRate()))
-			metric.OutV6TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetOutV6TrafficRate()))
-			metric.OutV6PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetOutV6PacketRate()))
-			metric.InputV4Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetInputV4Utilization())))
-			metric.OutputV4Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetOutputV4Utilization())))
-			metric.InputV6Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetInputV6Utilization())))
-			metric.OutputV6Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetOutputV6Utilization())))
+			metric.InV4TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetInV4TrafficRate())))
+			metric.InV4PacketRateKfps = float64Ptr(roundRate(float64(counters.GetInV4PacketRate())))
+			metric.OutV4TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetOutV4TrafficRate())))
+			metric.OutV4PacketRateKfps = float64Ptr(roundRate(float64(counters.GetOutV4PacketRate())))
+			metric.InV6TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetInV6TrafficRate())))
+			metric.InV6PacketRateKfps = float64Ptr(roundRate(float64(counters.GetInV6PacketRate())))
+			metric.OutV6TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetOutV6TrafficRate())))
+			metric.OutV6PacketRateKfps = float64Ptr(roundRate(float64(counters.GetOutV6PacketRate())))
+			metric.InputV4Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetInputV4Utilization())))
+			metric.OutputV4Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetOutputV4Utilization())))
+			metric.InputV6Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetInputV6Utilization())))
+			metric.OutputV6Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetOutputV6Utilization())))
 			metric.InBierOctets = uint64Ptr(counters.GetInBierOctets())
 			metric.InBierPkts = uint64Ptr(counters.GetInBierPkts())
 			metric.OutBierOctets = uint64Ptr(counters.GetOutBierOctets())
@@ -1117,19 +1335,40 @@ func (p *TelemetryParser) parseInterfaceCounters(msg *zteTelemetry.Telemetry) ([
 			metrics = append(metrics, metric)
 		}
 
+		// 接口消息内嵌的子接口
+		subMetrics = append(subMetrics, subinterfaceCountersMetrics(msg, interfaceInfo, false)...)
+
 		proto.Reset(interfaceInfo)
 		p.interfacePool.Put(interfaceInfo)
 	}
 
-	return metrics, nil
+	return metrics, subMetrics, nil
 }
 
 // parseSubinterfaceState 解析子接口状态数据
 func (p *TelemetryParser) parseSubinterfaceState(msg *zteTelemetry.Telemetry) ([]models.SubinterfaceMetric, error) {
+	return p.parseSubinterfaces(msg, subinterfaceStateMetrics)
+}
+
+// parseSubinterfaceZteState 解析子接口ZTE扩展状态数据
+func (p *TelemetryParser) parseSubinterfaceZteState(msg *zteTelemetry.Telemetry) ([]models.SubinterfaceMetric, error) {
+	return p.parseSubinterfaces(msg, subinterfaceZteStateMetrics)
+}
+
+// parseSubinterfaceCounters 解析子接口计数器数据
+func (p *TelemetryParser) parseSubinterfaceCounters(msg *zteTelemetry.Telemetry) ([]models.SubinterfaceMetric, error) {
+	return p.parseSubinterfaces(msg, subinterfaceCountersMetrics)
+}
+
+// subinterfaceExtractor 从接口消息中提取子接口指标，keepEmpty为true时无数据的子接口也输出一行
+type subinterfaceExtractor func(msg *zteTelemetry.Telemetry, interfaceInfo *interfaceProto.InterfaceInfo, keepEmpty bool) []models.SubinterfaceMetric
+
+// parseSubinterfaces 解码每个DataGpb中的接口消息并提取子接口指标
+func (p *TelemetryParser) parseSubinterfaces(msg *zteTelemetry.Telemetry, extract subinterfaceExtractor) ([]models.SubinterfaceMetric, error) {
 	if len(msg.DataGpb) == 0 {
 		return nil, fmt.Errorf("DataGpb为空")
 	}
-	
+
 	var metrics []models.SubinterfaceMetric
 
 	// 遍历所有DataGpb条目
@@ -1140,37 +1379,7 @@ func (p *TelemetryParser) parseSubinterfaceState(msg *zteTelemetry.Telemetry) ([
 			return nil, fmt.Errorf("解析接口信息失败: %v", err)
 		}
 
-		// 遍历子接口
-		for _, subintf := range interfaceInfo.GetSubinterface() {
-			// 创建基础metric
-			metric := models.SubinterfaceMetric{
-		Timestamp:        time.UnixMilli(int64(msg.MsgTimestamp)),
-		SystemID:         msg.SystemId,
-		InterfaceName:    interfaceInfo.GetName(),
-		SubinterfaceName: fmt.Sprintf("%d", subintf.GetSubPort()),
-	}
-			
-			// 解析子接口状态数据 (GetState返回数组，取第一个)
-			if states := subintf.GetState(); len(states) > 0 {
-				state := states[0]
-				metric.Ifindex = uint32Ptr(state.GetIfindex())
-				
-				// 将枚举值转换为字符串
-				adminStatusStr := convertAdminStatus(int32(state.GetAdminStatus()))
-				metric.AdminStatusStr = &adminStatusStr
-				
-				operStatusStr := convertOperStatus(int32(state.GetOperStatus()))
-				metric.OperStatusStr = &operStatusStr
-				
-				metric.LastChange = timePtr(nanosToTimestamp(state.GetLastChange()))
-				metric.Logical = boolPtr(state.GetLogical())
-				
-				ipv4OperStatusStr := convertIPv4OperStatus(int32(state.GetIpv4OperStatus()))
-				metric.IPv4OperStatusStr = &ipv4OperStatusStr
-			}
-
-			metrics = append(metrics, metric)
-		}
+		metrics = append(metrics, extract(msg, interfaceInfo, true)...)
 
 		proto.Reset(interfaceInfo)
 		p.interfacePool.Put(interfaceInfo)
@@ -1179,148 +1388,145 @@ func (p *TelemetryParser) parseSubinterfaceState(msg *zteTelemetry.Telemetry) ([
 	return metrics, nil
 }
 
-// parseSubinterfaceZteState 解析子接口ZTE扩展状态数据
-func (p *TelemetryParser) parseSubinterfaceZteState(msg *zteTelemetry.Telemetry) ([]models.SubinterfaceMetric, error) {
-	if len(msg.DataGpb) == 0 {
-		return nil, fmt.Errorf("DataGpb为空")
-	}
-	
-	var metrics []models.SubinterfaceMetric
-
-	// 遍历所有DataGpb条目
-	for _, dataGpb := range msg.DataGpb {
-		interfaceInfo := p.interfacePool.Get().(*interfaceProto.InterfaceInfo)
-		if err := proto.Unmarshal(dataGpb.GetContent(), interfaceInfo); err != nil {
-			p.interfacePool.Put(interfaceInfo)
-			return nil, fmt.Errorf("解析接口信息失败: %v", err)
-		}
-
-		// 遍历子接口
-		for _, subintf := range interfaceInfo.GetSubinterface() {
-			// 创建基础metric
-			metric := models.SubinterfaceMetric{
+// newSubinterfaceMetric 创建子接口基础指标
+func newSubinterfaceMetric(msg *zteTelemetry.Telemetry, interfaceInfo *interfaceProto.InterfaceInfo, subintf *interfaceProto.SubinterfaceInfo) models.SubinterfaceMetric {
+	return models.SubinterfaceMetric{
 		Timestamp:        time.UnixMilli(int64(msg.MsgTimestamp)),
 		SystemID:         msg.SystemId,
 		InterfaceName:    interfaceInfo.GetName(),
 		SubinterfaceName: fmt.Sprintf("%d", subintf.GetSubPort()),
 	}
-			
-			// 解析ZTE扩展状态数据
-			if zteStates := subintf.GetSubStatePeriod(); len(zteStates) > 0 {
-				zteState := zteStates[0]
-				metric.ZteifIfindex = uint32Ptr(zteState.GetIfindex())
-				
-				// 将枚举值转换为字符串
-				zteifAdminStatusStr := convertAdminStatus(int32(zteState.GetAdminStatus()))
-				metric.ZteifAdminStatusStr = &zteifAdminStatusStr
-				
-				zteifOperStatusStr := convertOperStatus(int32(zteState.GetOperStatus()))
-				metric.ZteifOperStatusStr = &zteifOperStatusStr
-				
-				zteifPhyStatusStr := convertPhyStatus(int32(zteState.GetPhyStatus()))
-				metric.ZteifPhyStatusStr = &zteifPhyStatusStr
-				
-				zteifIPv4OperStatusStr := convertIPv4OperStatus(int32(zteState.GetIpv4OperStatus()))
-				metric.ZteifIPv4OperStatusStr = &zteifIPv4OperStatusStr
-				
-				zteifIPv6OperStatusStr := convertIPv6OperStatus(int32(zteState.GetIpv6OperStatus()))
-				metric.ZteifIPv6OperStatusStr = &zteifIPv6OperStatusStr
-			}
-		metrics = append(metrics, metric)
+}
+
+// subinterfaceStateMetrics 提取子接口状态 (GetState返回数组，逐个输出)
+func subinterfaceStateMetrics(msg *zteTelemetry.Telemetry, interfaceInfo *interfaceProto.InterfaceInfo, keepEmpty bool) []models.SubinterfaceMetric {
+	var metrics []models.SubinterfaceMetric
+	for _, subintf := range interfaceInfo.GetSubinterface() {
+		states := subintf.GetState()
+		if len(states) == 0 && keepEmpty {
+			metrics = append(metrics, newSubinterfaceMetric(msg, interfaceInfo, subintf))
 		}
 
-		proto.Reset(interfaceInfo)
-		p.interfacePool.Put(interfaceInfo)
-	}
+		for _, state := range states {
+			metric := newSubinterfaceMetric(msg, interfaceInfo, subintf)
+			metric.Ifindex = uint32Ptr(state.GetIfindex())
 
-	return metrics, nil
-}
+			// 将枚举值转换为字符串
+			adminStatusStr := convertAdminStatus(int32(state.GetAdminStatus()))
+			metric.AdminStatusStr = &adminStatusStr
 
-// parseSubinterfaceCounters 解析子接口计数器数据
-func (p *TelemetryParser) parseSubinterfaceCounters(msg *zteTelemetry.Telemetry) ([]models.SubinterfaceMetric, error) {
-	if len(msg.DataGpb) == 0 {
-		return nil, fmt.Errorf("DataGpb为空")
+			operStatusStr := convertOperStatus(int32(state.GetOperStatus()))
+			metric.OperStatusStr = &operStatusStr
+
+			metric.LastChange = timePtr(nanosToTimestamp(state.GetLastChange()))
+			metric.Logical = boolPtr(state.GetLogical())
+
+			ipv4OperStatusStr := convertIPv4OperStatus(int32(state.GetIpv4OperStatus()))
+			metric.IPv4OperStatusStr = &ipv4OperStatusStr
+
+			metrics = append(metrics, metric)
+		}
 	}
-	
-	var metrics []models.SubinterfaceMetric
+	return metrics
+}
 
-	// 遍历所有DataGpb条目
-	for _, dataGpb := range msg.DataGpb {
-		interfaceInfo := p.interfacePool.Get().(*interfaceProto.InterfaceInfo)
-		if err := proto.Unmarshal(dataGpb.GetContent(), interfaceInfo); err != nil {
-			p.interfacePool.Put(interfaceInfo)
-			return nil, fmt.Errorf("解析接口信息失败: %v", err)
+// subinterfaceZteStateMetrics 提取子接口ZTE扩展状态 (GetSubStatePeriod返回数组，逐个输出)
+func subinterfaceZteStateMetrics(msg *zteTelemetry.Telemetry, interfaceInfo *interfaceProto.InterfaceInfo, keepEmpty bool) []models.SubinterfaceMetric {
+	var metrics []models.SubinterfaceMetric
+	for _, subintf := range interfaceInfo.GetSubinterface() {
+		zteStates := subintf.GetSubStatePeriod()
+		if len(zteStates) == 0 && keepEmpty {
+			metrics = append(metrics, newSubinterfaceMetric(msg, interfaceInfo, subintf))
 		}
 
-		// 遍历子接口
-		for _, subintf := range interfaceInfo.GetSubinterface() {
-			// 创建基础metric
-			metric := models.SubinterfaceMetric{
-		Timestamp:        time.UnixMilli(int64(msg.MsgTimestamp)),
-		SystemID:         msg.SystemId,
-		InterfaceName:    interfaceInfo.GetName(),
-		SubinterfaceName: fmt.Sprintf("%d", subintf.GetSubPort()),
-	}
-			
-			// 解析Counters数据 (GetCounters返回数组，取第一个)
-			if countersList := subintf.GetCounters(); len(countersList) > 0 {
-				counters := countersList[0]
-				metric.InOctets = uint64Ptr(counters.GetInOctets())
-				metric.InUnicastPkts = uint64Ptr(counters.GetInUnicastPkts())
-				metric.InBroadcastPkts = uint64Ptr(counters.GetInBroadcastPkts())
-				metric.InMulticastPkts = uint64Ptr(counters.GetInMulticastPkts())
-				metric.InDiscards = uint64Ptr(counters.GetInDiscards())
-				metric.InErrors = uint64Ptr(counters.GetInErrors())
-				metric.InUnknownProtos = uint64Ptr(counters.GetInUnknownProtos())
-				metric.InFcsErrors = uint64Ptr(counters.GetInFcsErrors())
-				metric.OutOctets = uint64Ptr(counters.GetOutOctets())
-				metric.OutUnicastPkts = uint64Ptr(counters.GetOutUnicastPkts())
-				metric.OutBroadcastPkts = uint64Ptr(counters.GetOutBroadcastPkts())
-				metric.OutMulticastPkts = uint64Ptr(counters.GetOutMulticastPkts())
-				metric.OutDiscards = uint64Ptr(counters.GetOutDiscards())
-				metric.OutErrors = uint64Ptr(counters.GetOutErrors())
-				metric.CarrierTransitions = uint64Ptr(counters.GetCarrierTransitions())
-				metric.LastClear = timePtr(nanosToTimestamp(counters.GetLastClear()))
-				metric.InPkts = uint64Ptr(counters.GetInPkts())
-				metric.OutPkts = uint64Ptr(counters.GetOutPkts())
-				metric.InputUtilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetInputUtilization())))
-				metric.OutputUtilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetOutputUtilization())))
-				metric.InTrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetInTrafficRate()))
-				metric.InPacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetInPacketRate()))
-				metric.OutTrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetOutTrafficRate()))
-				metric.OutPacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetOutPacketRate()))
-				metric.InV4Octets = uint64Ptr(counters.GetInV4Octets())
-				metric.OutV4Octets = uint64Ptr(counters.GetOutV4Octets())
-				metric.InV4Pkts = uint64Ptr(counters.GetInV4Pkts())
-				metric.OutV4Pkts = uint64Ptr(counters.GetOutV4Pkts())
-				metric.InV6Octets = uint64Ptr(counters.GetInV6Octets())
-				metric.OutV6Octets = uint64Ptr(counters.GetOutV6Octets())
-				metric.InV6Pkts = uint64Ptr(counters.GetInV6Pkts())
-				metric.OutV6Pkts = uint64Ptr(counters.GetOutV6Pkts())
-				metric.InV4TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetInV4TrafficRate()))
-				metric.InV4PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetInV4PacketRate()))
-				metric.OutV4TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetOutV4TrafficRate()))
-				metric.OutV4PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetOutV4PacketRate()))
-				metric.InV6TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetInV6TrafficRate()))
-				metric.InV6PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetInV6PacketRate()))
-				metric.OutV6TrafficRate = stringPtr(fmt.Sprintf("%.2f Mbps", counters.GetOutV6TrafficRate()))
-				metric.OutV6PacketRate = stringPtr(fmt.Sprintf("%.2f Kfps", counters.GetOutV6PacketRate()))
-				metric.InputV4Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetInputV4Utilization())))
-				metric.OutputV4Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetOutputV4Utilization())))
-				metric.InputV6Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetInputV6Utilization())))
-				metric.OutputV6Utilization = stringPtr(fmt.Sprintf("%.2f", utilizationToNumeric(counters.GetOutputV6Utilization())))
-				metric.InBierOctets = uint64Ptr(counters.GetInBierOctets())
-				metric.InBierPkts = uint64Ptr(counters.GetInBierPkts())
-				metric.OutBierOctets = uint64Ptr(counters.GetOutBierOctets())
-				metric.OutBierPkts = uint64Ptr(counters.GetOutBierPkts())
-			}
+		for _, zteState := range zteStates {
+			metric := newSubinterfaceMetric(msg, interfaceInfo, subintf)
+			metric.ZteifIfindex = uint32Ptr(zteState.GetIfindex())
+
+			// 将枚举值转换为字符串
+			zteifAdminStatusStr := convertAdminStatus(int32(zteState.GetAdminStatus()))
+			metric.ZteifAdminStatusStr = &zteifAdminStatusStr
+
+			zteifOperStatusStr := convertOperStatus(int32(zteState.GetOperStatus()))
+			metric.ZteifOperStatusStr = &zteifOperStatusStr
+
+			zteifPhyStatusStr := convertPhyStatus(int32(zteState.GetPhyStatus()))
+			metric.ZteifPhyStatusStr = &zteifPhyStatusStr
+
+			zteifIPv4OperStatusStr := convertIPv4OperStatus(int32(zteState.GetIpv4OperStatus()))
+			metric.ZteifIPv4OperStatusStr = &zteifIPv4OperStatusStr
+
+			zteifIPv6OperStatusStr := convertIPv6OperStatus(int32(zteState.GetIpv6OperStatus()))
+			metric.ZteifIPv6OperStatusStr = &zteifIPv6OperStatusStr
 
 			metrics = append(metrics, metric)
 		}
-
-		proto.Reset(interfaceInfo)
-		p.interfacePool.Put(interfaceInfo)
 	}
+	return metrics
+}
 
-	return metrics, nil
-}
\ No newline at end of file
+// subinterfaceCountersMetrics 提取子接口计数器 (GetCounters返回数组，逐个输出)
+func subinterfaceCountersMetrics(msg *zteTelemetry.Telemetry, interfaceInfo *interfaceProto.InterfaceInfo, keepEmpty bool) []models.SubinterfaceMetric {
+	var metrics []models.SubinterfaceMetric
+	for _, subintf := range interfaceInfo.GetSubinterface() {
+		countersList := subintf.GetCounters()
+		if len(countersList) == 0 && keepEmpty {
+			metrics = append(metrics, newSubinterfaceMetric(msg, interfaceInfo, subintf))
+		}
+
+		for _, counters := range countersList {
+			metric := newSubinterfaceMetric(msg, interfaceInfo, subintf)
+			metric.InOctets = uint64Ptr(counters.GetInOctets())
+			metric.InUnicastPkts = uint64Ptr(counters.GetInUnicastPkts())
+			metric.InBroadcastPkts = uint64Ptr(counters.GetInBroadcastPkts())
+			metric.InMulticastPkts = uint64Ptr(counters.GetInMulticastPkts())
+			metric.InDiscards = uint64Ptr(counters.GetInDiscards())
+			metric.InErrors = uint64Ptr(counters.GetInErrors())
+			metric.InUnknownProtos = uint64Ptr(counters.GetInUnknownProtos())
+			metric.InFcsErrors = uint64Ptr(counters.GetInFcsErrors())
+			metric.OutOctets = uint64Ptr(counters.GetOutOctets())
+			metric.OutUnicastPkts = uint64Ptr(counters.GetOutUnicastPkts())
+			metric.OutBroadcastPkts = uint64Ptr(counters.GetOutBroadcastPkts())
+			metric.OutMulticastPkts = uint64Ptr(counters.GetOutMulticastPkts())
+			metric.OutDiscards = uint64Ptr(counters.GetOutDiscards())
+			metric.OutErrors = uint64Ptr(counters.GetOutErrors())
+			metric.CarrierTransitions = uint64Ptr(counters.GetCarrierTransitions())
+			metric.LastClear = timePtr(nanosToTimestamp(counters.GetLastClear()))
+			metric.InPkts = uint64Ptr(counters.GetInPkts())
+			metric.OutPkts = uint64Ptr(counters.GetOutPkts())
+			metric.InputUtilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetInputUtilization())))
+			metric.OutputUtilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetOutputUtilization())))
+			metric.InTrafficRateMbps = float64Ptr(roundRate(float64(counters.GetInTrafficRate())))
+			metric.InPacketRateKfps = float64Ptr(roundRate(float64(counters.GetInPacketRate())))
+			metric.OutTrafficRateMbps = float64Ptr(roundRate(float64(counters.GetOutTrafficRate())))
+			metric.OutPacketRateKfps = float64Ptr(roundRate(float64(counters.GetOutPacketRate())))
+			metric.InV4Octets = uint64Ptr(counters.GetInV4Octets())
+			metric.OutV4Octets = uint64Ptr(counters.GetOutV4Octets())
+			metric.InV4Pkts = uint64Ptr(counters.GetInV4Pkts())
+			metric.OutV4Pkts = uint64Ptr(counters.GetOutV4Pkts())
+			metric.InV6Octets = uint64Ptr(counters.GetInV6Octets())
+			metric.OutV6Octets = uint64Ptr(counters.GetOutV6Octets())
+			metric.InV6Pkts = uint64Ptr(counters.GetInV6Pkts())
+			metric.OutV6Pkts = uint64Ptr(counters.GetOutV6Pkts())
+			metric.InV4TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetInV4TrafficRate())))
+			metric.InV4PacketRateKfps = float64Ptr(roundRate(float64(counters.GetInV4PacketRate())))
+			metric.OutV4TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetOutV4TrafficRate())))
+			metric.OutV4PacketRateKfps = float64Ptr(roundRate(float64(counters.GetOutV4PacketRate())))
+			metric.InV6TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetInV6TrafficRate())))
+			metric.InV6PacketRateKfps = float64Ptr(roundRate(float64(counters.GetInV6PacketRate())))
+			metric.OutV6TrafficRateMbps = float64Ptr(roundRate(float64(counters.GetOutV6TrafficRate())))
+			metric.OutV6PacketRateKfps = float64Ptr(roundRate(float64(counters.GetOutV6PacketRate())))
+			metric.InputV4Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetInputV4Utilization())))
+			metric.OutputV4Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetOutputV4Utilization())))
+			metric.InputV6Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetInputV6Utilization())))
+			metric.OutputV6Utilization = float64Ptr(roundRate(utilizationToNumeric(counters.GetOutputV6Utilization())))
+			metric.InBierOctets = uint64Ptr(counters.GetInBierOctets())
+			metric.InBierPkts = uint64Ptr(counters.GetInBierPkts())
+			metric.OutBierOctets = uint64Ptr(counters.GetOutBierOctets())
+			metric.OutBierPkts = uint64Ptr(counters.GetOutBierPkts())
+
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+	platformProto "github.com/wwswwsuns/ztelem/proto/openconfig_platform"
+	zteTelemetry "github.com/wwswwsuns/ztelem/proto/zte_telemetry"
+	interfaceProto "github.com/wwswwsuns/ztelem/proto/zxr10_interfaces"
+)
+
+func marshalTelemetry(t *testing.T, sensorPath string, content proto.Message) []byte {
+	t.Helper()
+	payload, err := proto.Marshal(content)
+	if err != nil {
+		t.Fatalf("proto.Marshal(content): %v", err)
+	}
+	data, err := proto.Marshal(&zteTelemetry.Telemetry{
+		SystemId:     "dev-1",
+		SensorPath:   sensorPath,
+		MsgTimestamp: 1700000000000,
+		DataGpb:      []*zteTelemetry.NotificationGpb{{Content: payload}},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal: %v", err)
+	}
+	return data
+}
+
+func TestParseTelemetryData_InterfaceRepeatedElements(t *testing.T) {
+	data := marshalTelemetry(t, "oc-if:interfaces/interface/state/counters", &interfaceProto.InterfaceInfo{
+		Name: "gei-1/2/1",
+		Counters: []*interfaceProto.InterfaceCounters{
+			{InOctets: 100},
+			{InOctets: 200},
+		},
+		Subinterface: []*interfaceProto.SubinterfaceInfo{
+			{SubPort: 9, Counters: []*interfaceProto.SubinterfaceCounters{{InPkts: 7}, {InPkts: 8}}},
+			{SubPort: 10}, // 无计数器的内嵌子接口不输出
+		},
+	})
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.InterfaceMetrics) != 2 {
+		t.Fatalf("len(InterfaceMetrics) = %d, want 2", len(result.InterfaceMetrics))
+	}
+	if *result.InterfaceMetrics[1].InOctets != 200 {
+		t.Errorf("InOctets[1] = %d, want 200", *result.InterfaceMetrics[1].InOctets)
+	}
+	if len(result.SubinterfaceMetrics) != 2 {
+		t.Fatalf("len(SubinterfaceMetrics) = %d, want 2", len(result.SubinterfaceMetrics))
+	}
+	for i, want := range []uint64{7, 8} {
+		m := result.SubinterfaceMetrics[i]
+		if m.InterfaceName != "gei-1/2/1" || m.SubinterfaceName != "9" || *m.InPkts != want {
+			t.Errorf("SubinterfaceMetrics[%d] = %s/%s InPkts=%d", i, m.InterfaceName, m.SubinterfaceName, *m.InPkts)
+		}
+	}
+}
+
+func TestParseTelemetryData_SubinterfaceKeepsEmpty(t *testing.T) {
+	data := marshalTelemetry(t, "oc-if:interfaces/interface/subinterfaces/subinterface/state", &interfaceProto.InterfaceInfo{
+		Name: "gei-1/2/1",
+		Subinterface: []*interfaceProto.SubinterfaceInfo{
+			{SubPort: 9, State: []*interfaceProto.SubinterfaceState{{Ifindex: 1}, {Ifindex: 2}}},
+			{SubPort: 10},
+		},
+	})
+
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+	if len(result.SubinterfaceMetrics) != 3 {
+		t.Fatalf("len(SubinterfaceMetrics) = %d, want 3", len(result.SubinterfaceMetrics))
+	}
+	if got := result.SubinterfaceMetrics[1].Ifindex; got == nil || *got != 2 {
+		t.Errorf("Ifindex[1] = %v, want 2", got)
+	}
+	if m := result.SubinterfaceMetrics[2]; m.SubinterfaceName != "10" || m.Ifindex != nil {
+		t.Errorf("empty subinterface = %+v", m)
+	}
+}
+
+func TestParseTelemetryData_OpticalChannels(t *testing.T) {
+	component := &platformProto.ComponentInfo{
+		Name:        "optical-1/2/1",
+		OpticalInfo: &platformProto.OpticalInfo{InPower: &platformProto.OpticalPower{Instant: -3.5}},
+		OpticalchanInfo: []*platformProto.OptChanInfo{
+			{Index: 1, OptchanInfo: &platformProto.OpticalInfo{InPower: &platformProto.OpticalPower{Instant: -2}}},
+			{Index: 2, OptchanInfo: &platformProto.OpticalInfo{}},
+			{Index: 3}, // 无通道信息
+		},
+	}
+
+	tests := []struct {
+		sensorPath   string
+		wantPlatform int
+	}{
+		{"oc-platform:components/component", 1},
+		{"oc-platform:components/component/oc-transceiver:transceiver/physical-channels/channel/state", 0},
+	}
+	for _, tt := range tests {
+		result, err := newTestParser().ParseTelemetryData(marshalTelemetry(t, tt.sensorPath, component))
+		if err != nil {
+			t.Fatalf("%s: ParseTelemetryData: %v", tt.sensorPath, err)
+		}
+		if len(result.PlatformMetrics) != tt.wantPlatform {
+			t.Errorf("%s: len(PlatformMetrics) = %d, want %d", tt.sensorPath, len(result.PlatformMetrics), tt.wantPlatform)
+		}
+		if len(result.OpticalChannelMetrics) != 2 {
+			t.Fatalf("%s: len(OpticalChannelMetrics) = %d, want 2", tt.sensorPath, len(result.OpticalChannelMetrics))
+		}
+		ch := result.OpticalChannelMetrics[0]
+		if ch.ComponentName != "optical-1/2/1" || ch.ChannelIndex != 1 || *ch.OpticalInPower != -2 {
+			t.Errorf("%s: channel = %s/%d in_power=%v", tt.sensorPath, ch.ComponentName, ch.ChannelIndex, *ch.OpticalInPower)
+		}
+		if got := *result.OpticalChannelMetrics[1].OpticalInPower; got != -60 {
+			t.Errorf("%s: channel 2 in_power = %v, want -60", tt.sensorPath, got)
+		}
+	}
+}
+
+func TestParseResult_SetSystemID(t *testing.T) {
+	data := marshalTelemetry(t, "oc-if:interfaces/interface/state/counters", &interfaceProto.InterfaceInfo{
+		Name:         "gei-1/2/1",
+		Counters:     []*interfaceProto.InterfaceCounters{{InOctets: 1}},
+		Subinterface: []*interfaceProto.SubinterfaceInfo{{SubPort: 9, Counters: []*interfaceProto.SubinterfaceCounters{{InPkts: 1}}}},
+	})
+	result, err := newTestParser().ParseTelemetryData(data)
+	if err != nil {
+		t.Fatalf("ParseTelemetryData: %v", err)
+	}
+
+	result.SetSystemID("dev-1#untrusted")
+	if result.SystemID != "dev-1#untrusted" {
+		t.Errorf("SystemID = %s", result.SystemID)
+	}
+	if got := result.InterfaceMetrics[0].SystemID; got != "dev-1#untrusted" {
+		t.Errorf("InterfaceMetrics[0].SystemID = %s", got)
+	}
+	if got := result.SubinterfaceMetrics[0].SystemID; got != "dev-1#untrusted" {
+		t.Errorf("SubinterfaceMetrics[0].SystemID = %s", got)
+	}
+}
+
+func TestParseResult_RowCount(t *testing.T) {
+	result := &ParseResult{
+		InterfaceMetrics:    make([]models.InterfaceMetric, 2),
+		SubinterfaceMetrics: make([]models.SubinterfaceMetric, 3),
+		AlarmReportMetrics:  make([]models.AlarmReportMetric, 1),
+	}
+	if got := result.RowCount(); got != 6 {
+		t.Errorf("RowCount = %d, want 6", got)
+	}
+}
+
+func TestParseResult_Shift(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	result := &ParseResult{
+		Timestamp:          ts,
+		InterfaceMetrics:   []models.InterfaceMetric{{Timestamp: ts}},
+		AlarmReportMetrics: []models.AlarmReportMetric{{Timestamp: ts}},
+	}
+	result.Shift(-3 * time.Hour)
+
+	want := ts.Add(-3 * time.Hour)
+	if !result.Timestamp.Equal(want) || !result.InterfaceMetrics[0].Timestamp.Equal(want) || !result.AlarmReportMetrics[0].Timestamp.Equal(want) {
+		t.Errorf("shifted = %v %v %v, want %v", result.Timestamp, result.InterfaceMetrics[0].Timestamp, result.AlarmReportMetrics[0].Timestamp, want)
+	}
+	// 未携带的轮次时间保持零值
+	if !result.CollectionStartTime.IsZero() {
+		t.Errorf("CollectionStartTime = %v, want zero", result.CollectionStartTime)
+	}
+}
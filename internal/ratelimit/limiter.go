@@ -0,0 +1,260 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+// Publish报文限速
+//
+// 每条报文依次检查对端地址、system_id、system_id+sensor_path 三级令牌桶，
+// 任一级令牌不足即按该级规则的 action 处理：
+//   - drop 丢弃报文
+//   - sample 超限期间每 sample_n 条保留 1 条
+//   - reject_stream 断开该设备的流，由设备重连
+//
+// 令牌以报文为单位，与报文内的行数无关。
+
+// Action 超限时的处理策略
+type Action string
+
+const (
+	Drop         Action = "drop"
+	Sample       Action = "sample"
+	RejectStream Action = "reject_stream"
+)
+
+// 限速维度，用作Prometheus标签
+const (
+	ScopePeer       = "peer"
+	ScopeDevice     = "device"
+	ScopeSensorPath = "sensor_path"
+)
+
+// Message 单条报文的限速维度，为空的维度不检查
+type Message struct {
+	Peer       string
+	SystemID   string
+	SensorPath string
+}
+
+// Exceeded 报文超出限速
+type Exceeded struct {
+	Scope  string
+	Key    string
+	Action Action
+	Rate   float64
+}
+
+// Error 返回给设备的错误描述（PublishArgs.errors）
+func (e *Exceeded) Error() string {
+	return fmt.Sprintf("rate limited: %s=%s exceeds %g msg/s", e.Scope, e.Key, e.Rate)
+}
+
+// CountKey 限速统计维度
+type CountKey struct {
+	Scope  string
+	Action Action
+}
+
+type rule struct {
+	rate    float64
+	burst   float64
+	action  Action
+	sampleN int64
+}
+
+type bucketKey struct {
+	scope string
+	key   string
+}
+
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	exceeded int64 // 连续超限的报文数，用于sample抽样
+}
+
+// Limiter 令牌桶限速器
+type Limiter struct {
+	defaults  map[string]*rule
+	overrides map[string]map[string]*rule
+	stateTTL  time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	counts  map[CountKey]int64
+}
+
+// NewLimiter 根据配置创建限速器，规则配置错误时返回错误
+func NewLimiter(cfg config.RateLimitConfig) (*Limiter, error) {
+	l := &Limiter{
+		defaults:  make(map[string]*rule, 3),
+		overrides: make(map[string]map[string]*rule, 3),
+		stateTTL:  cfg.StateTTL,
+		now:       time.Now,
+		buckets:   make(map[bucketKey]*bucket),
+		counts:    make(map[CountKey]int64),
+	}
+
+	for scope, r := range map[string]config.RateLimitRule{
+		ScopePeer:       cfg.Peer,
+		ScopeDevice:     cfg.Device,
+		ScopeSensorPath: cfg.SensorPath,
+	} {
+		parsed, err := newRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s限速配置错误: %v", scope, err)
+		}
+		l.defaults[scope] = parsed
+		l.overrides[scope] = make(map[string]*rule)
+	}
+
+	for _, o := range cfg.Overrides {
+		byMatch, ok := l.overrides[o.Scope]
+		if !ok {
+			return nil, fmt.Errorf("不支持的限速覆盖scope: %s（可选 peer/device/sensor_path）", o.Scope)
+		}
+		if o.Match == "" {
+			return nil, fmt.Errorf("%s限速覆盖缺少match", o.Scope)
+		}
+		parsed, err := newRule(o.RateLimitRule)
+		if err != nil {
+			return nil, fmt.Errorf("%s=%s 限速覆盖配置错误: %v", o.Scope, o.Match, err)
+		}
+		byMatch[o.Match] = parsed
+	}
+	return l, nil
+}
+
+// newRule 校验规则并填充默认值，rate为0时返回nil表示不限制
+func newRule(r config.RateLimitRule) (*rule, error) {
+	if r.Rate < 0 {
+		return nil, fmt.Errorf("rate不能为负数: %g", r.Rate)
+	}
+	action := Action(r.Action)
+	switch action {
+	case Drop, Sample, RejectStream:
+	case "":
+		action = Drop
+	default:
+		return nil, fmt.Errorf("不支持的action: %s（可选 drop/sample/reject_stream）", r.Action)
+	}
+	if action == Sample && r.SampleN < 1 {
+		return nil, fmt.Errorf("action=sample 需要配置sample_n")
+	}
+	if r.Rate == 0 {
+		return nil, nil
+	}
+
+	burst := float64(r.Burst)
+	if burst <= 0 {
+		burst = r.Rate
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return &rule{rate: r.Rate, burst: burst, action: action, sampleN: int64(r.SampleN)}, nil
+}
+
+// Allow 检查报文是否在限速内，返回非nil表示报文应被丢弃或断开流
+func (l *Limiter) Allow(m Message) *Exceeded {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	checks := []struct {
+		scope, key, match string
+	}{
+		{ScopePeer, m.Peer, m.Peer},
+		{ScopeDevice, m.SystemID, m.SystemID},
+		{ScopeSensorPath, m.SystemID + " " + m.SensorPath, m.SensorPath},
+	}
+	for _, c := range checks {
+		if c.match == "" {
+			continue
+		}
+		r := l.ruleFor(c.scope, c.match)
+		if r == nil {
+			continue
+		}
+		if e := l.take(bucketKey{c.scope, c.key}, r, now); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// ruleFor 覆盖规则优先于默认规则
+func (l *Limiter) ruleFor(scope, match string) *rule {
+	if r, ok := l.overrides[scope][match]; ok {
+		return r
+	}
+	return l.defaults[scope]
+}
+
+// take 从令牌桶取一个令牌，不足时按规则决定是否放行
+func (l *Limiter) take(key bucketKey, r *rule, now time.Time) *Exceeded {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * r.rate
+		if b.tokens > r.burst {
+			b.tokens = r.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.exceeded = 0
+		return nil
+	}
+
+	b.exceeded++
+	if r.action == Sample && (b.exceeded-1)%r.sampleN == 0 {
+		return nil
+	}
+	l.counts[CountKey{Scope: key.scope, Action: r.action}]++
+	return &Exceeded{Scope: key.scope, Key: key.key, Action: r.action, Rate: r.rate}
+}
+
+// Expire 清理超过state_ttl未使用的令牌桶，返回清理数量
+func (l *Limiter) Expire() int {
+	if l.stateTTL <= 0 {
+		return 0
+	}
+	cutoff := l.now().Add(-l.stateTTL)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	removed := 0
+	for key, b := range l.buckets {
+		if b.last.Before(cutoff) {
+			delete(l.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Counts 按维度与处理策略统计的超限报文数（累计值），sample保留的报文不计入
+func (l *Limiter) Counts() map[CountKey]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[CountKey]int64, len(l.counts))
+	for k, n := range l.counts {
+		counts[k] = n
+	}
+	return counts
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/config"
+)
+
+func newTestLimiter(t *testing.T, cfg config.RateLimitConfig) (*Limiter, *time.Time) {
+	t.Helper()
+	l, err := NewLimiter(cfg)
+	if err != nil {
+		t.Fatalf("NewLimiter: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	l, now := newTestLimiter(t, config.RateLimitConfig{
+		Device: config.RateLimitRule{Rate: 1, Burst: 2},
+	})
+	msg := Message{Peer: "10.1.1.1", SystemID: "PE-01", SensorPath: "oc-if:interfaces/interface/state/counters"}
+
+	for i := 0; i < 2; i++ {
+		if e := l.Allow(msg); e != nil {
+			t.Fatalf("burst message %d limited: %v", i, e)
+		}
+	}
+	e := l.Allow(msg)
+	if e == nil || e.Scope != ScopeDevice || e.Key != "PE-01" || e.Action != Drop {
+		t.Fatalf("Allow over burst = %+v, want device drop", e)
+	}
+
+	// 其他设备使用独立的令牌桶
+	if e := l.Allow(Message{SystemID: "PE-02"}); e != nil {
+		t.Fatalf("PE-02 limited: %v", e)
+	}
+
+	*now = now.Add(time.Second)
+	if e := l.Allow(msg); e != nil {
+		t.Fatalf("after refill limited: %v", e)
+	}
+	if got := l.Counts()[CountKey{ScopeDevice, Drop}]; got != 1 {
+		t.Errorf("device drop count = %d, want 1", got)
+	}
+}
+
+func TestLimiter_OverridesAndScopes(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{
+		Peer:       config.RateLimitRule{Rate: 100},
+		SensorPath: config.RateLimitRule{Rate: 100},
+		Overrides: []config.RateLimitOverride{
+			{Scope: ScopePeer, Match: "10.9.9.9", RateLimitRule: config.RateLimitRule{Rate: 1, Action: "reject_stream"}},
+			{Scope: ScopeSensorPath, Match: "oc-platform:components/component/state", RateLimitRule: config.RateLimitRule{Rate: 1}},
+		},
+	})
+
+	peer := Message{Peer: "10.9.9.9", SystemID: "PE-01"}
+	l.Allow(peer)
+	if e := l.Allow(peer); e == nil || e.Scope != ScopePeer || e.Action != RejectStream {
+		t.Fatalf("peer override = %+v, want reject_stream", e)
+	}
+
+	path := "oc-platform:components/component/state"
+	l.Allow(Message{SystemID: "PE-01", SensorPath: path})
+	if e := l.Allow(Message{SystemID: "PE-01", SensorPath: path}); e == nil || e.Scope != ScopeSensorPath || e.Key != "PE-01 "+path {
+		t.Fatalf("sensor_path override = %+v", e)
+	}
+	// sensor_path 令牌桶按设备区分
+	if e := l.Allow(Message{SystemID: "PE-02", SensorPath: path}); e != nil {
+		t.Fatalf("PE-02 sensor_path limited: %v", e)
+	}
+}
+
+func TestLimiter_Sample(t *testing.T) {
+	l, _ := newTestLimiter(t, config.RateLimitConfig{
+		Device: config.RateLimitRule{Rate: 1, Action: "sample", SampleN: 3},
+	})
+	msg := Message{SystemID: "PE-01"}
+
+	l.Allow(msg) // 消耗唯一的令牌
+	var kept int
+	for i := 0; i < 9; i++ {
+		if l.Allow(msg) == nil {
+			kept++
+		}
+	}
+	if kept != 3 {
+		t.Errorf("kept = %d, want 3 of 9", kept)
+	}
+	if got := l.Counts()[CountKey{ScopeDevice, Sample}]; got != 6 {
+		t.Errorf("sample count = %d, want 6", got)
+	}
+}
+
+func TestLimiter_Expire(t *testing.T) {
+	l, now := newTestLimiter(t, config.RateLimitConfig{
+		Device:   config.RateLimitRule{Rate: 1},
+		StateTTL: time.Minute,
+	})
+	l.Allow(Message{SystemID: "PE-01"})
+	if n := l.Expire(); n != 0 {
+		t.Fatalf("expired too early: %d", n)
+	}
+	*now = now.Add(2 * time.Minute)
+	if n := l.Expire(); n != 1 {
+		t.Fatalf("Expire() = %d, want 1", n)
+	}
+}
+
+func TestNewLimiter_InvalidConfig(t *testing.T) {
+	tests := []config.RateLimitConfig{
+		{Device: config.RateLimitRule{Rate: -1}},
+		{Peer: config.RateLimitRule{Rate: 1, Action: "block"}},
+		{SensorPath: config.RateLimitRule{Rate: 1, Action: "sample"}},
+		{Overrides: []config.RateLimitOverride{{Scope: "interface", Match: "x"}}},
+		{Overrides: []config.RateLimitOverride{{Scope: ScopeDevice}}},
+	}
+	for _, cfg := range tests {
+		if _, err := NewLimiter(cfg); err == nil {
+			t.Errorf("NewLimiter(%+v) should fail", cfg)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package rates
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+// 服务端计数器速率计算
+//
+// 设备自带的 in_traffic_rate 等字段精度低且部分型号不上报，而 InOctets 等计数器为累计值。
+// Calculator 按 (system_id, interface, subinterface) 保存每个计数器上一次的采样值，
+// 用相邻两次采样的差值除以时间间隔得到每秒速率，写入 models.CounterRates。
+//
+// 计数器变小时的处理：
+//   - 上次值位于64位上半区，视为64位回绕，差值按回绕计算
+//   - 否则视为计数器被清零，本次不出速率，以当前值重新建立基线
+//
+// 整行的基线在以下情况下丢弃：LastClear 变化（执行过clear counters）、
+// CarrierTransitions 变小、设备任一组件 uptime 变小（设备或单板重启）。
+
+// wrapThreshold 计数器变小时，上次值不低于该阈值才按64位回绕处理
+const wrapThreshold = uint64(1) << 63
+
+// Stats 速率计算统计（累计值）
+type Stats struct {
+	RatesComputed int64
+	Wraps         int64
+	Resets        int64
+	Restarts      int64
+	Series        int
+}
+
+type seriesKey struct {
+	interfaceName    string
+	subinterfaceName string
+}
+
+type counterSample struct {
+	value uint64
+	at    time.Time
+}
+
+type series struct {
+	counters           []*counterSample
+	lastClear          time.Time
+	carrierTransitions *uint64
+	lastSeen           time.Time
+}
+
+type device struct {
+	series map[seriesKey]*series
+	uptime map[string]time.Duration // 组件名 -> 最近一次uptime
+}
+
+// Calculator 计数器速率计算器
+type Calculator struct {
+	maxInterval time.Duration
+	stateTTL    time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	devices map[string]*device
+
+	ratesComputed int64
+	wraps         int64
+	resets        int64
+	restarts      int64
+}
+
+// NewCalculator 创建速率计算器
+// maxInterval为两次采样的最大间隔，超过则只更新基线不出速率；stateTTL为基线无更新后保留的时长
+func NewCalculator(maxInterval, stateTTL time.Duration) *Calculator {
+	return &Calculator{
+		maxInterval: maxInterval,
+		stateTTL:    stateTTL,
+		now:         time.Now,
+		devices:     make(map[string]*device),
+	}
+}
+
+// ApplyInterfaces 为接口指标计算计数器速率
+func (c *Calculator) ApplyInterfaces(metrics []models.InterfaceMetric) {
+	for i := range metrics {
+		m := &metrics[i]
+		m.CounterRates = c.compute(m.SystemID, seriesKey{interfaceName: m.InterfaceName},
+			m.Timestamp, m.CounterValues(), m.LastClear, m.CarrierTransitions)
+	}
+}
+
+// ApplySubinterfaces 为子接口指标计算计数器速率
+func (c *Calculator) ApplySubinterfaces(metrics []models.SubinterfaceMetric) {
+	for i := range metrics {
+		m := &metrics[i]
+		key := seriesKey{interfaceName: m.InterfaceName, subinterfaceName: m.SubinterfaceName}
+		m.CounterRates = c.compute(m.SystemID, key, m.Timestamp, m.CounterValues(), m.LastClear, m.CarrierTransitions)
+	}
+}
+
+// ObservePlatform 跟踪各组件uptime，任一组件uptime变小时丢弃该设备所有计数器基线
+func (c *Calculator) ObservePlatform(metrics []models.PlatformMetric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range metrics {
+		m := &metrics[i]
+		if m.CommonState == nil || m.Uptime == nil {
+			continue
+		}
+		uptime, ok := parseUptime(*m.Uptime)
+		if !ok {
+			continue
+		}
+		dev := c.device(m.SystemID)
+		if prev, seen := dev.uptime[m.ComponentName]; seen && uptime < prev {
+			atomic.AddInt64(&c.restarts, 1)
+			dev.series = make(map[seriesKey]*series)
+		}
+		dev.uptime[m.ComponentName] = uptime
+	}
+}
+
+// compute 更新基线并返回本次可计算的速率，没有任何速率时返回nil
+func (c *Calculator) compute(systemID string, key seriesKey, ts time.Time, values []*uint64, lastClear *time.Time, carrierTransitions *uint64) *models.CounterRates {
+	if !hasCounter(values) {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dev := c.device(systemID)
+	s, ok := dev.series[key]
+	if !ok {
+		s = &series{counters: make([]*counterSample, len(values))}
+		dev.series[key] = s
+	}
+	s.lastSeen = c.now()
+
+	if s.counterReset(lastClear, carrierTransitions) {
+		atomic.AddInt64(&c.resets, 1)
+		for i := range s.counters {
+			s.counters[i] = nil
+		}
+	}
+	if lastClear != nil {
+		s.lastClear = *lastClear
+	}
+	if carrierTransitions != nil {
+		s.carrierTransitions = carrierTransitions
+	}
+
+	var rates *models.CounterRates
+	var fields []**float64
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		prev := s.counters[i]
+		if prev != nil && !ts.After(prev.at) {
+			continue // 乱序或重复采样，保留原基线
+		}
+		s.counters[i] = &counterSample{value: *v, at: ts}
+		if prev == nil {
+			continue
+		}
+		elapsed := ts.Sub(prev.at)
+		if c.maxInterval > 0 && elapsed > c.maxInterval {
+			continue
+		}
+
+		delta, ok := c.delta(prev.value, *v)
+		if !ok {
+			continue
+		}
+		if rates == nil {
+			rates = &models.CounterRates{}
+			fields = rates.Fields()
+		}
+		rate := float64(delta) / elapsed.Seconds()
+		*fields[i] = &rate
+		atomic.AddInt64(&c.ratesComputed, 1)
+	}
+	return rates
+}
+
+// delta 计算两次采样的差值，计数器被清零时返回false
+func (c *Calculator) delta(prev, cur uint64) (uint64, bool) {
+	if cur >= prev {
+		return cur - prev, true
+	}
+	if prev >= wrapThreshold {
+		atomic.AddInt64(&c.wraps, 1)
+		return cur + (math.MaxUint64 - prev) + 1, true
+	}
+	atomic.AddInt64(&c.resets, 1)
+	return 0, false
+}
+
+// counterReset 根据 LastClear、CarrierTransitions 判断整行计数器是否被清零
+func (s *series) counterReset(lastClear *time.Time, carrierTransitions *uint64) bool {
+	if lastClear != nil && !s.lastClear.IsZero() && !lastClear.Equal(s.lastClear) {
+		return true
+	}
+	return carrierTransitions != nil && s.carrierTransitions != nil && *carrierTransitions < *s.carrierTransitions
+}
+
+func (c *Calculator) device(systemID string) *device {
+	dev, ok := c.devices[systemID]
+	if !ok {
+		dev = &device{
+			series: make(map[seriesKey]*series),
+			uptime: make(map[string]time.Duration),
+		}
+		c.devices[systemID] = dev
+	}
+	return dev
+}
+
+// Expire 清理超过stateTTL未更新的基线，返回清理数量
+func (c *Calculator) Expire() int {
+	if c.stateTTL <= 0 {
+		return 0
+	}
+	deadline := c.now().Add(-c.stateTTL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for systemID, dev := range c.devices {
+		for key, s := range dev.series {
+			if s.lastSeen.Before(deadline) {
+				delete(dev.series, key)
+				removed++
+			}
+		}
+		if len(dev.series) == 0 {
+			delete(c.devices, systemID)
+		}
+	}
+	return removed
+}
+
+// Stats 获取速率计算统计
+func (c *Calculator) Stats() Stats {
+	c.mu.Lock()
+	active := 0
+	for _, dev := range c.devices {
+		active += len(dev.series)
+	}
+	c.mu.Unlock()
+
+	return Stats{
+		RatesComputed: atomic.LoadInt64(&c.ratesComputed),
+		Wraps:         atomic.LoadInt64(&c.wraps),
+		Resets:        atomic.LoadInt64(&c.resets),
+		Restarts:      atomic.LoadInt64(&c.restarts),
+		Series:        active,
+	}
+}
+
+func hasCounter(values []*uint64) bool {
+	for _, v := range values {
+		if v != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseUptime 解析解析器输出的 dd:hh:mm:ss 格式uptime
+func parseUptime(s string) (time.Duration, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 {
+		return 0, false
+	}
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		d += time.Duration(n) * units[i]
+	}
+	return d, true
+}
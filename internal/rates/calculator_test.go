@@ -0,0 +1,209 @@
+package rates
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+func u64(v uint64) *uint64 { return &v }
+
+func interfaceSample(ts time.Time, inOctets uint64) models.InterfaceMetric {
+	return models.InterfaceMetric{
+		Timestamp:     ts,
+		SystemID:      "dev-1",
+		InterfaceName: "gei-1/2/1",
+		InOctets:      u64(inOctets),
+	}
+}
+
+func inOctetsRate(t *testing.T, c *Calculator, m models.InterfaceMetric) *float64 {
+	t.Helper()
+	metrics := []models.InterfaceMetric{m}
+	c.ApplyInterfaces(metrics)
+	if metrics[0].CounterRates == nil {
+		return nil
+	}
+	return metrics[0].InOctetsRate
+}
+
+func TestCalculator_Rate(t *testing.T) {
+	c := NewCalculator(10*time.Minute, time.Hour)
+	base := time.Unix(1700000000, 0)
+
+	if r := inOctetsRate(t, c, interfaceSample(base, 1000)); r != nil {
+		t.Fatalf("first sample rate = %v, want nil", *r)
+	}
+	r := inOctetsRate(t, c, interfaceSample(base.Add(10*time.Second), 6000))
+	if r == nil || *r != 500 {
+		t.Fatalf("rate = %v, want 500", r)
+	}
+
+	// 乱序采样不出速率也不覆盖基线
+	if r := inOctetsRate(t, c, interfaceSample(base.Add(5*time.Second), 7000)); r != nil {
+		t.Fatalf("out-of-order rate = %v, want nil", *r)
+	}
+	r = inOctetsRate(t, c, interfaceSample(base.Add(20*time.Second), 8000))
+	if r == nil || *r != 200 {
+		t.Fatalf("rate after out-of-order = %v, want 200", r)
+	}
+
+	// 间隔过长只更新基线
+	if r := inOctetsRate(t, c, interfaceSample(base.Add(time.Hour), 9000)); r != nil {
+		t.Fatalf("stale baseline rate = %v, want nil", *r)
+	}
+}
+
+func TestCalculator_WrapAndReset(t *testing.T) {
+	c := NewCalculator(0, 0)
+	base := time.Unix(1700000000, 0)
+
+	inOctetsRate(t, c, interfaceSample(base, math.MaxUint64-99))
+	r := inOctetsRate(t, c, interfaceSample(base.Add(time.Second), 100))
+	if r == nil || *r != 200 {
+		t.Fatalf("wrap rate = %v, want 200", r)
+	}
+
+	if r := inOctetsRate(t, c, interfaceSample(base.Add(2*time.Second), 50)); r != nil {
+		t.Fatalf("reset rate = %v, want nil", *r)
+	}
+	r = inOctetsRate(t, c, interfaceSample(base.Add(3*time.Second), 150))
+	if r == nil || *r != 100 {
+		t.Fatalf("rate after reset = %v, want 100", r)
+	}
+
+	stats := c.Stats()
+	if stats.Wraps != 1 || stats.Resets != 1 || stats.RatesComputed != 2 || stats.Series != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCalculator_LastClearAndCarrierTransitions(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	clear1 := base.Add(-time.Hour)
+	clear2 := base.Add(time.Second)
+
+	tests := []struct {
+		name          string
+		first, second func(*models.InterfaceMetric)
+	}{
+		{
+			name:   "last_clear changed",
+			first:  func(m *models.InterfaceMetric) { m.LastClear = &clear1 },
+			second: func(m *models.InterfaceMetric) { m.LastClear = &clear2 },
+		},
+		{
+			name:   "carrier_transitions decreased",
+			first:  func(m *models.InterfaceMetric) { m.CarrierTransitions = u64(5) },
+			second: func(m *models.InterfaceMetric) { m.CarrierTransitions = u64(0) },
+		},
+	}
+	for _, tt := range tests {
+		c := NewCalculator(0, 0)
+		first := interfaceSample(base, 1000)
+		tt.first(&first)
+		inOctetsRate(t, c, first)
+
+		// 计数器增长但已被清零过，差值不可信
+		second := interfaceSample(base.Add(10*time.Second), 2000)
+		tt.second(&second)
+		if r := inOctetsRate(t, c, second); r != nil {
+			t.Errorf("%s: rate = %v, want nil", tt.name, *r)
+		}
+		if r := inOctetsRate(t, c, interfaceSample(base.Add(20*time.Second), 3000)); r == nil || *r != 100 {
+			t.Errorf("%s: rate after rebaseline = %v, want 100", tt.name, r)
+		}
+	}
+}
+
+func TestCalculator_DeviceRestart(t *testing.T) {
+	c := NewCalculator(0, 0)
+	base := time.Unix(1700000000, 0)
+	uptime := func(s string) []models.PlatformMetric {
+		return []models.PlatformMetric{{
+			SystemID:      "dev-1",
+			ComponentName: "MPU-0/1/0",
+			CommonState:   &models.CommonState{Uptime: &s},
+		}}
+	}
+
+	c.ObservePlatform(uptime("10:00:00:00"))
+	inOctetsRate(t, c, interfaceSample(base, 1000))
+	c.ObservePlatform(uptime("00:00:05:00"))
+
+	if r := inOctetsRate(t, c, interfaceSample(base.Add(10*time.Second), 2000)); r != nil {
+		t.Fatalf("rate after restart = %v, want nil", *r)
+	}
+	if stats := c.Stats(); stats.Restarts != 1 {
+		t.Errorf("Restarts = %d, want 1", stats.Restarts)
+	}
+}
+
+func TestCalculator_SubinterfaceSeparateSeries(t *testing.T) {
+	c := NewCalculator(0, 0)
+	base := time.Unix(1700000000, 0)
+
+	sub := func(ts time.Time, name string, pkts uint64) []models.SubinterfaceMetric {
+		return []models.SubinterfaceMetric{{
+			Timestamp:        ts,
+			SystemID:         "dev-1",
+			InterfaceName:    "gei-1/2/1",
+			SubinterfaceName: name,
+			InPkts:           u64(pkts),
+		}}
+	}
+	c.ApplySubinterfaces(sub(base, "1", 100))
+	c.ApplySubinterfaces(sub(base, "2", 5000))
+	inOctetsRate(t, c, interfaceSample(base, 1))
+
+	metrics := sub(base.Add(2*time.Second), "1", 300)
+	c.ApplySubinterfaces(metrics)
+	if metrics[0].CounterRates == nil || *metrics[0].InPktsRate != 100 {
+		t.Fatalf("subinterface rate = %+v, want 100", metrics[0].CounterRates)
+	}
+	if metrics[0].InOctetsRate != nil {
+		t.Errorf("InOctetsRate should be nil without counter")
+	}
+	if stats := c.Stats(); stats.Series != 3 {
+		t.Errorf("Series = %d, want 3", stats.Series)
+	}
+}
+
+func TestCalculator_Expire(t *testing.T) {
+	c := NewCalculator(0, time.Minute)
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	inOctetsRate(t, c, interfaceSample(now, 1))
+	if n := c.Expire(); n != 0 {
+		t.Fatalf("expired too early: %d", n)
+	}
+	now = now.Add(2 * time.Minute)
+	if n := c.Expire(); n != 1 {
+		t.Fatalf("Expire() = %d, want 1", n)
+	}
+	if stats := c.Stats(); stats.Series != 0 {
+		t.Errorf("Series = %d, want 0", stats.Series)
+	}
+}
+
+func TestParseUptime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"01:02:03:04", 26*time.Hour + 3*time.Minute + 4*time.Second, true},
+		{"120:00:00:00", 120 * 24 * time.Hour, true},
+		{"1:2:3", 0, false},
+		{"aa:00:00:00", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseUptime(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseUptime(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package sequence
+
+import (
+	"sync"
+	"time"
+)
+
+// Counts 单台设备的ReqId统计（累计值）
+type Counts struct {
+	Messages   int64 // 参与检查的报文数
+	Lost       int64 // 跳过的ReqId数
+	Duplicates int64 // 重复的ReqId数
+	Exact      int64 // 其中内容完全相同的重传
+	Dropped    int64 // 被丢弃的完全重复报文
+	Reordered  int64 // 迟到的ReqId数
+	Resets     int64 // 序号重新开始的次数
+	Retries    int64 // 未能写入后重传的报文数
+}
+
+type streamKey struct {
+	systemID       string
+	subscriptionID string
+}
+
+type stream struct {
+	key     streamKey
+	tracker *Tracker
+}
+
+type closedStream struct {
+	tracker  *Tracker
+	closedAt time.Time
+}
+
+// Monitor 按流跟踪ReqId并按设备汇总
+//
+// 设备断线重连后若继续原序号（首条ReqId大于1），新流沿用同一 system_id + subscription_id
+// 最近关闭的流的跟踪状态，重连后重传的报文因此可识别为重复。
+type Monitor struct {
+	window    int
+	resumeTTL time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	streams map[string]*stream
+	closed  map[streamKey]*closedStream
+	counts  map[string]*Counts
+}
+
+// NewMonitor 创建ReqId监测，resumeTTL为重连后可沿用旧流状态的时长
+func NewMonitor(window int, resumeTTL time.Duration) *Monitor {
+	return &Monitor{
+		window:    window,
+		resumeTTL: resumeTTL,
+		now:       time.Now,
+		streams:   make(map[string]*stream),
+		closed:    make(map[streamKey]*closedStream),
+		counts:    make(map[string]*Counts),
+	}
+}
+
+// Observe 检查流上的一条报文，system_id与subscription_id以流的首条报文为准
+func (m *Monitor) Observe(streamID, systemID, subscriptionID string, reqID int64, hash uint64) Result {
+	if reqID <= 0 || systemID == "" {
+		return Result{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.streams[streamID]
+	if !ok {
+		s = &stream{key: streamKey{systemID: systemID, subscriptionID: subscriptionID}}
+		if prev, ok := m.closed[s.key]; ok && reqID > 1 && m.now().Sub(prev.closedAt) <= m.resumeTTL {
+			s.tracker = prev.tracker
+		} else {
+			s.tracker = NewTracker(m.window)
+		}
+		delete(m.closed, s.key)
+		m.streams[streamID] = s
+	}
+
+	r := s.tracker.Observe(reqID, hash)
+
+	c, ok := m.counts[s.key.systemID]
+	if !ok {
+		c = &Counts{}
+		m.counts[s.key.systemID] = c
+	}
+	c.Messages++
+	c.Lost += r.Lost
+	if r.Duplicate {
+		c.Duplicates++
+		if r.Exact {
+			c.Exact++
+		}
+	}
+	if r.Reordered {
+		c.Reordered++
+	}
+	if r.Reset {
+		c.Resets++
+	}
+	if r.Retry {
+		c.Retries++
+	}
+	return r
+}
+
+// Dropped 记录一条被丢弃的完全重复报文
+func (m *Monitor) Dropped(streamID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.streams[streamID]; ok {
+		if c, ok := m.counts[s.key.systemID]; ok {
+			c.Dropped++
+		}
+	}
+}
+
+// Rejected 记录一条已检查但未能写入的报文，设备重传时照常处理
+func (m *Monitor) Rejected(streamID string, reqID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.streams[streamID]; ok {
+		s.tracker.Reject(reqID)
+	}
+}
+
+// StreamClosed 流结束后保留其跟踪状态，供同一设备重连后沿用
+func (m *Monitor) StreamClosed(streamID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.streams[streamID]
+	if !ok {
+		return
+	}
+	delete(m.streams, streamID)
+	m.closed[s.key] = &closedStream{tracker: s.tracker, closedAt: m.now()}
+}
+
+// Expire 清理超过resumeTTL的已关闭流状态，返回清理数量
+func (m *Monitor) Expire() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	removed := 0
+	for key, c := range m.closed {
+		if now.Sub(c.closedAt) > m.resumeTTL {
+			delete(m.closed, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Counts 返回按system_id汇总的统计
+func (m *Monitor) Counts() map[string]Counts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]Counts, len(m.counts))
+	for systemID, c := range m.counts {
+		out[systemID] = *c
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package sequence
+
+// ReqId序列跟踪
+//
+// PublishArgs.ReqId 是设备在每条流上从1开始递增的请求序号。Tracker 记住最近 window 个ReqId
+// 及其报文内容摘要，按到达顺序判断：
+//   - 跳号：中间缺失的ReqId计为丢失
+//   - 重复：窗口内已出现过的ReqId，内容摘要相同为完全重复（重传）
+//   - 乱序：之前计为丢失的ReqId迟到
+//   - 重置：序号回到1，或回退超出窗口，视为设备重新开始计数
+//
+// 未能写入的报文（缓冲区满等）由 Reject 撤销其内容摘要，设备重传时按新报文处理，
+// 不会被当作已处理过的完全重复而丢弃。
+//
+// ReqId为0表示设备未填写，不做跟踪。
+
+// Result 单条报文的序号检查结果
+type Result struct {
+	Lost      int64 // 本条之前跳过的ReqId数
+	Duplicate bool
+	Exact     bool // 重复且内容与之前一致
+	Reordered bool
+	Reset     bool
+	Retry     bool // 之前未能写入的报文重传
+}
+
+type entry struct {
+	hash     uint64
+	rejected bool
+}
+
+// Tracker 单条流的ReqId跟踪，非并发安全（同一条流的报文按顺序处理）
+type Tracker struct {
+	window  int64
+	started bool
+	last    int64
+	seen    map[int64]entry // ReqId -> 内容摘要
+}
+
+// NewTracker 创建ReqId跟踪，window为用于判断重复与乱序的最近ReqId数
+func NewTracker(window int) *Tracker {
+	if window <= 0 {
+		window = 1024
+	}
+	return &Tracker{
+		window: int64(window),
+		seen:   make(map[int64]entry),
+	}
+}
+
+// Last 返回已见到的最大ReqId
+func (t *Tracker) Last() int64 {
+	return t.last
+}
+
+// Observe 检查一条报文的ReqId，hash为报文内容摘要
+func (t *Tracker) Observe(reqID int64, hash uint64) Result {
+	var r Result
+	if reqID <= 0 {
+		return r
+	}
+	if !t.started {
+		t.started = true
+		t.last = reqID
+		t.seen[reqID] = entry{hash: hash}
+		return r
+	}
+	if prev, ok := t.seen[reqID]; ok && prev.rejected {
+		r.Retry = true
+		t.seen[reqID] = entry{hash: hash}
+		return r
+	}
+
+	switch {
+	case reqID > t.last:
+		r.Lost = reqID - t.last - 1
+		t.last = reqID
+	default:
+		// 内容不同的ReqId=1是设备重新计数，不是重传
+		if prev, ok := t.seen[reqID]; ok && (prev.hash == hash || reqID != 1) {
+			r.Duplicate = true
+			r.Exact = prev.hash == hash
+			return r
+		}
+		if reqID == 1 || reqID <= t.last-t.window {
+			r.Reset = true
+			t.last = reqID
+			t.seen = make(map[int64]entry)
+			break
+		}
+		// 窗口内未见过：之前计为丢失的迟到报文，或早于本流首条报文的序号
+		r.Reordered = true
+	}
+
+	t.seen[reqID] = entry{hash: hash}
+	t.prune()
+	return r
+}
+
+// Reject 撤销报文的内容摘要，该报文未能写入，重传时不计为重复
+func (t *Tracker) Reject(reqID int64) {
+	if _, ok := t.seen[reqID]; ok {
+		t.seen[reqID] = entry{rejected: true}
+	}
+}
+
+// prune 清理窗口之外的记录
+func (t *Tracker) prune() {
+	if int64(len(t.seen)) <= 2*t.window {
+		return
+	}
+	floor := t.last - t.window
+	for id := range t.seen {
+		if id <= floor {
+			delete(t.seen, id)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package sequence
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker_Observe(t *testing.T) {
+	tr := NewTracker(16)
+
+	tests := []struct {
+		reqID int64
+		hash  uint64
+		want  Result
+	}{
+		{1, 1, Result{}},
+		{2, 2, Result{}},
+		{5, 5, Result{Lost: 2}},
+		{3, 3, Result{Reordered: true}},
+		{5, 5, Result{Duplicate: true, Exact: true}},
+		{5, 9, Result{Duplicate: true}},
+		{6, 6, Result{}},
+		{1, 1, Result{Duplicate: true, Exact: true}}, // 重传首条
+		{1, 100, Result{Reset: true}},                // 内容不同，设备重新计数
+		{2, 101, Result{}},
+		{0, 0, Result{}}, // 未填写ReqId
+		{40, 40, Result{Lost: 37}},
+		{3, 3, Result{Reset: true}}, // 回退超出窗口
+	}
+	for i, tt := range tests {
+		if got := tr.Observe(tt.reqID, tt.hash); got != tt.want {
+			t.Errorf("#%d Observe(%d) = %+v, want %+v", i, tt.reqID, got, tt.want)
+		}
+	}
+	if tr.Last() != 3 {
+		t.Errorf("Last = %d, want 3", tr.Last())
+	}
+}
+
+func TestTracker_Prune(t *testing.T) {
+	tr := NewTracker(8)
+	for id := int64(1); id <= 100; id++ {
+		tr.Observe(id, uint64(id))
+	}
+	if len(tr.seen) > 16 {
+		t.Errorf("seen = %d entries, want <= 16", len(tr.seen))
+	}
+	if r := tr.Observe(95, 95); !r.Duplicate {
+		t.Errorf("Observe(95) = %+v, want duplicate", r)
+	}
+}
+
+func TestTracker_Reject(t *testing.T) {
+	tr := NewTracker(16)
+	tr.Observe(1, 1)
+	tr.Observe(2, 2)
+
+	// 未能写入的报文重传时照常处理，再次重传才是重复
+	tr.Reject(2)
+	if r := tr.Observe(2, 2); r != (Result{Retry: true}) {
+		t.Errorf("retransmit after Reject = %+v", r)
+	}
+	if r := tr.Observe(2, 2); !r.Exact {
+		t.Errorf("second retransmit = %+v, want exact duplicate", r)
+	}
+
+	// 首条报文被拒绝后重传不视为重新计数
+	tr = NewTracker(16)
+	tr.Observe(1, 1)
+	tr.Reject(1)
+	if r := tr.Observe(1, 1); r != (Result{Retry: true}) {
+		t.Errorf("retransmit of first message = %+v", r)
+	}
+}
+
+func TestMonitor_Resume(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := NewMonitor(64, 10*time.Minute)
+	m.now = func() time.Time { return now }
+
+	for id := int64(1); id <= 10; id++ {
+		m.Observe("1", "PE-01", "sub-if", id, uint64(id))
+	}
+	m.StreamClosed("1")
+
+	// 重连后继续原序号，重传的8-10为重复，随后跳过11
+	now = now.Add(time.Minute)
+	for _, id := range []int64{8, 9, 10, 12} {
+		r := m.Observe("2", "PE-01", "sub-if", id, uint64(id))
+		if id <= 10 && !r.Exact {
+			t.Errorf("Observe(%d) after reconnect = %+v, want exact duplicate", id, r)
+		}
+		if id <= 10 {
+			m.Dropped("2")
+		}
+	}
+
+	// 从1开始的新流不沿用状态
+	m.StreamClosed("2")
+	if r := m.Observe("3", "PE-01", "sub-if", 1, 1000); r != (Result{}) {
+		t.Errorf("new sequence = %+v", r)
+	}
+
+	c := m.Counts()["PE-01"]
+	want := Counts{Messages: 15, Lost: 1, Duplicates: 3, Exact: 3, Dropped: 3}
+	if c != want {
+		t.Errorf("Counts = %+v, want %+v", c, want)
+	}
+
+	m.StreamClosed("3")
+	now = now.Add(time.Hour)
+	if removed := m.Expire(); removed != 1 {
+		t.Errorf("Expire = %d, want 1", removed)
+	}
+}
@@ -0,0 +1,410 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+// 缓冲数据预写日志（WAL）
+//
+// 日志由按序号命名的段文件组成，只有最新的段（活动段）可以追加。每个段以 Magic 开头，
+// 随后是若干帧：
+//
+//	uint32  载荷长度（大端）
+//	uint32  载荷的CRC32（IEEE，大端）
+//	[]byte  载荷
+//
+// 同一个段内所有帧的载荷连起来是一个gob流，类型信息只在段内第一次出现时写入。
+// 进程异常退出时最后一帧可能不完整或校验失败，读取时丢弃该帧及之后的内容。
+
+// Magic 段文件头
+const Magic = "ZTWAL01\n"
+
+// segmentExt 段文件扩展名
+const segmentExt = ".wal"
+
+// maxFrameSize 单帧长度上限，防止读取损坏文件时分配过大内存
+const maxFrameSize = 256 << 20
+
+// ErrFull 日志总大小达到上限
+var ErrFull = errors.New("wal full")
+
+func init() {
+	// 映射行的取值为interface{}，时间类型需要注册
+	gob.Register(time.Time{})
+}
+
+// Entry 一次写入缓冲区的数据，同一时间只有一种类型非空
+type Entry struct {
+	Platform           []models.PlatformMetric           `json:"platform,omitempty"`
+	Interface          []models.InterfaceMetric          `json:"interface,omitempty"`
+	Subinterface       []models.SubinterfaceMetric       `json:"subinterface,omitempty"`
+	AlarmReport        []models.AlarmReportMetric        `json:"alarm_report,omitempty"`
+	NotificationReport []models.NotificationReportMetric `json:"notification_report,omitempty"`
+	SelfDefinedEvent   []models.SelfDefinedEventMetric   `json:"self_defined_event,omitempty"`
+	Generic            []models.GenericMetric            `json:"generic,omitempty"`
+	MappedRow          []models.MappedRow                `json:"mapped_row,omitempty"`
+	OpticalChannel     []models.OpticalChannelMetric     `json:"optical_channel,omitempty"`
+}
+
+// Table 记录的数据类型，与 telemetry_buffer_size 的 buffer 标签一致
+func (e *Entry) Table() string {
+	switch {
+	case len(e.Platform) > 0:
+		return "platform"
+	case len(e.Interface) > 0:
+		return "interface"
+	case len(e.Subinterface) > 0:
+		return "subinterface"
+	case len(e.AlarmReport) > 0:
+		return "alarm_report"
+	case len(e.NotificationReport) > 0:
+		return "notification_report"
+	case len(e.SelfDefinedEvent) > 0:
+		return "self_defined_event"
+	case len(e.Generic) > 0:
+		return "generic"
+	case len(e.MappedRow) > 0:
+		return "mapped_row"
+	case len(e.OpticalChannel) > 0:
+		return "optical_channel"
+	}
+	return ""
+}
+
+// Rows 记录中的行数
+func (e *Entry) Rows() int {
+	return len(e.Platform) + len(e.Interface) + len(e.Subinterface) + len(e.AlarmReport) +
+		len(e.NotificationReport) + len(e.SelfDefinedEvent) + len(e.Generic) + len(e.MappedRow) +
+		len(e.OpticalChannel)
+}
+
+// Options 日志参数
+type Options struct {
+	// SegmentSize 活动段超过该大小后自动切换到新段
+	SegmentSize int64
+	// MaxSize 所有段的总大小上限，0为不限制
+	MaxSize int64
+	// SyncEveryWrite 每次追加后fsync，否则由调用方定期调用Sync
+	SyncEveryWrite bool
+}
+
+// Log 预写日志
+type Log struct {
+	dir  string
+	opts Options
+
+	mu        sync.Mutex
+	file      *os.File
+	seq       uint64       // 活动段序号
+	size      int64        // 活动段大小
+	entries   int          // 活动段记录数
+	enc       *gob.Encoder // 活动段的gob编码器
+	encBuf    bytes.Buffer // 编码器输出，每次追加后取出作为一帧
+	sealed    []uint64     // 上次Rotate后写满切换的段
+	sizes     map[uint64]int64
+	totalSize int64
+	recovered []uint64
+	dirty     bool // 有未fsync的写入
+}
+
+// Open 打开日志目录，已有的段作为待恢复段，新数据写入新的活动段
+func Open(dir string, opts Options) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("创建WAL目录失败: %v", err)
+	}
+	l := &Log{dir: dir, opts: opts, sizes: make(map[uint64]int64)}
+
+	seqs, err := l.list()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range seqs {
+		info, err := os.Stat(l.path(seq))
+		if err != nil {
+			return nil, fmt.Errorf("读取WAL段失败: %v", err)
+		}
+		l.sizes[seq] = info.Size()
+		l.totalSize += info.Size()
+		l.seq = seq
+	}
+	l.recovered = seqs
+
+	if err := l.openSegment(l.seq + 1); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Recovered 打开时已存在的段（上次运行未确认写库的数据），按序号升序
+func (l *Log) Recovered() []uint64 {
+	return l.recovered
+}
+
+// Append 追加一条记录，总大小达到上限时返回 ErrFull
+func (l *Log) Append(e *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return errors.New("WAL已关闭")
+	}
+	if l.opts.MaxSize > 0 && l.totalSize >= l.opts.MaxSize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrFull, l.totalSize, l.opts.MaxSize)
+	}
+	if l.opts.SegmentSize > 0 && l.size >= l.opts.SegmentSize && l.entries > 0 {
+		l.sealed = append(l.sealed, l.seq)
+		if err := l.openSegment(l.seq + 1); err != nil {
+			return err
+		}
+	}
+
+	if err := l.enc.Encode(e); err != nil {
+		// 编码失败时编码器状态不确定，切换到新段
+		l.encBuf.Reset()
+		if l.entries > 0 {
+			l.sealed = append(l.sealed, l.seq)
+		}
+		if rerr := l.openSegment(l.seq + 1); rerr != nil {
+			return rerr
+		}
+		return fmt.Errorf("编码WAL记录失败: %v", err)
+	}
+	payload := l.encBuf.Bytes()
+	frame := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(payload))
+	frame = append(frame, payload...)
+	l.encBuf.Reset()
+
+	n, err := l.file.Write(frame)
+	l.grow(int64(n))
+	if err != nil {
+		return fmt.Errorf("写入WAL失败: %v", err)
+	}
+	l.entries++
+	if l.opts.SyncEveryWrite {
+		return l.file.Sync()
+	}
+	l.dirty = true
+	return nil
+}
+
+// Sync 将活动段写入磁盘
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil || !l.dirty {
+		return nil
+	}
+	l.dirty = false
+	return l.file.Sync()
+}
+
+// Rotate 封存活动段并打开新段，返回自上次Rotate以来封存的所有段。
+// 活动段没有记录时不切换。
+func (l *Log) Rotate() ([]uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil, errors.New("WAL已关闭")
+	}
+	if l.entries > 0 {
+		l.sealed = append(l.sealed, l.seq)
+		if err := l.openSegment(l.seq + 1); err != nil {
+			return nil, err
+		}
+	}
+	sealed := l.sealed
+	l.sealed = nil
+	return sealed, nil
+}
+
+// Remove 删除已封存的段
+func (l *Log) Remove(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if seq == l.seq && l.file != nil {
+		return fmt.Errorf("不能删除活动段 %d", seq)
+	}
+	if err := os.Remove(l.path(seq)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除WAL段失败: %v", err)
+	}
+	l.totalSize -= l.sizes[seq]
+	delete(l.sizes, seq)
+	return nil
+}
+
+// Quarantine 将无法解码的段改名为 .corrupt 保留，不再参与恢复
+func (l *Log) Quarantine(seq uint64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.Rename(l.path(seq), l.path(seq)+".corrupt"); err != nil {
+		return fmt.Errorf("隔离WAL段失败: %v", err)
+	}
+	l.totalSize -= l.sizes[seq]
+	delete(l.sizes, seq)
+	return nil
+}
+
+// Size 所有段的总大小
+func (l *Log) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.totalSize
+}
+
+// Close 同步并关闭活动段，空的活动段直接删除
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.closeSegment()
+	l.file = nil
+	return err
+}
+
+// ReadSegment 按顺序读取段中的记录。最后一帧不完整或校验失败时停止读取并返回truncated=true。
+func (l *Log) ReadSegment(seq uint64, fn func(*Entry) error) (truncated bool, err error) {
+	data, err := os.ReadFile(l.path(seq))
+	if err != nil {
+		return false, fmt.Errorf("读取WAL段失败: %v", err)
+	}
+	return decodeSegment(data, fn)
+}
+
+// decodeSegment 校验各帧后将载荷作为gob流解码
+func decodeSegment(data []byte, fn func(*Entry) error) (bool, error) {
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		// 创建段时写文件头前崩溃
+		return len(data) > 0, nil
+	}
+	data = data[len(Magic):]
+
+	var stream bytes.Buffer
+	truncated := false
+	for len(data) > 0 {
+		if len(data) < 8 {
+			truncated = true
+			break
+		}
+		size := binary.BigEndian.Uint32(data)
+		sum := binary.BigEndian.Uint32(data[4:])
+		if size > maxFrameSize || int(size) > len(data)-8 {
+			truncated = true
+			break
+		}
+		payload := data[8 : 8+size]
+		if crc32.ChecksumIEEE(payload) != sum {
+			truncated = true
+			break
+		}
+		stream.Write(payload)
+		data = data[8+size:]
+	}
+
+	dec := gob.NewDecoder(&stream)
+	for {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return truncated, nil
+			}
+			return truncated, fmt.Errorf("解码WAL记录失败: %v", err)
+		}
+		if err := fn(&e); err != nil {
+			return truncated, err
+		}
+	}
+}
+
+// openSegment 关闭当前段并创建新段（调用方持有锁）
+func (l *Log) openSegment(seq uint64) error {
+	if l.file != nil {
+		if err := l.closeSegment(); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(l.path(seq), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+	if err != nil {
+		return fmt.Errorf("创建WAL段失败: %v", err)
+	}
+	if _, err := f.WriteString(Magic); err != nil {
+		f.Close()
+		return fmt.Errorf("写入WAL段文件头失败: %v", err)
+	}
+	l.file = f
+	l.seq = seq
+	l.size = 0
+	l.entries = 0
+	l.sizes[seq] = 0
+	l.grow(int64(len(Magic)))
+	l.encBuf.Reset()
+	l.enc = gob.NewEncoder(&l.encBuf)
+	return nil
+}
+
+// closeSegment 同步并关闭活动段，没有记录的段直接删除
+func (l *Log) closeSegment() error {
+	if l.entries == 0 {
+		l.file.Close()
+		l.totalSize -= l.sizes[l.seq]
+		delete(l.sizes, l.seq)
+		return os.Remove(l.path(l.seq))
+	}
+	if err := l.file.Sync(); err != nil {
+		l.file.Close()
+		return fmt.Errorf("同步WAL段失败: %v", err)
+	}
+	l.dirty = false
+	return l.file.Close()
+}
+
+func (l *Log) grow(n int64) {
+	l.size += n
+	l.sizes[l.seq] += n
+	l.totalSize += n
+}
+
+func (l *Log) path(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%016d%s", seq, segmentExt))
+}
+
+// list 目录中已有的段序号，升序
+func (l *Log) list() ([]uint64, error) {
+	files, err := filepath.Glob(filepath.Join(l.dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, f := range files {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(f), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
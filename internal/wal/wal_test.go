@@ -0,0 +1,170 @@
+package wal
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/wwswwsuns/ztelem/internal/models"
+)
+
+func readAll(t *testing.T, l *Log, seq uint64) ([]*Entry, bool) {
+	t.Helper()
+	var entries []*Entry
+	truncated, err := l.ReadSegment(seq, func(e *Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadSegment(%d): %v", seq, err)
+	}
+	return entries, truncated
+}
+
+func TestAppendRotateRead(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2026, 7, 1, 8, 0, 0, 0, time.UTC)
+	if err := l.Append(&Entry{Platform: []models.PlatformMetric{{Timestamp: ts, SystemID: "dev-1", ComponentName: "CPU0"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(&Entry{MappedRow: []models.MappedRow{{Timestamp: ts, SystemID: "dev-1", Table: "t",
+		Columns: []string{"a", "b", "c", "d"}, Values: []interface{}{int32(3), "up", ts, nil}}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := l.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sealed) != 1 {
+		t.Fatalf("sealed = %v, want one segment", sealed)
+	}
+	// 没有新记录时不切换
+	if again, _ := l.Rotate(); len(again) != 0 {
+		t.Fatalf("second Rotate sealed %v, want none", again)
+	}
+
+	entries, truncated := readAll(t, l, sealed[0])
+	if truncated || len(entries) != 2 {
+		t.Fatalf("got %d entries (truncated=%v), want 2", len(entries), truncated)
+	}
+	if got := entries[0].Platform[0]; got.ComponentName != "CPU0" || !got.Timestamp.Equal(ts) {
+		t.Errorf("platform entry = %+v", got)
+	}
+	row := entries[1].MappedRow[0]
+	if v, ok := row.Values[0].(int32); !ok || v != 3 {
+		t.Errorf("Values[0] = %#v, want int32(3)", row.Values[0])
+	}
+	if v, ok := row.Values[2].(time.Time); !ok || !v.Equal(ts) {
+		t.Errorf("Values[2] = %#v, want %v", row.Values[2], ts)
+	}
+	if row.Values[3] != nil {
+		t.Errorf("Values[3] = %#v, want nil", row.Values[3])
+	}
+
+	if err := l.Remove(sealed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 空的活动段关闭时删除
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("directory not empty after Remove+Close: %v", files)
+	}
+}
+
+func TestRecoverTruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := l.Append(&Entry{Generic: []models.GenericMetric{{SystemID: "dev-1"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seq := l.seq
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟写最后一帧时崩溃
+	path := l.path(seq)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if rec := l.Recovered(); len(rec) != 1 || rec[0] != seq {
+		t.Fatalf("Recovered() = %v, want [%d]", rec, seq)
+	}
+	entries, truncated := readAll(t, l, seq)
+	if !truncated || len(entries) != 2 {
+		t.Fatalf("got %d entries (truncated=%v), want 2 with truncated tail", len(entries), truncated)
+	}
+	// 新数据写入新段，不覆盖待恢复段
+	if l.seq <= seq {
+		t.Errorf("active segment %d, want > %d", l.seq, seq)
+	}
+}
+
+func TestSegmentSizeAndMaxSize(t *testing.T) {
+	l, err := Open(t.TempDir(), Options{SegmentSize: 1, MaxSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	e := &Entry{Interface: []models.InterfaceMetric{{SystemID: "dev-1", InterfaceName: "xgei-0/1/0/1"}}}
+	var appended int
+	for {
+		err := l.Append(e)
+		if errors.Is(err, ErrFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		appended++
+		if appended > 1000 {
+			t.Fatal("MaxSize never reached")
+		}
+	}
+	if l.Size() < 4096 {
+		t.Errorf("Size() = %d at ErrFull, want >= 4096", l.Size())
+	}
+
+	// 每个段一条记录：除活动段外都在Rotate结果中，且可以单独读取
+	sealed, err := l.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sealed) != appended {
+		t.Fatalf("sealed %d segments, want %d", len(sealed), appended)
+	}
+	for _, seq := range sealed {
+		if entries, _ := readAll(t, l, seq); len(entries) != 1 {
+			t.Fatalf("segment %d has %d entries, want 1", seq, len(entries))
+		}
+		if err := l.Remove(seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Append(e); err != nil {
+		t.Fatalf("Append after Remove: %v", err)
+	}
+}
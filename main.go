@@ -3,26 +3,46 @@ package main
 import (
 	"database/sql"
 	"flag"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/wwswwsuns/ztelem/internal/buffer"
+	"github.com/wwswwsuns/ztelem/internal/clockskew"
 	"github.com/wwswwsuns/ztelem/internal/collector"
 	"github.com/wwswwsuns/ztelem/internal/config"
 	"github.com/wwswwsuns/ztelem/internal/database"
 	"github.com/wwswwsuns/ztelem/internal/monitoring"
+	"github.com/wwswwsuns/ztelem/internal/ratelimit"
+	"github.com/wwswwsuns/ztelem/internal/rates"
+	"github.com/wwswwsuns/ztelem/internal/sequence"
 	"github.com/sirupsen/logrus"
 )
 
 // 用于计算 Prometheus Counter 增量
 var (
-	prevProcessedRecords int64
-	prevErrorRecords     int64
+	prevProcessedRecords  int64
+	prevErrorRecords      int64
+	prevRoundsCompleted   int64
+	prevRoundsIncomplete  int64
+	prevCollectionPackets int64
+	prevRateStats         rates.Stats
+	prevAccessRejections  = make(map[string]int64)
+	prevPublishStats      = collector.PublishStats{Errors: make(map[string]int64)}
+	prevRateLimitCounts   = make(map[ratelimit.CountKey]int64)
+	prevDuplicateStreams  int64
+	prevGapsOpened        = make(map[string]int64)
+	prevSequenceCounts    = make(map[string]sequence.Counts)
+	prevClockSkewStats    clockskew.Stats
+	prevWALStats          buffer.WALStats
+	prevDeadLetterStats   buffer.DeadLetterStats
+	prevOverflowCounts    = make(map[buffer.OverflowKey]int64)
 )
 
 var (
@@ -56,6 +76,7 @@ func main() {
 		log.WithError(err).Fatal("数据库连接失败")
 	}
 	defer db.Close()
+	db.SetCounterRateColumns(cfg.Rates.Enabled)
 
 	// 打印数据库连接池状态
 	stats := db.GetStats()
@@ -70,8 +91,36 @@ func main() {
 		log,
 	)
 
+	// 聚合窗口（在WAL载入数据之前）
+	if err := bufferManager.EnableAggregation(cfg.Buffer.Aggregation, cfg.Collection.Enabled); err != nil {
+		log.WithError(err).Fatal("聚合窗口配置错误")
+	}
+
+	// 启用死信队列：重试耗尽的批次保存到本地，可用telemetry-dlq或管理API重新写库（需在WAL载入积压数据之前）
+	if cfg.DeadLetter.Enabled {
+		if err := bufferManager.EnableDeadLetter(cfg.DeadLetter); err != nil {
+			log.WithError(err).Fatal("启用死信队列失败")
+		}
+		log.Infof("死信队列已启用: %s", cfg.DeadLetter.Dir)
+	}
+
+	// 启用预写日志：数据先写磁盘再应答，写库确认后删除，启动时载入未确认的数据
+	if cfg.Recovery.EnableDataPersistence {
+		if err := bufferManager.EnableWAL(cfg.Recovery); err != nil {
+			log.WithError(err).Fatal("启用预写日志失败")
+		}
+		log.Infof("预写日志已启用: %s", cfg.Recovery.PersistencePath)
+	}
+
+	// 缓冲区内存上限与溢出策略（spill需要WAL，在启用WAL之后）
+	if err := bufferManager.EnableMemoryBudget(cfg.Memory); err != nil {
+		log.WithError(err).Fatal("缓冲区内存预算配置错误")
+	}
+
 	// 创建采集器
-	telemetryCollector := collector.NewSimpleCollector(log, bufferManager, cfg.Server)
+	telemetryCollector := collector.NewSimpleCollector(log, bufferManager, cfg.Server, cfg.Parser, cfg.Collection, cfg.Rates, cfg.Access, cfg.RateLimit, cfg.Devices, cfg.Cadence, cfg.Sequence, cfg.ClockSkew, cfg.Capture)
+	telemetryCollector.SetDeviceStore(db)
+	telemetryCollector.SetGapStore(db)
 
 	// 启动监控服务（如果启用）
 	if cfg.Monitoring.Enabled {
@@ -82,6 +131,9 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP 重新加载proto描述、sensor_path映射与TLS证书
+	go watchReloadSignal(log, telemetryCollector)
+
 	// 确定服务端口
 	serverPort := cfg.Server.Port
 	if *port != 0 {
@@ -119,6 +171,27 @@ func main() {
 	log.Info("程序已关闭")
 }
 
+// watchReloadSignal 收到SIGHUP时重新读取配置文件，重载解析器描述与TLS证书
+func watchReloadSignal(log *logrus.Logger, telemetryCollector *collector.SimpleCollector) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	for range hupChan {
+		log.Info("收到SIGHUP，重新加载proto描述...")
+		cfg, err := config.LoadConfig(*configFile)
+		if err != nil {
+			log.WithError(err).Error("重新读取配置文件失败")
+			continue
+		}
+		if err := telemetryCollector.ReloadParserConfig(cfg.Parser); err != nil {
+			log.WithError(err).Error("重新加载proto描述失败")
+		}
+		if err := telemetryCollector.ReloadTLS(cfg.Server.TLS); err != nil {
+			log.WithError(err).Error("重新加载TLS证书失败")
+		}
+	}
+}
+
 // applyPerformanceConfig 应用性能配置
 func applyPerformanceConfig(perfConfig config.PerformanceConfig) {
 	// 设置最大CPU核数
@@ -191,8 +264,8 @@ func printConfigSummary(log *logrus.Logger, cfg *config.Config) {
 	log.Infof("数据库: %s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.Database)
 	log.Infof("连接池: MaxOpen=%d, MaxIdle=%d, MaxLifetime=%v", 
 		cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns, cfg.Database.ConnMaxLifetime)
-	log.Infof("缓冲区: MaxSize=%d, FlushInterval=%v, BatchSize=%d", 
-		cfg.Buffer.MaxSize, cfg.Buffer.FlushInterval, cfg.Buffer.BatchSize)
+	log.Infof("缓冲区: FlushInterval=%v, BatchSize=%d, MaxSize=%d, MaxMemoryUsage=%s, OverflowPolicy=%s", 
+		cfg.Buffer.FlushInterval, cfg.Buffer.BatchSize, cfg.Buffer.MaxSize, cfg.Memory.MaxMemoryUsage, cfg.Buffer.OverflowPolicy)
 	log.Infof("写入器: ParallelWriters=%d, MaxBatchSize=%d, RetryAttempts=%d", 
 		cfg.DatabaseWriter.ParallelWriters, cfg.DatabaseWriter.MaxBatchSize, cfg.DatabaseWriter.RetryAttempts)
 	log.Infof("性能: MaxProcs=%d, GCPercent=%d", 
@@ -203,6 +276,20 @@ func printConfigSummary(log *logrus.Logger, cfg *config.Config) {
 // startMonitoringService 启动监控服务
 func startMonitoringService(monConfig config.MonitoringConfig, log *logrus.Logger, bufferManager *buffer.FixedBufferManager, db *database.Database, collector *collector.SimpleCollector) *monitoring.PrometheusServer {
 	log.Infof("启动监控服务，健康检查端口: %d", monConfig.HealthCheckPort)
+
+	// 启动管理API（连接查询与强制断开）
+	if monConfig.HealthCheckPort > 0 {
+		addr := net.JoinHostPort(monConfig.AdminBindAddress, strconv.Itoa(monConfig.HealthCheckPort))
+		var deadLetters monitoring.DeadLetterQueue
+		if bufferManager.GetDeadLetterStats().Enabled {
+			deadLetters = bufferManager
+		}
+		adminServer := monitoring.NewAdminServer(addr, monConfig.AdminToken, collector, deadLetters, log)
+		adminServer.Start()
+		if monConfig.AdminToken == "" && monConfig.AdminBindAddress != "127.0.0.1" && monConfig.AdminBindAddress != "localhost" {
+			log.Warnf("管理API监听 %s 且未配置admin_token，任何可访问该地址的人都能断开设备连接", addr)
+		}
+	}
 	
 	// 启动Prometheus指标服务器（如果启用）
 	var prometheusServer *monitoring.PrometheusServer
@@ -229,12 +316,16 @@ func startMonitoringService(monConfig config.MonitoringConfig, log *logrus.Logge
 				dbStats := db.GetStats()
 				connStats := collector.GetConnectionStats()
 				
-				log.Infof("监控指标 - 缓冲区: Platform=%d, Interface=%d, Subinterface=%d, Alarm=%d, Notification=%d, 已处理=%d, 错误=%d", 
+				log.Infof("监控指标 - 缓冲区: Platform=%d, Interface=%d, Subinterface=%d, Alarm=%d, Notification=%d, SelfDefinedEvent=%d, Generic=%d, MappedRow=%d, OpticalChannel=%d, 已处理=%d, 错误=%d", 
 					bufferStats.PlatformBufferSize, 
 					bufferStats.InterfaceBufferSize, 
 					bufferStats.SubinterfaceBufferSize,
 					bufferStats.AlarmReportBufferSize,
 					bufferStats.NotificationReportBufferSize,
+					bufferStats.SelfDefinedEventBufferSize,
+					bufferStats.GenericBufferSize,
+					bufferStats.MappedRowBufferSize,
+					bufferStats.OpticalChannelBufferSize,
 					bufferStats.TotalRecordsProcessed,
 					bufferStats.TotalErrors)
 				
@@ -262,7 +353,7 @@ func startMonitoringService(monConfig config.MonitoringConfig, log *logrus.Logge
 // checkAlertThresholds 检查告警阈值
 func checkAlertThresholds(log *logrus.Logger, thresholds config.AlertThresholdsConfig, bufferStats buffer.FixedBufferStats, dbStats sql.DBStats, connStats map[string]interface{}) {
 	// 检查缓冲区使用率
-	totalBufferSize := bufferStats.PlatformBufferSize + bufferStats.InterfaceBufferSize + bufferStats.SubinterfaceBufferSize + bufferStats.AlarmReportBufferSize + bufferStats.NotificationReportBufferSize
+	totalBufferSize := bufferStats.PlatformBufferSize + bufferStats.InterfaceBufferSize + bufferStats.SubinterfaceBufferSize + bufferStats.AlarmReportBufferSize + bufferStats.NotificationReportBufferSize + bufferStats.SelfDefinedEventBufferSize + bufferStats.GenericBufferSize + bufferStats.MappedRowBufferSize + bufferStats.OpticalChannelBufferSize
 	if totalBufferSize > 0 {
 		// 这里需要知道最大缓冲区大小来计算百分比
 		// 暂时跳过具体实现
@@ -297,6 +388,10 @@ func updatePrometheusMetrics(prometheusServer *monitoring.PrometheusServer, buff
 	prometheusServer.UpdateBufferSize("subinterface", float64(bufferStats.SubinterfaceBufferSize))
 	prometheusServer.UpdateBufferSize("alarm_report", float64(bufferStats.AlarmReportBufferSize))
 	prometheusServer.UpdateBufferSize("notification_report", float64(bufferStats.NotificationReportBufferSize))
+	prometheusServer.UpdateBufferSize("self_defined_event", float64(bufferStats.SelfDefinedEventBufferSize))
+	prometheusServer.UpdateBufferSize("generic", float64(bufferStats.GenericBufferSize))
+	prometheusServer.UpdateBufferSize("mapped_row", float64(bufferStats.MappedRowBufferSize))
+	prometheusServer.UpdateBufferSize("optical_channel", float64(bufferStats.OpticalChannelBufferSize))
 	
 	// 更新数据库连接池指标
 	prometheusServer.UpdateDBPoolConnections("open", float64(dbStats.OpenConnections))
@@ -324,6 +419,158 @@ func updatePrometheusMetrics(prometheusServer *monitoring.PrometheusServer, buff
 	prevProcessedRecords = bufferStats.TotalRecordsProcessed
 	prevErrorRecords = bufferStats.TotalErrors
 	
+	// 更新采样轮次统计 — 同样只添加增量
+	collectionStats := collector.GetCollectionStats()
+	if delta := collectionStats.RoundsCompleted - prevRoundsCompleted; delta > 0 {
+		prometheusServer.UpdateCollectionRounds("complete", float64(delta))
+	}
+	if delta := collectionStats.RoundsIncomplete - prevRoundsIncomplete; delta > 0 {
+		prometheusServer.UpdateCollectionRounds("incomplete", float64(delta))
+	}
+	if delta := collectionStats.PacketsTracked - prevCollectionPackets; delta > 0 {
+		prometheusServer.UpdateCollectionPackets(float64(delta))
+	}
+	prometheusServer.UpdateActiveCollectionRounds(float64(collectionStats.ActiveRounds))
+	prevRoundsCompleted = collectionStats.RoundsCompleted
+	prevRoundsIncomplete = collectionStats.RoundsIncomplete
+	prevCollectionPackets = collectionStats.PacketsTracked
+
+	// 更新计数器速率计算统计
+	rateStats := collector.GetRateStats()
+	for event, delta := range map[string]int64{
+		"computed": rateStats.RatesComputed - prevRateStats.RatesComputed,
+		"wrap":     rateStats.Wraps - prevRateStats.Wraps,
+		"reset":    rateStats.Resets - prevRateStats.Resets,
+		"restart":  rateStats.Restarts - prevRateStats.Restarts,
+	} {
+		if delta > 0 {
+			prometheusServer.UpdateCounterRateEvents(event, float64(delta))
+		}
+	}
+	prevRateStats = rateStats
+
+	// 更新访问控制拒绝统计
+	for reason, count := range collector.GetAccessRejections() {
+		if delta := count - prevAccessRejections[reason]; delta > 0 {
+			prometheusServer.UpdateAccessRejections(reason, float64(delta))
+		}
+		prevAccessRejections[reason] = count
+	}
+
+	// 更新报文限速统计
+	for key, count := range collector.GetRateLimitCounts() {
+		if delta := count - prevRateLimitCounts[key]; delta > 0 {
+			prometheusServer.UpdateRateLimited(key.Scope, string(key.Action), float64(delta))
+		}
+		prevRateLimitCounts[key] = count
+	}
+
+	// 更新Publish错误响应与流控统计
+	publishStats := collector.GetPublishStats()
+	for reason, count := range publishStats.Errors {
+		if delta := count - prevPublishStats.Errors[reason]; delta > 0 {
+			prometheusServer.UpdatePublishErrors(reason, float64(delta))
+		}
+	}
+	if delta := publishStats.Delayed - prevPublishStats.Delayed; delta > 0 {
+		prometheusServer.UpdateFlowControl("delayed", float64(delta))
+	}
+	if delta := publishStats.Withheld - prevPublishStats.Withheld; delta > 0 {
+		prometheusServer.UpdateFlowControl("withheld", float64(delta))
+	}
+	prevPublishStats = publishStats
+
+	// 更新设备登记统计
+	deviceStats := collector.GetDeviceStats()
+	prometheusServer.UpdateDevices(float64(deviceStats.Devices), float64(deviceStats.Reporting))
+	if delta := deviceStats.DuplicatesDetected - prevDuplicateStreams; delta > 0 {
+		prometheusServer.UpdateDuplicateStreams(float64(delta))
+	}
+	prevDuplicateStreams = deviceStats.DuplicatesDetected
+
+	// 更新上报周期监测统计
+	cadenceStats := collector.GetCadenceStats()
+	prometheusServer.UpdateLateSeries(float64(cadenceStats.Late))
+	for sensorPath, count := range cadenceStats.GapsOpened {
+		if delta := count - prevGapsOpened[sensorPath]; delta > 0 {
+			prometheusServer.UpdateDataGaps(sensorPath, float64(delta))
+		}
+		prevGapsOpened[sensorPath] = count
+	}
+
+	// 更新ReqId异常统计，只有出现过异常的设备才产生序列
+	for systemID, counts := range collector.GetSequenceCounts() {
+		prev := prevSequenceCounts[systemID]
+		for event, delta := range map[string]int64{
+			"lost":            counts.Lost - prev.Lost,
+			"duplicate":       counts.Duplicates - prev.Duplicates,
+			"exact_duplicate": counts.Exact - prev.Exact,
+			"dropped":         counts.Dropped - prev.Dropped,
+			"reordered":       counts.Reordered - prev.Reordered,
+			"reset":           counts.Resets - prev.Resets,
+			"retry":           counts.Retries - prev.Retries,
+		} {
+			if delta > 0 {
+				prometheusServer.UpdateReqIDEvents(systemID, event, float64(delta))
+			}
+		}
+		prevSequenceCounts[systemID] = counts
+	}
+
+	// 更新设备时钟偏差
+	skewStats := collector.GetClockSkewStats()
+	skews := make(map[string]float64, len(skewStats.Skew))
+	for systemID, skew := range skewStats.Skew {
+		skews[systemID] = skew.Seconds()
+	}
+	prometheusServer.UpdateClockSkew(skews)
+	if delta := skewStats.Replaced - prevClockSkewStats.Replaced; delta > 0 {
+		prometheusServer.UpdateClockSkewActions("replaced", float64(delta))
+	}
+	if delta := skewStats.Rejected - prevClockSkewStats.Rejected; delta > 0 {
+		prometheusServer.UpdateClockSkewActions("rejected", float64(delta))
+	}
+	prevClockSkewStats = skewStats
+
+	// 更新预写日志统计
+	if walStats := bufferStats.WAL; walStats.Enabled {
+		prometheusServer.UpdateWAL(float64(walStats.Bytes), float64(walStats.PendingSegments), walStats.Spilling)
+		failed := walStats.FailedCheckpoints - prevWALStats.FailedCheckpoints
+		if delta := walStats.Checkpoints - prevWALStats.Checkpoints - failed; delta > 0 {
+			prometheusServer.UpdateWALCheckpoints("ok", float64(delta))
+		}
+		if failed > 0 {
+			prometheusServer.UpdateWALCheckpoints("failed", float64(failed))
+		}
+		prevWALStats = walStats
+	}
+
+	// 更新死信队列统计：丢弃的行数在未启用死信队列时同样导出
+	dlStats := bufferStats.DeadLetter
+	if dlStats.Enabled {
+		prometheusServer.UpdateDeadLetters(dlStats.Tables)
+	}
+	for event, delta := range map[string]int64{
+		"stored":   dlStats.StoredRows - prevDeadLetterStats.StoredRows,
+		"dropped":  dlStats.DroppedRows - prevDeadLetterStats.DroppedRows,
+		"redriven": dlStats.RedrivenRows - prevDeadLetterStats.RedrivenRows,
+	} {
+		if delta > 0 {
+			prometheusServer.UpdateDeadLetterRows(event, float64(delta))
+		}
+	}
+	prevDeadLetterStats = dlStats
+
+	// 更新缓冲区内存预算统计
+	memStats := bufferStats.Memory
+	prometheusServer.UpdateBufferMemory(memStats.Bytes, float64(memStats.Limit))
+	for key, count := range memStats.Overflow {
+		if delta := count - prevOverflowCounts[key]; delta > 0 {
+			prometheusServer.UpdateBufferOverflow(key.Buffer, key.Action, float64(delta))
+		}
+		prevOverflowCounts[key] = count
+	}
+	
 	// 更新gRPC连接指标（更健壮的类型兼容）
 	var totalConn, activeConn, staleConn int
 	if v, ok := connStats["total_connections"]; ok {
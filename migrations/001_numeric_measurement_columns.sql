@@ -0,0 +1,155 @@
+-- 001 速率、电压/电流/功率改为数值列
+--
+-- 单位约定：
+--   *_rate_mbps  流量速率，Mbps
+--   *_rate_kfps  报文速率，千包/秒
+--   *_v / *_a / *_w  电压(V) / 电流(A) / 功率(W)
+--   *_utilization  利用率百分比（0-100，NUMERIC(5,2)，未变）
+--
+-- 旧的TEXT列保留不删，配置 database.legacy_string_columns: true（默认）时继续写入，
+-- 下游查询迁移完成后可关闭该开关，再视情况删除旧列。
+-- 回填取旧列开头的数值部分（"12.50 Mbps" -> 12.5），无法解析的行保持NULL。
+--
+-- 执行方式：
+--   1. 新增列（只改表结构，不重写数据），单独一个事务
+--   2. 回填按chunk、每个chunk内按 batch 时间段（默认1天）执行，每张表每段一条UPDATE同时设置所有新列，
+--      每段单独提交：中途中断后重新执行只处理剩余的行，也不会长时间持有锁
+--   时间段按hypertable的时间维度列（time或timestamp）划分。
+--   回填使用带COMMIT的存储过程，需以自动提交方式执行（psql -f，不要加 -1/--single-transaction）。
+--
+-- 压缩的chunk：TimescaleDB不支持直接UPDATE压缩chunk，回填跳过这些chunk并以NOTICE列出，
+-- 其中的新列保持NULL（查询时可回退到旧的字符串列）。需要回填时先解压再重新执行本脚本，之后重新压缩：
+--   SELECT decompress_chunk(c) FROM show_chunks('telemetry.interface_metrics', older_than => ...) c;
+--   psql -f migrations/001_numeric_measurement_columns.sql
+--   SELECT compress_chunk(c) FROM show_chunks('telemetry.interface_metrics', older_than => ...) c;
+-- 解压需要相应的磁盘空间，建议按时间段分批进行。
+--
+-- 脚本可重复执行：新增列使用 IF NOT EXISTS，回填只处理数值列为空且旧列可解析的行。
+
+SET search_path TO telemetry;
+
+BEGIN;
+
+ALTER TABLE interface_metrics
+    ADD COLUMN IF NOT EXISTS in_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_packet_rate_kfps DOUBLE PRECISION;
+
+ALTER TABLE subinterface_metrics
+    ADD COLUMN IF NOT EXISTS in_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_packet_rate_kfps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_traffic_rate_mbps DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_packet_rate_kfps DOUBLE PRECISION;
+
+ALTER TABLE platform_metrics
+    ADD COLUMN IF NOT EXISTS current_voltage_v DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS current_current_a DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS total_capacity_w DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS used_capacity_w DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS total_input_power_w DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS fan_current_power_w DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS fan_current_voltage_v DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS fan_current_current_a DOUBLE PRECISION;
+
+COMMIT;
+
+-- 旧列开头的数值部分，无法解析时为NULL
+CREATE OR REPLACE FUNCTION leading_number(s TEXT) RETURNS DOUBLE PRECISION
+    LANGUAGE sql IMMUTABLE AS
+$$ SELECT substring(s FROM '^\s*(-?[0-9]+\.?[0-9]*)')::double precision $$;
+
+-- 按chunk与时间段回填：new_cols[i] 取自 old_cols[i]
+CREATE OR REPLACE PROCEDURE backfill_numeric_columns(tbl TEXT, new_cols TEXT[], old_cols TEXT[],
+                                                     batch INTERVAL DEFAULT INTERVAL '1 day')
+    LANGUAGE plpgsql AS
+$$
+DECLARE
+    set_list   TEXT;
+    pending    TEXT;
+    time_col   TEXT;
+    chunk      RECORD;
+    batch_from TIMESTAMPTZ;
+    updated    BIGINT;
+    total      BIGINT := 0;
+BEGIN
+    SELECT string_agg(format('%1$I = COALESCE(%1$I, leading_number(%2$I))', n, o), ', '),
+           string_agg(format('(%1$I IS NULL AND %2$I ~ ''^\s*-?[0-9]'')', n, o), ' OR ')
+    INTO set_list, pending
+    FROM unnest(new_cols, old_cols) AS c(n, o);
+
+    -- 时间列以hypertable的时间维度为准（create_tables.sql为time，按采集器COPY列建的库为timestamp）
+    SELECT column_name INTO time_col
+    FROM timescaledb_information.dimensions
+    WHERE hypertable_schema = 'telemetry' AND hypertable_name = tbl AND dimension_number = 1;
+    IF time_col IS NULL THEN
+        RAISE EXCEPTION '%: 不是hypertable，找不到时间维度', tbl;
+    END IF;
+
+    FOR chunk IN
+        SELECT range_start, range_end, is_compressed, chunk_name
+        FROM timescaledb_information.chunks
+        WHERE hypertable_schema = 'telemetry' AND hypertable_name = tbl
+        ORDER BY range_start
+    LOOP
+        IF chunk.is_compressed THEN
+            RAISE NOTICE '%: 跳过压缩的chunk % (% ~ %)', tbl, chunk.chunk_name, chunk.range_start, chunk.range_end;
+            CONTINUE;
+        END IF;
+
+        batch_from := chunk.range_start;
+        WHILE batch_from < chunk.range_end LOOP
+            EXECUTE format('UPDATE telemetry.%I SET %s WHERE %I >= $1 AND %I < $2 AND (%s)',
+                           tbl, set_list, time_col, time_col, pending)
+                USING batch_from, LEAST(batch_from + batch, chunk.range_end);
+            GET DIAGNOSTICS updated = ROW_COUNT;
+            total := total + updated;
+            COMMIT;
+            batch_from := batch_from + batch;
+        END LOOP;
+    END LOOP;
+    RAISE NOTICE '%: 回填 % 行', tbl, total;
+END
+$$;
+
+CALL backfill_numeric_columns('interface_metrics',
+    ARRAY['in_traffic_rate_mbps', 'in_packet_rate_kfps', 'out_traffic_rate_mbps',
+          'out_packet_rate_kfps', 'in_v4_traffic_rate_mbps', 'in_v4_packet_rate_kfps',
+          'out_v4_traffic_rate_mbps', 'out_v4_packet_rate_kfps', 'in_v6_traffic_rate_mbps',
+          'in_v6_packet_rate_kfps', 'out_v6_traffic_rate_mbps', 'out_v6_packet_rate_kfps'],
+    ARRAY['in_traffic_rate', 'in_packet_rate', 'out_traffic_rate', 'out_packet_rate',
+          'in_v4_traffic_rate', 'in_v4_packet_rate', 'out_v4_traffic_rate', 'out_v4_packet_rate',
+          'in_v6_traffic_rate', 'in_v6_packet_rate', 'out_v6_traffic_rate', 'out_v6_packet_rate']);
+CALL backfill_numeric_columns('subinterface_metrics',
+    ARRAY['in_traffic_rate_mbps', 'in_packet_rate_kfps', 'out_traffic_rate_mbps',
+          'out_packet_rate_kfps', 'in_v4_traffic_rate_mbps', 'in_v4_packet_rate_kfps',
+          'out_v4_traffic_rate_mbps', 'out_v4_packet_rate_kfps', 'in_v6_traffic_rate_mbps',
+          'in_v6_packet_rate_kfps', 'out_v6_traffic_rate_mbps', 'out_v6_packet_rate_kfps'],
+    ARRAY['in_traffic_rate', 'in_packet_rate', 'out_traffic_rate', 'out_packet_rate',
+          'in_v4_traffic_rate', 'in_v4_packet_rate', 'out_v4_traffic_rate', 'out_v4_packet_rate',
+          'in_v6_traffic_rate', 'in_v6_packet_rate', 'out_v6_traffic_rate', 'out_v6_packet_rate']);
+CALL backfill_numeric_columns('platform_metrics',
+    ARRAY['current_voltage_v', 'current_current_a', 'total_capacity_w', 'used_capacity_w',
+          'total_input_power_w', 'fan_current_power_w', 'fan_current_voltage_v',
+          'fan_current_current_a'],
+    ARRAY['current_voltage', 'current_current', 'total_capacity', 'used_capacity',
+          'total_input_power', 'fan_current_power', 'fan_current_voltage', 'fan_current_current']);
+
+DROP PROCEDURE backfill_numeric_columns(TEXT, TEXT[], TEXT[], INTERVAL);
+DROP FUNCTION leading_number(TEXT);
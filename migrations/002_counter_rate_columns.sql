@@ -0,0 +1,66 @@
+-- 002 服务端计算的计数器速率列
+--
+-- 单位：*_octets_rate 为字节/秒，*_pkts_rate 及错误/丢弃类为包/秒。
+-- 由采集器根据相邻两次计数器采样计算（rates.enabled），历史数据不回填。
+
+SET search_path TO telemetry;
+
+ALTER TABLE interface_metrics
+    ADD COLUMN IF NOT EXISTS in_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_unicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_broadcast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_multicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_discards_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_errors_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_unknown_protos_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_fcs_errors_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_unicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_broadcast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_multicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_discards_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_errors_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_bier_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_bier_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_bier_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_bier_pkts_rate DOUBLE PRECISION;
+
+ALTER TABLE subinterface_metrics
+    ADD COLUMN IF NOT EXISTS in_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_unicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_broadcast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_multicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_discards_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_errors_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_unknown_protos_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_fcs_errors_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_unicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_broadcast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_multicast_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_discards_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_errors_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v4_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v4_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_v6_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_v6_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_bier_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS in_bier_pkts_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_bier_octets_rate DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS out_bier_pkts_rate DOUBLE PRECISION;
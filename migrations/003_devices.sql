@@ -0,0 +1,21 @@
+-- 003 设备登记表
+--
+-- 采集器从每条流的报文中学习 system_id、管理地址、订阅与sensor_path（devices.enabled），
+-- 有变化的设备按 devices.persist_interval 写入。
+-- streams 为当前流列表，sensor_paths 为 sensor_path -> {subscription_id, current_period_ms, last_seen}。
+
+SET search_path TO telemetry;
+
+CREATE TABLE IF NOT EXISTS devices (
+    system_id TEXT PRIMARY KEY,
+    mng_ipv4 TEXT,
+    mng_ipv6 TEXT,
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    streams JSONB NOT NULL DEFAULT '[]',
+    sensor_paths JSONB NOT NULL DEFAULT '{}',
+    duplicate_streams INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+GRANT ALL ON devices TO telemetry_app;
@@ -0,0 +1,22 @@
+-- 004 数据缺口表
+--
+-- 采集器按 (system_id, sensor_path) 学习设备上报的 current_period（cadence.enabled），
+-- 超过 late_multiple 倍周期无数据时写入一行，数据恢复后填写 gap_end。
+
+SET search_path TO telemetry;
+
+CREATE TABLE IF NOT EXISTS telemetry_gaps (
+    id BIGSERIAL PRIMARY KEY,
+    system_id TEXT NOT NULL,
+    sensor_path TEXT NOT NULL,
+    expected_period_ms BIGINT NOT NULL,
+    gap_start TIMESTAMPTZ NOT NULL,
+    gap_end TIMESTAMPTZ,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (system_id, sensor_path, gap_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_telemetry_gaps_open ON telemetry_gaps (system_id, sensor_path) WHERE gap_end IS NULL;
+
+GRANT ALL ON telemetry_gaps TO telemetry_app;
+GRANT ALL ON SEQUENCE telemetry_gaps_id_seq TO telemetry_app;
@@ -0,0 +1,25 @@
+-- 005 自定义事件表
+--
+-- 设备侧阈值触发的 zte-telemetry:SelfDefinedEvent 连同过滤条件写入本表，
+-- 触发采样同时写入对应指标表。filters 为过滤条件数组（字段、比较方式、阈值、触发值）。
+
+SET search_path TO telemetry;
+
+CREATE TABLE IF NOT EXISTS self_defined_event (
+    timestamp TIMESTAMPTZ NOT NULL,
+    system_id TEXT NOT NULL,
+    level INTEGER NOT NULL,
+    description TEXT,
+    filter_relation TEXT,
+    filters JSONB,
+    sensor_path TEXT NOT NULL,
+    proto_path TEXT,
+    instance TEXT,
+    content_encoding TEXT
+);
+
+SELECT create_hypertable('self_defined_event', 'timestamp', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_self_defined_event_system_level ON self_defined_event (system_id, level, timestamp DESC);
+
+GRANT ALL ON self_defined_event TO telemetry_app;
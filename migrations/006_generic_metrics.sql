@@ -0,0 +1,24 @@
+-- 006 通用指标表
+--
+-- 无类型解析器的sensor_path按 proto_path 动态解码（parser.generic_dispatch），每个叶子一行写入本表；
+-- GPB-KV/JSON中未映射到指标模型的叶子同样写入。instance_keys 为列表元素中的 name/index/id 字段。
+-- parser.sensor_mappings 中配置的映射表需与本表结构相同（CREATE TABLE ... (LIKE telemetry.generic_metrics INCLUDING ALL)）。
+
+SET search_path TO telemetry;
+
+CREATE TABLE IF NOT EXISTS generic_metrics (
+    timestamp TIMESTAMPTZ NOT NULL,
+    system_id TEXT NOT NULL,
+    sensor_path TEXT NOT NULL,
+    proto_path TEXT,
+    instance_keys JSONB,
+    field TEXT NOT NULL,
+    value_num DOUBLE PRECISION,
+    value_str TEXT
+);
+
+SELECT create_hypertable('generic_metrics', 'timestamp', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_generic_metrics_system_sensor ON generic_metrics (system_id, sensor_path, field, timestamp DESC);
+
+GRANT ALL ON generic_metrics TO telemetry_app;
@@ -0,0 +1,34 @@
+-- 007 光通道指标表
+--
+-- 组件的每个光通道一行（opticalchan_info / physical-channels 路径），字段与平台指标的光模块字段相同。
+
+SET search_path TO telemetry;
+
+CREATE TABLE IF NOT EXISTS optical_channel_metrics (
+    timestamp TIMESTAMPTZ NOT NULL,
+    system_id TEXT NOT NULL,
+    component_name TEXT NOT NULL,
+    channel_index BIGINT NOT NULL,
+    optical_in_power DOUBLE PRECISION,
+    optical_out_power DOUBLE PRECISION,
+    optical_bias_current DOUBLE PRECISION,
+    optical_temperature DOUBLE PRECISION,
+    optical_voltage_vol33 DOUBLE PRECISION,
+    optical_voltage_vol5 DOUBLE PRECISION,
+    optical_alarm_los_status TEXT,
+    optical_alarm_los_info_event_id BIGINT,
+    optical_alarm_los_info_event_interval BIGINT,
+    optical_alarm_los_info_in_power DOUBLE PRECISION,
+    optical_alarm_los_info_out_power DOUBLE PRECISION,
+    optical_online_status TEXT,
+    optical_rx_threshold_high_alarm DOUBLE PRECISION,
+    optical_rx_threshold_pre_high_alarm DOUBLE PRECISION,
+    optical_rx_threshold_low_alarm DOUBLE PRECISION,
+    optical_rx_threshold_pre_low_alarm DOUBLE PRECISION
+);
+
+SELECT create_hypertable('optical_channel_metrics', 'timestamp', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_optical_channel_metrics_system_component ON optical_channel_metrics (system_id, component_name, channel_index, timestamp DESC);
+
+GRANT ALL ON optical_channel_metrics TO telemetry_app;